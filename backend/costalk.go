@@ -18,7 +18,7 @@ func main() {
 	flag.Parse()
 
 	var c config.Config
-	conf.MustLoad(*configFile, &c)
+	conf.MustLoad(*configFile, &c, conf.UseEnv()) // 支持 ${ENV} 展开
 
 	server := rest.MustNewServer(c.RestConf)
 	defer server.Stop()
@@ -5,6 +5,7 @@ go 1.23.4
 require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/zeromicro/go-zero v1.9.0
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
@@ -49,5 +50,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240701130421-f6361c86f094 // indirect
 	google.golang.org/grpc v1.65.0 // indirect
 	google.golang.org/protobuf v1.36.5 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -1,36 +1,113 @@
 package config
 
-import "github.com/zeromicro/go-zero/rest"
+import (
+	"time"
+
+	"github.com/zeromicro/go-zero/rest"
+)
 
 type Config struct {
 	rest.RestConf
-	
-	// Provider 配置
-	Providers ProviderConfig `json:"providers,omitempty"`
+
+	// Provider 列表，按顺序创建（故障转移链需排在其成员之后）
+	Providers []ProviderConfig `json:"providers,optional"`
+
+	// 会话未指定时使用的 Provider 与模型
+	Defaults DefaultsConfig `json:"defaults,optional"`
+
+	// 会话存储配置
+	Conversation ConversationConfig `json:"conversation,optional"`
+
+	// 角色目录配置
+	Roles RoleConfig `json:"roles,optional"`
+
+	// 内容审核配置
+	Moderation ModerationConfig `json:"moderation,optional"`
+
+	// Provider 健康检查配置
+	Health HealthConfig `json:"health,optional"`
+
+	// 用量计费配置
+	Usage UsageConfig `json:"usage,optional"`
+
+	// 管理接口鉴权配置
+	Admin AdminConfig `json:"admin,optional"`
 }
 
-type ProviderConfig struct {
-	// LLM Provider 配置
-	Qwen QwenConfig `json:"qwen,omitempty"`
-	
-	// ASR/TTS Provider 配置
-	Iflytek IflytekConfig `json:"iflytek,omitempty"`
-	Qiniu   QiniuConfig   `json:"qiniu,omitempty"`
+type AdminConfig struct {
+	// 管理 API Key，调用角色增删改等管理接口时通过 X-API-Key 请求头传入；为空时管理接口一律拒绝
+	APIKey string `json:"apiKey,optional"`
+}
+
+type UsageConfig struct {
+	Currency string        `json:"currency,default=CNY"`
+	Prices   []PriceConfig `json:"prices,optional"`
+	// API Key 对应的租户，连接时通过 X-API-Key 请求头或 apiKey 查询参数传入，未匹配的连接计入 anonymous
+	Tenants []TenantConfig `json:"tenants,optional"`
 }
 
-type QwenConfig struct {
-	APIKey  string `json:"apiKey,omitempty"`
-	BaseURL string `json:"baseUrl,omitempty"`
+// PriceConfig 计价规则：Provider 为空时按模型匹配任意 Provider（适用于故障转移链），Model 为空时匹配所有模型，
+// 两者都为空时作为该类型的默认价格
+type PriceConfig struct {
+	Kind            string  `json:"kind,options=llm|asr|tts"`
+	Provider        string  `json:"provider,optional"`
+	Model           string  `json:"model,optional"`
+	PromptPer1K     float64 `json:"promptPer1K,optional"`     // LLM 每千输入 token
+	CompletionPer1K float64 `json:"completionPer1K,optional"` // LLM 每千输出 token
+	PerChar         float64 `json:"perChar,optional"`         // TTS 每字符
+	PerSecond       float64 `json:"perSecond,optional"`       // ASR 每秒音频
 }
 
-type IflytekConfig struct {
-	AppID     string `json:"appId,omitempty"`
-	APISecret string `json:"apiSecret,omitempty"`
-	APIKey    string `json:"apiKey,omitempty"`
+type TenantConfig struct {
+	Name   string `json:"name"`
+	APIKey string `json:"apiKey"`
+}
+
+type HealthConfig struct {
+	Interval      time.Duration `json:"interval,default=30s"`     // 检查间隔，0 表示不做主动检查
+	Timeout       time.Duration `json:"timeout,default=5s"`       // 单次检查超时
+	OfflineAfter  int           `json:"offlineAfter,default=3"`   // 连续失败达到该次数判定离线
+	SlowThreshold time.Duration `json:"slowThreshold,default=2s"` // P95 延迟超过该值判定降级
+	Window        int           `json:"window,default=20"`        // 延迟统计的样本数
+}
+
+type ModerationConfig struct {
+	PreCheck  bool `json:"preCheck,default=true"`  // 审核用户输入（文本与 ASR 结果）
+	PostCheck bool `json:"postCheck,default=true"` // 审核 LLM 输出
+	// 输入被拦截时回复给用户的文本
+	RefusalText string `json:"refusalText,default=抱歉，这个话题我不能继续讨论，我们聊点别的吧。"`
+}
+
+type RoleConfig struct {
+	Dir string `json:"dir,default=etc/roles"` // 角色定义（YAML/JSON）所在目录
+	// 是否允许客户端在未指定 roleId 时通过 role 字段自定义系统提示，默认关闭
+	AllowCustomPrompt bool `json:"allowCustomPrompt,default=false"`
+	// 角色目录轮询间隔，文件变化后自动重新加载，0 表示不监听
+	ReloadInterval time.Duration `json:"reloadInterval,default=5s"`
+}
+
+type ConversationConfig struct {
+	Store string `json:"store,default=memory,options=memory|file"` // memory|file
+	Path  string `json:"path,default=data/conversations.jsonl"`    // file 模式下的存储文件
+
+	// 记忆配置：保留最近 WindowSize 条消息原文，未摘要历史超过 TokenBudget 时折叠为摘要
+	WindowSize  int `json:"windowSize,default=12"`
+	TokenBudget int `json:"tokenBudget,default=2000"`
+}
+
+// ProviderConfig 一个 Provider 的声明：Type 为实现类型（见 pkg/provider 中的 RegisterFactory），
+// Settings 由对应实现解析
+type ProviderConfig struct {
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Kind     string                 `json:"kind,options=llm|asr|tts|moderation"`
+	Settings map[string]interface{} `json:"settings,optional"`
 }
 
-type QiniuConfig struct {
-	AccessKey string `json:"accessKey,omitempty"` // 七牛云存储访问密钥
-	SecretKey string `json:"secretKey,omitempty"` // 七牛云存储私钥
-	APIKey    string `json:"apiKey,omitempty"`    // 七牛云 AI Token API 密钥
+type DefaultsConfig struct {
+	LLM   string `json:"llm,default=qiniu"`
+	ASR   string `json:"asr,default=iflytek"`
+	TTS   string `json:"tts,default=qiniu"`
+	Model string `json:"model,optional"` // 为空时使用 LLM Provider 的默认模型
+	Voice string `json:"voice,optional"` // TTS Provider 未声明默认音色时使用
 }
@@ -26,8 +26,14 @@ func ChatStreamHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 		}
 		defer conn.Close()
 
+		// 浏览器无法为 WebSocket 设置请求头，API Key 也可通过查询参数传入
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey == "" {
+			apiKey = r.URL.Query().Get("apiKey")
+		}
+
 		// 创建 ChatStream logic 并处理 WebSocket 连接
 		l := chat.NewChatStreamLogic(r.Context(), svcCtx)
-		l.HandleWebSocket(conn)
+		l.HandleWebSocket(conn, apiKey)
 	}
 }
@@ -10,6 +10,7 @@ import (
 	health "github.com/unclewu3242592726/CosTalk/backend/internal/handler/health"
 	role "github.com/unclewu3242592726/CosTalk/backend/internal/handler/role"
 	service "github.com/unclewu3242592726/CosTalk/backend/internal/handler/service"
+	usage "github.com/unclewu3242592726/CosTalk/backend/internal/handler/usage"
 	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
 
 	"github.com/zeromicro/go-zero/rest"
@@ -46,6 +47,29 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 		},
 	)
 
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.AdminAuth},
+			[]rest.Route{
+				{
+					Method:  http.MethodPost,
+					Path:    "/v1/roles/:id",
+					Handler: role.CreateRoleHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodPut,
+					Path:    "/v1/roles/:id",
+					Handler: role.UpdateRoleHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodDelete,
+					Path:    "/v1/roles/:id",
+					Handler: role.DeleteRoleHandler(serverCtx),
+				},
+			}...,
+		),
+	)
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
@@ -65,4 +89,27 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 			},
 		},
 	)
+
+	server.AddRoutes(
+		rest.WithMiddlewares(
+			[]rest.Middleware{serverCtx.TenantAuth},
+			[]rest.Route{
+				{
+					Method:  http.MethodGet,
+					Path:    "/v1/usage",
+					Handler: usage.GetUsageHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/v1/usage/tenants/:tenant",
+					Handler: usage.GetTenantUsageHandler(serverCtx),
+				},
+				{
+					Method:  http.MethodGet,
+					Path:    "/v1/usage/conversations/:id",
+					Handler: usage.GetConversationUsageHandler(serverCtx),
+				},
+			}...,
+		),
+	)
 }
@@ -0,0 +1,28 @@
+package usage
+
+import (
+	"net/http"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/usage"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func GetConversationUsageHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.ConversationUsageRequest
+		if err := httpx.Parse(r, &req); err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+			return
+		}
+
+		l := usage.NewGetConversationUsageLogic(r.Context(), svcCtx)
+		resp, err := l.GetConversationUsage(&req)
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
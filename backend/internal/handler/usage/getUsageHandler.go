@@ -0,0 +1,21 @@
+package usage
+
+import (
+	"net/http"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/usage"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+func GetUsageHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := usage.NewGetUsageLogic(r.Context(), svcCtx)
+		resp, err := l.GetUsage()
+		if err != nil {
+			httpx.ErrorCtx(r.Context(), w, err)
+		} else {
+			httpx.OkJsonCtx(r.Context(), w, resp)
+		}
+	}
+}
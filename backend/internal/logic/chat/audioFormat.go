@@ -0,0 +1,129 @@
+package chat
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+var errAudioTooLong = errors.New("audio too long")
+
+// 客户端音频格式
+//
+// 会话参数 audio_format / sample_rate / bits / channels 描述裸 PCM，消息中的同名字段
+// （format / sample_rate / bits / channels）优先；WAV 以文件头为准，
+// MP3、Ogg、WebM 等压缩格式无法解码，直接拒绝。
+func inputFormat(config *ConfigMessage, fields AudioFormat) (audio.Format, error) {
+	format := audio.PCM16kMono
+
+	values := map[string]string{}
+	for key, value := range config.Params {
+		values[key] = value
+	}
+	declared := map[string]string{
+		"format":      fields.Format,
+		"sample_rate": fields.SampleRate.String(),
+		"bits":        fields.Bits.String(),
+		"channels":    fields.Channels.String(),
+	}
+	for key, value := range declared {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	if value, ok := values["format"]; ok {
+		values["audio_format"] = value
+	}
+
+	switch name := strings.ToLower(values["audio_format"]); name {
+	case "", "pcm", "raw", "wav", "audio/l16":
+	case audio.EncodingU8, audio.EncodingS16LE, audio.EncodingS24LE, audio.EncodingS32LE, audio.EncodingF32LE:
+		format.Encoding = name
+	default:
+		return format, fmt.Errorf("%w: %s audio cannot be decoded, send WAV or raw PCM instead", audio.ErrUnsupportedContainer, name)
+	}
+
+	ints := map[string]*int{
+		"sample_rate": &format.SampleRate,
+		"channels":    &format.Channels,
+	}
+	for key, dst := range ints {
+		if value, ok := values[key]; ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v <= 0 || v != float64(int(v)) {
+				return format, fmt.Errorf("invalid %s '%s', expected a positive integer", key, value)
+			}
+			*dst = int(v)
+		}
+	}
+
+	if value, ok := values["bits"]; ok {
+		bits, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return format, fmt.Errorf("invalid bits '%s', expected 8, 16, 24 or 32", value)
+		}
+		if format.Encoding, err = audio.EncodingForBits(int(bits)); err != nil {
+			return format, err
+		}
+	}
+
+	return format, format.Validate()
+}
+
+// 会话使用的 ASR Provider
+func (l *ChatStreamLogic) asrProvider(config *ConfigMessage) (provider.ASRProvider, string, error) {
+	name := config.ASRProvider
+	if name == "" {
+		name = l.defaultProvider(provider.TypeASR)
+	}
+
+	asrProvider, err := l.svcCtx.Registry.GetASR(name)
+	return asrProvider, name, err
+}
+
+// 会话 ASR Provider 接受的输入格式，Provider 不可用时按 16kHz 单声道处理，由识别阶段报错
+func (l *ChatStreamLogic) asrInputFormats(config *ConfigMessage) []audio.Format {
+	if asrProvider, _, err := l.asrProvider(config); err == nil {
+		if formats := asrProvider.InputFormats(); len(formats) > 0 {
+			return formats
+		}
+	}
+
+	return []audio.Format{audio.PCM16kMono}
+}
+
+// 会话 ASR Provider 是否支持流式识别（返回中间结果）
+func (l *ChatStreamLogic) asrStreaming(config *ConfigMessage) bool {
+	asrProvider, _, err := l.asrProvider(config)
+	return err == nil && provider.Describe(asrProvider).Streaming
+}
+
+// 校验音频时长是否超过 Provider 的单次输入上限
+func checkAudioDuration(pcm []byte, format audio.Format, caps provider.Capabilities) error {
+	if caps.MaxInputLength <= 0 {
+		return nil
+	}
+
+	seconds := audioSeconds(pcm, format)
+	if seconds > float64(caps.MaxInputLength) {
+		return fmt.Errorf("%w: %.1fs exceeds the provider limit of %ds", errAudioTooLong, seconds, caps.MaxInputLength)
+	}
+	return nil
+}
+
+// PCM 音频时长（秒）
+func audioSeconds(pcm []byte, format audio.Format) float64 {
+	return float64(len(pcm)) / float64(format.SampleRate*format.BytesPerSample()*format.Channels)
+}
+
+// 音频格式与时长错误属于客户端错误
+func audioErrorCode(err error) int {
+	if errors.Is(err, audio.ErrUnsupportedContainer) || errors.Is(err, audio.ErrUnsupportedFormat) || errors.Is(err, errAudioTooLong) {
+		return 400
+	}
+	return 500
+}
@@ -0,0 +1,199 @@
+package chat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 二进制音频帧：hello 协商 binary_audio 后，双向音频都以 WebSocket 二进制消息传输，
+// 不再以 base64 或数字数组嵌入 JSON。
+//
+//	偏移  长度  字段
+//	0     1     帧版本，当前为 1
+//	1     1     标志，bit0 为 final
+//	2     1     音频格式，见 AudioFrameFormat*
+//	3     1     保留，为 0
+//	4     8     轮次 ID（int64，大端），客户端发送时为 0
+//	12    4     序号（uint32，大端）
+//	16    -     音频数据
+//
+// 服务端下发的帧序号在轮次内从 1 开始连续递增（同 tts 帧的 sequence），一轮的音频以
+// 不含音频数据的 final 帧结束，被打断的轮次没有 final 帧。
+//
+// 客户端上传的帧序号在一段音频内连续递增，final 帧结束这段音频：
+// start_audio 之后的帧送入实时音频流，final 相当于 end_audio；
+// 没有进行中的音频流时，各帧拼接为一段完整录音，final 后开始识别（同 audio_file）。
+const (
+	AudioFrameVersion    = 1
+	AudioFrameHeaderSize = 16
+
+	AudioFrameFinal = 0x01
+)
+
+// 音频格式编码，0 表示未声明：上传的音频按会话参数解析，下发的音频格式未知
+const (
+	AudioFrameFormatNone = iota
+	AudioFrameFormatPCM
+	AudioFrameFormatWAV
+	AudioFrameFormatMP3
+	AudioFrameFormatOpus
+	AudioFrameFormatAAC
+)
+
+var audioFrameFormats = map[byte]string{
+	AudioFrameFormatPCM:  "pcm",
+	AudioFrameFormatWAV:  "wav",
+	AudioFrameFormatMP3:  "mp3",
+	AudioFrameFormatOpus: "opus",
+	AudioFrameFormatAAC:  "aac",
+}
+
+// 完整录音的最大长度，约为 16kHz 16bit 单声道 17 分钟
+const maxRecordingSize = 32 << 20
+
+// FeatureBinaryAudio 以二进制音频帧收发音频，两个协议版本都需要显式启用
+const FeatureBinaryAudio = "binary_audio"
+
+// AudioFrame 二进制音频帧
+type AudioFrame struct {
+	TurnID   int64
+	Sequence uint32
+	Format   string // pcm、mp3 等，未声明或无法编码的格式为空
+	Final    bool
+	Data     []byte
+}
+
+// MarshalBinary 编码为二进制消息
+func (f *AudioFrame) MarshalBinary() ([]byte, error) {
+	data := make([]byte, AudioFrameHeaderSize, AudioFrameHeaderSize+len(f.Data))
+	data[0] = AudioFrameVersion
+	if f.Final {
+		data[1] |= AudioFrameFinal
+	}
+	data[2] = audioFrameFormat(f.Format)
+	binary.BigEndian.PutUint64(data[4:12], uint64(f.TurnID))
+	binary.BigEndian.PutUint32(data[12:16], f.Sequence)
+	return append(data, f.Data...), nil
+}
+
+// UnmarshalBinary 解码二进制消息
+func (f *AudioFrame) UnmarshalBinary(data []byte) error {
+	if len(data) < AudioFrameHeaderSize {
+		return fmt.Errorf("audio frame too short: %d bytes, header is %d bytes", len(data), AudioFrameHeaderSize)
+	}
+	if data[0] != AudioFrameVersion {
+		return fmt.Errorf("unsupported audio frame version: %d", data[0])
+	}
+
+	format, ok := audioFrameFormats[data[2]]
+	if !ok && data[2] != AudioFrameFormatNone {
+		return fmt.Errorf("unknown audio frame format: %d", data[2])
+	}
+
+	*f = AudioFrame{
+		TurnID:   int64(binary.BigEndian.Uint64(data[4:12])),
+		Sequence: binary.BigEndian.Uint32(data[12:16]),
+		Format:   format,
+		Final:    data[1]&AudioFrameFinal != 0,
+		Data:     data[AudioFrameHeaderSize:],
+	}
+	return nil
+}
+
+func audioFrameFormat(name string) byte {
+	name = strings.ToLower(name)
+	for code, format := range audioFrameFormats {
+		if format == name {
+			return code
+		}
+	}
+	return AudioFrameFormatNone
+}
+
+// 客户端正在上传的一段二进制音频，只在消息循环中读写
+type audioFrameInput struct {
+	active    bool
+	seq       uint32
+	format    string
+	recording []byte // 没有进行中的音频流时拼接的完整录音
+}
+
+// 会话是否启用了二进制音频帧
+func (l *ChatStreamLogic) binaryAudio() bool {
+	l.wsWriteMutex.Lock()
+	defer l.wsWriteMutex.Unlock()
+
+	return l.proto.features[FeatureBinaryAudio]
+}
+
+// 处理二进制音频帧
+func (l *ChatStreamLogic) handleAudioFrame(data []byte, config *ConfigMessage, conn *websocket.Conn) error {
+	var frame AudioFrame
+	if err := frame.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	in := &l.audioFrames
+	if expected := in.seq + 1; in.active && frame.Sequence != expected {
+		*in = audioFrameInput{}
+		return fmt.Errorf("audio frame %d out of order, expected %d", frame.Sequence, expected)
+	}
+	if !in.active {
+		in.active, in.format = true, frame.Format
+	}
+	in.seq = frame.Sequence
+
+	// 实时音频流：首帧声明的格式作为 start_audio 未声明时的格式
+	if s := l.currentAudioSession(); s != nil && !s.protocol {
+		if s.frameFormat == "" {
+			s.frameFormat = in.format
+		}
+		if frame.Final {
+			*in = audioFrameInput{}
+		}
+		if len(frame.Data) > 0 {
+			if err := l.pushAudio(conn, config, s, frame.Data); err != nil {
+				*in = audioFrameInput{}
+				return err
+			}
+		}
+		if frame.Final {
+			return l.endAudioStream(conn)
+		}
+		return nil
+	}
+
+	// 完整录音
+	if len(in.recording)+len(frame.Data) > maxRecordingSize {
+		*in = audioFrameInput{}
+		return fmt.Errorf("recording exceeds %d bytes", maxRecordingSize)
+	}
+	in.recording = append(in.recording, frame.Data...)
+	if !frame.Final {
+		return nil
+	}
+
+	recording, format := in.recording, in.format
+	*in = audioFrameInput{}
+	if len(recording) == 0 {
+		return fmt.Errorf("empty recording")
+	}
+	go l.processRecording(time.Now(), recording, AudioFormat{Format: format}, config.clone(), conn)
+	return nil
+}
+
+// 一轮的音频结束：启用二进制音频帧时下发 final 帧，被打断的轮次不下发
+func (l *ChatStreamLogic) endTurnAudio(t *turn, conn *websocket.Conn) {
+	if t.wasInterrupted() || !l.binaryAudio() {
+		return
+	}
+
+	l.sendTurnMessage(conn, t, &WSMessage{
+		Content:   AudioFrame{Sequence: uint32(t.nextAudioSeq()), Final: true},
+		Timestamp: time.Now().Unix(),
+	})
+}
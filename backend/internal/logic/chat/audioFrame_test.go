@@ -0,0 +1,173 @@
+package chat_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestAudioFrameEncoding(t *testing.T) {
+	want := chat.AudioFrame{TurnID: 1760000000123456789, Sequence: 7, Format: "mp3", Final: true, Data: []byte{1, 2, 3}}
+	data, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != chat.AudioFrameHeaderSize+3 || data[0] != chat.AudioFrameVersion || data[2] != chat.AudioFrameFormatMP3 {
+		t.Fatalf("encoded = %v", data)
+	}
+
+	var got chat.AudioFrame
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.TurnID != want.TurnID || got.Sequence != want.Sequence || got.Format != want.Format || !got.Final || !bytes.Equal(got.Data, want.Data) {
+		t.Errorf("decoded = %+v, want %+v", got, want)
+	}
+
+	// 无法编码的格式记为未声明
+	unknown := chat.AudioFrame{Format: "flac"}
+	data, _ = unknown.MarshalBinary()
+	if data[2] != chat.AudioFrameFormatNone {
+		t.Errorf("flac encoded as format %d", data[2])
+	}
+
+	for name, data := range map[string][]byte{
+		"short":          {1, 0, 1},
+		"version":        append([]byte{2}, make([]byte, 15)...),
+		"unknown format": append([]byte{1, 0, 200}, make([]byte, 13)...),
+	} {
+		var f chat.AudioFrame
+		if err := f.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
+
+func TestBinaryAudioTurn(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(providertest.Reply("你好，", "我是哈利。", "很高兴", "认识你！"))
+	c := h.dial()
+	c.hello(chat.HelloMessage{Version: 2, Features: []string{chat.FeatureBinaryAudio, chat.FeatureMeta}})
+	c.configure(chat.ConfigMessage{})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "你是谁？"})
+	frames := c.until(model.FrameTypeMeta)
+
+	// 音频以二进制帧下发，final 帧在完成帧之后结束本轮音频
+	want := []string{
+		"status:processing_llm",
+		"text_delta", "text_delta", "binary",
+		"text_delta", "text_delta", "binary",
+		"end",
+		"binary:final",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+
+	meta := frames[len(frames)-1].Content.(model.MetaFrame)
+	var audio []byte
+	for _, f := range frames {
+		af, ok := f.Content.(chat.AudioFrame)
+		if !ok {
+			continue
+		}
+		if af.TurnID != meta.TurnID {
+			t.Errorf("audio frame turn id = %d, want %d", af.TurnID, meta.TurnID)
+		}
+		if af.Final {
+			if len(af.Data) != 0 || af.Format != "" {
+				t.Errorf("final frame = %+v", af)
+			}
+			continue
+		}
+		if af.Format != "pcm" {
+			t.Errorf("audio frame format = %s", af.Format)
+		}
+		audio = append(audio, af.Data...)
+	}
+	if want := append(providertest.AudioFor("你好，我是哈利。"), providertest.AudioFor("很高兴认识你！")...); !bytes.Equal(audio, want) {
+		t.Errorf("audio = %q, want %q", audio, want)
+	}
+}
+
+func TestBinaryAudioRecording(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("讲个故事"))
+	h.llm.Enqueue(providertest.Reply("从前有座山。"))
+	c := h.dial()
+
+	// v1 也可以启用二进制音频帧，JSON 帧结构不变
+	c.hello(chat.HelloMessage{Version: 1, Features: []string{chat.FeatureBinaryAudio}})
+	c.configure(chat.ConfigMessage{})
+
+	// 没有进行中的音频流时，各帧拼接为一段录音，final 后开始识别
+	pcm := bytes.Repeat([]byte{1, 0}, 1600)
+	c.sendAudio(5, false, pcm[:1000])
+	c.sendAudio(6, false, pcm[1000:])
+	c.sendAudio(7, true, nil)
+
+	frames := c.until(model.FrameTypeMeta)
+	want := []string{
+		"status:processing_audio",
+		"asr_result",
+		"status:processing_llm",
+		"response", "binary",
+		"response:done",
+		"binary:final",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	if received := h.asr.Audio(); len(received) != 1 || !bytes.Equal(received[0], pcm) {
+		t.Errorf("ASR did not receive the recording")
+	}
+
+	c.sendAudio(1, false, pcm)
+	c.sendAudio(3, true, nil)
+	c.expectError(400, "audio frame 3 out of order, expected 2")
+
+	c.sendAudio(1, true, nil)
+	c.expectError(400, "empty recording")
+
+	c.sendRaw(websocket.BinaryMessage, []byte{1, 0})
+	c.expectError(400, "audio frame too short")
+}
+
+func TestBinaryAudioStream(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("讲个故事"))
+	h.llm.Enqueue(providertest.Reply("好的。"))
+	c := h.dial()
+	c.hello(chat.HelloMessage{Version: 2, Features: []string{chat.FeatureBinaryAudio}})
+	c.configure(chat.ConfigMessage{Params: map[string]string{"vad": "off"}})
+
+	// start_audio 之后的帧送入实时音频流，final 帧结束音频流
+	c.send(chat.MessageTypeStartAudio, nil)
+	c.expect(chat.MessageTypeStatus)
+	chunk := bytes.Repeat([]byte{1, 0}, 320)
+	c.sendAudio(1, false, chunk)
+	c.sendAudio(2, true, chunk)
+
+	frames := c.until(model.FrameTypeEnd)
+	if got, want := outline(frames), []string{"asr", "status:processing_llm", "text_delta", "binary", "end"}; !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	if final := c.expect(frameTypeBinary).Content.(chat.AudioFrame); !final.Final {
+		t.Errorf("audio frame after end = %+v, want the final frame", final)
+	}
+	if received := h.asr.Audio(); len(received) != 1 || len(received[0]) != 2*len(chunk) {
+		t.Errorf("ASR did not receive every chunk")
+	}
+
+	// 音频流已由 final 帧结束
+	c.send(chat.MessageTypeEndAudio, nil)
+	c.expectError(400, "no active audio stream")
+}
@@ -0,0 +1,457 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	MessageTypeSpeechStart = "speech_start"
+	MessageTypeSpeechEnd   = "speech_end"
+)
+
+// 语音开始前保留的音频时长，避免丢失句首
+const prerollDuration = 300 * time.Millisecond
+
+// 实时麦克风流：start_audio -> audio_chunk... -> end_audio，
+// 或七牛云 ASR 二进制协议：FULL_CLIENT_REQUEST -> AUDIO_ONLY_REQUEST... (最后一包带结束标志)
+//
+// 开启 VAD 时，服务端根据端点检测自动切分语句：speech_start 开始一次识别并打断正在播报的回复，
+// speech_end 结束识别并以最终结果触发 LLM；未开启时整个音频流作为一句话识别。
+//
+// 音频在送入 VAD 与 ASR 之前统一转换为 ASR Provider 的输入格式。
+//
+// 会话状态只在消息循环中读写。
+type audioSession struct {
+	protocol    bool        // 是否为 ASR 二进制协议
+	fields      AudioFormat // start_audio 声明的音频格式
+	frameFormat string      // 首个二进制音频帧声明的格式，start_audio 未声明格式时使用
+	transcoder  *audio.Transcoder
+	vadConfig   *audio.VADConfig // 开启 VAD 时非空，VAD 在确定输出格式后创建
+	vad         *audio.VAD
+	recognition *recognition // 当前语句的识别
+	preroll     [][]byte
+	prerollSize int
+	prerollMax  int
+}
+
+// 一次语句识别：音频 -> StreamRecognize -> asr 帧 -> LLM
+type recognition struct {
+	audio  chan []byte
+	cancel context.CancelFunc
+	done   chan struct{}
+	closed bool
+	ended  atomic.Int64 // 音频输入结束的时间（Unix 纳秒），由消息循环写入、结果协程读取
+}
+
+func (r *recognition) active() bool {
+	if r == nil || r.closed {
+		return false
+	}
+
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// 开始实时音频流
+func (l *ChatStreamLogic) startAudioStream(conn *websocket.Conn, config *ConfigMessage, fields AudioFormat, protocol bool) (*audioSession, error) {
+	// 结束上一个未结束的音频流
+	l.stopAudioStream()
+
+	s := &audioSession{protocol: protocol, fields: fields}
+
+	vadConfig, enabled, err := vadSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabled {
+		s.vadConfig = &vadConfig
+	} else {
+		// 用户开口说话，打断正在播报的回复
+		l.preemptTurn(conn, config)
+		s.recognition = l.startRecognition(conn, config, protocol)
+	}
+
+	l.audioMutex.Lock()
+	l.audioSession = s
+	l.audioMutex.Unlock()
+
+	logx.Infof("Audio stream started (protocol: %v, vad: %v)", protocol, enabled)
+	return s, nil
+}
+
+// 开始一次语句识别：音频持续送入 StreamRecognize，中间结果以 asr 帧推送，最终结果触发 LLM
+func (l *ChatStreamLogic) startRecognition(conn *websocket.Conn, config *ConfigMessage, protocol bool) *recognition {
+	// 识别协程持有配置副本，不受之后的 config 消息影响
+	config = config.clone()
+
+	ctx, cancel := context.WithCancel(l.ctx)
+	r := &recognition{
+		audio:  make(chan []byte, 100),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	asrResults := make(chan *provider.Transcript, 16)
+	textStream := make(chan string, 4)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go l.handleAudioStream(ctx, r.audio, asrResults, config, &wg)
+	go l.handleASRResults(ctx, r, asrResults, textStream, conn, protocol, &wg)
+	go l.handleTextStream(ctx, textStream, conn, config, &wg)
+
+	// 识别结束后释放资源
+	go func() {
+		wg.Wait()
+		cancel()
+		close(r.done)
+	}()
+
+	return r
+}
+
+// 结束语句的音频输入，等待 ASR 返回最终结果
+func (r *recognition) finish() {
+	if r != nil && !r.closed {
+		r.closed = true
+		r.ended.Store(time.Now().UnixNano())
+		close(r.audio)
+	}
+}
+
+// 用户说完话的时间：音频输入尚未结束时（Provider 提前给出最终结果）取当前时间
+func (r *recognition) inputEnd() time.Time {
+	if ended := r.ended.Load(); ended != 0 {
+		return time.Unix(0, ended)
+	}
+	return time.Now()
+}
+
+func (r *recognition) push(data []byte) error {
+	select {
+	case r.audio <- data:
+		return nil
+	default:
+		return fmt.Errorf("audio stream buffer full")
+	}
+}
+
+// 当前音频流
+func (l *ChatStreamLogic) currentAudioSession() *audioSession {
+	l.audioMutex.Lock()
+	defer l.audioMutex.Unlock()
+
+	return l.audioSession
+}
+
+// 送入一段音频，开启 VAD 时先做端点检测
+func (l *ChatStreamLogic) pushAudio(conn *websocket.Conn, config *ConfigMessage, s *audioSession, data []byte) error {
+	data, err := l.transcodeAudio(config, s, data)
+	if err != nil {
+		// 格式无法解码时后续音频也无意义，直接结束音频流
+		l.stopAudioStream()
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	if s.vad == nil {
+		if !s.recognition.active() {
+			return fmt.Errorf("audio stream already finished")
+		}
+		return l.feedRecognition(config, s, data)
+	}
+
+	s.appendPreroll(data)
+
+	fed := false
+	for _, ev := range s.vad.Process(data) {
+		switch ev.Type {
+		case audio.EventSpeechStart:
+			s.recognition.finish()
+			l.sendSpeechEvent(conn, ev)
+
+			// 用户开口说话，打断正在播报的回复
+			l.preemptTurn(conn, config)
+
+			s.recognition = l.startRecognition(conn, config, s.protocol)
+			for _, chunk := range s.preroll {
+				if err := l.feedRecognition(config, s, chunk); err != nil {
+					return err
+				}
+			}
+			fed = true
+
+		case audio.EventSpeechEnd:
+			if !fed && s.recognition.active() {
+				if err := l.feedRecognition(config, s, data); err != nil {
+					return err
+				}
+				fed = true
+			}
+			s.recognition.finish()
+			l.sendSpeechEvent(conn, ev)
+		}
+	}
+
+	if !fed && s.recognition.active() {
+		return l.feedRecognition(config, s, data)
+	}
+
+	return nil
+}
+
+// 送入当前语句的识别，并计量送入 ASR 的音频时长
+func (l *ChatStreamLogic) feedRecognition(config *ConfigMessage, s *audioSession, data []byte) error {
+	if err := s.recognition.push(data); err != nil {
+		return err
+	}
+
+	_, name, _ := l.asrProvider(config)
+	l.meterASR(name, audioSeconds(data, s.transcoder.Target()))
+	return nil
+}
+
+// 转换为 ASR Provider 的输入格式，首块音频决定源格式（WAV 文件头或声明的裸 PCM 格式）
+func (l *ChatStreamLogic) transcodeAudio(config *ConfigMessage, s *audioSession, data []byte) ([]byte, error) {
+	if s.transcoder == nil {
+		fields := s.fields
+		if fields.Format == "" {
+			fields.Format = s.frameFormat
+		}
+		hint, err := inputFormat(config, fields)
+		if err != nil {
+			return nil, err
+		}
+		if s.transcoder, err = audio.NewTranscoder(hint, l.asrInputFormats(config)); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := s.transcoder.Write(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// VAD 与预录缓冲按输出格式计算
+	if s.vadConfig != nil && s.vad == nil && s.transcoder.Ready() {
+		target := s.transcoder.Target()
+		vadConfig := *s.vadConfig
+		vadConfig.SampleRate = target.SampleRate
+		s.vad = audio.NewVAD(vadConfig)
+		s.prerollMax = int(prerollDuration.Milliseconds()) * target.SampleRate / 1000 * target.BytesPerSample() * target.Channels
+
+		logx.Infof("Audio stream format: %s -> %s", s.transcoder.Source(), target)
+	}
+
+	return out, nil
+}
+
+// 保留最近的音频，语音开始时一并送入识别
+func (s *audioSession) appendPreroll(data []byte) {
+	s.preroll = append(s.preroll, data)
+	s.prerollSize += len(data)
+	for len(s.preroll) > 1 && s.prerollSize-len(s.preroll[0]) >= s.prerollMax {
+		s.prerollSize -= len(s.preroll[0])
+		s.preroll = s.preroll[1:]
+	}
+}
+
+// 结束音频输入，等待 ASR 返回最终结果
+func (l *ChatStreamLogic) endAudioStream(conn *websocket.Conn) error {
+	l.audioMutex.Lock()
+	s := l.audioSession
+	l.audioSession = nil
+	l.audioMutex.Unlock()
+
+	if s == nil {
+		return fmt.Errorf("no active audio stream")
+	}
+
+	if s.vadConfig != nil {
+		if s.vad != nil {
+			for _, ev := range s.vad.Flush() {
+				l.sendSpeechEvent(conn, ev)
+			}
+		}
+		if s.recognition == nil {
+			return fmt.Errorf("no speech detected")
+		}
+	}
+
+	s.recognition.finish()
+	return nil
+}
+
+// 放弃当前音频流，不再等待识别结果
+func (l *ChatStreamLogic) stopAudioStream() {
+	l.audioMutex.Lock()
+	s := l.audioSession
+	l.audioSession = nil
+	l.audioMutex.Unlock()
+
+	if s == nil || s.recognition == nil {
+		return
+	}
+
+	s.recognition.cancel()
+	s.recognition.finish()
+}
+
+// 处理 audio_chunk 消息
+func (l *ChatStreamLogic) handleAudioChunk(msg *ClientMessage, config *ConfigMessage, conn *websocket.Conn) error {
+	s := l.currentAudioSession()
+	if s == nil {
+		return fmt.Errorf("no active audio stream, send start_audio first")
+	}
+
+	return l.handleAudioMessage(msg, func(data []byte) error {
+		return l.pushAudio(conn, config, s, data)
+	})
+}
+
+// 处理音频流期间的二进制消息，返回 false 表示不属于实时音频流
+func (l *ChatStreamLogic) handleStreamBinary(data []byte, config *ConfigMessage, conn *websocket.Conn) (bool, error) {
+	s := l.currentAudioSession()
+
+	// 没有进行中的音频流时，只有 ASR 协议的 FULL_CLIENT_REQUEST 会开启新的流
+	if s == nil {
+		if !isASRFullClientRequest(data) {
+			return false, nil
+		}
+
+		var err error
+		if s, err = l.startAudioStream(conn, config, AudioFormat{}, true); err != nil {
+			return true, err
+		}
+	}
+
+	// start_audio 开启的音频流：二进制消息即原始 PCM 数据
+	if !s.protocol {
+		return true, l.pushAudio(conn, config, s, data)
+	}
+
+	last, err := l.handleASRProtocolMessage(data, config, func(audio []byte) error {
+		return l.pushAudio(conn, config, s, audio)
+	})
+	if err != nil {
+		return true, err
+	}
+	if last {
+		return true, l.endAudioStream(conn)
+	}
+
+	return true, nil
+}
+
+// 判断二进制消息是否为 ASR 协议的完整请求（协议版本 1 + FULL_CLIENT_REQUEST）
+func isASRFullClientRequest(data []byte) bool {
+	return len(data) >= 4 && data[0]>>4 == 1 && data[1]>>4 == ASRProtocolFullClientRequest
+}
+
+// 通知客户端端点事件
+func (l *ChatStreamLogic) sendSpeechEvent(conn *websocket.Conn, ev audio.VADEvent) {
+	logx.Infof("VAD %s at %v", ev.Type, ev.Offset)
+
+	l.sendMessage(conn, &WSMessage{
+		Type:      ev.Type,
+		Content:   SpeechEventFrame{OffsetMs: ev.Offset.Milliseconds()},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// 推送识别结果：二进制协议的客户端额外收到协议格式的响应
+func (l *ChatStreamLogic) sendTranscript(conn *websocket.Conn, transcript *provider.Transcript, protocol bool) {
+	l.sendMessage(conn, &WSMessage{
+		Type: MessageTypeASR,
+		Content: TranscriptFrame{
+			Text:       transcript.Text,
+			IsFinal:    transcript.IsFinal,
+			Confidence: transcript.Confidence,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+
+	if protocol {
+		response := map[string]interface{}{
+			"result": map[string]interface{}{
+				"text":     transcript.Text,
+				"is_final": transcript.IsFinal,
+			},
+		}
+		if err := l.sendASRResponse(conn, response); err != nil {
+			logx.Errorf("Failed to send ASR protocol response: %v", err)
+		}
+	}
+}
+
+// 从会话参数读取 VAD 设置：
+//
+//	vad                   on|off，默认 on
+//	vad_energy_threshold  语音能量阈值（0-1）
+//	vad_zcr_threshold     清音过零率阈值（0-1）
+//	vad_min_speech_ms     判定开始说话的最短语音时长
+//	vad_silence_ms        判定说话结束的静音时长
+//	vad_max_speech_ms     单句最长时长
+//
+// 采样率取 ASR Provider 的输入格式，音频在端点检测前已完成转换。
+func vadSettings(config *ConfigMessage) (audio.VADConfig, bool, error) {
+	var cfg audio.VADConfig
+	params := config.Params
+
+	switch params["vad"] {
+	case "", "on", "true", "1":
+	case "off", "false", "0":
+		return cfg, false, nil
+	default:
+		return cfg, false, fmt.Errorf("invalid vad param '%s', expected on|off", params["vad"])
+	}
+
+	floats := map[string]*float64{
+		"vad_energy_threshold": &cfg.EnergyThreshold,
+		"vad_zcr_threshold":    &cfg.ZCRThreshold,
+	}
+	for key, dst := range floats {
+		if value, ok := params[key]; ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v <= 0 || v >= 1 {
+				return cfg, false, fmt.Errorf("invalid %s '%s', expected a number in (0, 1)", key, value)
+			}
+			*dst = v
+		}
+	}
+
+	ints := map[string]*int{
+		"vad_min_speech_ms": &cfg.MinSpeechMs,
+		"vad_silence_ms":    &cfg.SilenceMs,
+		"vad_max_speech_ms": &cfg.MaxSpeechMs,
+	}
+	for key, dst := range ints {
+		if value, ok := params[key]; ok {
+			v, err := strconv.Atoi(value)
+			if err != nil || v <= 0 {
+				return cfg, false, fmt.Errorf("invalid %s '%s', expected a positive integer", key, value)
+			}
+			*dst = v
+		}
+	}
+
+	return cfg, true, nil
+}
@@ -4,17 +4,22 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
 	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
 	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
 
 	"github.com/gorilla/websocket"
 	"github.com/zeromicro/go-zero/core/logx"
@@ -31,6 +36,15 @@ const (
 	MessageTypeTTS       = "tts"
 	MessageTypeResponse  = "response"
 	MessageTypeError     = "error"
+
+	MessageTypeConversation = "conversation"
+	MessageTypeInterrupt    = "interrupt"   // 客户端请求打断当前回复
+	MessageTypeInterrupted  = "interrupted" // 当前回复已被打断
+
+	// 实时麦克风流
+	MessageTypeStartAudio = "start_audio"
+	MessageTypeAudioChunk = "audio_chunk"
+	MessageTypeEndAudio   = "end_audio"
 )
 
 type ChatStreamLogic struct {
@@ -39,9 +53,26 @@ type ChatStreamLogic struct {
 	svcCtx *svc.ServiceContext
 	// WebSocket写入互斥锁 - 每个连接一个
 	wsWriteMutex sync.Mutex
-	// TTS队列管理器
-	ttsSequence int32 // 音频序列号
-	ttsMutex    sync.Mutex // TTS序列化锁
+	// 协议版本与协商结果
+	proto protocolState
+	// 客户端正在上传的二进制音频帧
+	audioFrames audioFrameInput
+	// 当前会话
+	conversationID string
+	convMutex      sync.Mutex
+	// 当前角色编译后的守则
+	guard      *role.Guard
+	guardMutex sync.Mutex
+	// 进行中与排队的对话轮次
+	turns turnManager
+	// 进行中的实时音频流
+	audioSession *audioSession
+	audioMutex   sync.Mutex
+	// 用量计费的租户，以及尚未计入轮次的语音识别用量与时间点
+	tenant       string
+	pendingUsage model.Usage
+	pendingInput *turnInput
+	pendingMutex sync.Mutex
 }
 
 func NewChatStreamLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ChatStreamLogic {
@@ -52,48 +83,19 @@ func NewChatStreamLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ChatSt
 	}
 }
 
-
-
-// WebSocket 消息结构
-type WSMessage struct {
-	Type      string      `json:"type"`
-	Seq       int         `json:"seq,omitempty"`
-	Content   interface{} `json:"content,omitempty"`
-	Timestamp int64       `json:"timestamp,omitempty"`
-}
-
-// 配置消息
-type ConfigMessage struct {
-	LLMProvider string            `json:"llmProvider,omitempty"`
-	ASRProvider string            `json:"asrProvider,omitempty"`
-	TTSProvider string            `json:"ttsProvider,omitempty"`
-	Voice       string            `json:"voice,omitempty"`
-	Speed       float64           `json:"speed,omitempty"`
-	Role        string            `json:"role,omitempty"`
-	Params      map[string]string `json:"params,omitempty"`
-}
-
-// 文本消息
-type TextMessage struct {
-	Content string `json:"content"`
-	Role    string `json:"role,omitempty"`
-}
-
-// 错误消息
-type ErrorMessage struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
-func (l *ChatStreamLogic) HandleWebSocket(conn *websocket.Conn) {
+// apiKey 用于确定用量计费的租户
+func (l *ChatStreamLogic) HandleWebSocket(conn *websocket.Conn, apiKey string) {
 	defer conn.Close()
 
+	l.tenant = l.svcCtx.Usage.TenantOf(apiKey)
+
 	// 会话状态
 	var config ConfigMessage
+	negotiable := true // hello 只能是第一条消息
 
 	// 发送欢迎消息
 	l.sendMessage(conn, &WSMessage{
-		Type:      "welcome",
+		Type:      MessageTypeWelcome,
 		Content:   "WebSocket connection established. Send config to start.",
 		Timestamp: time.Now().Unix(),
 	})
@@ -111,39 +113,108 @@ func (l *ChatStreamLogic) HandleWebSocket(conn *websocket.Conn) {
 		switch messageType {
 		case websocket.TextMessage:
 			// 处理JSON消息
-			var msg WSMessage
+			var msg ClientMessage
 			if err := json.Unmarshal(data, &msg); err != nil {
+				negotiable = false
 				l.sendError(conn, 400, "Invalid JSON message: "+err.Error())
 				continue
 			}
 
+			if msg.Type == MessageTypeHello {
+				if !negotiable {
+					l.sendError(conn, 400, "hello must be the first message")
+				} else if err := l.handleHello(&msg, &config, conn); err != nil {
+					l.sendError(conn, 400, err.Error())
+				} else {
+					negotiable = false
+				}
+				continue
+			}
+			negotiable = false
+
 			switch msg.Type {
 			case MessageTypeConfig:
 				if err := l.handleConfig(&msg, &config); err != nil {
 					l.sendError(conn, 400, err.Error())
 				} else {
 					l.sendMessage(conn, &WSMessage{
-						Type:      "config_updated",
+						Type:      MessageTypeConfigUpdated,
 						Content:   "Configuration updated successfully",
 						Timestamp: time.Now().Unix(),
 					})
+
+					if _, err := l.startConversation(conn, config.ConversationID, config.RoleID); errors.Is(err, conversation.ErrNotFound) {
+						l.sendError(conn, 404, "Conversation not found: "+config.ConversationID)
+					} else if err != nil {
+						l.sendError(conn, 500, "Failed to start conversation: "+err.Error())
+					}
 				}
 
 			case MessageTypeAudio, MessageTypeAudioFile:
 				// 处理完整音频文件进行ASR（支持audio和audio_file两种类型）
-				go l.handleAudioFile(&msg, &config, conn)
+				go l.handleAudioFile(&msg, config.clone(), conn)
 
 			case MessageTypeText:
-				// 直接处理文本输入
-				go l.handleTextInput(&msg, &config, conn)
+				// 直接处理文本输入，在消息循环中开始轮次，保证各轮按输入顺序排队
+				l.handleTextInput(&msg, &config, conn)
+
+			case MessageTypeInterrupt:
+				// 打断当前回复
+				l.interruptTurn(conn, interruptReasonClient)
+
+			case MessageTypeStartAudio:
+				// 开始实时音频流，消息内容可声明裸 PCM 的格式
+				var fields AudioFormat
+				if err := msg.decode(&fields); err != nil {
+					l.sendError(conn, 400, err.Error())
+					continue
+				}
+				if _, err := l.startAudioStream(conn, &config, fields, false); err != nil {
+					l.sendError(conn, 400, err.Error())
+					continue
+				}
+				streaming := l.asrStreaming(&config)
+				l.sendMessage(conn, &WSMessage{
+					Type:      MessageTypeStatus,
+					Content:   StatusFrame{Status: StatusListening, Message: "正在聆听...", Streaming: &streaming},
+					Timestamp: time.Now().Unix(),
+				})
+
+			case MessageTypeAudioChunk:
+				if err := l.handleAudioChunk(&msg, &config, conn); err != nil {
+					l.sendError(conn, 400, err.Error())
+				}
+
+			case MessageTypeEndAudio:
+				if err := l.endAudioStream(conn); err != nil {
+					l.sendError(conn, 400, err.Error())
+				}
 
 			default:
 				l.sendError(conn, 400, "Unknown message type: "+msg.Type)
 			}
 
 		case websocket.BinaryMessage:
+			negotiable = false
+
+			// 协商了二进制音频帧时，二进制消息都是音频帧
+			if l.binaryAudio() {
+				if err := l.handleAudioFrame(data, &config, conn); err != nil {
+					l.sendError(conn, 400, err.Error())
+				}
+				continue
+			}
+
+			// 实时音频流（PCM 数据或 ASR 二进制协议）
+			if handled, err := l.handleStreamBinary(data, &config, conn); handled {
+				if err != nil {
+					l.sendError(conn, 400, err.Error())
+				}
+				continue
+			}
+
 			// 处理二进制音频数据
-			go l.handleBinaryAudio(data, &config, conn)
+			go l.handleBinaryAudio(data, config.clone(), conn)
 
 		default:
 			l.sendError(conn, 400, "Unsupported message type")
@@ -152,90 +223,52 @@ func (l *ChatStreamLogic) HandleWebSocket(conn *websocket.Conn) {
 }
 
 // 处理完整音频文件进行ASR识别
-func (l *ChatStreamLogic) handleAudioFile(msg *WSMessage, config *ConfigMessage, conn *websocket.Conn) {
-	// 打印调试信息
-	logx.Infof("Audio message content: %+v", msg.Content)
-	
-	// 解析音频文件数据
-	audioData, ok := msg.Content.(map[string]interface{})
-	if !ok {
+func (l *ChatStreamLogic) handleAudioFile(msg *ClientMessage, config *ConfigMessage, conn *websocket.Conn) {
+	received := time.Now() // 整段音频上传完成即用户输入结束
+
+	var audioFile AudioFileMessage
+	if !msg.hasContent() {
 		l.sendError(conn, 400, "Invalid audio file format")
 		return
 	}
-
-	// 打印所有字段名以调试
-	logx.Infof("Audio data fields: %v", func() []string {
-		keys := make([]string, 0, len(audioData))
-		for k := range audioData {
-			keys = append(keys, k)
-		}
-		return keys
-	}())
-
-	// 获取音频数据，尝试多种可能的字段名
-	var audioBytes []byte
-	var audioDataRaw interface{}
-	var exists bool
-	
-	// 尝试不同的字段名
-	if audioDataRaw, exists = audioData["audio_data"]; exists {
-		// 使用 audio_data 字段
-	} else if audioDataRaw, exists = audioData["data"]; exists {
-		// 使用 data 字段
-	} else if audioDataRaw, exists = audioData["audioData"]; exists {
-		// 使用 audioData 字段
-	} else if audioDataRaw, exists = audioData["audio"]; exists {
-		// 使用 audio 字段
-	} else {
-		l.sendError(conn, 400, "Missing audio data field (tried: audio_data, data, audioData, audio)")
-		return
-	}
-
-	switch data := audioDataRaw.(type) {
-	case string:
-		// base64 编码的音频数据
-		var err error
-		audioBytes, err = base64.StdEncoding.DecodeString(data)
-		if err != nil {
-			l.sendError(conn, 400, "Failed to decode audio data: "+err.Error())
-			return
-		}
-	case []byte:
-		audioBytes = data
-	case []interface{}:
-		// 处理数字数组（JavaScript Array -> Go []interface{}）
-		audioBytes = make([]byte, len(data))
-		for i, v := range data {
-			if num, ok := v.(float64); ok {
-				audioBytes[i] = byte(num)
-			} else {
-				l.sendError(conn, 400, "Invalid audio data: array contains non-numeric values")
-				return
-			}
-		}
-	default:
-		l.sendError(conn, 400, fmt.Sprintf("Unsupported audio data format: %T", data))
+	if err := msg.decode(&audioFile); err != nil {
+		l.sendError(conn, 400, err.Error())
 		return
 	}
 
+	audioBytes := audioFile.audio()
 	if len(audioBytes) == 0 {
-		l.sendError(conn, 400, "Empty audio data")
+		l.sendError(conn, 400, "Missing audio data field (tried: audio_data, data, audioData, audio)")
 		return
 	}
 
-	logx.Infof("Processing audio file: %d bytes", len(audioBytes))
+	l.processRecording(received, audioBytes, audioFile.AudioFormat, config, conn)
+}
+
+// 识别一段完整录音，并以识别结果开始新一轮对话
+func (l *ChatStreamLogic) processRecording(received time.Time, audioBytes []byte, fields AudioFormat, config *ConfigMessage, conn *websocket.Conn) {
+	logx.Infof("Processing recording: %d bytes", len(audioBytes))
+
+	// 用户开口说话，打断正在播报的回复
+	l.preemptTurn(conn, config)
 
 	// 发送处理状态
 	l.sendMessage(conn, &WSMessage{
-		Type:      "status",
-		Content:   map[string]interface{}{"status": "processing_audio", "message": "正在识别语音..."},
+		Type:      MessageTypeStatus,
+		Content:   StatusFrame{Status: StatusProcessingAudio, Message: "正在识别语音..."},
 		Timestamp: time.Now().Unix(),
 	})
 
+	format, err := inputFormat(config, fields)
+	if err != nil {
+		l.sendError(conn, 400, err.Error())
+		return
+	}
+
 	// 调用ASR识别（一次性）
-	text, err := l.performASR(audioBytes, config)
+	text, err := l.performASR(audioBytes, format, config)
 	if err != nil {
-		l.sendError(conn, 500, "ASR failed: "+err.Error())
+		l.sendError(conn, audioErrorCode(err), "ASR failed: "+err.Error())
 		return
 	}
 
@@ -243,13 +276,14 @@ func (l *ChatStreamLogic) handleAudioFile(msg *WSMessage, config *ConfigMessage,
 		l.sendError(conn, 400, "No text recognized from audio")
 		return
 	}
+	l.markInput(received, time.Now())
 
 	logx.Infof("ASR result: '%s'", text)
 
 	// 发送ASR结果
 	l.sendMessage(conn, &WSMessage{
 		Type:      MessageTypeASRResult,
-		Content:   map[string]interface{}{"text": text},
+		Content:   ASRResultFrame{Text: text},
 		Timestamp: time.Now().Unix(),
 	})
 
@@ -258,19 +292,14 @@ func (l *ChatStreamLogic) handleAudioFile(msg *WSMessage, config *ConfigMessage,
 }
 
 // 处理文本输入
-func (l *ChatStreamLogic) handleTextInput(msg *WSMessage, config *ConfigMessage, conn *websocket.Conn) {
-	textData, ok := msg.Content.(map[string]interface{})
-	if !ok {
-		l.sendError(conn, 400, "Invalid text format")
-		return
-	}
-
-	text, ok := textData["content"].(string)
-	if !ok {
-		l.sendError(conn, 400, "Missing text content")
+func (l *ChatStreamLogic) handleTextInput(msg *ClientMessage, config *ConfigMessage, conn *websocket.Conn) {
+	var textMessage TextMessage
+	if err := msg.decode(&textMessage); err != nil {
+		l.sendError(conn, 400, err.Error())
 		return
 	}
 
+	text := textMessage.Content
 	if text == "" {
 		l.sendError(conn, 400, "Empty text content")
 		return
@@ -284,142 +313,45 @@ func (l *ChatStreamLogic) handleTextInput(msg *WSMessage, config *ConfigMessage,
 
 // 处理二进制音频数据
 func (l *ChatStreamLogic) handleBinaryAudio(audioData []byte, config *ConfigMessage, conn *websocket.Conn) {
+	received := time.Now() // 整段音频上传完成即用户输入结束
+
 	if len(audioData) == 0 {
 		l.sendError(conn, 400, "Empty binary audio data")
 		return
 	}
 
-	logx.Infof("Processing binary audio: %d bytes", len(audioData))
-
-	// 发送处理状态
-	l.sendMessage(conn, &WSMessage{
-		Type:      "status",
-		Content:   map[string]interface{}{"status": "processing_audio", "message": "正在识别语音..."},
-		Timestamp: time.Now().Unix(),
-	})
-
-	// 调用ASR识别
-	text, err := l.performASR(audioData, config)
-	if err != nil {
-		l.sendError(conn, 500, "ASR failed: "+err.Error())
-		return
-	}
-
-	if text == "" {
-		l.sendError(conn, 400, "No text recognized from audio")
-		return
-	}
-
-	// 发送ASR结果并继续处理
-	l.sendMessage(conn, &WSMessage{
-		Type:      MessageTypeASRResult,
-		Content:   map[string]interface{}{"text": text},
-		Timestamp: time.Now().Unix(),
-	})
-
-	l.processTextToResponse(text, config, conn)
+	l.processRecording(received, audioData, AudioFormat{}, config, conn)
 }
 
 // 处理音频消息
-func (l *ChatStreamLogic) handleAudioMessage(msg *WSMessage, audioStream chan<- []byte) error {
-	// 期望的音频数据格式：
-	// {
-	//   "audio_data": [byte array],
-	//   "format": "pcm",
-	//   "sample_rate": 16000,
-	//   "channels": 1
-	// }
-	audioData, ok := msg.Content.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid audio data format: expected object, got %T", msg.Content)
-	}
-
-	// 获取音频字节数组
-	audioDataRaw, exists := audioData["audio_data"]
-	if !exists {
-		return fmt.Errorf("missing audio_data field")
-	}
-
-	var audioBytes []byte
-	
-	// 处理不同的音频数据格式
-	switch data := audioDataRaw.(type) {
-	case []interface{}:
-		// 数组格式 [1, 2, 3, ...]
-		audioBytes = make([]byte, len(data))
-		for i, val := range data {
-			if byteVal, ok := val.(float64); ok {
-				audioBytes[i] = byte(byteVal)
-			} else {
-				return fmt.Errorf("invalid byte value in audio_data array at index %d", i)
-			}
-		}
-	case string:
-		// base64 编码的字符串
-		var err error
-		audioBytes, err = base64.StdEncoding.DecodeString(data)
-		if err != nil {
-			return fmt.Errorf("failed to decode base64 audio data: %v", err)
-		}
-	case []byte:
-		// 直接的字节数组
-		audioBytes = data
-	default:
-		return fmt.Errorf("unsupported audio_data format: %T", data)
+func (l *ChatStreamLogic) handleAudioMessage(msg *ClientMessage, push func([]byte) error) error {
+	var chunk AudioChunkMessage
+	if err := msg.decode(&chunk); err != nil {
+		return err
 	}
 
-	if len(audioBytes) == 0 {
+	if len(chunk.AudioData) == 0 {
 		return fmt.Errorf("empty audio data")
 	}
 
-	logx.Infof("Received audio data: %d bytes, format: %v, sample_rate: %v, channels: %v", 
-		len(audioBytes), 
-		audioData["format"], 
-		audioData["sample_rate"], 
-		audioData["channels"])
-	
-	select {
-	case audioStream <- audioBytes:
-		return nil
-	default:
-		return fmt.Errorf("audio stream buffer full")
-	}
-}
-
-// 处理文本消息
-func (l *ChatStreamLogic) handleTextMessage(msg *WSMessage, textStream chan<- string) error {
-	textData, ok := msg.Content.(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("invalid text format")
-	}
+	logx.Infof("Received audio data: %d bytes, format: %s, sample_rate: %s, channels: %s",
+		len(chunk.AudioData),
+		chunk.Format,
+		chunk.SampleRate,
+		chunk.Channels)
 
-	content, ok := textData["content"].(string)
-	if !ok {
-		return fmt.Errorf("missing text content")
-	}
-
-	select {
-	case textStream <- content:
-		return nil
-	default:
-		return fmt.Errorf("text stream buffer full")
-	}
+	return push(chunk.AudioData)
 }
 
 // 处理音频流 -> ASR
 func (l *ChatStreamLogic) handleAudioStream(ctx context.Context, audioStream <-chan []byte, asrResults chan<- *provider.Transcript, config *ConfigMessage, wg *sync.WaitGroup) {
-	wg.Add(1)
 	defer wg.Done()
 
-	// 获取 ASR Provider
-	asrProvider := config.ASRProvider
-	if asrProvider == "" {
-		asrProvider = "iflytek" // 默认使用讯飞ASR（更稳定）
-	}
-
-	asrProviderInstance, err := l.svcCtx.Registry.GetASR(asrProvider)
+	// 获取 ASR Provider，默认使用讯飞ASR（更稳定）
+	asrProviderInstance, asrProvider, err := l.asrProvider(config)
 	if err != nil {
 		logx.Errorf("Failed to get ASR provider %s: %v", asrProvider, err)
+		close(asrResults)
 		return
 	}
 
@@ -430,11 +362,13 @@ func (l *ChatStreamLogic) handleAudioStream(ctx context.Context, audioStream <-c
 	transcriptChan, err := asrProviderInstance.StreamRecognize(ctx, persistentAudioStream)
 	if err != nil {
 		logx.Errorf("ASR stream recognition failed: %v", err)
+		close(asrResults)
 		return
 	}
 
-	// 转发 ASR 结果
+	// 转发 ASR 结果，识别结束后关闭结果通道
 	go func() {
+		defer close(asrResults)
 		for transcript := range transcriptChan {
 			select {
 			case asrResults <- transcript:
@@ -450,17 +384,23 @@ func (l *ChatStreamLogic) handleAudioStream(ctx context.Context, audioStream <-c
 		case <-ctx.Done():
 			close(persistentAudioStream)
 			return
-		case audioData := <-audioStream:
+		case audioData, ok := <-audioStream:
+			if !ok {
+				// 音频输入结束，通知 ASR 发送结束帧
+				close(persistentAudioStream)
+				return
+			}
 			if len(audioData) == 0 {
 				continue
 			}
 
-			// 发送音频数据到持久流
+			// 发送音频数据到持久流，缓冲满时等待 ASR 消费，不丢弃音频
 			select {
 			case persistentAudioStream <- audioData:
 				// 音频数据已发送
-			default:
-				logx.Errorw("ASR audio stream buffer full, dropping audio data")
+			case <-ctx.Done():
+				close(persistentAudioStream)
+				return
 			}
 		}
 	}
@@ -468,135 +408,105 @@ func (l *ChatStreamLogic) handleAudioStream(ctx context.Context, audioStream <-c
 
 // 处理文本流 -> LLM -> TTS (支持流式处理)
 func (l *ChatStreamLogic) handleTextStream(ctx context.Context, textStream <-chan string, conn *websocket.Conn, config *ConfigMessage, wg *sync.WaitGroup) {
-	wg.Add(1)
 	defer wg.Done()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case text := <-textStream:
+		case text, ok := <-textStream:
+			if !ok {
+				return
+			}
 			if text == "" {
 				continue
 			}
 
 			logx.Infof("LLM输入文本: '%s'", text)
 
-			// 启动流式LLM处理
-			go l.processStreamingLLM(ctx, text, config, conn)
+			// 启动流式LLM处理（新一轮对话，含输入审核）
+			l.processTextToResponse(text, config, conn)
 		}
 	}
 }
 
 // 处理 ASR 结果
-func (l *ChatStreamLogic) handleASRResults(ctx context.Context, asrResults <-chan *provider.Transcript, textStream chan<- string, conn *websocket.Conn, wg *sync.WaitGroup) {
-	wg.Add(1)
+func (l *ChatStreamLogic) handleASRResults(ctx context.Context, r *recognition, asrResults <-chan *provider.Transcript, textStream chan<- string, conn *websocket.Conn, protocol bool, wg *sync.WaitGroup) {
 	defer wg.Done()
+	defer close(textStream)
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case transcript := <-asrResults:
+		case transcript, ok := <-asrResults:
+			if !ok {
+				return
+			}
 			if transcript == nil {
 				continue
 			}
+			if transcript.Err != nil {
+				logx.Errorf("ASR stream recognition failed: %v", transcript.Err)
+				l.sendError(conn, 500, "ASR recognition failed: "+transcript.Err.Error())
+				continue
+			}
 
 			logx.Infof("ASR结果: text='%s', is_final=%v, confidence=%.2f", 
 				transcript.Text, transcript.IsFinal, transcript.Confidence)
 
-			// 发送 ASR 结果给客户端
-			l.sendMessage(conn, &WSMessage{
-				Type: MessageTypeASR,
-				Content: map[string]interface{}{
-					"text":       transcript.Text,
-					"is_final":   transcript.IsFinal,
-					"confidence": transcript.Confidence,
-				},
-				Timestamp: time.Now().Unix(),
-			})
+			// 发送 ASR 结果给客户端（中间结果用于实时字幕）
+			l.sendTranscript(conn, transcript, protocol)
 
 			// 如果是最终结果，发送到文本流进行 LLM 处理
 			if transcript.IsFinal && transcript.Text != "" {
 				logx.Infof("发送到LLM处理: '%s'", transcript.Text)
+				l.markInput(r.inputEnd(), time.Now())
+				// 等待文本流空闲，识别被打断或结束时才放弃
 				select {
 				case textStream <- transcript.Text:
 				case <-ctx.Done():
 					return
-				default:
-					logx.Infof("Text stream buffer full, dropping message: %s", transcript.Text)
 				}
 			}
 		}
 	}
 }
 
-// 处理 TTS 结果
-func (l *ChatStreamLogic) handleTTSResults(ctx context.Context, ttsResults <-chan *provider.AudioChunk, conn *websocket.Conn, wg *sync.WaitGroup) {
-	wg.Add(1)
-	defer wg.Done()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case audioChunk := <-ttsResults:
-			if audioChunk == nil {
-				continue
-			}
-
-			// 发送 TTS 音频结果给客户端
-			l.sendMessage(conn, &WSMessage{
-				Type: MessageTypeTTS,
-				Content: map[string]interface{}{
-					"audio_data": audioChunk.Data,
-					"format":     audioChunk.Format,
-					"seq_num":    audioChunk.SeqNum,
-				},
-				Timestamp: time.Now().Unix(),
-			})
-		}
-	}
-}
-
 // 流式LLM处理 - 支持逐句TTS
-func (l *ChatStreamLogic) processStreamingLLM(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) {
-	llmProvider := config.LLMProvider
-	if llmProvider == "" {
-		llmProvider = "qiniu" // 默认使用七牛云
-	}
-
+func (l *ChatStreamLogic) processStreamingLLM(ctx context.Context, t *turn, text string, config *ConfigMessage, conn *websocket.Conn) {
+	llmProvider, req := l.llmSettings(config)
 	llmProviderInstance, err := l.svcCtx.Registry.GetLLM(llmProvider)
 	if err != nil {
 		logx.Errorf("Failed to get LLM provider %s: %v", llmProvider, err)
-		l.sendError(conn, 500, "LLM provider not available: "+err.Error())
+		l.sendTurnError(conn, t, 500, "LLM provider not available: "+err.Error())
 		return
 	}
 
-	// 构建聊天请求
-	messages := []*provider.Message{
-		{Role: "user", Content: text},
+	// 获取会话并构建包含历史的聊天请求，被打断的上一轮需先记录已播报的内容
+	t.waitPrevious()
+	conv, err := l.ensureConversation(conn, config.RoleID)
+	if err != nil {
+		logx.Errorf("Failed to load conversation: %v", err)
+		l.sendTurnError(conn, t, 500, "Conversation not available: "+err.Error())
+		return
 	}
 
-	if config.Role != "" {
-		messages = []*provider.Message{
-			{Role: "system", Content: config.Role},
-			{Role: "user", Content: text},
-		}
-	}
+	messages := l.buildChatMessages(conv, text, config)
+	l.appendMessage(conv.ID, model.RoleUser, text)
 
 	// 启用流式处理
-	req := &provider.ChatRequest{
-		Model:    "deepseek-v3", // 使用七牛云支持的模型
-		Messages: messages,
-		Stream:   true, // 关键：启用流式处理
-	}
+	req.Messages = messages
+	req.Stream = true // 关键：启用流式处理
+
+	// 调用流式LLM，输出审核拦截时可提前终止生成
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// 调用流式LLM
 	streamChan, err := llmProviderInstance.ChatStream(ctx, req)
 	if err != nil {
 		logx.Errorf("LLM stream call failed: %v", err)
-		l.sendError(conn, 500, "LLM stream processing failed: "+err.Error())
+		l.sendTurnError(conn, t, 500, "LLM stream processing failed: "+err.Error())
 		return
 	}
 
@@ -604,57 +514,122 @@ func (l *ChatStreamLogic) processStreamingLLM(ctx context.Context, text string,
 		accumulatedText = ""
 		sentenceBuffer  = ""
 		isFirstChunk    = true
+		gated           = l.gateOutput(config)
+		completion      strings.Builder // 模型生成的全部文本，用于估算用量
+		reported        *provider.Usage
+		streamErr       error // 生成中途失败
 	)
+	defer func() {
+		l.meterLLM(t, llmProvider, llmProviderInstance, req, reported, completion.String())
+	}()
+
+	// 发送实时流式响应给客户端
+	emit := func(text string) {
+		accumulatedText += text
+		t.markGenerated(text)
+		l.sendTurnMessage(conn, t, &WSMessage{
+			Type: MessageTypeResponse,
+			Content: ResponseFrame{
+				Text:        text,
+				Type:        ResponseTypeLLMStream,
+				Accumulated: accumulatedText,
+				IsFirst:     isFirstChunk,
+			},
+			Timestamp: time.Now().Unix(),
+		})
+		isFirstChunk = false
+	}
+
+	// 完整句子：审核后下发（开启输出审核时）并送入 TTS，返回 false 表示终止生成
+	flush := func(sentence string) bool {
+		t.mark(spanFirstSentence)
+		proceed := true
+		if gated {
+			sentence, proceed = l.checkSentence(ctx, sentence, config, conn)
+			if sentence != "" {
+				emit(sentence)
+			}
+		}
+		if sentence != "" {
+			// 序列化处理TTS，确保音频按顺序播放
+			l.callSequentialTTS(ctx, sentence, config, conn)
+			t.markSpoken(sentence)
+		}
+		return proceed
+	}
 
 	for chunk := range streamChan {
+		if ctx.Err() != nil {
+			break // 被打断或已终止生成
+		}
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			logx.Errorf("LLM stream interrupted: %v", streamErr)
+			l.sendTurnError(conn, t, 500, "LLM stream interrupted: "+streamErr.Error())
+			break
+		}
+		if chunk.Usage != nil {
+			reported = chunk.Usage
+		}
 		if chunk.Text == "" {
 			continue
 		}
 
-		accumulatedText += chunk.Text
+		t.mark(spanLLMFirstToken)
+		completion.WriteString(chunk.Text)
 		sentenceBuffer += chunk.Text
-
-		// 发送实时流式响应给客户端
-		l.sendMessage(conn, &WSMessage{
-			Type: MessageTypeResponse,
-			Content: map[string]interface{}{
-				"text":        chunk.Text,
-				"type":        "llm_stream",
-				"accumulated": accumulatedText,
-				"is_first":    isFirstChunk,
-				"is_done":     false,
-			},
-			Timestamp: time.Now().Unix(),
-		})
-
-		isFirstChunk = false
+		if !gated {
+			emit(chunk.Text)
+		}
 
 		// 检查是否完成了一个句子（以句号、问号、感叹号结尾）
 		if l.isSentenceComplete(sentenceBuffer) {
 			logx.Infof("检测到完整句子，启动TTS: '%s'", sentenceBuffer)
-			
-			// 序列化处理TTS，确保音频按顺序播放
-			l.callSequentialTTS(ctx, sentenceBuffer, config, conn)
-			
+
+			proceed := flush(sentenceBuffer)
 			sentenceBuffer = "" // 清空句子缓冲区
+			if !proceed {
+				logx.Infof("输出审核拦截，终止LLM生成")
+				cancel()
+				break
+			}
 		}
 	}
 
-	// 处理最后可能剩余的文本
-	if sentenceBuffer != "" {
+	// 处理最后可能剩余的文本，生成失败时不合成不完整的句子
+	if sentenceBuffer != "" && ctx.Err() == nil && streamErr == nil {
 		logx.Infof("处理剩余文本TTS: '%s'", sentenceBuffer)
-		l.callSequentialTTS(ctx, sentenceBuffer, config, conn)
+		flush(sentenceBuffer)
+	}
+
+	// 提前终止时排空通道，避免 Provider 协程阻塞
+	go func() {
+		for range streamChan {
+		}
+	}()
+
+	// 被打断或生成失败时只记录用户实际听到的内容，完成标志由 interrupted 帧或 error 帧代替
+	if t.wasInterrupted() || streamErr != nil {
+		if spoken := t.spokenText(); spoken != "" {
+			l.appendMessage(conv.ID, model.RoleAssistant, spoken)
+		}
+		logx.Infof("LLM流式处理未完成，已播报: '%s'", t.spokenText())
+		return
+	}
+
+	// 记录助手回复（仅记录实际下发的内容）
+	if accumulatedText != "" {
+		l.appendMessage(conv.ID, model.RoleAssistant, accumulatedText)
+		l.compactMemory(llmProviderInstance, req.Model, conv.ID)
 	}
 
 	// 发送完成标志
-	l.sendMessage(conn, &WSMessage{
+	l.sendTurnMessage(conn, t, &WSMessage{
 		Type: MessageTypeResponse,
-		Content: map[string]interface{}{
-			"text":        "",
-			"type":        "llm_stream",
-			"accumulated": accumulatedText,
-			"is_first":    false,
-			"is_done":     true,
+		Content: ResponseFrame{
+			Type:        ResponseTypeLLMStream,
+			Accumulated: accumulatedText,
+			IsDone:      true,
 		},
 		Timestamp: time.Now().Unix(),
 	})
@@ -667,25 +642,18 @@ func (l *ChatStreamLogic) isSentenceComplete(text string) bool {
 	if len(text) < 2 {
 		return false
 	}
-	
-	// 检查中文和英文的句子结束符
-	lastChar := text[len(text)-1:]
-	return lastChar == "。" || lastChar == "？" || lastChar == "！" || 
-		   lastChar == "." || lastChar == "?" || lastChar == "!" ||
-		   lastChar == "\n"
+
+	// 检查中文和英文的句子结束符（按字符而非字节比较）
+	lastChar, _ := utf8.DecodeLastRuneInString(text)
+	return lastChar == '。' || lastChar == '？' || lastChar == '！' ||
+		lastChar == '.' || lastChar == '?' || lastChar == '!' ||
+		lastChar == '\n'
 }
 
 // 流式TTS处理
-// callSequentialTTS 序列化TTS处理，确保音频按顺序播放
+// callSequentialTTS 合成一个句子并下发音频块；同一轮内逐句调用，轮次之间由轮次管理保证不交错
 func (l *ChatStreamLogic) callSequentialTTS(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) {
-	// 使用TTS互斥锁确保串行处理
-	l.ttsMutex.Lock()
-	defer l.ttsMutex.Unlock()
-	
-	ttsProvider := config.TTSProvider
-	if ttsProvider == "" {
-		ttsProvider = "qiniu" // 默认使用七牛云
-	}
+	ttsProvider, opts := l.ttsSettings(config)
 
 	ttsProviderInstance, err := l.svcCtx.Registry.GetTTS(ttsProvider)
 	if err != nil {
@@ -698,213 +666,69 @@ func (l *ChatStreamLogic) callSequentialTTS(ctx context.Context, text string, co
 	textStreamChan <- text
 	close(textStreamChan)
 
-	// TTS 选项
-	opts := &provider.TTSOptions{
-		Voice: config.Voice,
-		Speed: config.Speed,
-	}
-	if opts.Voice == "" {
-		opts.Voice = "qiniu_zh_female_wwxkjx"
-	}
-	if opts.Speed == 0 {
-		opts.Speed = 1.0
-	}
-
 	// 调用 TTS
 	audioChunkChan, err := ttsProviderInstance.SynthesizeStream(ctx, textStreamChan, opts)
 	if err != nil {
 		logx.Errorf("TTS stream call failed: %v", err)
 		return
 	}
+	l.meterTTS(ctx, ttsProvider, text)
+	t := turnOf(ctx)
 
-	// 流式发送音频块，使用全局序列号
+	// 流式发送音频块，序列号在轮次内递增
 	for audioChunk := range audioChunkChan {
-		if audioChunk == nil {
+		if audioChunk == nil || ctx.Err() != nil {
+			continue // 被打断后不再下发，继续排空通道
+		}
+		if audioChunk.Err != nil {
+			logx.Errorf("TTS synthesis failed: %v", audioChunk.Err)
 			continue
 		}
-
-		// 获取并递增序列号
-		seqNumber := atomic.AddInt32(&l.ttsSequence, 1)
+		var seqNumber int32
+		if t != nil {
+			t.mark(spanTTSFirstByte)
+			seqNumber = t.nextAudioSeq()
+		}
 
 		logx.Infof("发送TTS音频块: %d bytes, format: %s, seq: %d", 
 			len(audioChunk.Data), audioChunk.Format, seqNumber)
 
 		// 发送音频块给客户端
-		l.sendMessage(conn, &WSMessage{
+		l.sendTurnMessage(conn, t, &WSMessage{
 			Type: MessageTypeTTS,
-			Content: map[string]interface{}{
-				"audio":     base64.StdEncoding.EncodeToString(audioChunk.Data),
-				"format":    audioChunk.Format,
-				"sequence":  seqNumber,
-				"text":      text, // 关联的文本
+			Content: TTSFrame{
+				Audio:    audioChunk.Data,
+				Format:   audioChunk.Format,
+				Sequence: seqNumber,
+				Text:     text, // 关联的文本
 			},
 			Timestamp: time.Now().Unix(),
 		})
+		if t != nil {
+			t.mark(spanFirstAudioSent)
+		}
 	}
 	
 	logx.Infof("TTS序列化处理完成: %s", text)
 }
 
-func (l *ChatStreamLogic) callStreamTTS(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) {
-	ttsProvider := config.TTSProvider
-	if ttsProvider == "" {
-		ttsProvider = "qiniu" // 默认使用七牛云
-	}
-
-	ttsProviderInstance, err := l.svcCtx.Registry.GetTTS(ttsProvider)
-	if err != nil {
-		logx.Errorf("Failed to get TTS provider %s: %v", ttsProvider, err)
-		return
-	}
-
-	// 创建文本流通道
-	textStreamChan := make(chan string, 1)
-	textStreamChan <- text
-	close(textStreamChan)
-
-	// TTS 选项
-	opts := &provider.TTSOptions{
-		Voice: config.Voice,
-		Speed: config.Speed,
-	}
-	if opts.Voice == "" {
-		opts.Voice = "qiniu_zh_female_wwxkjx"
-	}
-	if opts.Speed == 0 {
-		opts.Speed = 1.0
-	}
-
-	// 调用 TTS
-	audioChunkChan, err := ttsProviderInstance.SynthesizeStream(ctx, textStreamChan, opts)
-	if err != nil {
-		logx.Errorf("TTS stream call failed: %v", err)
-		return
-	}
-
-	// 立即流式发送音频块，不等待完整音频
-	for audioChunk := range audioChunkChan {
-		if audioChunk == nil {
-			continue
-		}
-
-		logx.Infof("发送TTS音频块: %d bytes, format: %s, seq: %d", 
-			len(audioChunk.Data), audioChunk.Format, audioChunk.SeqNum)
-
-		l.sendMessage(conn, &WSMessage{
-			Type: MessageTypeTTS,
-			Content: map[string]interface{}{
-				"audio_data": audioChunk.Data,
-				"format":     audioChunk.Format,
-				"seq_num":    audioChunk.SeqNum,
-				"text":       text, // 包含对应的文本
-				"streaming":  true,
-			},
-			Timestamp: time.Now().Unix(),
-		})
-	}
-}
-
 // 调用 LLM
 func (l *ChatStreamLogic) callLLM(ctx context.Context, text string, config *ConfigMessage) (string, error) {
-	llmProvider := config.LLMProvider
-	if llmProvider == "" {
-		llmProvider = "qiniu" // 默认使用七牛云
-	}
-
+	llmProvider, req := l.llmSettings(config)
 	llmProviderInstance, err := l.svcCtx.Registry.GetLLM(llmProvider)
 	if err != nil {
 		return "", err
 	}
 
 	// 构建聊天请求
-	messages := []*provider.Message{
-		{Role: "user", Content: text},
-	}
-
-	if config.Role != "" {
-		messages = []*provider.Message{
-			{Role: "system", Content: config.Role},
-			{Role: "user", Content: text},
-		}
-	}
-
-	req := &provider.ChatRequest{
-		Model:    "deepseek-v3", // 使用七牛云支持的模型
-		Messages: messages,
-		Stream:   false,
-	}
+	req.Messages = l.buildChatMessages(l.currentConversation(), text, config)
 
 	resp, err := llmProviderInstance.Chat(ctx, req)
 	if err != nil {
 		return "", err
 	}
 
-	return resp.Text, nil
-}
-
-// 调用 TTS
-func (l *ChatStreamLogic) callTTS(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) error {
-	ttsProvider := config.TTSProvider
-	if ttsProvider == "" {
-		ttsProvider = "qiniu" // 默认使用七牛云
-	}
-
-	ttsProviderInstance, err := l.svcCtx.Registry.GetTTS(ttsProvider)
-	if err != nil {
-		return err
-	}
-
-	// 创建文本流通道
-	textStreamChan := make(chan string, 1)
-	textStreamChan <- text
-	close(textStreamChan)
-
-	// TTS 选项
-	opts := &provider.TTSOptions{
-		Voice: config.Voice,
-		Speed: config.Speed,
-	}
-	if opts.Voice == "" {
-		opts.Voice = "qiniu_zh_female_wwxkjx"
-	}
-	if opts.Speed == 0 {
-		opts.Speed = 1.0
-	}
-
-	// 调用 TTS
-	audioChunkChan, err := ttsProviderInstance.SynthesizeStream(ctx, textStreamChan, opts)
-	if err != nil {
-		return err
-	}
-
-	// 收集所有音频块并合并
-	go func() {
-		var allAudioData []byte
-		var format string
-		
-		for audioChunk := range audioChunkChan {
-			allAudioData = append(allAudioData, audioChunk.Data...)
-			if format == "" {
-				format = audioChunk.Format
-			}
-		}
-		
-		// 发送合并后的完整音频
-		if len(allAudioData) > 0 {
-			logx.Infof("Sending complete TTS audio: %d bytes, format: %s", len(allAudioData), format)
-			l.sendMessage(conn, &WSMessage{
-				Type: MessageTypeTTS,
-				Content: map[string]interface{}{
-					"audio_data": allAudioData,
-					"format":     format,
-					"complete":   true,
-				},
-				Timestamp: time.Now().Unix(),
-			})
-		}
-	}()
-
-	return nil
+	return l.checkOutput(ctx, resp.Text, config, nil), nil
 }
 
 // 发送消息 - 使用互斥锁确保线程安全
@@ -912,14 +736,19 @@ func (l *ChatStreamLogic) sendMessage(conn *websocket.Conn, msg *WSMessage) {
 	l.wsWriteMutex.Lock()
 	defer l.wsWriteMutex.Unlock()
 	
-	if err := conn.WriteJSON(msg); err != nil {
+	if err := l.writeMessage(conn, msg); err != nil {
 		logx.Errorf("Failed to send WebSocket message: %v", err)
 	}
 }
 
 // 发送错误消息
 func (l *ChatStreamLogic) sendError(conn *websocket.Conn, code int, message string) {
-	l.sendMessage(conn, &WSMessage{
+	l.sendTurnError(conn, nil, code, message)
+}
+
+// 发送对话轮次内的错误消息
+func (l *ChatStreamLogic) sendTurnError(conn *websocket.Conn, t *turn, code int, message string) {
+	l.sendTurnMessage(conn, t, &WSMessage{
 		Type: MessageTypeError,
 		Content: ErrorMessage{
 			Code:    code,
@@ -972,6 +801,9 @@ func (l *ChatStreamLogic) sendASRResponse(conn *websocket.Conn, response interfa
 	responseMsg.Write(compressedData.Bytes())
 
 	// 发送二进制消息
+	l.wsWriteMutex.Lock()
+	defer l.wsWriteMutex.Unlock()
+
 	return conn.WriteMessage(websocket.BinaryMessage, responseMsg.Bytes())
 }
 
@@ -1003,10 +835,10 @@ type ASRProtocolHeader struct {
 	Compress    uint8
 }
 
-// 处理ASR协议的二进制消息
-func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMessage, audioStream chan<- []byte) error {
+// 处理ASR协议的二进制消息，返回是否为最后一包音频
+func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMessage, push func([]byte) error) (bool, error) {
 	if len(data) < 4 {
-		return fmt.Errorf("message too short for ASR protocol header")
+		return false, fmt.Errorf("message too short for ASR protocol header")
 	}
 
 	// 解析协议头（按照七牛云官方协议格式）
@@ -1019,10 +851,12 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 
 	// 解析消息类型特定标志
 	messageFlags := data[1] & 0x0F
+	// 0b0010/0b0011 表示最后一包
+	last := messageFlags&0x02 != 0
 
 	// 验证版本号
 	if header.Version != 1 {
-		return fmt.Errorf("unsupported ASR protocol version: %d", header.Version)
+		return false, fmt.Errorf("unsupported ASR protocol version: %d", header.Version)
 	}
 
 	// 获取头部大小并计算当前读取位置
@@ -1030,14 +864,14 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 	currentPos := headerSize * 4
 
 	if len(data) < currentPos {
-		return fmt.Errorf("message too short for declared header size")
+		return false, fmt.Errorf("message too short for declared header size")
 	}
 
 	// 检查是否有序列号字段
 	hasSequence := (messageFlags & 0x01) != 0
 	if hasSequence {
 		if len(data) < currentPos+4 {
-			return fmt.Errorf("message too short for sequence number")
+			return false, fmt.Errorf("message too short for sequence number")
 		}
 		// 跳过序列号字段（4字节）
 		currentPos += 4
@@ -1045,7 +879,7 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 
 	// 读取负载长度（4字节）
 	if len(data) < currentPos+4 {
-		return fmt.Errorf("message too short for payload length")
+		return false, fmt.Errorf("message too short for payload length")
 	}
 	
 	payloadLength := int(uint32(data[currentPos])<<24 | uint32(data[currentPos+1])<<16 | 
@@ -1054,7 +888,7 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 
 	// 验证负载长度
 	if len(data) < currentPos+payloadLength {
-		return fmt.Errorf("message too short for declared payload length")
+		return false, fmt.Errorf("message too short for declared payload length")
 	}
 
 	// 获取压缩的负载数据
@@ -1066,13 +900,13 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 		// GZIP 解压缩
 		reader, err := gzip.NewReader(bytes.NewReader(compressedPayload))
 		if err != nil {
-			return fmt.Errorf("failed to create gzip reader: %v", err)
+			return false, fmt.Errorf("failed to create gzip reader: %v", err)
 		}
 		defer reader.Close()
 
 		payload, err = io.ReadAll(reader)
 		if err != nil {
-			return fmt.Errorf("failed to decompress message: %v", err)
+			return false, fmt.Errorf("failed to decompress message: %v", err)
 		}
 	} else {
 		payload = compressedPayload
@@ -1081,16 +915,16 @@ func (l *ChatStreamLogic) handleASRProtocolMessage(data []byte, config *ConfigMe
 	// 根据消息类型处理
 	switch header.MessageType {
 	case ASRProtocolFullClientRequest:
-		return l.handleASRFullRequest(payload, config, audioStream)
+		return last, l.handleASRFullRequest(payload, config)
 	case ASRProtocolAudioOnlyRequest:
-		return l.handleASRAudioOnlyRequest(payload, audioStream)
+		return last, l.handleASRAudioOnlyRequest(payload, push)
 	default:
-		return fmt.Errorf("unsupported ASR message type: %d", header.MessageType)
+		return false, fmt.Errorf("unsupported ASR message type: %d", header.MessageType)
 	}
 }
 
 // 处理完整请求消息（包含配置和音频）
-func (l *ChatStreamLogic) handleASRFullRequest(payload []byte, config *ConfigMessage, audioStream chan<- []byte) error {
+func (l *ChatStreamLogic) handleASRFullRequest(payload []byte, config *ConfigMessage) error {
 	// 对于 FULL_CLIENT_REQUEST，负载直接是 JSON 配置
 	var request map[string]interface{}
 	if err := json.Unmarshal(payload, &request); err != nil {
@@ -1151,74 +985,70 @@ func (l *ChatStreamLogic) handleASRFullRequest(payload []byte, config *ConfigMes
 }
 
 // 处理纯音频请求消息
-func (l *ChatStreamLogic) handleASRAudioOnlyRequest(payload []byte, audioStream chan<- []byte) error {
+func (l *ChatStreamLogic) handleASRAudioOnlyRequest(payload []byte, push func([]byte) error) error {
 	// 对于 AUDIO_ONLY_REQUEST，负载直接是音频数据
 	if len(payload) == 0 {
 		return nil // 空音频数据，忽略
 	}
 
 	logx.Infof("Received audio data: %d bytes", len(payload))
-	
-	select {
-	case audioStream <- payload:
-		return nil
-	default:
-		return fmt.Errorf("audio stream buffer full")
-	}
+
+	return push(payload)
 }
 
 // 处理配置消息
-func (l *ChatStreamLogic) handleConfig(msg *WSMessage, config *ConfigMessage) error {
-	configData, ok := msg.Content.(map[string]interface{})
-	if !ok {
+func (l *ChatStreamLogic) handleConfig(msg *ClientMessage, config *ConfigMessage) error {
+	if !msg.hasContent() {
 		return fmt.Errorf("invalid config format")
 	}
 
-	// 将 map 转换为 ConfigMessage
-	configBytes, err := json.Marshal(configData)
-	if err != nil {
+	// 在副本上解码，避免写入已交给处理协程的 Params、Stop 等字段
+	updated := config.clone()
+	if err := msg.decode(updated); err != nil {
 		return err
 	}
 
-	return json.Unmarshal(configBytes, config)
+	if err := l.validateRole(updated); err != nil {
+		return err
+	}
+	if err := l.validateLLMSettings(updated); err != nil {
+		return err
+	}
+	if err := l.validateAudioFormat(updated, l.clientAudioFormats()); err != nil {
+		return err
+	}
+	if err := validateTurnPolicy(updated); err != nil {
+		return err
+	}
+
+	*config = *updated
+	return nil
 }
 
 // 执行ASR识别
-func (l *ChatStreamLogic) performASR(audioData []byte, config *ConfigMessage) (string, error) {
-	// 设置默认ASR提供商
-	asrProviderName := config.ASRProvider
-	if asrProviderName == "" {
-		asrProviderName = "iflytek" // 使用iFlytek作为默认ASR
+func (l *ChatStreamLogic) performASR(audioData []byte, format audio.Format, config *ConfigMessage) (string, error) {
+	// 故障转移由组合 Provider 负责（见配置 Failover.ASR）
+	asrProvider, asrProviderName, err := l.asrProvider(config)
+	if err != nil {
+		return "", fmt.Errorf("no ASR provider available: %v", err)
 	}
-	
-	logx.Infof("Using ASR provider: %s (configured: %s)", asrProviderName, config.ASRProvider)
-	
-	asrProvider, err := l.svcCtx.Registry.GetASR(asrProviderName)
+
+	// 转换为 Provider 接受的输入格式
+	pcm, target, err := audio.Transcode(audioData, format, asrProvider.InputFormats())
 	if err != nil {
-		// 如果指定的provider不可用，尝试备选方案
-		logx.Errorf("ASR provider '%s' not found: %v, trying fallback", asrProviderName, err)
-		
-		// 尝试备选provider
-		fallbackProviders := []string{"iflytek", "qiniu"}
-		for _, fallback := range fallbackProviders {
-			if fallback != asrProviderName {
-				logx.Infof("Trying fallback ASR provider: %s", fallback)
-				asrProvider, err = l.svcCtx.Registry.GetASR(fallback)
-				if err == nil {
-					asrProviderName = fallback
-					break
-				}
-			}
-		}
-		
-		if err != nil {
-			return "", fmt.Errorf("no ASR provider available: %v", err)
-		}
+		return "", err
+	}
+	if len(pcm) == 0 {
+		return "", fmt.Errorf("%w: no audio samples", audio.ErrUnsupportedFormat)
+	}
+	if err := checkAudioDuration(pcm, target, provider.Describe(asrProvider)); err != nil {
+		return "", err
 	}
+	l.meterASR(asrProviderName, audioSeconds(pcm, target))
 
 	// 使用批量识别接口
-	logx.Infof("Calling ASR provider '%s' with %d bytes of audio data", asrProviderName, len(audioData))
-	text, err := asrProvider.Recognize(audioData)
+	logx.Infof("Calling ASR provider '%s' with %d bytes of %s audio (input %d bytes)", asrProviderName, len(pcm), target, len(audioData))
+	text, err := asrProvider.Recognize(pcm)
 	if err != nil {
 		return "", fmt.Errorf("ASR recognition failed: %v", err)
 	}
@@ -1229,19 +1059,37 @@ func (l *ChatStreamLogic) performASR(audioData []byte, config *ConfigMessage) (s
 
 // 处理文本到响应的完整流程（LLM + TTS）
 func (l *ChatStreamLogic) processTextToResponse(text string, config *ConfigMessage, conn *websocket.Conn) {
+	// 开始新一轮对话，按会话策略打断、排队或拒绝，调用LLM获取回复（流式）
+	t, err := l.beginTurn(conn, config)
+	if err != nil {
+		l.sendError(conn, 409, err.Error())
+		return
+	}
+	go l.processLLMStreaming(t, text, config.clone(), conn)
+}
+
+// 处理LLM流式生成
+func (l *ChatStreamLogic) processLLMStreaming(t *turn, text string, config *ConfigMessage, conn *websocket.Conn) {
+	defer l.endTurn(t)
+	defer l.reportTurn(t, conn)
+	defer l.endTurnAudio(t, conn)
+	if !l.waitTurn(t) {
+		return
+	}
+	ctx := t.ctx
+
 	// 发送处理状态
-	l.sendMessage(conn, &WSMessage{
-		Type:      "status",
-		Content:   map[string]interface{}{"status": "processing_llm", "message": "正在生成回复..."},
+	l.sendTurnMessage(conn, t, &WSMessage{
+		Type:      MessageTypeStatus,
+		Content:   StatusFrame{Status: StatusProcessingLLM, Message: "正在生成回复..."},
 		Timestamp: time.Now().Unix(),
 	})
 
-	// 调用LLM获取回复（流式）
-	go l.processLLMStreaming(text, config, conn)
-}
+	// 输入审核（文本与 ASR 结果）
+	text, ok := l.checkInput(ctx, text, config, conn)
+	if !ok {
+		return
+	}
 
-// 处理LLM流式生成
-func (l *ChatStreamLogic) processLLMStreaming(text string, config *ConfigMessage, conn *websocket.Conn) {
-	ctx := context.Background()
-	l.processStreamingLLM(ctx, text, config, conn)
+	l.processStreamingLLM(ctx, t, text, config, conn)
 }
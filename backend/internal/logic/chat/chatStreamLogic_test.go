@@ -0,0 +1,427 @@
+package chat_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestChatStreamConfig(t *testing.T) {
+	h := newHarness(t)
+	c := h.dial()
+
+	conv := c.configure(chat.ConfigMessage{})
+	if conv.ConversationID == "" || conv.Resumed || conv.Messages != 0 {
+		t.Errorf("conversation = %+v, want a new conversation", conv)
+	}
+
+	// 再次配置时沿用当前会话
+	again := c.configure(chat.ConfigMessage{Voice: "fake"})
+	if again.ConversationID != conv.ConversationID || !again.Resumed {
+		t.Errorf("conversation = %+v, want %s resumed", again, conv.ConversationID)
+	}
+}
+
+func TestChatStreamResumeOwnership(t *testing.T) {
+	h := newHarness(t)
+	owned := h.dialAs("key-a").configure(chat.ConfigMessage{}).ConversationID
+
+	cases := []struct {
+		name   string
+		apiKey string
+		id     string
+		found  bool
+	}{
+		{"same tenant", "key-a", owned, true},
+		{"other tenant", "key-b", owned, false},
+		{"anonymous", "", owned, false},
+		// 不存在的 ID 不会被创建
+		{"unknown id", "key-a", "missing", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := h.dialAs(tc.apiKey)
+			c.send(chat.MessageTypeConfig, chat.ConfigMessage{ConversationID: tc.id})
+			c.expect(chat.MessageTypeConfigUpdated)
+			if !tc.found {
+				c.expectError(404, "not found")
+				return
+			}
+			if conv := c.expect(chat.MessageTypeConversation).Content.(chat.ConversationFrame); conv.ConversationID != tc.id || !conv.Resumed {
+				t.Errorf("conversation = %+v, want %s resumed", conv, tc.id)
+			}
+		})
+	}
+
+	if _, err := h.store.Get(context.Background(), "missing"); err == nil {
+		t.Errorf("unknown conversation id was created")
+	}
+}
+
+func TestChatStreamTextTurn(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(
+		providertest.Reply("你好，", "我是哈利。", "很高兴", "认识你！"),
+		providertest.Reply("再见。"),
+	)
+	c := h.dial()
+	c.configure(chat.ConfigMessage{})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "你是谁？"})
+	frames := c.until(model.FrameTypeMeta)
+
+	// 每个完整句子先下发文本、再下发音频，完成帧在全部音频之后，meta 帧结束本轮
+	want := []string{
+		"status:processing_llm",
+		"response", "response", "tts",
+		"response", "response", "tts",
+		"response:done",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+
+	turn := collectTurn(frames)
+	if text := turn.text(); text != "你好，我是哈利。很高兴认识你！" {
+		t.Errorf("text = %q", text)
+	}
+	accumulated := ""
+	for i, r := range turn.responses {
+		accumulated += r.Text
+		if r.Type != chat.ResponseTypeLLMStream || r.Accumulated != accumulated || r.IsFirst != (i == 0) {
+			t.Errorf("response %d = %+v", i, r)
+		}
+	}
+	for _, sentence := range []string{"你好，我是哈利。", "很高兴认识你！"} {
+		if audio := turn.audio(sentence); !bytes.Equal(audio, providertest.AudioFor(sentence)) {
+			t.Errorf("audio for %q = %q", sentence, audio)
+		}
+	}
+	for i, f := range turn.tts {
+		if f.Sequence != int32(i+1) || f.Format != "pcm" {
+			t.Errorf("tts %d: sequence = %d, format = %s", i, f.Sequence, f.Format)
+		}
+	}
+
+	meta := frames[len(frames)-1].Content.(model.MetaFrame)
+	// 轮次 ID 是连接内从 1 开始的计数
+	if meta.TurnID != 1 || meta.Timing == nil || meta.Timing.InputEnd == 0 || meta.Timing.FirstAudioSent < meta.Timing.LLMFirstToken {
+		t.Errorf("meta = %+v, timing = %+v", meta, meta.Timing)
+	}
+	// 各节点耗时同时计入进程内直方图
+	if s := h.latency.Summaries()["first_audio_sent"]; s.Count != 1 {
+		t.Errorf("first_audio_sent latency = %+v", s)
+	}
+
+	// 第二轮：音频序号在轮次内重新从 1 开始，LLM 收到上一轮的历史
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再见"})
+	second := c.until(model.FrameTypeMeta)
+	if tts := collectTurn(second).tts; len(tts) == 0 || tts[0].Sequence != 1 {
+		t.Errorf("second turn tts = %+v, want sequences starting from 1", tts)
+	}
+	if next := second[len(second)-1].Content.(model.MetaFrame); next.TurnID != meta.TurnID+1 {
+		t.Errorf("second turn id = %d, first = %d", next.TurnID, meta.TurnID)
+	}
+	requests := h.llm.Requests()
+	if history := requests[1].Messages; len(history) < 3 || history[len(history)-2].Content != "你好，我是哈利。很高兴认识你！" {
+		t.Errorf("second request messages = %+v", history)
+	}
+
+	// 新连接的轮次 ID 重新从 1 开始
+	other := h.dial()
+	other.configure(chat.ConfigMessage{})
+	h.llm.Enqueue(providertest.Reply("好。"))
+	other.send(chat.MessageTypeText, chat.TextMessage{Content: "你好"})
+	otherFrames := other.until(model.FrameTypeMeta)
+	if id := otherFrames[len(otherFrames)-1].Content.(model.MetaFrame).TurnID; id != 1 {
+		t.Errorf("first turn id on a new connection = %d", id)
+	}
+}
+
+func TestChatStreamAudioFile(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("讲个故事"))
+	h.llm.Enqueue(providertest.Reply("从前有座山。"))
+	c := h.dial()
+
+	pcm := bytes.Repeat([]byte{1, 0}, 1600)
+	c.send(chat.MessageTypeAudioFile, map[string]interface{}{"audio_data": pcm, "sample_rate": 16000})
+	frames := c.until(model.FrameTypeMeta)
+
+	// 未发送配置时，首轮回复前创建会话
+	want := []string{
+		"status:processing_audio",
+		"asr_result",
+		"status:processing_llm",
+		"conversation",
+		"response", "tts",
+		"response:done",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	if result := frames[1].Content.(chat.ASRResultFrame); result.Text != "讲个故事" {
+		t.Errorf("asr_result = %+v", result)
+	}
+	if received := h.asr.Audio(); len(received) != 1 || !bytes.Equal(received[0], pcm) {
+		t.Errorf("ASR did not receive the uploaded audio")
+	}
+	if meta := frames[len(frames)-1].Content.(model.MetaFrame); meta.Timing.ASRFinal == 0 {
+		t.Errorf("timing = %+v, want asrFinal", meta.Timing)
+	}
+}
+
+func TestChatStreamLiveAudio(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("讲个故事", "讲", "讲个", "讲个故"))
+	h.llm.Enqueue(providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{Params: map[string]string{"vad": "off"}})
+
+	c.send(chat.MessageTypeStartAudio, chat.AudioFormat{SampleRate: "16000"})
+	if status := c.expect(chat.MessageTypeStatus).Content.(chat.StatusFrame); status.Status != chat.StatusListening || status.Streaming == nil || !*status.Streaming {
+		t.Fatalf("status = %+v", status)
+	}
+
+	// audio_chunk 与二进制消息可以混用
+	chunk := bytes.Repeat([]byte{1, 0}, 320)
+	c.send(chat.MessageTypeAudioChunk, chat.AudioChunkMessage{AudioData: chunk})
+	c.sendRaw(websocket.BinaryMessage, chunk)
+	c.send(chat.MessageTypeAudioChunk, map[string]interface{}{"audio_data": []int{1, 0, 1, 0}})
+	c.send(chat.MessageTypeEndAudio, nil)
+
+	frames := c.until(model.FrameTypeMeta)
+	want := []string{
+		"asr", "asr", "asr", "asr",
+		"status:processing_llm",
+		"response", "tts",
+		"response:done",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+
+	var transcripts []string
+	for i, f := range frames[:4] {
+		transcript := f.Content.(chat.TranscriptFrame)
+		if transcript.IsFinal != (i == 3) {
+			t.Errorf("transcript %d = %+v", i, transcript)
+		}
+		transcripts = append(transcripts, transcript.Text)
+	}
+	if !slices.Equal(transcripts, []string{"讲", "讲个", "讲个故", "讲个故事"}) {
+		t.Errorf("transcripts = %q", transcripts)
+	}
+	if received := h.asr.Audio(); len(received) != 1 || len(received[0]) != 2*len(chunk)+4 {
+		t.Errorf("ASR did not receive every chunk")
+	}
+}
+
+func TestChatStreamSlowASRKeepsAudio(t *testing.T) {
+	h := newHarness(t)
+	hold := make(chan struct{})
+	h.asr.Enqueue(providertest.ASRResult{Final: "讲个故事", Hold: hold})
+	h.llm.Enqueue(providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{Params: map[string]string{"vad": "off"}})
+
+	c.send(chat.MessageTypeStartAudio, chat.AudioFormat{SampleRate: "16000"})
+	c.expect(chat.MessageTypeStatus)
+
+	// ASR 未读取音频时送入超过缓冲的音频块：每块要么送达 ASR，要么以错误帧拒绝，不能静默丢弃
+	const chunks = 250
+	chunk := bytes.Repeat([]byte{1, 0}, 32)
+	for i := 0; i < chunks; i++ {
+		c.sendRaw(websocket.BinaryMessage, chunk)
+	}
+	// 以错误帧作为同步点，之前的音频块都已被服务端处理
+	c.send(chat.MessageTypeAudioChunk, chat.AudioChunkMessage{})
+	rejected := 0
+	for {
+		e := c.expect(chat.MessageTypeError).Content.(chat.ErrorMessage)
+		if strings.Contains(e.Message, "empty audio data") {
+			break
+		}
+		if !strings.Contains(e.Message, "audio stream buffer full") {
+			t.Fatalf("error = %+v", e)
+		}
+		rejected++
+	}
+
+	close(hold)
+	c.send(chat.MessageTypeEndAudio, nil)
+	frames := c.until(model.FrameTypeMeta)
+	if text := collectTurn(frames).text(); text != "好的。" {
+		t.Errorf("text = %q", text)
+	}
+	want := (chunks - rejected) * len(chunk)
+	if received := h.asr.Audio(); len(received) != 1 || len(received[0]) != want {
+		t.Errorf("ASR received %d bytes, want %d (%d chunks rejected)", len(received[0]), want, rejected)
+	}
+}
+
+func TestChatStreamInterrupt(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(providertest.LLMReply{
+		Chunks: []string{"让我想想，", "这个问题", "很有意思。"},
+		Delay:  200 * time.Millisecond,
+	})
+	c := h.dial()
+	c.configure(chat.ConfigMessage{})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	c.expect(chat.MessageTypeStatus)
+	first := c.expect(chat.MessageTypeResponse).Content.(chat.ResponseFrame)
+
+	c.send(chat.MessageTypeInterrupt, nil)
+	interrupted := c.expect(chat.MessageTypeInterrupted).Content.(chat.InterruptedFrame)
+	if interrupted.TurnID == 0 || interrupted.Reason != "client" || interrupted.SpokenText != "" || interrupted.GeneratedChars != len([]rune(first.Text)) {
+		t.Errorf("interrupted = %+v", interrupted)
+	}
+
+	// 被打断的轮次不再下发文本、音频与完成帧，只以 meta 帧结束
+	if meta := c.expect(model.FrameTypeMeta).Content.(model.MetaFrame); meta.TurnID != interrupted.TurnID {
+		t.Errorf("meta turn id = %d, want %d", meta.TurnID, interrupted.TurnID)
+	}
+
+	// 没有进行中的回复时，interrupt 不产生任何帧
+	h.llm.Enqueue(providertest.Reply("好。"))
+	c.send(chat.MessageTypeInterrupt, nil)
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "继续"})
+	c.expect(chat.MessageTypeStatus)
+}
+
+func TestChatStreamInterruptHistory(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(providertest.LLMReply{
+		Chunks: []string{"你好。", "这个问题", "很有意思。"},
+		Delay:  100 * time.Millisecond,
+	}, providertest.Reply("好的。"))
+	c := h.dial()
+	conv := c.configure(chat.ConfigMessage{})
+
+	// 第一句播报完成后打断，并立即开始新一轮
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	for {
+		if r, ok := c.next().Content.(chat.ResponseFrame); ok && r.Text == "这个问题" {
+			break
+		}
+	}
+	c.send(chat.MessageTypeInterrupt, nil)
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "讲个笑话"})
+	c.until(model.FrameTypeMeta)
+	c.until(model.FrameTypeMeta)
+
+	// 被打断的回复先于下一轮的输入写入历史，下一轮的请求也能看到它
+	want := []string{"user:宇宙有多大？", "assistant:你好。", "user:讲个笑话", "assistant:好的。"}
+	if got := h.history(conv.ConversationID); !slices.Equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	var sent []string
+	for _, msg := range h.llm.Requests()[1].Messages {
+		sent = append(sent, msg.Role+":"+msg.Content)
+	}
+	if !slices.Equal(sent, want[:3]) {
+		t.Errorf("second request = %q, want %q", sent, want[:3])
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.dial()
+
+	cases := []struct {
+		name     string
+		send     func()
+		before   string // error 帧之前的帧
+		code     int
+		contains string
+	}{
+		{"invalid json", func() { c.sendRaw(websocket.TextMessage, []byte("{")) }, "", 400, "Invalid JSON"},
+		{"unknown type", func() { c.send("user_message", nil) }, "", 400, "Unknown message type: user_message"},
+		{"config without content", func() { c.send(chat.MessageTypeConfig, nil) }, "", 400, "invalid config format"},
+		{"invalid config", func() { c.send(chat.MessageTypeConfig, map[string]interface{}{"temperature": 5}) }, "", 400, "temperature"},
+		{"mistyped config", func() { c.send(chat.MessageTypeConfig, map[string]interface{}{"voice": 1}) }, "", 400, "invalid config content"},
+		{"invalid turn policy", func() { c.send(chat.MessageTypeConfig, chat.ConfigMessage{TurnPolicy: "fifo"}) }, "", 400, "invalid turnPolicy 'fifo'"},
+		{"empty text", func() { c.send(chat.MessageTypeText, chat.TextMessage{}) }, "", 400, "Empty text content"},
+		{"mistyped text", func() { c.send(chat.MessageTypeText, "你好") }, "", 400, "invalid text content"},
+		{"audio file without audio", func() { c.send(chat.MessageTypeAudioFile, map[string]interface{}{"format": "pcm"}) }, "", 400, "Missing audio data"},
+		{"invalid base64", func() { c.send(chat.MessageTypeAudioFile, map[string]interface{}{"audio_data": "???"}) }, "", 400, "base64"},
+		{"compressed audio", func() {
+			c.send(chat.MessageTypeAudioFile, map[string]interface{}{"audio_data": []byte{1, 2}, "format": "mp3"})
+		}, chat.MessageTypeStatus, 400, "mp3"},
+		{"chunk before start", func() { c.send(chat.MessageTypeAudioChunk, chat.AudioChunkMessage{AudioData: []byte{1, 0}}) }, "", 400, "send start_audio first"},
+		{"end before start", func() { c.send(chat.MessageTypeEndAudio, nil) }, "", 400, "no active audio stream"},
+		{"empty binary", func() { c.sendRaw(websocket.BinaryMessage, nil) }, "", 400, "Empty binary audio data"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c.t = t
+			tc.send()
+			if tc.before != "" {
+				c.expect(tc.before)
+			}
+			c.expectError(tc.code, tc.contains)
+		})
+	}
+
+	// 错误不会关闭连接，也不会改变会话配置
+	c.t = t
+	h.llm.Enqueue(providertest.Reply("还在。"))
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "还在吗？"})
+	frames := c.until(model.FrameTypeMeta)
+	if text := collectTurn(frames).text(); text != "还在。" {
+		t.Errorf("text = %q", text)
+	}
+	if temperature := h.llm.Requests()[0].Temperature; temperature != nil {
+		t.Errorf("temperature = %v, want the rejected config to be discarded", *temperature)
+	}
+}
+
+func TestChatStreamProviderErrors(t *testing.T) {
+	h := newHarness(t)
+	c := h.dial()
+	conv := c.configure(chat.ConfigMessage{})
+
+	// LLM 调用失败：error 帧后仍以 meta 帧结束本轮
+	h.llm.Enqueue(providertest.LLMReply{Err: errors.New("503")})
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "你好"})
+	frames := c.until(model.FrameTypeMeta)
+	if got, want := outline(frames), []string{"status:processing_llm", "error", "meta"}; !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	if e := frames[1].Content.(chat.ErrorMessage); e.Code != 500 {
+		t.Errorf("error = %+v", e)
+	}
+
+	// 流中途失败：已生成的句子照常播报，随后以 error 帧代替完成帧，历史只记录已播报的句子
+	h.llm.Enqueue(providertest.LLMReply{Chunks: []string{"你好。", "我是"}, StreamErr: errors.New("connection reset")})
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "你好"})
+	frames = c.until(model.FrameTypeMeta)
+	want := []string{"status:processing_llm", "response", "tts", "response", "error", "meta"}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	history := []string{"user:你好", "user:你好", "assistant:你好。"}
+	if got := h.history(conv.ConversationID); !slices.Equal(got, history) {
+		t.Errorf("history = %q, want %q", got, history)
+	}
+
+	// ASR 失败
+	h.asr.Enqueue(providertest.ASRResult{Err: errors.New("engine busy")})
+	c.send(chat.MessageTypeAudioFile, chat.AudioChunkMessage{AudioData: bytes.Repeat([]byte{1, 0}, 1600)})
+	c.expect(chat.MessageTypeStatus)
+	c.expectError(500, "engine busy")
+}
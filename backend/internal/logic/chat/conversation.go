@@ -0,0 +1,133 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 开始或恢复会话，并通知客户端当前会话 ID
+func (l *ChatStreamLogic) startConversation(conn *websocket.Conn, id, roleID string) (*model.Conversation, error) {
+	l.convMutex.Lock()
+	defer l.convMutex.Unlock()
+
+	if id == "" {
+		id = l.conversationID
+	}
+
+	conv, resumed, err := conversation.Resume(l.ctx, l.svcCtx.Conversations, id, l.tenant, roleID)
+	if err != nil {
+		return nil, err
+	}
+	l.conversationID = conv.ID
+
+	logx.Infof("Conversation %s ready (resumed: %v, messages: %d)", conv.ID, resumed, len(conv.Messages))
+
+	l.sendMessage(conn, &WSMessage{
+		Type: MessageTypeConversation,
+		Content: ConversationFrame{
+			ConversationID: conv.ID,
+			Resumed:        resumed,
+			Messages:       len(conv.Messages),
+		},
+		Timestamp: time.Now().Unix(),
+	})
+
+	return conv, nil
+}
+
+// 获取当前会话，尚未建立或已被删除时创建新会话
+func (l *ChatStreamLogic) ensureConversation(conn *websocket.Conn, roleID string) (*model.Conversation, error) {
+	l.convMutex.Lock()
+	id := l.conversationID
+	l.convMutex.Unlock()
+
+	if id != "" {
+		conv, err := l.svcCtx.Conversations.Get(l.ctx, id)
+		if err == nil {
+			return conv, nil
+		}
+		if !errors.Is(err, conversation.ErrNotFound) {
+			return nil, err
+		}
+
+		l.convMutex.Lock()
+		if l.conversationID == id {
+			l.conversationID = ""
+		}
+		l.convMutex.Unlock()
+	}
+
+	return l.startConversation(conn, "", roleID)
+}
+
+// 读取当前会话，未建立会话时返回空
+func (l *ChatStreamLogic) currentConversation() *model.Conversation {
+	l.convMutex.Lock()
+	id := l.conversationID
+	l.convMutex.Unlock()
+
+	if id == "" {
+		return nil
+	}
+
+	conv, err := l.svcCtx.Conversations.Get(l.ctx, id)
+	if err != nil {
+		logx.Errorf("Failed to load conversation %s: %v", id, err)
+		return nil
+	}
+
+	return conv
+}
+
+// 向会话追加一条消息
+func (l *ChatStreamLogic) appendMessage(conversationID, role, content string) {
+	msg := &model.Message{
+		Role:      role,
+		Content:   content,
+		Timestamp: time.Now(),
+	}
+
+	if err := l.svcCtx.Conversations.Append(l.ctx, conversationID, msg); err != nil {
+		logx.Errorf("Failed to append %s message to conversation %s: %v", role, conversationID, err)
+	}
+}
+
+// 后台压缩会话记忆，不阻塞当前轮次
+func (l *ChatStreamLogic) compactMemory(llm provider.LLMProvider, modelName, conversationID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		if err := l.svcCtx.Memory.Compact(ctx, llm, modelName, conversationID); err != nil {
+			logx.Errorf("Failed to compact memory of conversation %s: %v", conversationID, err)
+		}
+	}()
+}
+
+// 构建发送给 LLM 的消息列表：系统提示（含记忆摘要）+ 近期消息 + 本轮用户输入
+func (l *ChatStreamLogic) buildChatMessages(conv *model.Conversation, text string, config *ConfigMessage) []*provider.Message {
+	var history []*model.Message
+	if conv != nil {
+		history = l.svcCtx.Memory.Recent(conv)
+	}
+
+	messages := make([]*provider.Message, 0, len(history)+2)
+
+	if systemPrompt := l.svcCtx.Memory.SystemPrompt(l.rolePrompt(config), conv); systemPrompt != "" {
+		messages = append(messages, &provider.Message{Role: model.RoleSystem, Content: systemPrompt})
+	}
+
+	for _, msg := range history {
+		messages = append(messages, &provider.Message{Role: msg.Role, Content: msg.Content})
+	}
+
+	return append(messages, &provider.Message{Role: model.RoleUser, Content: text})
+}
@@ -0,0 +1,390 @@
+package chat
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/jsonschema"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+// /v1/chat/stream 的帧结构：每个 type 对应一个 content 结构，Frames 列出全部对应关系，
+// 已发布的 JSON Schema（api/chat-stream.schema.json）由 ProtocolSchema 生成。
+
+const (
+	MessageTypeWelcome       = "welcome"
+	MessageTypeConfigUpdated = "config_updated"
+	MessageTypeStatus        = "status"
+)
+
+// status 帧的状态
+const (
+	StatusListening       = "listening"
+	StatusProcessingAudio = "processing_audio"
+	StatusProcessingLLM   = "processing_llm"
+	StatusQueued          = "queued" // queue 策略下等待上一轮回复结束
+)
+
+// response 帧的类型
+const ResponseTypeLLMStream = "llm_stream"
+
+// WebSocket 消息结构（v1），v2 连接发送前转换为 model.WSFrame
+type WSMessage struct {
+	Type      string      `json:"type"`
+	Seq       int         `json:"seq,omitempty"`
+	Content   interface{} `json:"content,omitempty"`
+	Timestamp int64       `json:"timestamp,omitempty"`
+	// 所属对话轮次，轮次外的帧为 0 并省略
+	TurnID int64 `json:"turnId,omitempty"`
+}
+
+// 客户端消息，content 按 type 解码为对应的帧结构
+type ClientMessage struct {
+	Type      string          `json:"type"`
+	Seq       int             `json:"seq,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	Timestamp int64           `json:"timestamp,omitempty"`
+}
+
+// 解码 content，content 缺省时 v 保持不变
+func (m *ClientMessage) decode(v interface{}) error {
+	if !m.hasContent() {
+		return nil
+	}
+	if err := json.Unmarshal(m.Content, v); err != nil {
+		return fmt.Errorf("invalid %s content: %v", m.Type, err)
+	}
+	return nil
+}
+
+func (m *ClientMessage) hasContent() bool {
+	return len(m.Content) > 0 && string(m.Content) != "null"
+}
+
+// AudioData 音频数据：base64 字符串或字节数组（[1, 2, 3]）
+type AudioData []byte
+
+func (d *AudioData) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err == nil {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("failed to decode base64 audio data: %v", err)
+		}
+		*d = decoded
+		return nil
+	}
+
+	var values []int
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("unsupported audio data, expected a base64 string or a byte array")
+	}
+	decoded := make([]byte, len(values))
+	for i, v := range values {
+		if v < 0 || v > 255 {
+			return fmt.Errorf("invalid byte value %d in audio data at index %d", v, i)
+		}
+		decoded[i] = byte(v)
+	}
+	*d = decoded
+	return nil
+}
+
+func (AudioData) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{OneOf: []*jsonschema.Schema{
+		{Type: "string", ContentEncoding: "base64"},
+		{Type: "array", Items: &jsonschema.Schema{Type: "integer"}},
+	}}
+}
+
+// ---- 客户端 → 服务端 ----
+
+// hello：协商协议版本，必须是连接上的第一条消息
+type HelloMessage struct {
+	Version int `json:"version"`
+	// 客户端可播放的音频格式，如 mp3、pcm
+	AudioFormats []string `json:"audioFormats,omitempty"`
+	// 希望启用的特性，见 Feature*
+	Features []string `json:"features,omitempty"`
+}
+
+// 配置消息
+type ConfigMessage struct {
+	LLMProvider string            `json:"llmProvider,omitempty"`
+	ASRProvider string            `json:"asrProvider,omitempty"`
+	TTSProvider string            `json:"ttsProvider,omitempty"`
+	Voice       string            `json:"voice,omitempty"`
+	Speed       float64           `json:"speed,omitempty"`
+	Role        string            `json:"role,omitempty"`
+	Params      map[string]string `json:"params,omitempty"`
+	// 服务端角色 ID，指定后由服务端决定系统提示、音色与守则
+	RoleID string `json:"roleId,omitempty"`
+	// 会话 ID，为空时创建新会话，否则恢复已有会话
+	ConversationID string `json:"conversationId,omitempty"`
+	// 模型与采样参数，未设置时依次使用角色的 llmDefault 与配置的 Defaults
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	// 回复进行中收到新输入时的处理方式：supersede（默认）、queue 或 reject
+	TurnPolicy string `json:"turnPolicy,omitempty"`
+}
+
+// clone 深拷贝配置。消息循环随 config 消息更新配置，交给处理协程的配置须为副本
+func (c *ConfigMessage) clone() *ConfigMessage {
+	cfg := *c
+	cfg.Params = maps.Clone(c.Params)
+	cfg.Stop = slices.Clone(c.Stop)
+	cfg.Temperature = clonePtr(c.Temperature)
+	cfg.TopP = clonePtr(c.TopP)
+	cfg.Seed = clonePtr(c.Seed)
+	return &cfg
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// 文本消息
+type TextMessage struct {
+	Content string `json:"content"`
+	Role    string `json:"role,omitempty"`
+}
+
+// 裸 PCM 的音频格式，未设置的字段使用会话参数 audio_format / sample_rate / bits / channels
+type AudioFormat struct {
+	Format     string      `json:"format,omitempty"`
+	SampleRate json.Number `json:"sample_rate,omitempty"`
+	Bits       json.Number `json:"bits,omitempty"`
+	Channels   json.Number `json:"channels,omitempty"`
+}
+
+// audio / audio_file：一段完整的录音
+type AudioFileMessage struct {
+	AudioData AudioData `json:"audio_data,omitempty"`
+	// 兼容旧客户端的字段名
+	Data         AudioData `json:"data,omitempty"`
+	AudioDataAlt AudioData `json:"audioData,omitempty"`
+	Audio        AudioData `json:"audio,omitempty"`
+	AudioFormat
+}
+
+// 音频数据，依次尝试 audio_data、data、audioData、audio
+func (m *AudioFileMessage) audio() []byte {
+	for _, data := range []AudioData{m.AudioData, m.Data, m.AudioDataAlt, m.Audio} {
+		if len(data) > 0 {
+			return data
+		}
+	}
+	return nil
+}
+
+// audio_chunk：实时音频流中的一段音频，也可以直接发送二进制消息
+type AudioChunkMessage struct {
+	AudioData AudioData `json:"audio_data"`
+	AudioFormat
+}
+
+// ---- 服务端 → 客户端 ----
+
+// hello：协商结果
+type HelloFrame struct {
+	Version int `json:"version"`
+	// 客户端声明的格式中服务端能输出的部分，客户端未声明时为服务端能输出的全部格式
+	AudioFormats []string `json:"audioFormats"`
+	Features     []string `json:"features"`
+}
+
+// status：处理进度
+type StatusFrame struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// 仅 listening 状态：ASR 是否会返回中间结果
+	Streaming *bool `json:"streaming,omitempty"`
+}
+
+// asr_result：整段录音的识别结果
+type ASRResultFrame struct {
+	Text string `json:"text"`
+}
+
+// asr：实时音频流的识别结果，is_final 为 false 时为中间结果
+type TranscriptFrame struct {
+	Text       string  `json:"text"`
+	IsFinal    bool    `json:"is_final"`
+	Confidence float64 `json:"confidence"`
+}
+
+// speech_start / speech_end：端点检测事件，offsetMs 为相对音频流开始的时间
+type SpeechEventFrame struct {
+	OffsetMs int64 `json:"offsetMs"`
+}
+
+// response：LLM 回复的增量文本，is_done 为 true 的帧表示本轮回复结束
+type ResponseFrame struct {
+	Text        string `json:"text"`
+	Type        string `json:"type"`
+	Accumulated string `json:"accumulated"`
+	IsFirst     bool   `json:"is_first"`
+	IsDone      bool   `json:"is_done"`
+}
+
+// tts：一个句子的合成音频块，sequence 在轮次内从 1 开始递增，客户端按序播放
+type TTSFrame struct {
+	Audio    []byte `json:"audio"`
+	Format   string `json:"format"`
+	Sequence int32  `json:"sequence"`
+	Text     string `json:"text"`
+}
+
+// conversation：当前会话
+type ConversationFrame struct {
+	ConversationID string `json:"conversationId"`
+	Resumed        bool   `json:"resumed"`
+	Messages       int    `json:"messages"`
+}
+
+// interrupted：进行中的回复被打断
+type InterruptedFrame struct {
+	TurnID         int64  `json:"turnId"`
+	Reason         string `json:"reason"`
+	SpokenText     string `json:"spokenText"`
+	SpokenChars    int    `json:"spokenChars"`
+	GeneratedChars int    `json:"generatedChars"`
+}
+
+// 错误消息
+type ErrorMessage struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// FrameSpec 协议中的一种帧
+type FrameSpec struct {
+	Type        string
+	Description string
+	// content 的零值，nil 表示没有 content
+	Content interface{}
+	// content 可以省略
+	Optional bool
+}
+
+// Frames 指定协议版本下客户端与服务端的全部帧，客户端帧两个版本相同
+func Frames(version int) (client, server []FrameSpec) {
+	client = []FrameSpec{
+		{Type: MessageTypeHello, Description: "协商协议版本，只能作为第一条消息", Content: HelloMessage{}},
+		{Type: MessageTypeConfig, Description: "更新会话配置，可随时发送", Content: ConfigMessage{}},
+		{Type: MessageTypeText, Description: "文本输入", Content: TextMessage{}},
+		{Type: MessageTypeAudio, Description: "完整录音，同 audio_file", Content: AudioFileMessage{}},
+		{Type: MessageTypeAudioFile, Description: "完整录音", Content: AudioFileMessage{}},
+		{Type: MessageTypeStartAudio, Description: "开始实时音频流，可声明裸 PCM 的格式", Content: AudioFormat{}, Optional: true},
+		{Type: MessageTypeAudioChunk, Description: "实时音频流中的一段音频", Content: AudioChunkMessage{}},
+		{Type: MessageTypeEndAudio, Description: "结束实时音频流"},
+		{Type: MessageTypeInterrupt, Description: "打断当前回复"},
+	}
+
+	if version == ProtocolV2 {
+		server = []FrameSpec{
+			{Type: MessageTypeHello, Description: "协商结果", Content: HelloFrame{}},
+			{Type: MessageTypeConfigUpdated, Description: "配置已更新，随后下发 conversation", Content: ""},
+			{Type: MessageTypeConversation, Description: "当前会话", Content: ConversationFrame{}},
+			{Type: MessageTypeStatus, Description: "处理进度", Content: StatusFrame{}},
+			{Type: MessageTypeASRResult, Description: "整段录音的识别结果", Content: ASRResultFrame{}},
+			{Type: MessageTypeASR, Description: "实时音频流的识别结果，中间结果需启用 partial_transcripts", Content: TranscriptFrame{}},
+			{Type: MessageTypeSpeechStart, Description: "检测到开始说话，需启用 speech_events", Content: SpeechEventFrame{}},
+			{Type: MessageTypeSpeechEnd, Description: "检测到说话结束，需启用 speech_events", Content: SpeechEventFrame{}},
+			{Type: model.FrameTypeTextDelta, Description: "LLM 回复的增量文本", Content: model.TextDeltaFrame{}},
+			{Type: model.FrameTypeAudioChunk, Description: "合成音频块", Content: model.AudioChunkFrame{}},
+			{Type: model.FrameTypeEnd, Description: "回复文本结束，text 为完整回复", Content: model.EndFrame{}},
+			{Type: MessageTypeInterrupted, Description: "当前回复被打断", Content: InterruptedFrame{}},
+			{Type: model.FrameTypeMeta, Description: "审核告警，或一轮结束时的用量与各阶段时间点，需启用 meta", Content: model.MetaFrame{}},
+			{Type: model.FrameTypeError, Description: "错误，错误不会关闭连接", Content: model.ErrorFrame{}},
+		}
+		return client, server
+	}
+
+	server = []FrameSpec{
+		{Type: MessageTypeWelcome, Description: "连接建立", Content: ""},
+		{Type: MessageTypeHello, Description: "协商结果，hello 请求的版本为 1 时以 v1 下发", Content: HelloFrame{}},
+		{Type: MessageTypeConfigUpdated, Description: "配置已更新，随后下发 conversation", Content: ""},
+		{Type: MessageTypeConversation, Description: "当前会话", Content: ConversationFrame{}},
+		{Type: MessageTypeStatus, Description: "处理进度", Content: StatusFrame{}},
+		{Type: MessageTypeASRResult, Description: "整段录音的识别结果", Content: ASRResultFrame{}},
+		{Type: MessageTypeASR, Description: "实时音频流的识别结果", Content: TranscriptFrame{}},
+		{Type: MessageTypeSpeechStart, Description: "检测到开始说话", Content: SpeechEventFrame{}},
+		{Type: MessageTypeSpeechEnd, Description: "检测到说话结束", Content: SpeechEventFrame{}},
+		{Type: MessageTypeResponse, Description: "LLM 回复的增量文本", Content: ResponseFrame{}},
+		{Type: MessageTypeTTS, Description: "合成音频块", Content: TTSFrame{}},
+		{Type: MessageTypeInterrupted, Description: "当前回复被打断", Content: InterruptedFrame{}},
+		{Type: model.FrameTypeMeta, Description: "审核告警，或一轮结束时的用量与各阶段时间点", Content: model.MetaFrame{}},
+		{Type: MessageTypeError, Description: "错误，code 沿用 HTTP 状态码语义；错误不会关闭连接", Content: ErrorMessage{}},
+	}
+	return client, server
+}
+
+// ProtocolSchema 由 Frames 生成的协议 JSON Schema
+func ProtocolSchema() *jsonschema.Schema {
+	r := jsonschema.NewReflector()
+	client, server := Frames(ProtocolV1)
+	_, serverV2 := Frames(ProtocolV2)
+
+	variants := func(specs []FrameSpec, server, v2 bool) []*jsonschema.Schema {
+		schemas := make([]*jsonschema.Schema, 0, len(specs))
+		for _, spec := range specs {
+			frame := &jsonschema.Schema{
+				Title:       spec.Type,
+				Description: spec.Description,
+				Type:        "object",
+				Properties: map[string]*jsonschema.Schema{
+					"type":      {Const: spec.Type},
+					"seq":       {Type: "integer"},
+					"timestamp": {Type: "integer"},
+				},
+				Required: []string{"type"},
+			}
+			if server {
+				frame.Properties["turnId"] = &jsonschema.Schema{Type: "integer", Description: "所属对话轮次，轮次外的帧省略"}
+			}
+			if v2 {
+				frame.Required = append(frame.Required, "seq", "timestamp")
+			}
+			if content := r.Reflect(spec.Content); content != nil {
+				frame.Properties["content"] = content
+				if !spec.Optional {
+					frame.Required = append(frame.Required, "content")
+				}
+			}
+			schemas = append(schemas, frame)
+		}
+		return schemas
+	}
+
+	r.Defs["ClientFrame"] = &jsonschema.Schema{Description: "客户端 → 服务端", OneOf: variants(client, false, false)}
+	r.Defs["ServerFrame"] = &jsonschema.Schema{Description: "服务端 → 客户端（v1）", OneOf: variants(server, true, false)}
+	r.Defs["ServerFrameV2"] = &jsonschema.Schema{
+		Description: "服务端 → 客户端（v2）：seq 在连接内从 1 开始连续递增，timestamp 为 Unix 毫秒",
+		OneOf:       variants(serverV2, true, true),
+	}
+
+	return &jsonschema.Schema{
+		Schema:      jsonschema.Draft,
+		ID:          "https://github.com/unclewu3242592726/CosTalk/backend/api/chat-stream.schema.json",
+		Title:       "CosTalk /v1/chat/stream",
+		Description: "WebSocket 文本帧：{type, seq?, content?, timestamp?}，content 的结构由 type 决定；以 hello 协商 v2 后服务端帧改用 ServerFrameV2",
+		OneOf: []*jsonschema.Schema{
+			{Ref: "#/$defs/ClientFrame"},
+			{Ref: "#/$defs/ServerFrame"},
+			{Ref: "#/$defs/ServerFrameV2"},
+		},
+		Defs: r.Defs,
+	}
+}
@@ -0,0 +1,68 @@
+package chat_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+)
+
+var update = flag.Bool("update", false, "regenerate api/chat-stream.schema.json")
+
+const schemaPath = "../../../api/chat-stream.schema.json"
+
+// 已发布的 Schema 必须与帧结构一致，修改帧结构后运行
+//
+//	go test ./internal/logic/chat -run TestProtocolSchema -update
+func TestProtocolSchema(t *testing.T) {
+	schema, err := json.MarshalIndent(chat.ProtocolSchema(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema = append(schema, '\n')
+
+	if *update {
+		if err := os.WriteFile(schemaPath, schema, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	published, err := os.ReadFile(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(published, schema) {
+		t.Errorf("%s is out of date, run go test ./internal/logic/chat -run TestProtocolSchema -update", schemaPath)
+	}
+}
+
+func TestAudioDataEncodings(t *testing.T) {
+	for _, content := range []string{
+		`{"audio_data": "AQIDBA=="}`,
+		`{"audio_data": [1, 2, 3, 4]}`,
+	} {
+		var chunk chat.AudioChunkMessage
+		if err := json.Unmarshal([]byte(content), &chunk); err != nil {
+			t.Errorf("%s: %v", content, err)
+			continue
+		}
+		if !bytes.Equal(chunk.AudioData, []byte{1, 2, 3, 4}) {
+			t.Errorf("%s decoded to %v", content, chunk.AudioData)
+		}
+	}
+
+	for _, content := range []string{
+		`{"audio_data": "not base64"}`,
+		`{"audio_data": [1, 256]}`,
+		`{"audio_data": {"bytes": 1}}`,
+	} {
+		var chunk chat.AudioChunkMessage
+		if err := json.Unmarshal([]byte(content), &chunk); err == nil {
+			t.Errorf("%s decoded without error", content)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package chat_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/config"
+	handler "github.com/unclewu3242592726/CosTalk/backend/internal/handler/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/latency"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+)
+
+// 等待单个帧的超时
+const frameTimeout = 5 * time.Second
+
+// harness 以假 Provider 启动真实的 /v1/chat/stream 处理器
+type harness struct {
+	t       *testing.T
+	server  *httptest.Server
+	llm     *providertest.LLM
+	asr     *providertest.ASR
+	tts     *providertest.TTS
+	store   *conversation.MemoryStore
+	latency *latency.HistogramVec
+}
+
+// newHarness 启动处理器，options 可在启动前调整服务配置（如注册审核 Provider）
+func newHarness(t *testing.T, options ...func(*svc.ServiceContext)) *harness {
+	t.Helper()
+
+	h := &harness{
+		t:   t,
+		llm: providertest.NewLLM("fake"),
+		asr: providertest.NewASR("fake"),
+		tts: providertest.NewTTS("fake"),
+	}
+
+	registry := provider.NewRegistry()
+	registry.RegisterLLM("fake", h.llm)
+	registry.RegisterASR("fake", h.asr)
+	registry.RegisterTTS("fake", h.tts)
+
+	roles, err := role.NewCatalog(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c config.Config
+	c.Defaults = config.DefaultsConfig{LLM: "fake", ASR: "fake", TTS: "fake"}
+	store := conversation.NewMemoryStore()
+	h.store = store
+	h.latency = latency.NewHistogramVec(latency.PipelineBuckets)
+	svcCtx := &svc.ServiceContext{
+		Config:        c,
+		Registry:      registry,
+		Conversations: store,
+		Memory:        conversation.NewMemoryManager(store, 0, 0),
+		Roles:         roles,
+		Usage:         usage.NewMeter("CNY", nil, map[string]string{"key-a": "a", "key-b": "b"}),
+		Latency:       h.latency,
+	}
+	for _, option := range options {
+		option(svcCtx)
+	}
+
+	h.server = httptest.NewServer(handler.ChatStreamHandler(svcCtx))
+	t.Cleanup(h.server.Close)
+	return h
+}
+
+// dial 建立连接并读取 welcome 帧
+func (h *harness) dial() *client {
+	h.t.Helper()
+
+	return h.dialAs("")
+}
+
+// dialAs 以指定 API Key（租户 a 为 key-a，租户 b 为 key-b）建立连接
+func (h *harness) dialAs(apiKey string) *client {
+	h.t.Helper()
+
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("X-API-Key", apiKey)
+	}
+	url := "ws" + strings.TrimPrefix(h.server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		h.t.Fatalf("dial: %v", err)
+	}
+	h.t.Cleanup(func() { conn.Close() })
+
+	c := &client{t: h.t, conn: conn, version: chat.ProtocolV1}
+	c.expect(chat.MessageTypeWelcome)
+	return c
+}
+
+// history 读取会话中存储的消息，格式为 role:content
+func (h *harness) history(id string) []string {
+	h.t.Helper()
+
+	conv, err := h.store.Get(context.Background(), id)
+	if err != nil {
+		h.t.Fatalf("get conversation %s: %v", id, err)
+	}
+	var messages []string
+	for _, msg := range conv.Messages {
+		messages = append(messages, msg.Role+":"+msg.Content)
+	}
+	return messages
+}
+
+// 二进制音频帧在 frame 中的类型
+const frameTypeBinary = "binary"
+
+// frame 收到的帧，content 已按协议解码为对应的结构，二进制音频帧为 chat.AudioFrame
+type frame struct {
+	Type    string
+	Seq     int64
+	TurnID  int64
+	Content interface{}
+}
+
+type client struct {
+	t         *testing.T
+	conn      *websocket.Conn
+	version   int    // 服务端帧的协议版本
+	seq       int64  // 最近一个 v2 帧的序号
+	binary    bool   // 是否协商了二进制音频帧
+	audioTurn int64  // 最近一个二进制音频帧所属的轮次
+	audioSeq  uint32 // 最近一个二进制音频帧的序号
+}
+
+func (c *client) send(messageType string, content interface{}) {
+	c.t.Helper()
+
+	if err := c.conn.WriteJSON(chat.WSMessage{Type: messageType, Content: content}); err != nil {
+		c.t.Fatalf("send %s: %v", messageType, err)
+	}
+}
+
+func (c *client) sendRaw(messageType int, data []byte) {
+	c.t.Helper()
+
+	if err := c.conn.WriteMessage(messageType, data); err != nil {
+		c.t.Fatalf("send: %v", err)
+	}
+}
+
+// next 读取下一帧，并按协议严格解码：未知的 type 或 content 中多余的字段都视为失败
+func (c *client) next() frame {
+	c.t.Helper()
+
+	c.conn.SetReadDeadline(time.Now().Add(frameTimeout))
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatalf("read frame: %v", err)
+	}
+	if messageType != websocket.TextMessage {
+		return c.audioFrame(data)
+	}
+
+	f, err := decodeFrame(data, c.version)
+	if err != nil {
+		c.t.Fatalf("frame %s does not conform to the v%d protocol: %v", data, c.version, err)
+	}
+	if c.version == chat.ProtocolV2 {
+		if f.Seq != c.seq+1 {
+			c.t.Fatalf("frame %s has seq %d, want %d", data, f.Seq, c.seq+1)
+		}
+		c.seq = f.Seq
+	}
+	return f
+}
+
+// 解码二进制音频帧，序号在轮次内从 1 开始连续递增
+func (c *client) audioFrame(data []byte) frame {
+	c.t.Helper()
+
+	if !c.binary {
+		c.t.Fatalf("got a binary frame of %d bytes without binary_audio", len(data))
+	}
+	var af chat.AudioFrame
+	if err := af.UnmarshalBinary(data); err != nil {
+		c.t.Fatalf("binary frame does not conform to the protocol: %v", err)
+	}
+	if af.TurnID != c.audioTurn {
+		c.audioTurn, c.audioSeq = af.TurnID, 0
+	}
+	if af.Sequence != c.audioSeq+1 {
+		c.t.Fatalf("audio frame sequence = %d, want %d", af.Sequence, c.audioSeq+1)
+	}
+	c.audioSeq = af.Sequence
+	return frame{Type: frameTypeBinary, TurnID: af.TurnID, Content: af}
+}
+
+// sendAudio 发送二进制音频帧
+func (c *client) sendAudio(seq uint32, final bool, data []byte) {
+	c.t.Helper()
+
+	af := chat.AudioFrame{Sequence: seq, Format: "pcm", Final: final, Data: data}
+	message, _ := af.MarshalBinary()
+	c.sendRaw(websocket.BinaryMessage, message)
+}
+
+// expect 读取下一帧并检查类型
+func (c *client) expect(messageType string) frame {
+	c.t.Helper()
+
+	f := c.next()
+	if f.Type != messageType {
+		c.t.Fatalf("got %s frame %+v, want %s", f.Type, f.Content, messageType)
+	}
+	return f
+}
+
+// expectError 读取下一帧，检查为指定 code 的 error 帧
+func (c *client) expectError(code int, contains string) {
+	c.t.Helper()
+
+	f := c.expect(chat.MessageTypeError)
+	if c.version == chat.ProtocolV2 {
+		want := "invalid_request"
+		if code >= 500 {
+			want = "internal_error"
+		}
+		e := f.Content.(model.ErrorFrame)
+		if e.Code != want || !strings.Contains(e.Message, contains) {
+			c.t.Fatalf("error = %+v, want code %s containing %q", e, want, contains)
+		}
+		return
+	}
+
+	e := f.Content.(chat.ErrorMessage)
+	if e.Code != code || !strings.Contains(e.Message, contains) {
+		c.t.Fatalf("error = %+v, want code %d containing %q", e, code, contains)
+	}
+}
+
+// hello 发送 hello 并读取协商结果，之后按协商的版本解码服务端帧
+func (c *client) hello(hello chat.HelloMessage) chat.HelloFrame {
+	c.t.Helper()
+
+	c.send(chat.MessageTypeHello, hello)
+	c.version = min(hello.Version, chat.ProtocolV2)
+	reply := c.expect(chat.MessageTypeHello).Content.(chat.HelloFrame)
+	c.binary = slices.Contains(reply.Features, chat.FeatureBinaryAudio)
+	return reply
+}
+
+// until 读取帧直到（含）指定类型的帧
+func (c *client) until(messageType string) []frame {
+	c.t.Helper()
+
+	var frames []frame
+	for {
+		f := c.next()
+		frames = append(frames, f)
+		if f.Type == messageType {
+			return frames
+		}
+	}
+}
+
+// configure 发送配置并读取 config_updated 与 conversation 帧
+func (c *client) configure(config chat.ConfigMessage) chat.ConversationFrame {
+	c.t.Helper()
+
+	c.send(chat.MessageTypeConfig, config)
+	c.expect(chat.MessageTypeConfigUpdated)
+	return c.expect(chat.MessageTypeConversation).Content.(chat.ConversationFrame)
+}
+
+// envelope 两个版本共用的帧外壳，v1 帧没有 turnId
+type envelope struct {
+	Type      string          `json:"type"`
+	Seq       int64           `json:"seq"`
+	TurnID    int64           `json:"turnId"`
+	Content   json.RawMessage `json:"content"`
+	Timestamp int64           `json:"timestamp"`
+}
+
+func decodeFrame(data []byte, version int) (frame, error) {
+	var msg envelope
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&msg); err != nil {
+		return frame{}, err
+	}
+	if version == chat.ProtocolV2 && (msg.Seq == 0 || msg.Timestamp == 0) {
+		return frame{}, fmt.Errorf("missing seq or timestamp")
+	}
+
+	_, server := chat.Frames(version)
+	for _, spec := range server {
+		if spec.Type != msg.Type {
+			continue
+		}
+		f := frame{Type: msg.Type, Seq: msg.Seq, TurnID: msg.TurnID}
+		if spec.Content == nil {
+			if len(msg.Content) > 0 {
+				return frame{}, fmt.Errorf("unexpected content")
+			}
+			return f, nil
+		}
+
+		content := reflect.New(reflect.TypeOf(spec.Content))
+		decoder := json.NewDecoder(bytes.NewReader(msg.Content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(content.Interface()); err != nil {
+			return frame{}, err
+		}
+		f.Content = content.Elem().Interface()
+		return f, nil
+	}
+
+	return frame{}, fmt.Errorf("unknown frame type %q", msg.Type)
+}
+
+// turnFrames 一轮回复中按类型归集的帧
+type turnFrames struct {
+	responses []chat.ResponseFrame
+	tts       []chat.TTSFrame
+}
+
+// 回复文本（不含完成帧）
+func (tf turnFrames) text() string {
+	var text strings.Builder
+	for _, r := range tf.responses {
+		text.WriteString(r.Text)
+	}
+	return text.String()
+}
+
+// 某个句子的全部音频
+func (tf turnFrames) audio(sentence string) []byte {
+	var audio []byte
+	for _, f := range tf.tts {
+		if f.Text == sentence {
+			audio = append(audio, f.Audio...)
+		}
+	}
+	return audio
+}
+
+func collectTurn(frames []frame) turnFrames {
+	var tf turnFrames
+	for _, f := range frames {
+		switch content := f.Content.(type) {
+		case chat.ResponseFrame:
+			tf.responses = append(tf.responses, content)
+		case chat.TTSFrame:
+			tf.tts = append(tf.tts, content)
+		}
+	}
+	return tf
+}
+
+// outline 帧类型序列：连续的音频帧（tts、audio_chunk 或二进制帧）合并为一项，
+// response 完成帧记为 response:done，二进制 final 帧记为 binary:final，status 帧附带状态
+func outline(frames []frame) []string {
+	var types []string
+	for _, f := range frames {
+		name := f.Type
+		if r, ok := f.Content.(chat.ResponseFrame); ok && r.IsDone {
+			name += ":done"
+		}
+		if s, ok := f.Content.(chat.StatusFrame); ok {
+			name += ":" + s.Status
+		}
+		if af, ok := f.Content.(chat.AudioFrame); ok && af.Final {
+			name += ":final"
+		}
+		audio := name == chat.MessageTypeTTS || name == model.FrameTypeAudioChunk || name == frameTypeBinary
+		if audio && len(types) > 0 && types[len(types)-1] == name {
+			continue
+		}
+		types = append(types, name)
+	}
+	return types
+}
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/latency"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+// 一轮对话的计时节点，均从用户输入结束起算
+const (
+	spanASRFinal       = "asr_final"
+	spanLLMFirstToken  = "llm_first_token"
+	spanFirstSentence  = "first_sentence"
+	spanTTSFirstByte   = "tts_first_byte"
+	spanFirstAudioSent = "first_audio_sent"
+)
+
+// 各节点相对用户输入结束的耗时（毫秒），开启 Prometheus 时上报（见配置 DevServer），
+// 同时计入 ServiceContext.Latency 供健康检查接口给出分位数
+var pipelineLatency = metric.NewHistogramVec(&metric.HistogramVecOpts{
+	Namespace: "costalk",
+	Subsystem: "pipeline",
+	Name:      "latency_ms",
+	Help:      "Latency of each pipeline stage since the end of user input, in milliseconds.",
+	Labels:    []string{"span"},
+	Buckets:   latency.PipelineBuckets,
+})
+
+// 用户输入的时间点：语音输入的识别发生在轮次开始之前，先暂存，由下一轮对话取用
+type turnInput struct {
+	end      time.Time // 用户说完话（或发送文本）
+	asrFinal time.Time // 语音识别返回最终结果，文本输入为零值
+}
+
+// 暂存即将开始的一轮对话的输入时间点
+func (l *ChatStreamLogic) markInput(end, asrFinal time.Time) {
+	l.pendingMutex.Lock()
+	defer l.pendingMutex.Unlock()
+
+	l.pendingInput = &turnInput{end: end, asrFinal: asrFinal}
+}
+
+// 取出暂存的输入时间点，没有时以当前时间作为输入结束
+func (l *ChatStreamLogic) takePendingInput() turnInput {
+	l.pendingMutex.Lock()
+	defer l.pendingMutex.Unlock()
+
+	in := l.pendingInput
+	l.pendingInput = nil
+	if in == nil {
+		return turnInput{end: time.Now()}
+	}
+	return *in
+}
+
+// 上报本轮各节点耗时，返回下发给客户端的时间点
+func (t *turn) observeTiming(pipeline *latency.HistogramVec) *model.Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	timing := &model.Timing{InputEnd: t.input.end.UnixMilli()}
+	at := func(span string, ts time.Time) int64 {
+		if ts.IsZero() {
+			return 0
+		}
+		ms := float64(ts.Sub(t.input.end).Microseconds()) / 1000
+		pipelineLatency.ObserveFloat(ms, span)
+		if pipeline != nil {
+			pipeline.Observe(ms, span)
+		}
+		return ts.UnixMilli()
+	}
+
+	timing.ASRFinal = at(spanASRFinal, t.input.asrFinal)
+	timing.LLMFirstToken = at(spanLLMFirstToken, t.marks[spanLLMFirstToken])
+	timing.FirstSentence = at(spanFirstSentence, t.marks[spanFirstSentence])
+	timing.TTSFirstByte = at(spanTTSFirstByte, t.marks[spanTTSFirstByte])
+	timing.FirstAudioSent = at(spanFirstAudioSent, t.marks[spanFirstAudioSent])
+	return timing
+}
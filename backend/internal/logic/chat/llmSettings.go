@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"fmt"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// LLM 设置：会话配置优先，其次角色的 llmDefault，最后是配置的 Defaults。
+// 返回 provider 名称和填好模型与采样参数的请求模板
+func (l *ChatStreamLogic) llmSettings(config *ConfigMessage) (string, *provider.ChatRequest) {
+	providerName := config.LLMProvider
+	req := &provider.ChatRequest{
+		Model:       config.Model,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		MaxTokens:   config.MaxTokens,
+		Stop:        config.Stop,
+		Seed:        config.Seed,
+	}
+
+	// 角色与全局默认模型只在使用其对应的 provider 时生效
+	var inherited string
+	defaults := l.svcCtx.Config.Defaults
+	if r := l.sessionRole(config); r != nil && r.LLMDefault != nil {
+		d := r.LLMDefault
+		if providerName == "" {
+			providerName = d.Provider
+		}
+		if d.Provider == "" || d.Provider == providerName {
+			inherited = d.Model
+		}
+		if req.Temperature == nil {
+			req.Temperature = d.Temperature
+		}
+		if req.TopP == nil {
+			req.TopP = d.TopP
+		}
+		if req.MaxTokens == 0 {
+			req.MaxTokens = d.MaxTokens
+		}
+		if len(req.Stop) == 0 {
+			req.Stop = d.Stop
+		}
+		if req.Seed == nil {
+			req.Seed = d.Seed
+		}
+	}
+
+	if providerName == "" {
+		providerName = l.defaultProvider(provider.TypeLLM)
+	}
+	if inherited == "" && providerName == defaults.LLM {
+		inherited = defaults.Model
+	}
+
+	// 会话模型已在配置时校验；继承的模型不被当前 provider 支持时交由 provider 使用其默认模型
+	if req.Model == "" && inherited != "" {
+		if llm, err := l.svcCtx.Registry.GetLLM(providerName); err == nil {
+			if err := provider.CheckModel(llm, inherited); err != nil {
+				logx.Infof("Ignoring default model: %v", err)
+			} else {
+				req.Model = inherited
+			}
+		}
+	}
+
+	return providerName, req
+}
+
+// 校验会话配置中的模型与采样参数，模型须在 provider 声明的模型列表中
+func (l *ChatStreamLogic) validateLLMSettings(config *ConfigMessage) error {
+	settings := &model.LLMSettings{
+		Model:       config.Model,
+		Temperature: config.Temperature,
+		TopP:        config.TopP,
+		MaxTokens:   config.MaxTokens,
+		Stop:        config.Stop,
+		Seed:        config.Seed,
+	}
+	if err := role.ValidateLLMSettings(settings); err != nil {
+		return err
+	}
+
+	if config.Model == "" {
+		return nil
+	}
+
+	providerName, _ := l.llmSettings(config)
+	llm, err := l.svcCtx.Registry.GetLLM(providerName)
+	if err != nil {
+		return fmt.Errorf("cannot select model '%s': %w", config.Model, err)
+	}
+	return provider.CheckModel(llm, config.Model)
+}
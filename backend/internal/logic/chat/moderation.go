@@ -0,0 +1,209 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 审核阶段
+const (
+	moderationStageInput  = "input"
+	moderationStageOutput = "output"
+)
+
+// 依次调用所有审核 Provider，返回最严重的结果
+//
+// rewrite 结果会作为后续 Provider 的输入；单个 Provider 出错时记录日志并跳过。
+func (l *ChatStreamLogic) moderate(ctx context.Context, text string) *model.SafetyResult {
+	result := &model.SafetyResult{Action: model.SafetyActionPass}
+	current := text
+
+	var reasons []string
+	for _, moderator := range l.svcCtx.Registry.ListModeration() {
+		res, err := moderator.CheckText(ctx, current)
+		if err != nil {
+			logx.Errorf("Moderation provider %s failed: %v", moderator.Name(), err)
+			continue
+		}
+		if res.Level == model.SafetyActionPass {
+			continue
+		}
+
+		if provider.ModerationSeverity(res.Level) > provider.ModerationSeverity(result.Action) {
+			result.Action = res.Level
+		}
+		if res.Score > result.Score {
+			result.Score = res.Score
+		}
+		result.Labels = append(result.Labels, res.Labels...)
+		if res.Reason != "" {
+			reasons = append(reasons, res.Reason)
+		}
+		if res.Level == model.SafetyActionRewrite && res.Rewritten != "" {
+			current = res.Rewritten
+		}
+	}
+
+	result.Reason = strings.Join(reasons, "; ")
+	if result.Action == model.SafetyActionRewrite {
+		result.Rewritten = current
+	}
+
+	return result
+}
+
+// 输出审核结果：审核 Provider 与角色守则中更严重的一个
+func (l *ChatStreamLogic) outputSafety(ctx context.Context, text string, config *ConfigMessage) *model.SafetyResult {
+	result := &model.SafetyResult{Action: model.SafetyActionPass}
+	if l.svcCtx.Config.Moderation.PostCheck {
+		result = l.moderate(ctx, text)
+	}
+
+	if guard := l.roleGuard(config); guard != nil {
+		if res := guard.CheckOutput(text); provider.ModerationSeverity(res.Action) > provider.ModerationSeverity(result.Action) {
+			result = res
+		}
+	}
+
+	return result
+}
+
+// 输入审核：返回实际送入 LLM 的文本，被拦截时直接回复拒答并返回 false
+func (l *ChatStreamLogic) checkInput(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) (string, bool) {
+	if l.svcCtx.Config.Moderation.PreCheck {
+		result := l.moderate(ctx, text)
+		if result.Action != model.SafetyActionPass {
+			logx.Infof("Input moderation %s: %s", result.Action, result.Reason)
+			l.sendModerationMeta(ctx, conn, moderationStageInput, result)
+		}
+
+		switch result.Action {
+		case model.SafetyActionBlock:
+			l.sendRefusal(ctx, l.refusalText(config), config, conn)
+			return "", false
+		case model.SafetyActionRewrite:
+			text = result.Rewritten
+		}
+	}
+
+	// 角色守则：触及角色拒绝讨论的话题时以角色口吻婉拒
+	if guard := l.roleGuard(config); guard != nil {
+		if result := guard.CheckInput(text); result.Action != model.SafetyActionPass {
+			logx.Infof("Input guardrail %s: %s", result.Action, result.Reason)
+			l.sendModerationMeta(ctx, conn, moderationStageInput, result)
+			l.sendRefusal(ctx, l.refusalText(config), config, conn)
+			return "", false
+		}
+	}
+
+	return text, true
+}
+
+// 是否对流式输出逐句审核
+func (l *ChatStreamLogic) gateOutput(config *ConfigMessage) bool {
+	if l.svcCtx.Config.Moderation.PostCheck && len(l.svcCtx.Registry.ListModeration()) > 0 {
+		return true
+	}
+
+	guard := l.roleGuard(config)
+	return guard != nil && guard.ChecksOutput()
+}
+
+// 逐句输出审核：返回实际下发与播报的句子，block 时返回 false 表示终止生成
+//
+// 与 checkOutput 一致：rewrite 的句子替换为改写后的文本，block 时以角色拒答语代替并结束本轮。
+func (l *ChatStreamLogic) checkSentence(ctx context.Context, sentence string, config *ConfigMessage, conn *websocket.Conn) (string, bool) {
+	result := l.outputSafety(ctx, sentence, config)
+	if result.Action == model.SafetyActionPass {
+		return sentence, true
+	}
+
+	logx.Infof("Output moderation %s: %s", result.Action, result.Reason)
+	l.sendModerationMeta(ctx, conn, moderationStageOutput, result)
+
+	switch result.Action {
+	case model.SafetyActionBlock:
+		return l.refusalText(config), false
+	case model.SafetyActionRewrite:
+		return result.Rewritten, true
+	default:
+		return sentence, true
+	}
+}
+
+// 输出审核：返回可展示与写入历史的文本
+func (l *ChatStreamLogic) checkOutput(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) string {
+	if text == "" {
+		return text
+	}
+
+	result := l.outputSafety(ctx, text, config)
+	if result.Action == model.SafetyActionPass {
+		return text
+	}
+
+	logx.Infof("Output moderation %s: %s", result.Action, result.Reason)
+	l.sendModerationMeta(ctx, conn, moderationStageOutput, result)
+
+	switch result.Action {
+	case model.SafetyActionBlock:
+		return l.refusalText(config)
+	case model.SafetyActionRewrite:
+		return result.Rewritten
+	default:
+		return text
+	}
+}
+
+// 拒答语：优先使用角色口吻的拒答，否则使用全局配置
+func (l *ChatStreamLogic) refusalText(config *ConfigMessage) string {
+	if r := l.sessionRole(config); r != nil && r.Refusal != "" {
+		return r.Refusal
+	}
+
+	return l.svcCtx.Config.Moderation.RefusalText
+}
+
+// 发送拒答回复（文本 + 语音）
+func (l *ChatStreamLogic) sendRefusal(ctx context.Context, text string, config *ConfigMessage, conn *websocket.Conn) {
+	l.sendTurnMessage(conn, turnOf(ctx), &WSMessage{
+		Type: MessageTypeResponse,
+		Content: ResponseFrame{
+			Text:        text,
+			Type:        ResponseTypeLLMStream,
+			Accumulated: text,
+			IsFirst:     true,
+			IsDone:      true,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+
+	l.callSequentialTTS(ctx, text, config, conn)
+}
+
+// 通过 meta 帧通知客户端审核结果
+func (l *ChatStreamLogic) sendModerationMeta(ctx context.Context, conn *websocket.Conn, stage string, result *model.SafetyResult) {
+	if conn == nil {
+		return
+	}
+
+	// 只下发命中的规则类别，不回显命中内容
+	warning := fmt.Sprintf("moderation %s %s", stage, result.Action)
+	if len(result.Labels) > 0 {
+		warning += ": " + strings.Join(result.Labels, ",")
+	}
+
+	l.sendTurnMessage(conn, turnOf(ctx), &WSMessage{
+		Type:      model.FrameTypeMeta,
+		Content:   &model.MetaFrame{Warnings: []string{warning}},
+		Timestamp: time.Now().Unix(),
+	})
+}
@@ -0,0 +1,127 @@
+package chat_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+const refusal = "这个问题我不能回答。"
+
+// withOutputModeration 注册规则审核 Provider 并只开启输出审核
+func withOutputModeration(t *testing.T, rules ...provider.ModerationRule) func(*svc.ServiceContext) {
+	return func(svcCtx *svc.ServiceContext) {
+		p, err := provider.NewRuleModerationProvider("rules", rules)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svcCtx.Registry.RegisterModeration("rules", p)
+		svcCtx.Config.Moderation.PostCheck = true
+		svcCtx.Config.Moderation.RefusalText = refusal
+	}
+}
+
+// untilTurnEnd 读取帧直到携带计时的 meta 帧（审核结果同样以 meta 帧下发）
+func (c *client) untilTurnEnd() []frame {
+	c.t.Helper()
+
+	var frames []frame
+	for {
+		f := c.next()
+		frames = append(frames, f)
+		if meta, ok := f.Content.(model.MetaFrame); ok && meta.Timing != nil {
+			return frames
+		}
+	}
+}
+
+// warnings 审核结果 meta 帧中的警告
+func warnings(frames []frame) []string {
+	var out []string
+	for _, f := range frames {
+		if meta, ok := f.Content.(model.MetaFrame); ok {
+			out = append(out, meta.Warnings...)
+		}
+	}
+	return out
+}
+
+func TestChatStreamOutputModeration(t *testing.T) {
+	rules := []provider.ModerationRule{
+		{Name: "privacy", Action: model.SafetyActionRewrite, Patterns: []string{`1[3-9]\d{9}`}, Replacement: "[隐私]"},
+		{Name: "violence", Action: model.SafetyActionBlock, Keywords: []string{"炸弹"}},
+	}
+
+	cases := []struct {
+		name     string
+		reply    []string
+		text     string   // 下发与写入历史的回复
+		spoken   []string // 送入 TTS 的句子
+		warnings []string
+	}{
+		{
+			name:   "pass",
+			reply:  []string{"你好，", "我是哈利。", "再见。"},
+			text:   "你好，我是哈利。再见。",
+			spoken: []string{"你好，我是哈利。", "再见。"},
+		},
+		{
+			// 改写的句子以改写后的文本下发与播报，之后的句子照常输出
+			name:     "rewrite",
+			reply:    []string{"我的电话是", "13800138000。", "再见。"},
+			text:     "我的电话是[隐私]。再见。",
+			spoken:   []string{"我的电话是[隐私]。", "再见。"},
+			warnings: []string{"moderation output rewrite: privacy"},
+		},
+		{
+			// 拦截的句子以拒答语代替，并终止生成
+			name:     "block",
+			reply:    []string{"你好。", "教你做炸弹。", "第一步。"},
+			text:     "你好。" + refusal,
+			spoken:   []string{"你好。", refusal},
+			warnings: []string{"moderation output block: violence"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, withOutputModeration(t, rules...))
+			h.llm.Enqueue(providertest.Reply(tc.reply...))
+			c := h.dial()
+			conv := c.configure(chat.ConfigMessage{})
+
+			c.send(chat.MessageTypeText, chat.TextMessage{Content: "你好"})
+			frames := c.untilTurnEnd()
+
+			turn := collectTurn(frames)
+			if text := turn.text(); text != tc.text {
+				t.Errorf("text = %q, want %q", text, tc.text)
+			}
+			var spoken []string
+			for _, f := range turn.tts {
+				if len(spoken) == 0 || spoken[len(spoken)-1] != f.Text {
+					spoken = append(spoken, f.Text)
+				}
+			}
+			if !slices.Equal(spoken, tc.spoken) {
+				t.Errorf("spoken = %q, want %q", spoken, tc.spoken)
+			}
+			for _, sentence := range tc.spoken {
+				if audio := turn.audio(sentence); !bytes.Equal(audio, providertest.AudioFor(sentence)) {
+					t.Errorf("audio for %q = %q", sentence, audio)
+				}
+			}
+			if got := warnings(frames); !slices.Equal(got, tc.warnings) {
+				t.Errorf("warnings = %q, want %q", got, tc.warnings)
+			}
+			if history := h.history(conv.ConversationID); !slices.Equal(history, []string{"user:你好", "assistant:" + tc.text}) {
+				t.Errorf("history = %q", history)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package chat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+
+	"github.com/gorilla/websocket"
+)
+
+// 协议版本：连接建立后默认 v1，客户端以 hello 作为第一条消息可协商 v2
+//
+// v2 的服务端帧统一使用 model.WSFrame：seq 在连接内单调递增，turnId 标记所属轮次，
+// 回复文本以 text_delta + end、音频以 audio_chunk 下发，错误码为字符串。
+// 客户端消息的结构两个版本相同。
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+)
+
+const MessageTypeHello = "hello"
+
+// v2 可协商的特性，未启用时不下发对应的帧
+const (
+	FeaturePartialTranscripts = "partial_transcripts" // 实时音频流的中间识别结果
+	FeatureSpeechEvents       = "speech_events"       // 端点检测事件 speech_start / speech_end
+	FeatureMeta               = "meta"                // 审核告警，以及每轮的用量与时间点
+)
+
+var serverFeatures = []string{FeaturePartialTranscripts, FeatureSpeechEvents, FeatureMeta, FeatureBinaryAudio}
+
+// v1 不区分的特性，对应的帧照常下发
+var v1Features = []string{FeaturePartialTranscripts, FeatureSpeechEvents, FeatureMeta}
+
+// 连接的协议状态，在 WebSocket 写锁内读写
+type protocolState struct {
+	version      int
+	features     map[string]bool
+	audioFormats []string // 客户端可播放的音频格式，为空表示不限制
+	seq          int64    // 最近一个 v2 帧的序号
+}
+
+// 处理 hello：协商协议版本、音频格式与特性，并以协商后的版本回复 hello
+func (l *ChatStreamLogic) handleHello(msg *ClientMessage, config *ConfigMessage, conn *websocket.Conn) error {
+	var hello HelloMessage
+	if !msg.hasContent() {
+		return fmt.Errorf("invalid hello format")
+	}
+	if err := msg.decode(&hello); err != nil {
+		return err
+	}
+
+	version := hello.Version
+	if version < ProtocolV1 {
+		return fmt.Errorf("unsupported protocol version %d", hello.Version)
+	}
+	version = min(version, ProtocolV2)
+
+	var formats []string
+	if len(hello.AudioFormats) > 0 {
+		produced := l.ttsFormats()
+		for _, format := range hello.AudioFormats {
+			format = strings.ToLower(format)
+			if slices.Contains(produced, format) && !slices.Contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+		if len(formats) == 0 {
+			return fmt.Errorf("no common audio format: client plays %v, server produces %v", hello.AudioFormats, produced)
+		}
+		if err := l.validateAudioFormat(config, formats); err != nil {
+			return err
+		}
+	}
+
+	features := make(map[string]bool)
+	for _, feature := range serverFeatures {
+		if (version == ProtocolV1 && slices.Contains(v1Features, feature)) || slices.Contains(hello.Features, feature) {
+			features[feature] = true
+		}
+	}
+
+	l.wsWriteMutex.Lock()
+	l.proto = protocolState{version: version, features: features, audioFormats: formats}
+	l.wsWriteMutex.Unlock()
+
+	reply := HelloFrame{Version: version, AudioFormats: formats, Features: []string{}}
+	for _, feature := range serverFeatures {
+		if features[feature] {
+			reply.Features = append(reply.Features, feature)
+		}
+	}
+	if reply.AudioFormats == nil {
+		reply.AudioFormats = l.ttsFormats()
+	}
+
+	l.sendMessage(conn, &WSMessage{
+		Type:      MessageTypeHello,
+		Content:   reply,
+		Timestamp: time.Now().Unix(),
+	})
+	return nil
+}
+
+// 已注册的 TTS Provider 能输出的音频格式
+func (l *ChatStreamLogic) ttsFormats() []string {
+	formats := []string{}
+	for _, info := range l.svcCtx.Registry.GetProvidersByType(provider.TypeTTS) {
+		for _, format := range info.Details.AudioFormats {
+			format = strings.ToLower(format)
+			if !slices.Contains(formats, format) {
+				formats = append(formats, format)
+			}
+		}
+	}
+	return formats
+}
+
+// 会话的 TTS Provider 必须能输出客户端可播放的格式，formats 为空时不限制
+func (l *ChatStreamLogic) validateAudioFormat(config *ConfigMessage, formats []string) error {
+	if len(formats) == 0 {
+		return nil
+	}
+
+	name, _ := l.ttsSettings(config)
+	ttsProvider, err := l.svcCtx.Registry.GetTTS(name)
+	if err != nil {
+		return nil // Provider 不可用时由合成阶段报错
+	}
+
+	produced := provider.Describe(ttsProvider).AudioFormats
+	if len(produced) == 0 {
+		return nil
+	}
+	for _, format := range produced {
+		if slices.Contains(formats, strings.ToLower(format)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("TTS provider %s produces %v, which the client cannot play (%v)", name, produced, formats)
+}
+
+// 客户端协商的音频格式
+func (l *ChatStreamLogic) clientAudioFormats() []string {
+	l.wsWriteMutex.Lock()
+	defer l.wsWriteMutex.Unlock()
+
+	return l.proto.audioFormats
+}
+
+// 写出一条消息，v2 连接先转换为 v2 帧，启用二进制音频帧时音频以二进制消息下发；调用方持有写锁
+func (l *ChatStreamLogic) writeMessage(conn *websocket.Conn, msg *WSMessage) error {
+	if l.proto.features[FeatureBinaryAudio] {
+		switch content := msg.Content.(type) {
+		case TTSFrame:
+			return l.writeAudioFrame(conn, &AudioFrame{
+				TurnID:   msg.TurnID,
+				Sequence: uint32(content.Sequence),
+				Format:   content.Format,
+				Data:     content.Audio,
+			})
+		case AudioFrame:
+			content.TurnID = msg.TurnID
+			return l.writeAudioFrame(conn, &content)
+		}
+	}
+
+	if l.proto.version != ProtocolV2 {
+		return conn.WriteJSON(msg)
+	}
+
+	for _, frame := range l.framesV2(msg) {
+		l.proto.seq++
+		frame.Seq = l.proto.seq
+		frame.TurnID = msg.TurnID
+		frame.Timestamp = time.Now().UnixMilli()
+		if err := conn.WriteJSON(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *ChatStreamLogic) writeAudioFrame(conn *websocket.Conn, frame *AudioFrame) error {
+	data, err := frame.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
+// v1 消息对应的 v2 帧，未启用的特性不产生帧
+func (l *ChatStreamLogic) framesV2(msg *WSMessage) []*model.WSFrame {
+	frame := func(frameType string, content interface{}) *model.WSFrame {
+		return &model.WSFrame{Type: frameType, Content: content}
+	}
+
+	switch content := msg.Content.(type) {
+	case ResponseFrame:
+		var frames []*model.WSFrame
+		if content.Text != "" {
+			frames = append(frames, frame(model.FrameTypeTextDelta, model.TextDeltaFrame{Text: content.Text}))
+		}
+		if content.IsDone {
+			frames = append(frames, frame(model.FrameTypeEnd, model.EndFrame{Text: content.Accumulated}))
+		}
+		return frames
+
+	case TTSFrame:
+		return []*model.WSFrame{frame(model.FrameTypeAudioChunk, model.AudioChunkFrame{
+			Format: content.Format,
+			Data:   content.Audio,
+			Text:   content.Text,
+		})}
+
+	case ErrorMessage:
+		return []*model.WSFrame{frame(model.FrameTypeError, model.ErrorFrame{
+			Code:    errorCode(content.Code),
+			Message: content.Message,
+		})}
+
+	case TranscriptFrame:
+		if !content.IsFinal && !l.proto.features[FeaturePartialTranscripts] {
+			return nil
+		}
+
+	case SpeechEventFrame:
+		if !l.proto.features[FeatureSpeechEvents] {
+			return nil
+		}
+
+	case *model.MetaFrame:
+		if !l.proto.features[FeatureMeta] {
+			return nil
+		}
+	}
+
+	return []*model.WSFrame{frame(msg.Type, msg.Content)}
+}
+
+// v2 error 帧的错误码，由 v1 的 HTTP 状态码映射
+func errorCode(status int) string {
+	switch {
+	case status >= 500:
+		return "internal_error"
+	case status == 409:
+		return "turn_rejected"
+	default:
+		return "invalid_request"
+	}
+}
@@ -0,0 +1,159 @@
+package chat_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestHelloNegotiation(t *testing.T) {
+	h := newHarness(t)
+
+	// 高于服务端的版本降为 v2，只启用服务端支持的特性
+	c := h.dial()
+	hello := c.hello(chat.HelloMessage{Version: 3, AudioFormats: []string{"MP3", "pcm"}, Features: []string{chat.FeatureMeta, "video"}})
+	if hello.Version != chat.ProtocolV2 || !slices.Equal(hello.AudioFormats, []string{"pcm"}) || !slices.Equal(hello.Features, []string{chat.FeatureMeta}) {
+		t.Errorf("hello = %+v", hello)
+	}
+
+	// 未声明音频格式时回复服务端能输出的全部格式
+	c = h.dial()
+	hello = c.hello(chat.HelloMessage{Version: 2})
+	if !slices.Equal(hello.AudioFormats, []string{"pcm"}) || len(hello.Features) != 0 {
+		t.Errorf("hello = %+v", hello)
+	}
+
+	// v1 hello 不改变帧结构，所有特性照常下发
+	c = h.dial()
+	hello = c.hello(chat.HelloMessage{Version: 1})
+	if hello.Version != chat.ProtocolV1 || len(hello.Features) != 3 {
+		t.Errorf("hello = %+v", hello)
+	}
+	c.configure(chat.ConfigMessage{})
+}
+
+func TestHelloErrors(t *testing.T) {
+	h := newHarness(t)
+
+	cases := []struct {
+		name     string
+		hello    interface{}
+		contains string
+	}{
+		{"without content", nil, "invalid hello format"},
+		{"unsupported version", chat.HelloMessage{Version: 0}, "unsupported protocol version 0"},
+		{"no common audio format", chat.HelloMessage{Version: 2, AudioFormats: []string{"opus"}}, "no common audio format"},
+		{"mistyped", map[string]interface{}{"version": "2"}, "invalid hello content"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := h.dial()
+			c.t = t
+			c.send(chat.MessageTypeHello, tc.hello)
+			c.expectError(400, tc.contains)
+
+			// 协商失败后仍是 v1，可以重新发送 hello
+			c.hello(chat.HelloMessage{Version: 2})
+		})
+	}
+
+	// hello 只能作为第一条消息
+	c := h.dial()
+	c.configure(chat.ConfigMessage{})
+	c.send(chat.MessageTypeHello, chat.HelloMessage{Version: 2})
+	c.expectError(400, "hello must be the first message")
+
+	c = h.dial()
+	c.hello(chat.HelloMessage{Version: 2})
+	c.send(chat.MessageTypeHello, chat.HelloMessage{Version: 2})
+	c.expectError(400, "hello must be the first message")
+}
+
+func TestProtocolV2Turn(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(providertest.Reply("你好，", "我是哈利。", "很高兴", "认识你！"))
+	c := h.dial()
+	c.hello(chat.HelloMessage{Version: 2, Features: []string{chat.FeatureMeta}})
+	c.configure(chat.ConfigMessage{})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "你是谁？"})
+	frames := c.until(model.FrameTypeMeta)
+
+	want := []string{
+		"status:processing_llm",
+		"text_delta", "text_delta", "audio_chunk",
+		"text_delta", "text_delta", "audio_chunk",
+		"end",
+		"meta",
+	}
+	if got := outline(frames); !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+
+	// 轮次内的帧都带同一个 turnId，status 在轮次开始前下发
+	meta := frames[len(frames)-1].Content.(model.MetaFrame)
+	var text string
+	audio := make(map[string][]byte)
+	for _, f := range frames[1:] {
+		if f.TurnID == 0 || f.TurnID != meta.TurnID {
+			t.Errorf("%s frame turnId = %d, want %d", f.Type, f.TurnID, meta.TurnID)
+		}
+		switch content := f.Content.(type) {
+		case model.TextDeltaFrame:
+			text += content.Text
+		case model.AudioChunkFrame:
+			if content.Format != "pcm" {
+				t.Errorf("audio chunk format = %s", content.Format)
+			}
+			audio[content.Text] = append(audio[content.Text], content.Data...)
+		case model.EndFrame:
+			if content.Text != "你好，我是哈利。很高兴认识你！" {
+				t.Errorf("end = %+v", content)
+			}
+		}
+	}
+	if text != "你好，我是哈利。很高兴认识你！" {
+		t.Errorf("text = %q", text)
+	}
+	for _, sentence := range []string{"你好，我是哈利。", "很高兴认识你！"} {
+		if !bytes.Equal(audio[sentence], providertest.AudioFor(sentence)) {
+			t.Errorf("audio for %q = %q", sentence, audio[sentence])
+		}
+	}
+
+	// 错误帧改用字符串错误码
+	c.send(chat.MessageTypeText, chat.TextMessage{})
+	c.expectError(400, "Empty text content")
+}
+
+func TestProtocolV2Features(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("讲个故事", "讲", "讲个"))
+	h.llm.Enqueue(providertest.Reply("好的。"))
+	c := h.dial()
+	c.hello(chat.HelloMessage{Version: 2})
+	c.configure(chat.ConfigMessage{Params: map[string]string{"vad": "off"}})
+
+	// 未启用 partial_transcripts 与 meta：只下发最终识别结果，轮次以 end 结束
+	c.send(chat.MessageTypeStartAudio, nil)
+	c.expect(chat.MessageTypeStatus)
+	c.send(chat.MessageTypeAudioChunk, chat.AudioChunkMessage{AudioData: bytes.Repeat([]byte{1, 0}, 320)})
+	c.send(chat.MessageTypeEndAudio, nil)
+
+	frames := c.until(model.FrameTypeEnd)
+	if got, want := outline(frames), []string{"asr", "status:processing_llm", "text_delta", "audio_chunk", "end"}; !slices.Equal(got, want) {
+		t.Fatalf("frames = %v, want %v", got, want)
+	}
+	if transcript := frames[0].Content.(chat.TranscriptFrame); !transcript.IsFinal || transcript.Text != "讲个故事" {
+		t.Errorf("transcript = %+v", transcript)
+	}
+
+	// meta 帧被过滤，下一帧就是新一轮的 status
+	h.llm.Enqueue(providertest.Reply("好。"))
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "继续"})
+	c.expect(chat.MessageTypeStatus)
+}
@@ -0,0 +1,19 @@
+package chat
+
+import (
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 会话未指定 Provider 时使用的默认值（见配置 Defaults）
+func (l *ChatStreamLogic) defaultProvider(providerType string) string {
+	defaults := l.svcCtx.Config.Defaults
+	switch providerType {
+	case provider.TypeLLM:
+		return defaults.LLM
+	case provider.TypeASR:
+		return defaults.ASR
+	case provider.TypeTTS:
+		return defaults.TTS
+	}
+	return ""
+}
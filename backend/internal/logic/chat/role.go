@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 校验会话配置中的角色设置
+func (l *ChatStreamLogic) validateRole(config *ConfigMessage) error {
+	if config.RoleID != "" {
+		if _, ok := l.svcCtx.Roles.Get(config.RoleID); !ok {
+			return fmt.Errorf("unknown role: %s", config.RoleID)
+		}
+		return nil
+	}
+
+	if config.Role != "" && !l.svcCtx.Config.Roles.AllowCustomPrompt {
+		return fmt.Errorf("custom role prompts are disabled, please select a role by roleId")
+	}
+
+	return nil
+}
+
+// 获取会话角色，每轮从角色目录读取以便角色更新及时生效
+func (l *ChatStreamLogic) sessionRole(config *ConfigMessage) *model.Role {
+	if config.RoleID == "" {
+		return nil
+	}
+
+	r, ok := l.svcCtx.Roles.Get(config.RoleID)
+	if !ok {
+		logx.Errorf("Role %s no longer exists, falling back to no persona", config.RoleID)
+		return nil
+	}
+
+	return r
+}
+
+// 会话角色的运行时守则，角色重新加载后重新编译
+func (l *ChatStreamLogic) roleGuard(config *ConfigMessage) *role.Guard {
+	r := l.sessionRole(config)
+	if r == nil {
+		return nil
+	}
+
+	l.guardMutex.Lock()
+	defer l.guardMutex.Unlock()
+
+	if l.guard == nil || l.guard.Role() != r {
+		l.guard = role.CompileGuard(r)
+	}
+
+	return l.guard
+}
+
+// 角色系统提示：服务端角色优先，否则在允许时使用客户端自定义提示
+func (l *ChatStreamLogic) rolePrompt(config *ConfigMessage) string {
+	if r := l.sessionRole(config); r != nil {
+		return role.SystemPrompt(r)
+	}
+
+	if l.svcCtx.Config.Roles.AllowCustomPrompt {
+		return config.Role
+	}
+
+	return ""
+}
+
+// TTS 设置：角色默认的 provider/音色/风格由服务端决定，语速允许客户端调整
+func (l *ChatStreamLogic) ttsSettings(config *ConfigMessage) (string, *provider.TTSOptions) {
+	providerName := config.TTSProvider
+	opts := &provider.TTSOptions{
+		Voice: config.Voice,
+		Speed: config.Speed,
+	}
+
+	if r := l.sessionRole(config); r != nil && r.TTSDefault != nil {
+		if name := r.TTSDefault["provider"]; name != "" {
+			providerName = name
+		}
+		if voice := r.TTSDefault["voice"]; voice != "" {
+			opts.Voice = voice
+		}
+		opts.Style = r.TTSDefault["style"]
+		if opts.Speed == 0 {
+			if speed, err := strconv.ParseFloat(r.TTSDefault["speed"], 64); err == nil {
+				opts.Speed = speed
+			}
+		}
+	}
+
+	if providerName == "" {
+		providerName = l.defaultProvider(provider.TypeTTS)
+	}
+	if opts.Voice == "" {
+		opts.Voice = l.defaultVoice(providerName)
+	}
+	if opts.Speed == 0 {
+		opts.Speed = 1.0
+	}
+
+	return providerName, opts
+}
+
+// TTS provider 的默认音色，未声明时使用配置的 Defaults.Voice（为空则由 Provider 自行决定）
+func (l *ChatStreamLogic) defaultVoice(providerName string) string {
+	if ttsProvider, err := l.svcCtx.Registry.GetTTS(providerName); err == nil {
+		if voice := provider.Describe(ttsProvider).DefaultVoice; voice != "" {
+			return voice
+		}
+	}
+
+	return l.svcCtx.Config.Defaults.Voice
+}
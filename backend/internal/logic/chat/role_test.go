@@ -0,0 +1,101 @@
+package chat_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+const harryRefusal = "这可不是霍格沃茨的学生该聊的事，我们说说魁地奇吧。"
+
+// withGuardedRole 在角色目录中创建带守则的角色 harry
+func withGuardedRole(t *testing.T) func(*svc.ServiceContext) {
+	return func(svcCtx *svc.ServiceContext) {
+		_, err := svcCtx.Roles.Create(&model.Role{
+			ID:           "harry",
+			Name:         "哈利·波特",
+			SystemPrompt: "你是哈利·波特。",
+			Guardrails:   []string{"refuse_topic: 政治", "never_claim"},
+			Refusal:      harryRefusal,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChatStreamGuardrails(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		reply    []string
+		asked    bool   // 是否调用 LLM，未调用时输入被拒绝
+		text     string // 下发与写入历史的回复
+		warnings []string
+	}{
+		{
+			name:  "clean",
+			input: "你好",
+			reply: []string{"你好，", "我是哈利。"},
+			asked: true,
+			text:  "你好，我是哈利。",
+		},
+		{
+			// 触及拒绝的话题时不调用 LLM，直接以角色口吻婉拒
+			name:     "refused topic",
+			input:    "聊聊政治吧",
+			text:     harryRefusal,
+			warnings: []string{"moderation input block: guardrail:refuse_topic"},
+		},
+		{
+			// 未开启审核 Provider 时守则同样逐句检查输出
+			name:     "claim",
+			input:    "你是谁？",
+			reply:    []string{"你好。", "其实我是一个人工智能。", "有什么可以帮你？"},
+			asked:    true,
+			text:     "你好。" + harryRefusal,
+			warnings: []string{"moderation output block: guardrail:never_claim"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, withGuardedRole(t))
+			if tc.reply != nil {
+				h.llm.Enqueue(providertest.Reply(tc.reply...))
+			}
+			c := h.dial()
+			conv := c.configure(chat.ConfigMessage{RoleID: "harry"})
+
+			c.send(chat.MessageTypeText, chat.TextMessage{Content: tc.input})
+			frames := c.untilTurnEnd()
+
+			if text := collectTurn(frames).text(); text != tc.text {
+				t.Errorf("text = %q, want %q", text, tc.text)
+			}
+			if got := warnings(frames); !slices.Equal(got, tc.warnings) {
+				t.Errorf("warnings = %q, want %q", got, tc.warnings)
+			}
+
+			requests := h.llm.Requests()
+			if !tc.asked {
+				// 被拒绝的输入与拒答都不进入历史
+				if len(requests) != 0 || len(h.history(conv.ConversationID)) != 0 {
+					t.Errorf("LLM called %d times, history = %q", len(requests), h.history(conv.ConversationID))
+				}
+				return
+			}
+			if history := h.history(conv.ConversationID); !slices.Equal(history, []string{"user:" + tc.input, "assistant:" + tc.text}) {
+				t.Errorf("history = %q", history)
+			}
+			// 守则编入系统提示
+			if len(requests) != 1 || !strings.Contains(requests[0].Messages[0].Content, "【角色守则】") {
+				t.Errorf("requests = %+v", requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+
+	"github.com/gorilla/websocket"
+)
+
+// 打断原因
+const (
+	interruptReasonClient   = "client"    // 客户端发送 interrupt 消息
+	interruptReasonNewInput = "new_input" // 回复过程中收到新的用户输入
+)
+
+// 一轮对话：用户输入 -> LLM -> TTS，可被打断
+type turn struct {
+	id     int64
+	ctx    context.Context
+	cancel context.CancelFunc
+	start  chan struct{} // 轮次开始下发回复时关闭，queue 策略下排队的轮次需等待
+
+	prev     *turn         // 上一轮，本轮在其写完会话历史后才读写历史
+	recorded chan struct{} // 本轮写完会话历史（或无需再写）时关闭
+
+	mu          sync.Mutex
+	spoken      strings.Builder // 已完整播报（或已展示）的文本
+	generated   int             // 已下发的文本字数
+	audioSeq    int32           // 最近一个音频块的序号，在轮次内从 1 开始递增
+	interrupted bool
+	usage       model.Usage // 本轮用量，含触发本轮的语音识别
+	input       turnInput
+	marks       map[string]time.Time // 各计时节点首次到达的时间
+}
+
+type turnKey struct{}
+
+// 轮次上下文所属的轮次
+func turnOf(ctx context.Context) *turn {
+	t, _ := ctx.Value(turnKey{}).(*turn)
+	return t
+}
+
+// 计入本轮用量
+func (t *turn) addUsage(u model.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage.Add(&t.usage, u)
+}
+
+// 记录计时节点，只保留首次到达的时间
+func (t *turn) mark(span string) {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.marks[span]; !ok {
+		t.marks[span] = now
+	}
+}
+
+func (t *turn) totalUsage() model.Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.usage
+}
+
+// 记录已下发的文本
+func (t *turn) markGenerated(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.generated += utf8.RuneCountInString(text)
+}
+
+// 记录已完整播报的句子，打断之后的句子不再计入
+func (t *turn) markSpoken(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ctx.Err() == nil {
+		t.spoken.WriteString(text)
+	}
+}
+
+// 用户实际听到的文本
+func (t *turn) spokenText() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.spoken.String()
+}
+
+// 下一个音频块的序号
+func (t *turn) nextAudioSeq() int32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.audioSeq++
+	return t.audioSeq
+}
+
+// 等待上一轮写完会话历史。被打断的轮次在取消之后才记录已播报的内容，
+// 新一轮需在此之后读取历史并追加用户输入，否则历史会按 用户1、用户2、助手1 交错。
+// 只由本轮的处理协程调用
+func (t *turn) waitPrevious() {
+	if t.prev != nil {
+		<-t.prev.recorded
+		t.prev = nil
+	}
+}
+
+func (t *turn) wasInterrupted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.interrupted
+}
+
+// 轮次结束：通过 meta 帧下发本轮用量与各阶段时间点
+func (l *ChatStreamLogic) reportTurn(t *turn, conn *websocket.Conn) {
+	frame := &model.MetaFrame{
+		TurnID: t.id,
+		Timing: t.observeTiming(l.svcCtx.Latency),
+	}
+	l.recordUsage(t, frame)
+
+	l.sendMessage(conn, &WSMessage{
+		Type:      model.FrameTypeMeta,
+		Content:   frame,
+		Timestamp: time.Now().Unix(),
+		TurnID:    t.id,
+	})
+}
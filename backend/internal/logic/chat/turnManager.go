@@ -0,0 +1,228 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 轮次策略：回复进行中收到新的用户输入时的处理方式，由会话配置 turnPolicy 指定
+const (
+	TurnPolicySupersede = "supersede" // 打断进行中的回复，立即开始新一轮（默认）
+	TurnPolicyQueue     = "queue"     // 排队，进行中的回复结束后依次开始
+	TurnPolicyReject    = "reject"    // 拒绝新的输入
+)
+
+var turnPolicies = []string{TurnPolicySupersede, TurnPolicyQueue, TurnPolicyReject}
+
+// queue 策略下最多排队的轮次
+const maxQueuedTurns = 4
+
+// 轮次管理：分配轮次 ID，并按会话策略保证同一时刻只有一轮在下发回复。
+//
+// 轮次内的帧经 sendTurnMessage 下发并带上轮次 ID；轮次被打断后只再下发 interrupted 与 meta，
+// 因此各轮的回复在连接上不会交错。
+type turnManager struct {
+	mu     sync.Mutex
+	active *turn   // 正在下发回复的轮次
+	queue  []*turn // 等待开始的轮次
+	last   *turn   // 最近开始的轮次，各轮按开始顺序写入会话历史
+	lastID int64
+}
+
+// 会话的轮次策略
+func turnPolicy(config *ConfigMessage) string {
+	if config.TurnPolicy == "" {
+		return TurnPolicySupersede
+	}
+	return config.TurnPolicy
+}
+
+func validateTurnPolicy(config *ConfigMessage) error {
+	if config.TurnPolicy != "" && !slices.Contains(turnPolicies, config.TurnPolicy) {
+		return fmt.Errorf("invalid turnPolicy '%s', expected one of %v", config.TurnPolicy, turnPolicies)
+	}
+	return nil
+}
+
+// 开始新一轮对话：supersede 打断进行中与排队的轮次，queue 在其后排队，
+// reject 在有进行中的轮次时返回错误。排队的轮次需等待 waitTurn 返回后再下发回复
+func (l *ChatStreamLogic) beginTurn(conn *websocket.Conn, config *ConfigMessage) (*turn, error) {
+	policy := turnPolicy(config)
+	m := &l.turns
+
+	m.mu.Lock()
+	busy := m.active != nil
+	if busy && policy == TurnPolicyReject {
+		id := m.active.id
+		m.mu.Unlock()
+		l.takePendingInput() // 被拒绝的输入不计入下一轮的时间点与用量
+		l.recordRejectedInput()
+		return nil, fmt.Errorf("a reply is in progress (turn %d), input rejected", id)
+	}
+	if busy && policy == TurnPolicyQueue && len(m.queue) >= maxQueuedTurns {
+		m.mu.Unlock()
+		l.takePendingInput()
+		l.recordRejectedInput()
+		return nil, fmt.Errorf("too many queued turns (%d), input rejected", maxQueuedTurns)
+	}
+
+	// 轮次 ID 为连接内从 1 开始的计数，严格递增且不超出 JSON 数字的安全整数范围
+	m.lastID++
+	t := &turn{
+		id:       m.lastID,
+		start:    make(chan struct{}),
+		prev:     m.last,
+		recorded: make(chan struct{}),
+		usage:    l.takePendingUsage(),
+		input:    l.takePendingInput(),
+		marks:    make(map[string]time.Time),
+	}
+	m.last = t
+	t.ctx, t.cancel = context.WithCancel(context.WithValue(l.ctx, turnKey{}, t))
+
+	queued := busy && policy == TurnPolicyQueue
+	var superseded []*turn
+	if queued {
+		m.queue = append(m.queue, t)
+	} else {
+		superseded = m.takeAll()
+		m.active = t
+		close(t.start)
+	}
+	m.mu.Unlock()
+
+	for _, old := range superseded {
+		l.interrupt(conn, old, interruptReasonNewInput)
+	}
+	if queued {
+		l.sendTurnMessage(conn, t, &WSMessage{
+			Type:      MessageTypeStatus,
+			Content:   StatusFrame{Status: StatusQueued, Message: "等待上一轮回复结束..."},
+			Timestamp: time.Now().Unix(),
+		})
+	}
+
+	return t, nil
+}
+
+// 等待轮次开始，轮次在排队期间被打断时返回 false
+func (l *ChatStreamLogic) waitTurn(t *turn) bool {
+	select {
+	case <-t.start:
+		return t.ctx.Err() == nil
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// 结束轮次并释放资源，排队的下一轮随即开始
+func (l *ChatStreamLogic) endTurn(t *turn) {
+	m := &l.turns
+
+	// 未读写历史就结束的轮次（排队时被取消等）同样要等上一轮写完，保证下一轮看到完整的历史
+	t.waitPrevious()
+	close(t.recorded)
+
+	m.mu.Lock()
+	if m.active == t {
+		m.active = nil
+		if len(m.queue) > 0 {
+			m.active, m.queue = m.queue[0], m.queue[1:]
+			close(m.active.start)
+		}
+	} else {
+		m.queue = slices.DeleteFunc(m.queue, func(queued *turn) bool { return queued == t })
+	}
+	m.mu.Unlock()
+
+	t.cancel()
+}
+
+// 取出进行中与排队的全部轮次，调用方持有锁
+func (m *turnManager) takeAll() []*turn {
+	var turns []*turn
+	if m.active != nil {
+		turns = append(turns, m.active)
+	}
+	turns = append(turns, m.queue...)
+	m.active, m.queue = nil, nil
+	return turns
+}
+
+// 打断进行中与排队的全部轮次
+func (l *ChatStreamLogic) interruptTurn(conn *websocket.Conn, reason string) bool {
+	l.turns.mu.Lock()
+	turns := l.turns.takeAll()
+	l.turns.mu.Unlock()
+
+	for _, t := range turns {
+		l.interrupt(conn, t, reason)
+	}
+	return len(turns) > 0
+}
+
+// 新的语音输入开始：supersede 策略下立即打断正在播报的回复，其他策略等到识别出文本后再按策略处理
+func (l *ChatStreamLogic) preemptTurn(conn *websocket.Conn, config *ConfigMessage) {
+	if turnPolicy(config) == TurnPolicySupersede {
+		l.interruptTurn(conn, interruptReasonNewInput)
+	}
+}
+
+// 打断轮次：取消 LLM 与待播报的 TTS，并告知客户端已播报的内容
+func (l *ChatStreamLogic) interrupt(conn *websocket.Conn, t *turn, reason string) {
+	// 持有写锁完成标记与下发：此后该轮的其他帧都会被 sendTurnMessage 丢弃，
+	// 结束本轮的 meta 帧也只能在 interrupted 之后下发
+	l.wsWriteMutex.Lock()
+	defer l.wsWriteMutex.Unlock()
+
+	t.mu.Lock()
+	t.interrupted = true
+	t.cancel()
+	spoken := t.spoken.String()
+	generated := t.generated
+	t.mu.Unlock()
+
+	logx.Infof("Turn %d interrupted (%s), spoken %d chars", t.id, reason, utf8.RuneCountInString(spoken))
+
+	err := l.writeMessage(conn, &WSMessage{
+		Type: MessageTypeInterrupted,
+		Content: InterruptedFrame{
+			TurnID:         t.id,
+			Reason:         reason,
+			SpokenText:     spoken,
+			SpokenChars:    utf8.RuneCountInString(spoken),
+			GeneratedChars: generated,
+		},
+		Timestamp: time.Now().Unix(),
+		TurnID:    t.id,
+	})
+	if err != nil {
+		logx.Errorf("Failed to send WebSocket message: %v", err)
+	}
+}
+
+// 下发轮次内的帧：带上轮次 ID，轮次被打断后丢弃。t 为空时同 sendMessage
+func (l *ChatStreamLogic) sendTurnMessage(conn *websocket.Conn, t *turn, msg *WSMessage) {
+	if t == nil {
+		l.sendMessage(conn, msg)
+		return
+	}
+	msg.TurnID = t.id
+
+	l.wsWriteMutex.Lock()
+	defer l.wsWriteMutex.Unlock()
+
+	if t.wasInterrupted() {
+		return
+	}
+	if err := l.writeMessage(conn, msg); err != nil {
+		logx.Errorf("Failed to send WebSocket message: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package chat_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/chat"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+// 两轮之间较慢的回复，保证第二条输入到达时第一轮仍在进行
+func slowReply(chunks ...string) providertest.LLMReply {
+	return providertest.LLMReply{Chunks: chunks, Delay: 100 * time.Millisecond}
+}
+
+// 按轮次 ID 归集帧，返回各轮次首次出现的顺序
+func byTurn(frames []frame) (order []int64, turns map[int64][]frame) {
+	turns = make(map[int64][]frame)
+	for _, f := range frames {
+		if f.TurnID == 0 {
+			continue
+		}
+		if _, ok := turns[f.TurnID]; !ok {
+			order = append(order, f.TurnID)
+		}
+		turns[f.TurnID] = append(turns[f.TurnID], f)
+	}
+	return order, turns
+}
+
+func TestTurnPolicySupersede(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("让我想想，", "这个问题", "很有意思。"), providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	first := c.expect(chat.MessageTypeStatus).TurnID
+	c.expect(chat.MessageTypeResponse)
+
+	// 新的输入打断进行中的回复，被打断的轮次此后只有 meta 帧
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "算了，讲个笑话"})
+	interrupted := c.expect(chat.MessageTypeInterrupted)
+	if reason := interrupted.Content.(chat.InterruptedFrame).Reason; interrupted.TurnID != first || reason != "new_input" {
+		t.Errorf("interrupted = %+v", interrupted)
+	}
+
+	frames := c.until(model.FrameTypeMeta)
+	frames = append(frames, c.until(model.FrameTypeMeta)...)
+	order, turns := byTurn(frames)
+	if len(order) != 2 || !slices.Contains(order, first) {
+		t.Fatalf("turns = %v, want %d and a new turn", order, first)
+	}
+	for _, f := range turns[first] {
+		if f.Type != model.FrameTypeMeta {
+			t.Errorf("superseded turn sent %s after interrupted", f.Type)
+		}
+	}
+	second := order[0]
+	if second == first {
+		second = order[1]
+	}
+	if second <= first {
+		t.Errorf("new turn id = %d, want greater than %d", second, first)
+	}
+	if got, want := outline(turns[second]), []string{"status:processing_llm", "response", "tts", "response:done", "meta"}; !slices.Equal(got, want) {
+		t.Errorf("second turn = %v, want %v", got, want)
+	}
+}
+
+func TestTurnPolicySupersedeHistory(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("你好。", "这个问题", "很有意思。"), slowReply("从前", "有座山。"), providertest.Reply("好的。"))
+	c := h.dial()
+	conv := c.configure(chat.ConfigMessage{})
+
+	// 第一轮播报完第一句、第二轮尚未播报时分别被新的输入打断
+	metas := 0
+	until := func(done func(f frame) bool) {
+		for {
+			f := c.next()
+			if f.Type == model.FrameTypeMeta {
+				metas++
+			}
+			if done(f) {
+				return
+			}
+		}
+	}
+	spoke := func(text string) {
+		until(func(f frame) bool {
+			r, ok := f.Content.(chat.ResponseFrame)
+			return ok && r.Text == text
+		})
+	}
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	spoke("这个问题")
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "讲个故事"})
+	spoke("从前")
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "算了"})
+	until(func(frame) bool { return metas == 3 })
+
+	// 历史按轮次顺序排列，被打断的轮次只记录已播报的句子
+	want := []string{
+		"user:宇宙有多大？", "assistant:你好。",
+		"user:讲个故事",
+		"user:算了", "assistant:好的。",
+	}
+	if got := h.history(conv.ConversationID); !slices.Equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+	requests := h.llm.Requests()
+	if got := requests[1].Messages[len(requests[1].Messages)-2].Content; got != "你好。" {
+		t.Errorf("second request ends with %q before the input, want the spoken partial", got)
+	}
+	if got := len(requests[2].Messages); got != 4 {
+		t.Errorf("third request has %d messages, want 4", got)
+	}
+}
+
+func TestTurnPolicyQueue(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("让我想想，", "很有意思。"), providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{TurnPolicy: chat.TurnPolicyQueue})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再讲个笑话"})
+
+	frames := c.until(model.FrameTypeMeta)
+	frames = append(frames, c.until(model.FrameTypeMeta)...)
+	// 排队的 status 可能先于第一轮的帧到达，轮次按 ID 排序
+	order, turns := byTurn(frames)
+	slices.Sort(order)
+	if len(order) != 2 {
+		t.Fatalf("turns = %v", order)
+	}
+
+	// 第二轮在第一轮进行中排队，第一轮的 meta 之后才开始下发回复
+	want := map[int64][]string{
+		order[0]: {"status:processing_llm", "response", "response", "tts", "response:done", "meta"},
+		order[1]: {"status:queued", "status:processing_llm", "response", "tts", "response:done", "meta"},
+	}
+	for id, frames := range turns {
+		if got := outline(frames); !slices.Equal(got, want[id]) {
+			t.Errorf("turn %d = %v, want %v", id, got, want[id])
+		}
+	}
+	started := false
+	for _, f := range frames {
+		if f.TurnID == order[0] && f.Type == model.FrameTypeMeta {
+			started = true
+		}
+		if s, ok := f.Content.(chat.StatusFrame); f.TurnID == order[1] && !started && (!ok || s.Status != chat.StatusQueued) {
+			t.Errorf("queued turn sent %s before the first turn ended", f.Type)
+		}
+	}
+
+	// 两轮的历史按输入顺序记录
+	if requests := h.llm.Requests(); len(requests) != 2 || requests[1].Messages[len(requests[1].Messages)-2].Content != "让我想想，很有意思。" {
+		t.Errorf("second request did not see the first reply")
+	}
+}
+
+func TestTurnPolicyQueueKeepsConfig(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("让我想想，", "很有意思。"), providertest.Reply("好的。"))
+	c := h.dial()
+	temperature := 0.5
+	c.configure(chat.ConfigMessage{TurnPolicy: chat.TurnPolicyQueue, Stop: []string{"甲"}, Temperature: &temperature})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再讲个笑话"})
+	// 排队期间的配置更新只影响之后的输入
+	changed := 1.5
+	c.send(chat.MessageTypeConfig, chat.ConfigMessage{Stop: []string{"乙"}, Temperature: &changed})
+
+	c.until(model.FrameTypeMeta)
+	c.until(model.FrameTypeMeta)
+	requests := h.llm.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d LLM requests, want 2", len(requests))
+	}
+	for i, req := range requests {
+		if !slices.Equal(req.Stop, []string{"甲"}) || req.Temperature == nil || *req.Temperature != temperature {
+			t.Errorf("request %d = %+v, want the config at input time", i+1, req)
+		}
+	}
+}
+
+func TestTurnPolicyQueueInterrupt(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("让我想想，", "很有意思。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{TurnPolicy: chat.TurnPolicyQueue})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	first := c.expect(chat.MessageTypeStatus).TurnID
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再讲个笑话"})
+	queued := c.expect(chat.MessageTypeStatus)
+	if s := queued.Content.(chat.StatusFrame); s.Status != chat.StatusQueued || queued.TurnID <= first {
+		t.Fatalf("status = %+v, turn %d", s, queued.TurnID)
+	}
+
+	// interrupt 同时取消排队的轮次
+	c.send(chat.MessageTypeInterrupt, nil)
+	frames := c.until(model.FrameTypeMeta)
+	frames = append(frames, c.until(model.FrameTypeMeta)...)
+	interrupted := map[int64]bool{}
+	for _, f := range frames {
+		if f.Type == chat.MessageTypeInterrupted {
+			interrupted[f.TurnID] = true
+		}
+		if f.Type == chat.MessageTypeResponse && f.TurnID == queued.TurnID {
+			t.Errorf("cancelled turn sent a response")
+		}
+	}
+	if !interrupted[first] || !interrupted[queued.TurnID] {
+		t.Errorf("interrupted turns = %v, want %d and %d", interrupted, first, queued.TurnID)
+	}
+	if n := len(h.llm.Requests()); n != 1 {
+		t.Errorf("LLM called %d times, want the queued turn to be dropped", n)
+	}
+}
+
+func TestTurnPolicyReject(t *testing.T) {
+	h := newHarness(t)
+	h.llm.Enqueue(slowReply("让我想想，", "很有意思。"), providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{TurnPolicy: chat.TurnPolicyReject})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	first := c.expect(chat.MessageTypeStatus).TurnID
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再讲个笑话"})
+
+	// 被拒绝的输入只产生 error 帧，进行中的回复不受影响
+	frames := c.until(model.FrameTypeMeta)
+	var rejected bool
+	for _, f := range frames {
+		if e, ok := f.Content.(chat.ErrorMessage); ok {
+			rejected = e.Code == 409 && f.TurnID == 0
+			continue
+		}
+		if f.TurnID != first {
+			t.Errorf("%s frame turn id = %d, want %d", f.Type, f.TurnID, first)
+		}
+	}
+	if !rejected {
+		t.Errorf("frames = %v, want a 409 error", outline(frames))
+	}
+	if got := collectTurn(frames).text(); got != "让我想想，很有意思。" {
+		t.Errorf("text = %q", got)
+	}
+
+	// 上一轮结束后可以开始新一轮
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "再讲个笑话"})
+	if next := c.expect(chat.MessageTypeStatus); next.TurnID <= first {
+		t.Errorf("next turn id = %d", next.TurnID)
+	}
+}
+
+func TestTurnPolicyRejectAudioUsage(t *testing.T) {
+	h := newHarness(t)
+	h.asr.Enqueue(providertest.Transcript("再讲个笑话"))
+	h.llm.Enqueue(slowReply("让我想想，", "很有意思。"), providertest.Reply("好的。"))
+	c := h.dial()
+	c.configure(chat.ConfigMessage{TurnPolicy: chat.TurnPolicyReject})
+
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "宇宙有多大？"})
+	c.expect(chat.MessageTypeStatus)
+	c.send(chat.MessageTypeAudioFile, chat.AudioChunkMessage{AudioData: bytes.Repeat([]byte{1, 0}, 16000)})
+
+	// 被拒绝的语音输入在第一轮结束前识别完成
+	var rejected bool
+	for !rejected {
+		if e, ok := c.next().Content.(chat.ErrorMessage); ok {
+			rejected = e.Code == 409
+		}
+	}
+	c.until(model.FrameTypeMeta)
+
+	// 其语音识别用量计入会话，但不计入下一轮
+	c.send(chat.MessageTypeText, chat.TextMessage{Content: "继续"})
+	meta := c.until(model.FrameTypeMeta)
+	frame := meta[len(meta)-1].Content.(model.MetaFrame)
+	if frame.Usage == nil || frame.Usage.ASRSeconds != 0 {
+		t.Errorf("next turn usage = %+v, want no ASR seconds", frame.Usage)
+	}
+	if frame.ConversationUsage == nil || frame.ConversationUsage.ASRSeconds == 0 {
+		t.Errorf("conversation usage = %+v, want the rejected input's ASR seconds", frame.ConversationUsage)
+	}
+}
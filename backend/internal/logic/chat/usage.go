@@ -0,0 +1,105 @@
+package chat
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 语音识别发生在轮次开始之前，先暂存，由下一轮对话计入
+func (l *ChatStreamLogic) meterASR(providerName string, seconds float64) {
+	u := l.svcCtx.Usage.ASR(providerName, seconds)
+
+	l.pendingMutex.Lock()
+	defer l.pendingMutex.Unlock()
+
+	usage.Add(&l.pendingUsage, u)
+}
+
+// 取出暂存的语音识别用量
+func (l *ChatStreamLogic) takePendingUsage() model.Usage {
+	l.pendingMutex.Lock()
+	defer l.pendingMutex.Unlock()
+
+	u := l.pendingUsage
+	l.pendingUsage = model.Usage{}
+	return u
+}
+
+// 输入被拒绝、不会开始新一轮：暂存的语音识别用量直接计入会话与租户，不再由下一轮计入
+func (l *ChatStreamLogic) recordRejectedInput() {
+	u := l.takePendingUsage()
+	if u == (model.Usage{}) {
+		return
+	}
+
+	l.convMutex.Lock()
+	conversationID := l.conversationID
+	l.convMutex.Unlock()
+
+	l.svcCtx.Usage.RecordOutsideTurn(l.tenant, conversationID, u)
+}
+
+// 计入一次 LLM 调用。Provider 未返回用量时（不支持或生成被中断）按文本估算
+func (l *ChatStreamLogic) meterLLM(t *turn, providerName string, llm provider.LLMProvider, req *provider.ChatRequest, reported *provider.Usage, completion string) {
+	modelName := req.Model
+	if reported != nil && reported.Model != "" {
+		modelName = reported.Model
+	} else if modelName == "" {
+		if models := provider.Describe(llm).Models; len(models) > 0 {
+			modelName = models[0]
+		}
+	}
+
+	if reported == nil {
+		estimated := provider.EstimateChatUsage(req, completion)
+		reported = &estimated
+	}
+	u := l.svcCtx.Usage.LLM(providerName, modelName, reported.PromptTokens, reported.CompletionTokens)
+	u.Estimated = reported.Estimated
+
+	t.addUsage(u)
+}
+
+// 计入一次语音合成，按送入 TTS 的字符数计费
+func (l *ChatStreamLogic) meterTTS(ctx context.Context, providerName, text string) {
+	u := l.svcCtx.Usage.TTS(providerName, utf8.RuneCountInString(text))
+	if t := turnOf(ctx); t != nil {
+		t.addUsage(u)
+		return
+	}
+
+	// 不属于任何轮次的合成并入下一轮
+	l.pendingMutex.Lock()
+	defer l.pendingMutex.Unlock()
+
+	usage.Add(&l.pendingUsage, u)
+}
+
+// 轮次结束：累计到会话与租户，并将本轮用量与会话累计填入 meta 帧
+func (l *ChatStreamLogic) recordUsage(t *turn, frame *model.MetaFrame) {
+	u := t.totalUsage()
+	if u == (model.Usage{}) {
+		return
+	}
+
+	l.convMutex.Lock()
+	conversationID := l.conversationID
+	l.convMutex.Unlock()
+
+	l.svcCtx.Usage.Record(l.tenant, conversationID, u)
+
+	frame.Usage = &u
+	frame.Currency = l.svcCtx.Usage.Currency()
+	if totals, ok := l.svcCtx.Usage.Conversation(conversationID); ok {
+		frame.ConversationUsage = &totals.Usage
+	}
+
+	logx.Infof("Turn %d usage: %d prompt + %d completion tokens, %d TTS chars, %.1fs ASR, cost %.6f %s",
+		t.id, u.PromptTokens, u.CompletionTokens, u.TTSCharacters, u.ASRSeconds, u.Cost, frame.Currency)
+}
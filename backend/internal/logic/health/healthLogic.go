@@ -2,13 +2,18 @@ package health
 
 import (
 	"context"
+	"math"
 
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/service"
 	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
 	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
 
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+const version = "v1.0"
+
 type HealthLogic struct {
 	logx.Logger
 	ctx    context.Context
@@ -23,8 +28,60 @@ func NewHealthLogic(ctx context.Context, svcCtx *svc.ServiceContext) *HealthLogi
 	}
 }
 
+// Health 汇总 Provider 健康状态：
+// 对话必需的某类 Provider（llm/asr/tts）全部离线时为 offline，任一 Provider 降级或离线时为 degraded
 func (l *HealthLogic) Health() (resp *types.HealthResponse, err error) {
-	// todo: add your logic here and delete this line
+	services, err := service.NewGetServicesLogic(l.ctx, l.svcCtx).GetServices()
+	if err != nil {
+		return nil, err
+	}
+
+	status := provider.StatusOnline
+	providers := make(map[string]types.ProviderHealth, len(services.Data))
+	registered := map[string]bool{}
+	available := map[string]bool{}
+
+	for _, p := range services.Data {
+		providers[p.Type+"/"+p.Name] = p.Health
 
-	return
+		registered[p.Type] = true
+		if p.Status != provider.StatusOffline {
+			available[p.Type] = true
+		}
+		if p.Status == provider.StatusDegraded || p.Status == provider.StatusOffline {
+			status = provider.StatusDegraded
+		}
+	}
+
+	for _, providerType := range []string{provider.TypeLLM, provider.TypeASR, provider.TypeTTS} {
+		if registered[providerType] && !available[providerType] {
+			status = provider.StatusOffline
+		}
+	}
+
+	return &types.HealthResponse{
+		Status:    status,
+		Service:   l.svcCtx.Config.Name,
+		Version:   version,
+		Providers: providers,
+		Pipeline:  l.pipeline(),
+	}, nil
+}
+
+// pipeline 对话流水线各计时节点的耗时分位数（自进程启动起）
+func (l *HealthLogic) pipeline() map[string]types.PipelineLatency {
+	if l.svcCtx.Latency == nil {
+		return nil
+	}
+
+	summaries := l.svcCtx.Latency.Summaries()
+	pipeline := make(map[string]types.PipelineLatency, len(summaries))
+	for span, s := range summaries {
+		pipeline[span] = types.PipelineLatency{
+			Count: s.Count,
+			P50Ms: int64(math.Round(s.P50)),
+			P95Ms: int64(math.Round(s.P95)),
+		}
+	}
+	return pipeline
 }
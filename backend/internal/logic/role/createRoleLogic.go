@@ -0,0 +1,47 @@
+package role
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type CreateRoleLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewCreateRoleLogic(ctx context.Context, svcCtx *svc.ServiceContext) *CreateRoleLogic {
+	return &CreateRoleLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *CreateRoleLogic) CreateRole(req *types.UpsertRoleRequest) (resp *types.RoleDetailResponse, err error) {
+	r := toModelRole(req)
+	if err := validateTTSDefault(l.ctx, l.svcCtx, r); err != nil {
+		return errorResponse(err), nil
+	}
+	if err := validateLLMDefault(l.svcCtx, r); err != nil {
+		return errorResponse(err), nil
+	}
+
+	created, err := l.svcCtx.Roles.Create(r)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	l.Infof("Role %s created, version %d", created.ID, created.Version)
+
+	return &types.RoleDetailResponse{
+		Code:    0,
+		Message: "success",
+		Data:    toRoleDetail(created),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package role
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DeleteRoleLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewDeleteRoleLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DeleteRoleLogic {
+	return &DeleteRoleLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *DeleteRoleLogic) DeleteRole(req *types.DeleteRoleRequest) (resp *types.RoleDetailResponse, err error) {
+	current, ok := l.svcCtx.Roles.Get(req.ID)
+	if !ok {
+		return &types.RoleDetailResponse{
+			Code:    404,
+			Message: "role not found",
+		}, nil
+	}
+
+	if err := l.svcCtx.Roles.Delete(req.ID); err != nil {
+		return errorResponse(err), nil
+	}
+
+	l.Infof("Role %s deleted at version %d", current.ID, current.Version)
+
+	return &types.RoleDetailResponse{
+		Code:    0,
+		Message: "success",
+		Data:    toRoleDetail(current),
+	}, nil
+}
@@ -24,7 +24,23 @@ func NewGetRolesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetRoles
 }
 
 func (l *GetRolesLogic) GetRoles() (resp *types.RolesResponse, err error) {
-	// todo: add your logic here and delete this line
+	// 系统提示与守则仅在服务端使用，不下发给客户端
+	roles := l.svcCtx.Roles.List()
+	data := make([]types.Role, 0, len(roles))
+	for _, r := range roles {
+		data = append(data, types.Role{
+			ID:          r.ID,
+			Name:        r.Name,
+			Avatar:      r.Avatar,
+			Description: r.Description,
+			Skills:      r.Skills,
+			TTSDefault:  r.TTSDefault,
+		})
+	}
 
-	return
+	return &types.RolesResponse{
+		Code:    0,
+		Message: "success",
+		Data:    data,
+	}, nil
 }
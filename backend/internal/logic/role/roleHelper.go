@@ -0,0 +1,162 @@
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	rolecatalog "github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 将请求转换为角色模型
+func toModelRole(req *types.UpsertRoleRequest) *model.Role {
+	return &model.Role{
+		ID:           req.ID,
+		Name:         req.Name,
+		Avatar:       req.Avatar,
+		Description:  req.Description,
+		SystemPrompt: req.SystemPrompt,
+		Guardrails:   req.Guardrails,
+		Refusal:      req.Refusal,
+		TTSDefault:   req.TTSDefault,
+		LLMDefault:   toModelLLMSettings(req.LLMDefault),
+		Skills:       req.Skills,
+		Version:      req.Version,
+	}
+}
+
+// 将角色模型转换为完整定义响应
+func toRoleDetail(r *model.Role) types.RoleDetail {
+	return types.RoleDetail{
+		ID:           r.ID,
+		Name:         r.Name,
+		Avatar:       r.Avatar,
+		Description:  r.Description,
+		SystemPrompt: r.SystemPrompt,
+		Guardrails:   r.Guardrails,
+		Refusal:      r.Refusal,
+		TTSDefault:   r.TTSDefault,
+		LLMDefault:   toLLMSettings(r.LLMDefault),
+		Skills:       r.Skills,
+		Version:      r.Version,
+	}
+}
+
+func toModelLLMSettings(s *types.LLMSettings) *model.LLMSettings {
+	if s == nil {
+		return nil
+	}
+	return &model.LLMSettings{
+		Provider:    s.Provider,
+		Model:       s.Model,
+		Temperature: s.Temperature,
+		TopP:        s.TopP,
+		MaxTokens:   s.MaxTokens,
+		Stop:        s.Stop,
+		Seed:        s.Seed,
+	}
+}
+
+func toLLMSettings(s *model.LLMSettings) *types.LLMSettings {
+	if s == nil {
+		return nil
+	}
+	return &types.LLMSettings{
+		Provider:    s.Provider,
+		Model:       s.Model,
+		Temperature: s.Temperature,
+		TopP:        s.TopP,
+		MaxTokens:   s.MaxTokens,
+		Stop:        s.Stop,
+		Seed:        s.Seed,
+	}
+}
+
+// 校验角色默认 LLM 设置：provider 必须已注册，模型必须在该 provider（未指定时为默认 provider）中可用。
+// 采样参数范围由角色目录校验
+func validateLLMDefault(svcCtx *svc.ServiceContext, r *model.Role) error {
+	if r.LLMDefault == nil {
+		return nil
+	}
+
+	providerName := r.LLMDefault.Provider
+	if providerName == "" {
+		if r.LLMDefault.Model == "" {
+			return nil
+		}
+		providerName = svcCtx.Config.Defaults.LLM
+	}
+
+	llm, err := svcCtx.Registry.GetLLM(providerName)
+	if err != nil {
+		return err
+	}
+	return provider.CheckModel(llm, r.LLMDefault.Model)
+}
+
+// 校验角色默认 TTS 设置：provider 必须已注册，音色必须存在于该 provider
+func validateTTSDefault(ctx context.Context, svcCtx *svc.ServiceContext, r *model.Role) error {
+	providerName := r.TTSDefault["provider"]
+	voice := r.TTSDefault["voice"]
+
+	if providerName == "" {
+		if voice != "" {
+			return fmt.Errorf("ttsDefault.provider is required when ttsDefault.voice is set")
+		}
+		return nil
+	}
+
+	ttsProvider, err := svcCtx.Registry.GetTTS(providerName)
+	if err != nil {
+		return err
+	}
+
+	if voice == "" {
+		return nil
+	}
+
+	// 优先使用 provider 声明的固定音色，否则在线获取音色列表
+	voices := provider.Describe(ttsProvider).Voices
+	if len(voices) == 0 {
+		lister, ok := ttsProvider.(provider.VoiceLister)
+		if !ok {
+			return nil
+		}
+
+		if voices, err = lister.ListVoices(ctx); err != nil {
+			// 音色列表暂不可用时不阻塞角色编辑
+			logx.WithContext(ctx).Errorf("Failed to list voices of TTS provider %s, skipping voice check: %v", providerName, err)
+			return nil
+		}
+	}
+
+	for _, v := range voices {
+		if v == voice {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("voice '%s' not found in TTS provider %s", voice, providerName)
+}
+
+// 将角色目录错误映射为响应码
+func errorResponse(err error) *types.RoleDetailResponse {
+	code := 400
+	switch {
+	case errors.Is(err, rolecatalog.ErrNotFound):
+		code = 404
+	case errors.Is(err, rolecatalog.ErrAlreadyExists), errors.Is(err, rolecatalog.ErrVersionConflict):
+		code = 409
+	}
+
+	return &types.RoleDetailResponse{
+		Code:    code,
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,47 @@
+package role
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpdateRoleLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewUpdateRoleLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpdateRoleLogic {
+	return &UpdateRoleLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *UpdateRoleLogic) UpdateRole(req *types.UpsertRoleRequest) (resp *types.RoleDetailResponse, err error) {
+	r := toModelRole(req)
+	if err := validateTTSDefault(l.ctx, l.svcCtx, r); err != nil {
+		return errorResponse(err), nil
+	}
+	if err := validateLLMDefault(l.svcCtx, r); err != nil {
+		return errorResponse(err), nil
+	}
+
+	updated, err := l.svcCtx.Roles.Update(r)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
+	l.Infof("Role %s updated, version %d", updated.ID, updated.Version)
+
+	return &types.RoleDetailResponse{
+		Code:    0,
+		Message: "success",
+		Data:    toRoleDetail(updated),
+	}, nil
+}
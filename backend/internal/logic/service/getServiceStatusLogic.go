@@ -36,12 +36,6 @@ func (l *GetServiceStatusLogic) GetServiceStatus(serviceType, name string) (resp
 	return &types.ServiceStatusResponse{
 		Code:    0,
 		Message: "success",
-		Data: types.ProviderInfo{
-			Name:         providerInfo.Name,
-			Type:         providerInfo.Type,
-			Status:       providerInfo.Status,
-			Capabilities: providerInfo.Capabilities,
-			Config:       providerInfo.Config,
-		},
+		Data:    toProviderInfo(*providerInfo),
 	}, nil
 }
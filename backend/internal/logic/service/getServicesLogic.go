@@ -28,15 +28,9 @@ func (l *GetServicesLogic) GetServices() (resp *types.ServiceListResponse, err e
 	providers := l.svcCtx.Registry.GetAllProviders()
 	
 	// 转换为 API 响应格式
-	var providerInfos []types.ProviderInfo
+	providerInfos := make([]types.ProviderInfo, 0, len(providers))
 	for _, p := range providers {
-		providerInfos = append(providerInfos, types.ProviderInfo{
-			Name:         p.Name,
-			Type:         p.Type,
-			Status:       p.Status,
-			Capabilities: p.Capabilities,
-			Config:       p.Config,
-		})
+		providerInfos = append(providerInfos, toProviderInfo(p))
 	}
 	
 	return &types.ServiceListResponse{
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 转换为 API 响应格式
+func toProviderInfo(p provider.ProviderInfo) types.ProviderInfo {
+	return types.ProviderInfo{
+		Name:         p.Name,
+		Type:         p.Type,
+		Status:       p.Status,
+		Capabilities: p.Capabilities,
+		Details: types.ProviderCapabilities{
+			Streaming:      p.Details.Streaming,
+			Batch:          p.Details.Batch,
+			AudioFormats:   p.Details.AudioFormats,
+			SampleRates:    p.Details.SampleRates,
+			Voices:         p.Details.Voices,
+			DefaultVoice:   p.Details.DefaultVoice,
+			Models:         p.Details.Models,
+			MaxInputLength: p.Details.MaxInputLength,
+			Languages:      p.Details.Languages,
+		},
+		Health: types.ProviderHealth{
+			Status:              p.Health.Status,
+			LastError:           p.Health.LastError,
+			LastErrorAt:         unixOrZero(p.Health.LastErrorAt),
+			LastCheck:           unixOrZero(p.Health.LastCheck),
+			ConsecutiveFailures: p.Health.ConsecutiveFailures,
+			LatencyP50Ms:        p.Health.LatencyP50.Milliseconds(),
+			LatencyP95Ms:        p.Health.LatencyP95.Milliseconds(),
+		},
+		Config: p.Config,
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/service"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+func TestServiceCapabilities(t *testing.T) {
+	registry := provider.NewRegistry()
+	registry.RegisterLLM("qiniu", provider.NewQiniuLLMProvider("key"))
+	registry.RegisterASR("qiniu", provider.NewQiniuASRProvider("key"))
+	registry.RegisterTTS("qiniu", provider.NewQiniuTTSProvider("key"))
+	svcCtx := &svc.ServiceContext{Registry: registry}
+	ctx := context.Background()
+
+	list, err := service.NewGetServicesByTypeLogic(ctx, svcCtx).GetServicesByType(provider.TypeASR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("asr services = %+v", list.Data)
+	}
+	asr := list.Data[0]
+	if !reflect.DeepEqual(asr.Capabilities, []string{"recognize"}) || asr.Details.Streaming || !asr.Details.Batch ||
+		!reflect.DeepEqual(asr.Details.AudioFormats, []string{"s16le/16000Hz/1ch"}) || !reflect.DeepEqual(asr.Details.SampleRates, []int{16000}) {
+		t.Errorf("asr = %+v", asr)
+	}
+
+	all, err := service.NewGetServicesLogic(ctx, svcCtx).GetServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, p := range all.Data {
+		types = append(types, p.Type)
+	}
+	if !reflect.DeepEqual(types, []string{provider.TypeLLM, provider.TypeASR, provider.TypeTTS}) {
+		t.Errorf("types = %v", types)
+	}
+
+	cases := []struct {
+		name         string
+		providerType string
+		provider     string
+		code         int
+		features     []string
+	}{
+		{"llm", provider.TypeLLM, "qiniu", 0, []string{"chat", "chat_stream"}},
+		{"tts", provider.TypeTTS, "qiniu", 0, []string{"synthesize_stream", "list_voices"}},
+		{"unknown", provider.TypeLLM, "missing", 404, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := service.NewGetServiceStatusLogic(ctx, svcCtx).GetServiceStatus(tc.providerType, tc.provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tc.code || !reflect.DeepEqual(resp.Data.Capabilities, tc.features) {
+				t.Errorf("response = %+v, want code %d, capabilities %v", resp, tc.code, tc.features)
+			}
+		})
+	}
+}
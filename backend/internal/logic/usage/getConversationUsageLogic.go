@@ -0,0 +1,43 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/middleware"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetConversationUsageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetConversationUsageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetConversationUsageLogic {
+	return &GetConversationUsageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetConversationUsageLogic) GetConversationUsage(req *types.ConversationUsageRequest) (resp *types.UsageResponse, err error) {
+	// 其他租户的会话与不存在的会话同样返回 404，不暴露会话是否存在
+	owner, _ := l.svcCtx.Usage.Owner(req.ID)
+	totals, ok := l.svcCtx.Usage.Conversation(req.ID)
+	if !ok || !middleware.CallerFrom(l.ctx).CanAccess(owner) {
+		return &types.UsageResponse{
+			Code:    404,
+			Message: "no usage recorded for conversation " + req.ID,
+		}, nil
+	}
+
+	return &types.UsageResponse{
+		Code:    0,
+		Message: "success",
+		Data:    toUsageTotals(totals, l.svcCtx.Usage.Currency()),
+	}, nil
+}
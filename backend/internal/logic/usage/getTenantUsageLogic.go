@@ -0,0 +1,48 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/middleware"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetTenantUsageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetTenantUsageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetTenantUsageLogic {
+	return &GetTenantUsageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetTenantUsageLogic) GetTenantUsage(req *types.TenantUsageRequest) (resp *types.UsageResponse, err error) {
+	if !middleware.CallerFrom(l.ctx).CanAccess(req.Tenant) {
+		return &types.UsageResponse{
+			Code:    403,
+			Message: "no access to tenant " + req.Tenant,
+		}, nil
+	}
+
+	totals, ok := l.svcCtx.Usage.Tenant(req.Tenant)
+	if !ok {
+		return &types.UsageResponse{
+			Code:    404,
+			Message: "no usage recorded for tenant " + req.Tenant,
+		}, nil
+	}
+
+	return &types.UsageResponse{
+		Code:    0,
+		Message: "success",
+		Data:    toUsageTotals(totals, l.svcCtx.Usage.Currency()),
+	}, nil
+}
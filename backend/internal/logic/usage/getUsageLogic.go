@@ -0,0 +1,50 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/middleware"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetUsageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+func NewGetUsageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetUsageLogic {
+	return &GetUsageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// 管理 API Key 返回所有租户，租户 API Key 只返回本租户
+func (l *GetUsageLogic) GetUsage() (resp *types.UsageListResponse, err error) {
+	meter := l.svcCtx.Usage
+	caller := middleware.CallerFrom(l.ctx)
+
+	tenants := make([]types.TenantUsage, 0)
+	for _, name := range meter.Tenants() {
+		if !caller.CanAccess(name) {
+			continue
+		}
+		if totals, ok := meter.Tenant(name); ok {
+			tenants = append(tenants, types.TenantUsage{
+				Tenant: name,
+				Usage:  toUsageTotals(totals, meter.Currency()),
+			})
+		}
+	}
+
+	return &types.UsageListResponse{
+		Code:    0,
+		Message: "success",
+		Data:    tenants,
+	}, nil
+}
@@ -0,0 +1,25 @@
+package usage
+
+import (
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	usagemeter "github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+)
+
+// 将累计用量转换为响应
+func toUsageTotals(t usagemeter.Totals, currency string) types.UsageTotals {
+	totals := types.UsageTotals{
+		PromptTokens:     t.PromptTokens,
+		CompletionTokens: t.CompletionTokens,
+		TotalTokens:      t.TotalTokens,
+		TTSCharacters:    t.TTSCharacters,
+		ASRSeconds:       t.ASRSeconds,
+		Cost:             t.Cost,
+		Currency:         currency,
+		Estimated:        t.Estimated,
+		Turns:            t.Turns,
+	}
+	if !t.UpdatedAt.IsZero() {
+		totals.UpdatedAt = t.UpdatedAt.Unix()
+	}
+	return totals
+}
@@ -0,0 +1,84 @@
+package usage_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/internal/logic/usage"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/middleware"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/svc"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/types"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	usagemeter "github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+)
+
+const adminKey = "admin-key"
+
+// authenticate 以指定 API Key 经过 TenantAuth 中间件，返回 401 时 ctx 为空
+func authenticate(t *testing.T, meter *usagemeter.Meter, apiKey string) (context.Context, int) {
+	t.Helper()
+
+	var ctx context.Context
+	handler := middleware.NewTenantAuthMiddleware(adminKey, meter).Handle(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	if apiKey != "" {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return ctx, w.Code
+}
+
+func TestUsageScopedToTenant(t *testing.T) {
+	meter := usagemeter.NewMeter("CNY", nil, map[string]string{"key-a": "a", "key-b": "b"})
+	meter.Record("a", "conv-a", model.Usage{TotalTokens: 10})
+	meter.Record("b", "conv-b", model.Usage{TotalTokens: 20})
+	svcCtx := &svc.ServiceContext{Usage: meter}
+
+	cases := []struct {
+		name          string
+		apiKey        string
+		status        int
+		tenants       int // GET /v1/usage 返回的租户数
+		tenantB       int // GET /v1/usage/tenants/b 的 code
+		conversationB int // GET /v1/usage/conversations/conv-b 的 code
+	}{
+		{"missing key", "", http.StatusUnauthorized, 0, 0, 0},
+		{"unknown key", "guess", http.StatusUnauthorized, 0, 0, 0},
+		{"other tenant", "key-a", http.StatusOK, 1, 403, 404},
+		{"own tenant", "key-b", http.StatusOK, 1, 0, 0},
+		{"admin", adminKey, http.StatusOK, 2, 0, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, status := authenticate(t, meter, tc.apiKey)
+			if status != tc.status {
+				t.Fatalf("status = %d, want %d", status, tc.status)
+			}
+			if ctx == nil {
+				return
+			}
+
+			list, _ := usage.NewGetUsageLogic(ctx, svcCtx).GetUsage()
+			if len(list.Data) != tc.tenants {
+				t.Errorf("tenants = %+v, want %d", list.Data, tc.tenants)
+			}
+			tenant, _ := usage.NewGetTenantUsageLogic(ctx, svcCtx).GetTenantUsage(&types.TenantUsageRequest{Tenant: "b"})
+			if tenant.Code != tc.tenantB {
+				t.Errorf("tenant usage code = %d, want %d", tenant.Code, tc.tenantB)
+			}
+			conv, _ := usage.NewGetConversationUsageLogic(ctx, svcCtx).GetConversationUsage(&types.ConversationUsageRequest{ID: "conv-b"})
+			if conv.Code != tc.conversationB {
+				t.Errorf("conversation usage code = %d, want %d", conv.Code, tc.conversationB)
+			}
+			if tc.conversationB == 0 && conv.Data.TotalTokens != 20 {
+				t.Errorf("conversation usage = %+v", conv.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/zeromicro/go-zero/rest/httpx"
+)
+
+// AdminAuthMiddleware 管理接口鉴权：X-API-Key 请求头必须等于配置的管理 API Key，
+// 未配置管理 API Key 时管理接口一律拒绝
+type AdminAuthMiddleware struct {
+	apiKey string
+}
+
+func NewAdminAuthMiddleware(apiKey string) *AdminAuthMiddleware {
+	return &AdminAuthMiddleware{apiKey: apiKey}
+}
+
+func (m *AdminAuthMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if m.apiKey == "" {
+			reject(w, r, http.StatusForbidden, "admin API is disabled, set Admin.APIKey to enable it")
+			return
+		}
+		if !isAdmin(m.apiKey, r) {
+			reject(w, r, http.StatusUnauthorized, "invalid or missing admin API key")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// 请求是否携带管理 API Key
+func isAdmin(apiKey string, r *http.Request) bool {
+	key := r.Header.Get("X-API-Key")
+	return apiKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1
+}
+
+// 鉴权失败的响应，格式与业务响应一致
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func reject(w http.ResponseWriter, r *http.Request, code int, message string) {
+	httpx.WriteJsonCtx(r.Context(), w, code, &errorResponse{Code: code, Message: message})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+)
+
+// Caller 请求方：管理 API Key 可访问所有租户，租户 API Key 只能访问本租户
+type Caller struct {
+	Tenant string
+	Admin  bool
+}
+
+// CanAccess 是否可以访问指定租户的数据
+func (c Caller) CanAccess(tenant string) bool {
+	return c.Admin || c.Tenant == tenant
+}
+
+type callerKey struct{}
+
+// CallerFrom 返回 TenantAuthMiddleware 识别的请求方，未经过该中间件时不可访问任何租户
+func CallerFrom(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// TenantAuthMiddleware 按 X-API-Key 请求头识别请求方的租户，未配置的 API Key 拒绝访问
+type TenantAuthMiddleware struct {
+	adminKey string
+	meter    *usage.Meter
+}
+
+func NewTenantAuthMiddleware(adminKey string, meter *usage.Meter) *TenantAuthMiddleware {
+	return &TenantAuthMiddleware{adminKey: adminKey, meter: meter}
+}
+
+func (m *TenantAuthMiddleware) Handle(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var caller Caller
+		if isAdmin(m.adminKey, r) {
+			caller.Admin = true
+		} else if caller.Tenant = m.meter.TenantOf(r.Header.Get("X-API-Key")); caller.Tenant == usage.AnonymousTenant {
+			reject(w, r, http.StatusUnauthorized, "invalid or missing API key")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, caller)))
+	}
+}
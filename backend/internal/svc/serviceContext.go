@@ -1,82 +1,113 @@
 package svc
 
 import (
-	"os"
-	
 	"github.com/unclewu3242592726/CosTalk/backend/internal/config"
+	"github.com/unclewu3242592726/CosTalk/backend/internal/middleware"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/latency"
 	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/rest"
 )
 
 type ServiceContext struct {
-	Config   config.Config
-	Registry *provider.Registry
+	Config        config.Config
+	Registry      *provider.Registry
+	Conversations conversation.ConversationStore
+	Memory        *conversation.MemoryManager
+	Roles         *role.Catalog
+	Usage         *usage.Meter
+	Latency       *latency.HistogramVec // 对话流水线各阶段耗时
+	AdminAuth     rest.Middleware
+	TenantAuth    rest.Middleware
 }
 
 func NewServiceContext(c config.Config) *ServiceContext {
-	// 创建 Provider Registry
+	// 按配置创建 Provider，缺少密钥等配置错误只跳过对应 Provider
 	registry := provider.NewRegistry()
-	
-	// 注册 Qwen LLM Provider
-	qwenAPIKey := c.Providers.Qwen.APIKey
-	if qwenAPIKey == "" {
-		qwenAPIKey = os.Getenv("QWEN_API_KEY")
-	}
-	if qwenAPIKey != "" {
-		qwenProvider := provider.NewQwenLLMProvider(qwenAPIKey)
-		registry.RegisterLLM("qwen", qwenProvider)
-	}
-	
-	// 注册科大讯飞 ASR/TTS Provider
-	iflytekAppID := c.Providers.Iflytek.AppID
-	iflytekAPISecret := c.Providers.Iflytek.APISecret
-	iflytekAPIKey := c.Providers.Iflytek.APIKey
-	
-	if iflytekAppID == "" {
-		iflytekAppID = os.Getenv("IFLYTEK_APP_ID")
+	specs := make([]provider.Spec, 0, len(c.Providers))
+	for _, p := range c.Providers {
+		specs = append(specs, provider.Spec{
+			Name:     p.Name,
+			Type:     p.Type,
+			Kind:     p.Kind,
+			Settings: p.Settings,
+		})
 	}
-	if iflytekAPISecret == "" {
-		iflytekAPISecret = os.Getenv("IFLYTEK_API_SECRET")
+	if err := registry.Load(specs); err != nil {
+		logx.Errorf("Some providers were not registered:\n%v", err)
 	}
-	if iflytekAPIKey == "" {
-		iflytekAPIKey = os.Getenv("IFLYTEK_API_KEY")
+	for _, kind := range []string{provider.TypeLLM, provider.TypeASR, provider.TypeTTS, provider.TypeModeration} {
+		for _, info := range registry.GetProvidersByType(kind) {
+			logx.Infof("Registered %s provider %s", kind, info.Name)
+		}
 	}
 	
-	if iflytekAppID != "" && iflytekAPISecret != "" && iflytekAPIKey != "" {
-		logx.Infof("Registering iFlytek providers with AppID: %s", iflytekAppID)
-		asrProvider := provider.NewIflytekASRProvider(iflytekAppID, iflytekAPISecret, iflytekAPIKey)
-		ttsProvider := provider.NewIflytekTTSProvider(iflytekAppID, iflytekAPISecret, iflytekAPIKey)
-		
-		registry.RegisterASR("iflytek", asrProvider)
-		registry.RegisterTTS("iflytek", ttsProvider)
-	} else {
-		logx.Errorf("iFlytek configuration incomplete: AppID=%s, APISecret=%s, APIKey=%s", 
-			iflytekAppID, iflytekAPISecret, iflytekAPIKey)
+	// 后台检查 Provider 健康状态
+	if c.Health.Interval > 0 {
+		registry.StartHealthChecks(provider.HealthConfig{
+			Interval:      c.Health.Interval,
+			Timeout:       c.Health.Timeout,
+			OfflineAfter:  c.Health.OfflineAfter,
+			SlowThreshold: c.Health.SlowThreshold,
+			Window:        c.Health.Window,
+		})
 	}
 	
-	// 注册七牛云 LLM/ASR/TTS Provider
-	qiniuAPIKey := c.Providers.Qiniu.APIKey
-	
-	if qiniuAPIKey == "" {
-		qiniuAPIKey = os.Getenv("QINIU_API_KEY")
+	// 创建会话存储
+	var conversations conversation.ConversationStore
+	switch c.Conversation.Store {
+	case "file":
+		fileStore, err := conversation.NewFileStore(c.Conversation.Path)
+		if err != nil {
+			logx.Must(err)
+		}
+		logx.Infof("Using file conversation store: %s", c.Conversation.Path)
+		conversations = fileStore
+	default:
+		conversations = conversation.NewMemoryStore()
 	}
 	
-	if qiniuAPIKey != "" {
-		// 注册七牛云 LLM Provider
-		qiniuLLMProvider := provider.NewQiniuLLMProvider(qiniuAPIKey)
-		registry.RegisterLLM("qiniu", qiniuLLMProvider)
-		
-		// 注册七牛云 ASR Provider
-		qiniuASRProvider := provider.NewQiniuASRProvider(qiniuAPIKey)
-		registry.RegisterASR("qiniu", qiniuASRProvider)
-		
-		// 注册七牛云 TTS Provider
-		qiniuTTSProvider := provider.NewQiniuTTSProvider(qiniuAPIKey)
-		registry.RegisterTTS("qiniu", qiniuTTSProvider)
+	// 加载角色目录
+	roles, err := role.NewCatalog(c.Roles.Dir)
+	logx.Must(err)
+	if c.Roles.ReloadInterval > 0 {
+		roles.Watch(c.Roles.ReloadInterval)
 	}
 	
+	// 用量计价
+	prices := make([]usage.Price, 0, len(c.Usage.Prices))
+	for _, p := range c.Usage.Prices {
+		prices = append(prices, usage.Price{
+			Kind:            p.Kind,
+			Provider:        p.Provider,
+			Model:           p.Model,
+			PromptPer1K:     p.PromptPer1K,
+			CompletionPer1K: p.CompletionPer1K,
+			PerChar:         p.PerChar,
+			PerSecond:       p.PerSecond,
+		})
+	}
+	tenants := make(map[string]string, len(c.Usage.Tenants))
+	for _, t := range c.Usage.Tenants {
+		if t.APIKey != "" {
+			tenants[t.APIKey] = t.Name
+		}
+	}
+	meter := usage.NewMeter(c.Usage.Currency, prices, tenants)
+
 	return &ServiceContext{
-		Config:   c,
-		Registry: registry,
+		Config:        c,
+		Registry:      registry,
+		Conversations: conversations,
+		Memory:        conversation.NewMemoryManager(conversations, c.Conversation.WindowSize, c.Conversation.TokenBudget),
+		Roles:         roles,
+		Usage:         meter,
+		Latency:       latency.NewHistogramVec(latency.PipelineBuckets),
+		AdminAuth:     middleware.NewAdminAuthMiddleware(c.Admin.APIKey).Handle,
+		TenantAuth:    middleware.NewTenantAuthMiddleware(c.Admin.APIKey, meter).Handle,
 	}
 }
+
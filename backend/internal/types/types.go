@@ -20,6 +20,14 @@ type ChatRequest struct {
 	TTSConfig map[string]interface{} `json:"ttsConfig,optional"`
 }
 
+type ConversationUsageRequest struct {
+	ID string `path:"id"`
+}
+
+type DeleteRoleRequest struct {
+	ID string `path:"id"`
+}
+
 type ChatResponse struct {
 	Code    int      `json:"code"`
 	Message string   `json:"message"`
@@ -27,17 +35,59 @@ type ChatResponse struct {
 }
 
 type HealthResponse struct {
-	Status  string `json:"status"`
-	Service string `json:"service"`
-	Version string `json:"version"`
+	Status    string                     `json:"status"` // online|degraded|offline
+	Service   string                     `json:"service"`
+	Version   string                     `json:"version"`
+	Providers map[string]ProviderHealth  `json:"providers"`         // key: type/name
+	Pipeline  map[string]PipelineLatency `json:"pipeline,optional"` // key: 计时节点
+}
+
+type PipelineLatency struct {
+	Count int64 `json:"count"`
+	P50Ms int64 `json:"p50Ms"`
+	P95Ms int64 `json:"p95Ms"`
+}
+
+type LLMSettings struct {
+	Provider    string   `json:"provider,optional"`
+	Model       string   `json:"model,optional"`
+	Temperature *float64 `json:"temperature,optional"`
+	TopP        *float64 `json:"topP,optional"`
+	MaxTokens   int      `json:"maxTokens,optional"`
+	Stop        []string `json:"stop,optional"`
+	Seed        *int     `json:"seed,optional"`
+}
+
+type ProviderCapabilities struct {
+	Streaming      bool     `json:"streaming"`
+	Batch          bool     `json:"batch"`
+	AudioFormats   []string `json:"audioFormats,optional"`
+	SampleRates    []int    `json:"sampleRates,optional"`
+	Voices         []string `json:"voices,optional"`
+	DefaultVoice   string   `json:"defaultVoice,optional"`
+	Models         []string `json:"models,optional"`
+	MaxInputLength int      `json:"maxInputLength,optional"` // LLM 为 token，TTS 为字符，ASR 为音频秒数
+	Languages      []string `json:"languages,optional"`
+}
+
+type ProviderHealth struct {
+	Status              string `json:"status"` // unknown|online|degraded|offline
+	LastError           string `json:"lastError,optional"`
+	LastErrorAt         int64  `json:"lastErrorAt,optional"` // Unix 秒
+	LastCheck           int64  `json:"lastCheck,optional"`   // Unix 秒
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LatencyP50Ms        int64  `json:"latencyP50Ms"`
+	LatencyP95Ms        int64  `json:"latencyP95Ms"`
 }
 
 type ProviderInfo struct {
-	Name         string            `json:"name"`
-	Type         string            `json:"type"`   // llm|asr|tts|moderation
-	Status       string            `json:"status"` // online|offline|error
-	Capabilities []string          `json:"capabilities,optional"`
-	Config       map[string]string `json:"config,optional"`
+	Name         string               `json:"name"`
+	Type         string               `json:"type"`   // llm|asr|tts|moderation
+	Status       string               `json:"status"` // unknown|online|degraded|offline
+	Capabilities []string             `json:"capabilities,optional"`
+	Details      ProviderCapabilities `json:"details"`
+	Health       ProviderHealth       `json:"health"`
+	Config       map[string]string    `json:"config,optional"`
 }
 
 type Role struct {
@@ -49,6 +99,26 @@ type Role struct {
 	TTSDefault  map[string]string `json:"ttsDefault"`
 }
 
+type RoleDetail struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Avatar       string            `json:"avatar"`
+	Description  string            `json:"description"`
+	SystemPrompt string            `json:"systemPrompt"`
+	Guardrails   []string          `json:"guardrails"`
+	Refusal      string            `json:"refusal"`
+	TTSDefault   map[string]string `json:"ttsDefault"`
+	LLMDefault   *LLMSettings      `json:"llmDefault,optional"`
+	Skills       []string          `json:"skills"`
+	Version      int               `json:"version"`
+}
+
+type RoleDetailResponse struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    RoleDetail `json:"data"`
+}
+
 type RolesResponse struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -67,6 +137,54 @@ type ServiceStatusResponse struct {
 	Data    ProviderInfo `json:"data"`
 }
 
+type TenantUsage struct {
+	Tenant string      `json:"tenant"`
+	Usage  UsageTotals `json:"usage"`
+}
+
+type TenantUsageRequest struct {
+	Tenant string `path:"tenant"`
+}
+
+type UpsertRoleRequest struct {
+	ID           string            `path:"id"`
+	Name         string            `json:"name"`
+	Avatar       string            `json:"avatar,optional"`
+	Description  string            `json:"description,optional"`
+	SystemPrompt string            `json:"systemPrompt"`
+	Guardrails   []string          `json:"guardrails,optional"`
+	Refusal      string            `json:"refusal,optional"` // 角色口吻的拒答语
+	TTSDefault   map[string]string `json:"ttsDefault,optional"`
+	LLMDefault   *LLMSettings      `json:"llmDefault,optional"`
+	Skills       []string          `json:"skills,optional"`
+	Version      int               `json:"version,optional"` // 更新时的期望版本，用于乐观锁
+}
+
+type UsageListResponse struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    []TenantUsage `json:"data"`
+}
+
+type UsageResponse struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    UsageTotals `json:"data"`
+}
+
+type UsageTotals struct {
+	PromptTokens     int     `json:"promptTokens"`
+	CompletionTokens int     `json:"completionTokens"`
+	TotalTokens      int     `json:"totalTokens"`
+	TTSCharacters    int     `json:"ttsCharacters"`
+	ASRSeconds       float64 `json:"asrSeconds"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency"`
+	Estimated        bool    `json:"estimated"` // 部分 token 数为估算值
+	Turns            int     `json:"turns"`
+	UpdatedAt        int64   `json:"updatedAt"` // Unix 秒
+}
+
 type WSFrame struct {
 	Type    string      `json:"type"`
 	Seq     int         `json:"seq,optional"`
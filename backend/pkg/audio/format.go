@@ -0,0 +1,151 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// 采样编码
+const (
+	EncodingU8    = "u8"    // 8bit 无符号
+	EncodingS16LE = "s16le" // 16bit 小端有符号
+	EncodingS24LE = "s24le" // 24bit 小端有符号
+	EncodingS32LE = "s32le" // 32bit 小端有符号
+	EncodingF32LE = "f32le" // 32bit 小端浮点
+)
+
+// 容器类型
+const (
+	ContainerRaw  = "raw" // 无容器的裸 PCM
+	ContainerWAV  = "wav"
+	ContainerOgg  = "ogg"
+	ContainerWebM = "webm"
+	ContainerMP3  = "mp3"
+	ContainerAAC  = "aac"
+	ContainerMP4  = "mp4"
+	ContainerFLAC = "flac"
+	ContainerAMR  = "amr"
+)
+
+var (
+	ErrUnsupportedContainer = errors.New("unsupported audio container")
+	ErrUnsupportedFormat    = errors.New("unsupported audio format")
+)
+
+// Format PCM 音频格式
+type Format struct {
+	Encoding   string `json:"encoding"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+}
+
+// PCM16kMono 16kHz 单声道 16bit PCM，大多数 ASR 服务的输入格式
+var PCM16kMono = Format{Encoding: EncodingS16LE, SampleRate: 16000, Channels: 1}
+
+func (f Format) String() string {
+	return fmt.Sprintf("%s/%dHz/%dch", f.Encoding, f.SampleRate, f.Channels)
+}
+
+// BytesPerSample 单个采样的字节数，编码未知时返回 0
+func (f Format) BytesPerSample() int {
+	switch f.Encoding {
+	case EncodingU8:
+		return 1
+	case EncodingS16LE:
+		return 2
+	case EncodingS24LE:
+		return 3
+	case EncodingS32LE, EncodingF32LE:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Validate 校验格式参数
+func (f Format) Validate() error {
+	if f.BytesPerSample() == 0 {
+		return fmt.Errorf("%w: encoding '%s'", ErrUnsupportedFormat, f.Encoding)
+	}
+	if f.SampleRate < 1000 || f.SampleRate > 384000 {
+		return fmt.Errorf("%w: sample rate %d", ErrUnsupportedFormat, f.SampleRate)
+	}
+	if f.Channels < 1 || f.Channels > 8 {
+		return fmt.Errorf("%w: %d channels", ErrUnsupportedFormat, f.Channels)
+	}
+	return nil
+}
+
+// EncodingForBits 按位深返回整型 PCM 编码（8bit 为无符号，其余为小端有符号）
+func EncodingForBits(bits int) (string, error) {
+	switch bits {
+	case 8:
+		return EncodingU8, nil
+	case 16:
+		return EncodingS16LE, nil
+	case 24:
+		return EncodingS24LE, nil
+	case 32:
+		return EncodingS32LE, nil
+	default:
+		return "", fmt.Errorf("%w: %d bits per sample", ErrUnsupportedFormat, bits)
+	}
+}
+
+// DetectContainer 根据文件头识别容器类型，无法识别时视为裸 PCM
+func DetectContainer(data []byte) string {
+	switch {
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE")):
+		return ContainerWAV
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return ContainerOgg
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return ContainerWebM
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return ContainerFLAC
+	case bytes.HasPrefix(data, []byte("#!AMR")):
+		return ContainerAMR
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return ContainerMP3
+	case len(data) >= 8 && bytes.Equal(data[4:8], []byte("ftyp")):
+		return ContainerMP4
+	}
+
+	if container := detectFrameSync(data); container != "" {
+		return container
+	}
+	return ContainerRaw
+}
+
+// detectFrameSync 识别没有 ID3 标签的 MP3 与 ADTS AAC 帧头
+//
+// 裸 PCM 中 0xFFFF（-1）很常见，因此要求帧头的版本、码率与采样率字段均合法。
+func detectFrameSync(data []byte) string {
+	if len(data) < 4 || data[0] != 0xFF || data[1]&0xE0 != 0xE0 {
+		return ""
+	}
+
+	version := (data[1] >> 3) & 0x03
+	layer := (data[1] >> 1) & 0x03
+
+	// ADTS：同步字 12 位全 1，layer 固定为 0
+	if data[1]&0xF0 == 0xF0 && layer == 0 {
+		if (data[2]>>2)&0x0F < 13 {
+			return ContainerAAC
+		}
+		return ""
+	}
+
+	bitrate := data[2] >> 4
+	sampleRate := (data[2] >> 2) & 0x03
+	if version == 1 || layer == 0 || bitrate == 0 || bitrate == 0x0F || sampleRate == 0x03 {
+		return ""
+	}
+	return ContainerMP3
+}
+
+// unsupportedContainer 构造明确的不支持容器错误
+func unsupportedContainer(container string) error {
+	return fmt.Errorf("%w: %s audio cannot be decoded, send WAV or raw PCM instead", ErrUnsupportedContainer, container)
+}
@@ -0,0 +1,369 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// WAV 文件头的最大缓冲长度，超过后仍未找到 data 块视为无效文件
+const maxWAVHeader = 64 * 1024
+
+// Transcoder 把客户端音频转换为 ASR Provider 的输入格式
+//
+// 首块数据决定源格式：WAV 按文件头解析，无法识别的数据按 hint 作为裸 PCM 处理，
+// MP3、Ogg、WebM 等压缩容器直接报错。之后的数据依次解码、混音、重采样并编码为 16bit PCM，
+// 块之间的采样帧余量与重采样相位会被保留，因此既可用于整段音频也可用于分块的实时音频流。
+type Transcoder struct {
+	hint     Format
+	accepted []Format
+
+	started   bool
+	err       error  // 无法解码时的错误，之后的数据不再处理
+	header    []byte // 等待完整的 WAV 文件头
+	remaining int    // WAV data 块剩余字节数，-1 表示直到流结束
+
+	source      Format
+	target      Format
+	passthrough bool
+
+	pending []byte     // 不足一个采样帧的数据
+	filters [][]biquad // 降采样前的抗混叠滤波，按目标声道
+	pos     float64    // 重采样位置，相对上一块的最后一个采样
+	prev    []float64  // 上一块的最后一个采样，按目标声道
+	hasPrev bool
+}
+
+// NewTranscoder 创建转码器：hint 为裸 PCM 的格式，accepted 为 Provider 接受的输入格式（按优先级）
+func NewTranscoder(hint Format, accepted []Format) (*Transcoder, error) {
+	if err := hint.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 目前只能输出 16bit PCM
+	var targets []Format
+	for _, f := range accepted {
+		if f.Encoding == EncodingS16LE && f.Validate() == nil {
+			targets = append(targets, f)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("%w: provider accepts no 16-bit PCM input", ErrUnsupportedFormat)
+	}
+
+	return &Transcoder{hint: hint, accepted: targets, remaining: -1}, nil
+}
+
+// Transcode 一次性转换整段音频，返回转换后的数据与其格式
+func Transcode(data []byte, hint Format, accepted []Format) ([]byte, Format, error) {
+	t, err := NewTranscoder(hint, accepted)
+	if err != nil {
+		return nil, Format{}, err
+	}
+
+	out, err := t.Write(data)
+	if err != nil {
+		return nil, Format{}, err
+	}
+	if !t.started {
+		return nil, Format{}, fmt.Errorf("invalid wav file: %v", errShortHeader)
+	}
+
+	return out, t.target, nil
+}
+
+// Ready 源格式是否已确定
+func (t *Transcoder) Ready() bool {
+	return t.started
+}
+
+// Source 源音频格式，Ready 之后有效
+func (t *Transcoder) Source() Format {
+	return t.source
+}
+
+// Target 输出音频格式，Ready 之后有效
+func (t *Transcoder) Target() Format {
+	return t.target
+}
+
+// Write 转换一块音频数据；WAV 文件头未完整到达时返回空数据并等待后续数据
+func (t *Transcoder) Write(data []byte) ([]byte, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+
+	if !t.started {
+		var err error
+		if data, err = t.start(data); err != nil {
+			t.err = err
+			return nil, err
+		}
+		if !t.started {
+			return nil, nil
+		}
+	}
+
+	// WAV data 块之后的其他块（如 LIST）不是音频
+	if t.remaining >= 0 {
+		if len(data) > t.remaining {
+			data = data[:t.remaining]
+		}
+		t.remaining -= len(data)
+	}
+
+	if t.passthrough {
+		return t.passthroughFrames(data), nil
+	}
+	return t.convert(data), nil
+}
+
+// start 识别容器并确定源格式与输出格式，返回去掉文件头后的音频数据
+func (t *Transcoder) start(data []byte) ([]byte, error) {
+	if len(t.header) > 0 {
+		data = append(t.header, data...)
+	}
+
+	switch container := DetectContainer(data); container {
+	case ContainerWAV:
+		format, offset, size, err := parseWAVHeader(data)
+		if errors.Is(err, errShortHeader) {
+			if len(data) > maxWAVHeader {
+				return nil, fmt.Errorf("invalid wav file: no data chunk in first %d bytes", maxWAVHeader)
+			}
+			t.header = append([]byte(nil), data...)
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t.source = format
+		t.remaining = size
+		data = data[offset:]
+
+	case ContainerRaw:
+		// 不足 12 字节时无法区分 WAV 文件头与裸 PCM，先缓冲
+		if len(data) < 12 && strings.HasPrefix("RIFF", string(data[:min(len(data), 4)])) {
+			t.header = append([]byte(nil), data...)
+			return nil, nil
+		}
+		t.source = t.hint
+
+	default:
+		return nil, unsupportedContainer(container)
+	}
+
+	t.header = nil
+	t.started = true
+	t.target = chooseTarget(t.source, t.accepted)
+	t.passthrough = t.source == t.target
+
+	if !t.passthrough {
+		t.prev = make([]float64, t.target.Channels)
+		if t.target.SampleRate < t.source.SampleRate {
+			// 截止频率略低于目标采样率的奈奎斯特频率，两级级联
+			cutoff := 0.45 * float64(t.target.SampleRate)
+			t.filters = make([][]biquad, t.target.Channels)
+			for c := range t.filters {
+				t.filters[c] = []biquad{
+					newLowPass(cutoff, float64(t.source.SampleRate)),
+					newLowPass(cutoff, float64(t.source.SampleRate)),
+				}
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// chooseTarget 选择输出格式：源格式可直接接受时不转换，其次保持采样率，否则使用首选格式
+func chooseTarget(source Format, accepted []Format) Format {
+	for _, f := range accepted {
+		if f == source {
+			return f
+		}
+	}
+	for _, f := range accepted {
+		if f.SampleRate == source.SampleRate {
+			return f
+		}
+	}
+	return accepted[0]
+}
+
+// 直接透传，只保证输出按采样帧对齐
+func (t *Transcoder) passthroughFrames(data []byte) []byte {
+	frame := t.source.BytesPerSample() * t.source.Channels
+	if len(t.pending) > 0 {
+		data = append(t.pending, data...)
+	}
+
+	n := len(data) / frame * frame
+	t.pending = append([]byte(nil), data[n:]...)
+	return data[:n]
+}
+
+func (t *Transcoder) convert(data []byte) []byte {
+	src, dst := t.source, t.target
+	width := src.BytesPerSample()
+	frame := width * src.Channels
+
+	if len(t.pending) > 0 {
+		data = append(t.pending, data...)
+	}
+	n := len(data) / frame
+	t.pending = append([]byte(nil), data[n*frame:]...)
+	if n == 0 {
+		return nil
+	}
+
+	// 解码并映射声道
+	channels := make([][]float64, dst.Channels)
+	for c := range channels {
+		channels[c] = make([]float64, n)
+	}
+	samples := make([]float64, src.Channels)
+	for i := 0; i < n; i++ {
+		for c := range samples {
+			samples[c] = decodeSample(src.Encoding, data[i*frame+c*width:])
+		}
+		mixChannels(samples, channels, i)
+	}
+
+	// 抗混叠滤波
+	for c, chain := range t.filters {
+		for i := range chain {
+			chain[i].process(channels[c])
+		}
+	}
+
+	if src.SampleRate != dst.SampleRate {
+		channels = t.resample(channels)
+	}
+
+	return encodeS16LE(channels)
+}
+
+// mixChannels 声道映射：多声道混为单声道取平均，单声道扩展为多声道时复制
+func mixChannels(samples []float64, channels [][]float64, i int) {
+	switch {
+	case len(samples) == len(channels):
+		for c, s := range samples {
+			channels[c][i] = s
+		}
+	case len(channels) == 1:
+		var sum float64
+		for _, s := range samples {
+			sum += s
+		}
+		channels[0][i] = sum / float64(len(samples))
+	default:
+		for c := range channels {
+			channels[c][i] = samples[c%len(samples)]
+		}
+	}
+}
+
+// resample 线性插值重采样
+func (t *Transcoder) resample(in [][]float64) [][]float64 {
+	step := float64(t.source.SampleRate) / float64(t.target.SampleRate)
+
+	// 缓冲区下标 0 为上一块的最后一个采样（若有）
+	offset := 0
+	if t.hasPrev {
+		offset = 1
+	}
+	total := len(in[0]) + offset
+	at := func(c, i int) float64 {
+		if i < offset {
+			return t.prev[c]
+		}
+		return in[c][i-offset]
+	}
+
+	out := make([][]float64, len(in))
+	for {
+		i := int(t.pos)
+		if i+1 >= total {
+			break
+		}
+		frac := t.pos - float64(i)
+		for c := range in {
+			out[c] = append(out[c], at(c, i)*(1-frac)+at(c, i+1)*frac)
+		}
+		t.pos += step
+	}
+
+	t.pos -= float64(total - 1)
+	for c := range in {
+		t.prev[c] = at(c, total-1)
+	}
+	t.hasPrev = true
+
+	return out
+}
+
+// decodeSample 解码单个采样并归一化到 [-1, 1)
+func decodeSample(encoding string, b []byte) float64 {
+	switch encoding {
+	case EncodingU8:
+		return (float64(b[0]) - 128) / 128
+	case EncodingS16LE:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+	case EncodingS24LE:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / 8388608
+	case EncodingS32LE:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+	case EncodingF32LE:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	default:
+		return 0
+	}
+}
+
+// encodeS16LE 交织编码为 16bit 小端 PCM
+func encodeS16LE(channels [][]float64) []byte {
+	n := len(channels[0])
+	out := make([]byte, n*len(channels)*2)
+	for i := 0; i < n; i++ {
+		for c := range channels {
+			v := math.Round(channels[c][i] * 32767)
+			v = math.Max(-32768, math.Min(32767, v))
+			binary.LittleEndian.PutUint16(out[(i*len(channels)+c)*2:], uint16(int16(v)))
+		}
+	}
+	return out
+}
+
+// biquad 二阶 IIR 低通滤波（Butterworth，Q=0.707）
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func newLowPass(cutoff, sampleRate float64) biquad {
+	w0 := 2 * math.Pi * cutoff / sampleRate
+	alpha := math.Sin(w0) / math.Sqrt2 // sin(w0) / (2Q)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	return biquad{
+		b0: (1 - cos) / 2 / a0,
+		b1: (1 - cos) / a0,
+		b2: (1 - cos) / 2 / a0,
+		a1: -2 * cos / a0,
+		a2: (1 - alpha) / a0,
+	}
+}
+
+func (f *biquad) process(samples []float64) {
+	for i, x := range samples {
+		y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+		f.x2, f.x1 = f.x1, x
+		f.y2, f.y1 = f.y1, y
+		samples[i] = y
+	}
+}
@@ -0,0 +1,301 @@
+package audio_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+)
+
+// constant 生成 n 个采样帧的 16bit PCM，各声道取固定值
+func constant(n int, values ...float64) []byte {
+	var pcm []byte
+	for i := 0; i < n; i++ {
+		for _, v := range values {
+			pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(v*32767)))
+		}
+	}
+	return pcm
+}
+
+// decode16 解码 16bit PCM 并归一化
+func decode16(pcm []byte) []float64 {
+	out := make([]float64, len(pcm)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32767
+	}
+	return out
+}
+
+func format(encoding string, sampleRate, channels int) audio.Format {
+	return audio.Format{Encoding: encoding, SampleRate: sampleRate, Channels: channels}
+}
+
+func TestTranscode(t *testing.T) {
+	s16 := audio.EncodingS16LE
+	mono16k := []audio.Format{audio.PCM16kMono}
+
+	cases := []struct {
+		name     string
+		data     []byte
+		hint     audio.Format
+		accepted []audio.Format
+		target   audio.Format
+		samples  int     // 输出的采样数（所有声道）
+		value    float64 // 稳定后的采样值
+	}{
+		{
+			name:     "passthrough",
+			data:     constant(1600, 0.5),
+			hint:     audio.PCM16kMono,
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.5,
+		},
+		{
+			// 多声道取平均混为单声道
+			name:     "downmix",
+			data:     constant(1600, 0.5, -0.1),
+			hint:     format(s16, 16000, 2),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.2,
+		},
+		{
+			name:     "upmix",
+			data:     constant(1600, 0.3),
+			hint:     audio.PCM16kMono,
+			accepted: []audio.Format{format(s16, 16000, 2)},
+			target:   format(s16, 16000, 2),
+			samples:  3200,
+			value:    0.3,
+		},
+		{
+			// 48kHz 降采样为 16kHz，采样数为三分之一
+			name:     "downsample",
+			data:     constant(4800, 0.25),
+			hint:     format(s16, 48000, 1),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.25,
+		},
+		{
+			// 8kHz 升采样为 16kHz，插值到倒数第二个采样之后为止，最后一个采样留待下一块
+			name:     "upsample",
+			data:     constant(800, -0.4),
+			hint:     format(s16, 8000, 1),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1598,
+			value:    -0.4,
+		},
+		{
+			// 源采样率可接受时保持采样率
+			name:     "keep sample rate",
+			data:     constant(800, 0.1),
+			hint:     format(s16, 8000, 1),
+			accepted: []audio.Format{audio.PCM16kMono, format(s16, 8000, 1)},
+			target:   format(s16, 8000, 1),
+			samples:  800,
+			value:    0.1,
+		},
+		{
+			name:     "u8",
+			data:     bytes.Repeat([]byte{192}, 1600),
+			hint:     format(audio.EncodingU8, 16000, 1),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.5,
+		},
+		{
+			name:     "s24",
+			data:     bytes.Repeat([]byte{0x00, 0x00, 0xC0}, 1600),
+			hint:     format(audio.EncodingS24LE, 16000, 1),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    -0.5,
+		},
+		{
+			name:     "f32",
+			data:     bytes.Repeat(binary.LittleEndian.AppendUint32(nil, math.Float32bits(0.75)), 1600),
+			hint:     format(audio.EncodingF32LE, 16000, 1),
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.75,
+		},
+		{
+			// WAV 文件头决定源格式，hint 被忽略，data 之后的块不是音频
+			name:     "wav",
+			data:     riff(fmtChunk(1, 2, 32000, 16, false), chunk("data", constant(3200, 0.6, 0.2)), chunk("LIST", []byte("INFO"))),
+			hint:     audio.PCM16kMono,
+			accepted: mono16k,
+			target:   audio.PCM16kMono,
+			samples:  1600,
+			value:    0.4,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, target, err := audio.Transcode(tc.data, tc.hint, tc.accepted)
+			if err != nil {
+				t.Fatalf("Transcode: %v", err)
+			}
+			if target != tc.target {
+				t.Errorf("target = %v, want %v", target, tc.target)
+			}
+
+			samples := decode16(out)
+			if len(samples) != tc.samples {
+				t.Fatalf("got %d samples, want %d", len(samples), tc.samples)
+			}
+			// 降采样的低通滤波有起始过渡，只检查后半段
+			for i, v := range samples[len(samples)/2:] {
+				if math.Abs(v-tc.value) > 0.001 {
+					t.Fatalf("sample %d = %.4f, want %.4f", len(samples)/2+i, v, tc.value)
+				}
+			}
+		})
+	}
+}
+
+func TestTranscoderChunks(t *testing.T) {
+	// 441Hz 正弦波，44.1kHz 立体声 WAV 转为 16kHz 单声道
+	n := 44100 / 2
+	var pcm []byte
+	for i := 0; i < n; i++ {
+		v := 0.5 * math.Sin(2*math.Pi*441*float64(i)/44100)
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(v*32767)))
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(v*32767)))
+	}
+	wav := riff(fmtChunk(1, 2, 44100, 16, false), chunk("data", pcm))
+
+	whole, _, err := audio.Transcode(wav, audio.PCM16kMono, []audio.Format{audio.PCM16kMono})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := n * 16000 / 44100; len(whole)/2 < want-1 || len(whole)/2 > want+1 {
+		t.Errorf("got %d samples, want about %d", len(whole)/2, want)
+	}
+
+	// 文件头与采样帧被切在块中间，分块转换的结果与整段一致
+	for _, size := range []int{7, 333, 4096} {
+		tr, err := audio.NewTranscoder(audio.PCM16kMono, []audio.Format{audio.PCM16kMono})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var chunked []byte
+		for data := wav; len(data) > 0; {
+			k := min(len(data), size)
+			out, err := tr.Write(data[:k])
+			if err != nil {
+				t.Fatalf("chunk size %d: %v", size, err)
+			}
+			chunked = append(chunked, out...)
+			data = data[k:]
+		}
+
+		a, b := decode16(whole), decode16(chunked)
+		if len(a) != len(b) {
+			t.Fatalf("chunk size %d: got %d samples, want %d", size, len(b), len(a))
+		}
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > 2.0/32767 {
+				t.Fatalf("chunk size %d: sample %d = %.5f, want %.5f", size, i, b[i], a[i])
+			}
+		}
+	}
+}
+
+func TestTranscodeRejects(t *testing.T) {
+	cases := []struct {
+		name     string
+		data     []byte
+		hint     audio.Format
+		accepted []audio.Format
+		want     error  // errors.Is 匹配，为空时检查 contains
+		contains string // 错误应包含的内容
+	}{
+		{
+			name:     "mp3",
+			data:     []byte("ID3\x04\x00\x00\x00\x00\x00\x00"),
+			hint:     audio.PCM16kMono,
+			accepted: []audio.Format{audio.PCM16kMono},
+			want:     audio.ErrUnsupportedContainer,
+		},
+		{
+			name:     "truncated wav",
+			data:     riff(fmtChunk(1, 1, 16000, 16, false))[:20],
+			hint:     audio.PCM16kMono,
+			accepted: []audio.Format{audio.PCM16kMono},
+			contains: "invalid wav file",
+		},
+		{
+			name:     "a-law wav",
+			data:     riff(fmtChunk(6, 1, 8000, 8, false), chunk("data", []byte{1, 2})),
+			hint:     audio.PCM16kMono,
+			accepted: []audio.Format{audio.PCM16kMono},
+			want:     audio.ErrUnsupportedFormat,
+		},
+		{
+			name:     "invalid hint",
+			data:     constant(10, 0),
+			hint:     format(audio.EncodingS16LE, 16000, 0),
+			accepted: []audio.Format{audio.PCM16kMono},
+			want:     audio.ErrUnsupportedFormat,
+		},
+		{
+			// 目前只能输出 16bit PCM
+			name:     "no 16-bit target",
+			data:     constant(10, 0),
+			hint:     audio.PCM16kMono,
+			accepted: []audio.Format{format(audio.EncodingF32LE, 16000, 1)},
+			want:     audio.ErrUnsupportedFormat,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := audio.Transcode(tc.data, tc.hint, tc.accepted)
+			switch {
+			case err == nil:
+				t.Fatal("Transcode succeeded")
+			case tc.want != nil && !errors.Is(err, tc.want):
+				t.Errorf("err = %v, want %v", err, tc.want)
+			case tc.want == nil && !strings.Contains(err.Error(), tc.contains):
+				t.Errorf("err = %v, want it to contain %q", err, tc.contains)
+			}
+		})
+	}
+}
+
+func TestTranscoderWaitsForHeader(t *testing.T) {
+	wav := riff(fmtChunk(1, 1, 16000, 16, false), chunk("data", constant(160, 0.5)))
+	tr, err := audio.NewTranscoder(audio.PCM16kMono, []audio.Format{audio.PCM16kMono})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不足 12 字节时无法区分 WAV 与裸 PCM，之后文件头不完整时继续等待
+	if out, err := tr.Write(wav[:4]); err != nil || len(out) != 0 || tr.Ready() {
+		t.Fatalf("after 4 bytes: out = %d bytes, err = %v, ready = %v", len(out), err, tr.Ready())
+	}
+	if out, err := tr.Write(wav[4:30]); err != nil || len(out) != 0 || tr.Ready() {
+		t.Fatalf("after 30 bytes: out = %d bytes, err = %v, ready = %v", len(out), err, tr.Ready())
+	}
+	out, err := tr.Write(wav[30:])
+	if err != nil || !tr.Ready() || len(out) != 320 {
+		t.Fatalf("out = %d bytes, err = %v, ready = %v", len(out), err, tr.Ready())
+	}
+	if tr.Source() != audio.PCM16kMono || tr.Target() != audio.PCM16kMono {
+		t.Errorf("source = %v, target = %v", tr.Source(), tr.Target())
+	}
+}
@@ -0,0 +1,205 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// VAD 事件类型
+const (
+	EventSpeechStart = "speech_start"
+	EventSpeechEnd   = "speech_end"
+)
+
+// VADConfig 端点检测参数，零值字段使用默认值
+type VADConfig struct {
+	SampleRate      int     // 采样率，默认 16000
+	FrameMs         int     // 分析帧长，默认 20ms
+	EnergyThreshold float64 // 语音帧的最小 RMS 能量（归一化到 0-1），默认 0.015
+	ZCRThreshold    float64 // 清音判定的过零率下限，默认 0.25
+	MinSpeechMs     int     // 连续语音达到该时长才判定开始说话，默认 100ms
+	SilenceMs       int     // 连续静音达到该时长判定说话结束，默认 800ms
+	MaxSpeechMs     int     // 单句最长时长，超过后强制结束，默认 30s，0 表示使用默认值
+}
+
+func (c VADConfig) withDefaults() VADConfig {
+	if c.SampleRate <= 0 {
+		c.SampleRate = 16000
+	}
+	if c.FrameMs <= 0 {
+		c.FrameMs = 20
+	}
+	if c.EnergyThreshold <= 0 {
+		c.EnergyThreshold = 0.015
+	}
+	if c.ZCRThreshold <= 0 {
+		c.ZCRThreshold = 0.25
+	}
+	if c.MinSpeechMs <= 0 {
+		c.MinSpeechMs = 100
+	}
+	if c.SilenceMs <= 0 {
+		c.SilenceMs = 800
+	}
+	if c.MaxSpeechMs <= 0 {
+		c.MaxSpeechMs = 30000
+	}
+	return c
+}
+
+// VADEvent 端点事件，Offset 为事件在音频流中的位置
+type VADEvent struct {
+	Type   string
+	Offset time.Duration
+}
+
+// VAD 基于短时能量与过零率的端点检测，输入为 16bit 小端单声道 PCM
+//
+// 能量超过阈值的帧视为浊音；能量稍低但过零率高的帧视为清音（如 s、sh 等摩擦音）。
+// 阈值会随背景噪声自适应抬高，避免持续噪声被误判为语音。
+type VAD struct {
+	cfg         VADConfig
+	frameBytes  int
+	pending     []byte
+	frames      int // 已处理帧数
+	inSpeech    bool
+	speechRun   int // 连续语音帧
+	silenceRun  int // 连续静音帧
+	speechStart int // 当前语句起始帧
+	noiseFloor  float64
+}
+
+func NewVAD(cfg VADConfig) *VAD {
+	cfg = cfg.withDefaults()
+	return &VAD{
+		cfg:        cfg,
+		frameBytes: cfg.SampleRate * cfg.FrameMs / 1000 * 2,
+	}
+}
+
+// InSpeech 当前是否处于说话状态
+func (v *VAD) InSpeech() bool {
+	return v.inSpeech
+}
+
+// Process 处理一段 PCM 数据，返回期间产生的端点事件
+func (v *VAD) Process(pcm []byte) []VADEvent {
+	var events []VADEvent
+
+	v.pending = append(v.pending, pcm...)
+	for len(v.pending) >= v.frameBytes {
+		if ev := v.processFrame(v.pending[:v.frameBytes]); ev != nil {
+			events = append(events, *ev)
+		}
+		v.pending = v.pending[v.frameBytes:]
+	}
+
+	// 避免底层数组无限增长
+	if len(v.pending) == 0 {
+		v.pending = nil
+	}
+
+	return events
+}
+
+// Flush 音频流结束：仍在说话时补发 speech_end
+func (v *VAD) Flush() []VADEvent {
+	v.pending = nil
+	if !v.inSpeech {
+		return nil
+	}
+
+	v.inSpeech = false
+	v.speechRun, v.silenceRun = 0, 0
+	return []VADEvent{{Type: EventSpeechEnd, Offset: v.offset(v.frames)}}
+}
+
+func (v *VAD) processFrame(frame []byte) *VADEvent {
+	energy, zcr := frameFeatures(frame)
+	v.frames++
+
+	threshold := math.Max(v.cfg.EnergyThreshold, v.noiseFloor*3)
+	speech := energy >= threshold || (energy >= threshold/2 && zcr >= v.cfg.ZCRThreshold)
+
+	if !speech {
+		// 静音帧更新背景噪声估计
+		if v.noiseFloor == 0 {
+			v.noiseFloor = energy
+		} else {
+			v.noiseFloor = 0.95*v.noiseFloor + 0.05*energy
+		}
+	}
+
+	minSpeech := v.framesFor(v.cfg.MinSpeechMs)
+	silence := v.framesFor(v.cfg.SilenceMs)
+	maxSpeech := v.framesFor(v.cfg.MaxSpeechMs)
+
+	if !v.inSpeech {
+		if !speech {
+			v.speechRun = 0
+			return nil
+		}
+
+		v.speechRun++
+		if v.speechRun < minSpeech {
+			return nil
+		}
+
+		v.inSpeech = true
+		v.silenceRun = 0
+		v.speechStart = v.frames - v.speechRun
+		return &VADEvent{Type: EventSpeechStart, Offset: v.offset(v.speechStart)}
+	}
+
+	if speech {
+		v.silenceRun = 0
+	} else {
+		v.silenceRun++
+	}
+
+	if v.silenceRun >= silence || v.frames-v.speechStart >= maxSpeech {
+		v.inSpeech = false
+		v.speechRun = 0
+		end := v.frames - v.silenceRun
+		v.silenceRun = 0
+		return &VADEvent{Type: EventSpeechEnd, Offset: v.offset(end)}
+	}
+
+	return nil
+}
+
+func (v *VAD) framesFor(ms int) int {
+	n := ms / v.cfg.FrameMs
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (v *VAD) offset(frames int) time.Duration {
+	return time.Duration(frames*v.cfg.FrameMs) * time.Millisecond
+}
+
+// frameFeatures 计算帧的 RMS 能量（归一化）与过零率
+func frameFeatures(frame []byte) (energy, zcr float64) {
+	samples := len(frame) / 2
+	if samples == 0 {
+		return 0, 0
+	}
+
+	var sum float64
+	crossings := 0
+	prev := int16(0)
+	for i := 0; i < samples; i++ {
+		s := int16(binary.LittleEndian.Uint16(frame[i*2:]))
+		f := float64(s) / 32768
+		sum += f * f
+		if i > 0 && (s >= 0) != (prev >= 0) {
+			crossings++
+		}
+		prev = s
+	}
+
+	return math.Sqrt(sum / float64(samples)), float64(crossings) / float64(samples)
+}
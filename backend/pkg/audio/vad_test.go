@@ -0,0 +1,152 @@
+package audio_test
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+)
+
+// 测试音频均为 16kHz 16bit 单声道
+const sampleRate = 16000
+
+// samples 按 fn 生成 ms 毫秒的 PCM，fn 返回 -1 到 1 之间的采样值
+func samples(ms int, fn func(i int) float64) []byte {
+	n := sampleRate * ms / 1000
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(fn(i)*32767)))
+	}
+	return pcm
+}
+
+// tone 正弦波，amplitude 为峰值
+func tone(ms int, freq, amplitude float64) []byte {
+	return samples(ms, func(i int) float64 {
+		return amplitude * math.Sin(2*math.Pi*freq*float64(i)/sampleRate)
+	})
+}
+
+func silence(ms int) []byte {
+	return make([]byte, sampleRate*ms/1000*2)
+}
+
+// hiss 逐点翻转符号的方波，模拟能量低、过零率高的清音
+func hiss(ms int, amplitude float64) []byte {
+	return samples(ms, func(i int) float64 {
+		if i%2 == 0 {
+			return amplitude
+		}
+		return -amplitude
+	})
+}
+
+func concat(parts ...[]byte) []byte {
+	return slices.Concat(parts...)
+}
+
+// detect 以不与分析帧对齐的块送入音频，音频结束后 Flush，返回事件的 类型@偏移
+func detect(cfg audio.VADConfig, pcm []byte) []string {
+	v := audio.NewVAD(cfg)
+
+	var events []audio.VADEvent
+	for len(pcm) > 0 {
+		n := min(len(pcm), 777)
+		events = append(events, v.Process(pcm[:n])...)
+		pcm = pcm[n:]
+	}
+	events = append(events, v.Flush()...)
+
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Type+"@"+ev.Offset.String())
+	}
+	return got
+}
+
+func at(eventType string, offset time.Duration) string {
+	return eventType + "@" + offset.String()
+}
+
+func TestVAD(t *testing.T) {
+	const ms = time.Millisecond
+	start, end := audio.EventSpeechStart, audio.EventSpeechEnd
+
+	cases := []struct {
+		name string
+		cfg  audio.VADConfig
+		pcm  []byte
+		want []string
+	}{
+		{
+			name: "silence",
+			pcm:  silence(2000),
+		},
+		{
+			// 起点为语音的第一帧，终点为静音的第一帧
+			name: "tone between silence",
+			pcm:  concat(silence(500), tone(500, 440, 0.3), silence(1000)),
+			want: []string{at(start, 500*ms), at(end, 1000*ms)},
+		},
+		{
+			// 短于 MinSpeechMs 的声音不算开口
+			name: "click",
+			pcm:  concat(silence(200), tone(60, 440, 0.3), silence(1000)),
+		},
+		{
+			// 静音短于 SilenceMs 时视为同一句话
+			name: "short pause",
+			pcm:  concat(tone(300, 440, 0.3), silence(400), tone(300, 440, 0.3), silence(1000)),
+			want: []string{at(start, 0), at(end, 1000*ms)},
+		},
+		{
+			name: "custom silence",
+			cfg:  audio.VADConfig{SilenceMs: 200},
+			pcm:  concat(tone(300, 440, 0.3), silence(400), tone(300, 440, 0.3), silence(1000)),
+			want: []string{at(start, 0), at(end, 300*ms), at(start, 700*ms), at(end, 1000*ms)},
+		},
+		{
+			// 音频结束时仍在说话，Flush 补发终点
+			name: "flush",
+			pcm:  concat(silence(100), tone(400, 440, 0.3)),
+			want: []string{at(start, 100*ms), at(end, 500*ms)},
+		},
+		{
+			// 超过 MaxSpeechMs 强制切分，仍在说话时紧接着开始下一句
+			name: "max speech",
+			cfg:  audio.VADConfig{MaxSpeechMs: 300},
+			pcm:  concat(tone(400, 440, 0.3), silence(1000)),
+			want: []string{at(start, 0), at(end, 300*ms), at(start, 300*ms), at(end, 400*ms)},
+		},
+		{
+			// 能量低于阈值的低频声音不是语音，同等能量的高过零率清音是
+			name: "quiet hum",
+			pcm:  concat(tone(500, 200, 0.014), silence(1000)),
+		},
+		{
+			// 背景噪声抬高阈值：安静环境中的轻声可以检出，持续噪声之后同样的音量不再触发
+			name: "soft voice",
+			pcm:  concat(silence(500), tone(500, 200, 0.035), silence(1000)),
+			want: []string{at(start, 500*ms), at(end, 1000*ms)},
+		},
+		{
+			name: "soft voice in noise",
+			pcm:  concat(tone(3000, 200, 0.017), tone(500, 200, 0.035), silence(1000)),
+		},
+		{
+			name: "fricative",
+			pcm:  concat(silence(200), hiss(300, 0.01), silence(1000)),
+			want: []string{at(start, 200*ms), at(end, 500*ms)},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := detect(tc.cfg, tc.pcm); !slices.Equal(got, tc.want) {
+				t.Errorf("events = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
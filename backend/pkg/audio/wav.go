@@ -0,0 +1,127 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WAV fmt 块中的格式标识
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// 文件头尚未完整到达
+var errShortHeader = errors.New("incomplete wav header")
+
+// ParseWAV 解析 WAV 文件头，返回音频格式与 data 块内容
+//
+// 浏览器录音等流式写出的 WAV 常把 data 块长度写为 0 或 0xFFFFFFFF，此时取文件剩余部分。
+func ParseWAV(data []byte) (Format, []byte, error) {
+	format, offset, size, err := parseWAVHeader(data)
+	if errors.Is(err, errShortHeader) {
+		return format, nil, fmt.Errorf("invalid wav file: %v", err)
+	}
+	if err != nil {
+		return format, nil, err
+	}
+
+	body := data[offset:]
+	if size >= 0 && size < len(body) {
+		body = body[:size]
+	}
+
+	// 丢弃末尾不完整的采样帧
+	frame := format.BytesPerSample() * format.Channels
+	return format, body[:len(body)/frame*frame], nil
+}
+
+// parseWAVHeader 解析 RIFF 块直到 data 块开始，返回 data 的起始偏移与长度（未知时为 -1）
+func parseWAVHeader(data []byte) (Format, int, int, error) {
+	var format Format
+
+	if len(data) < 12 {
+		return format, 0, 0, errShortHeader
+	}
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return format, 0, 0, fmt.Errorf("invalid wav file: missing RIFF/WAVE header")
+	}
+
+	hasFormat := false
+	pos := 12
+	for {
+		if len(data) < pos+8 {
+			return format, 0, 0, errShortHeader
+		}
+
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		pos += 8
+
+		switch id {
+		case "fmt ":
+			if len(data) < pos+int(size) {
+				return format, 0, 0, errShortHeader
+			}
+			var err error
+			if format, err = parseWAVFormat(data[pos : pos+int(size)]); err != nil {
+				return format, 0, 0, err
+			}
+			hasFormat = true
+
+		case "data":
+			if !hasFormat {
+				return format, 0, 0, fmt.Errorf("invalid wav file: data chunk before fmt chunk")
+			}
+			length := int(size)
+			if size == 0 || size == 0xFFFFFFFF {
+				length = -1
+			}
+			return format, pos, length, nil
+		}
+
+		// 块长度为奇数时有一个填充字节
+		pos += int(size) + int(size&1)
+	}
+}
+
+func parseWAVFormat(chunk []byte) (Format, error) {
+	var format Format
+	if len(chunk) < 16 {
+		return format, fmt.Errorf("invalid wav file: fmt chunk too short")
+	}
+
+	tag := binary.LittleEndian.Uint16(chunk[0:2])
+	format.Channels = int(binary.LittleEndian.Uint16(chunk[2:4]))
+	format.SampleRate = int(binary.LittleEndian.Uint32(chunk[4:8]))
+	bits := int(binary.LittleEndian.Uint16(chunk[14:16]))
+
+	// WAVE_FORMAT_EXTENSIBLE：实际格式在子格式 GUID 的前两个字节
+	if tag == wavFormatExtensible {
+		if len(chunk) < 26 {
+			return format, fmt.Errorf("invalid wav file: extensible fmt chunk too short")
+		}
+		tag = binary.LittleEndian.Uint16(chunk[24:26])
+	}
+
+	switch tag {
+	case wavFormatPCM:
+		encoding, err := EncodingForBits(bits)
+		if err != nil {
+			return format, err
+		}
+		format.Encoding = encoding
+	case wavFormatFloat:
+		if bits != 32 {
+			return format, fmt.Errorf("%w: %d bit float wav", ErrUnsupportedFormat, bits)
+		}
+		format.Encoding = EncodingF32LE
+	default:
+		// A-law、μ-law、ADPCM 等压缩编码
+		return format, fmt.Errorf("%w: wav format tag 0x%04X, only PCM and float are supported", ErrUnsupportedFormat, tag)
+	}
+
+	return format, format.Validate()
+}
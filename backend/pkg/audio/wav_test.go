@@ -0,0 +1,232 @@
+package audio_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+)
+
+// chunk RIFF 块，长度为奇数时补一个填充字节
+func chunk(id string, body []byte) []byte {
+	b := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	b = append(b, body...)
+	if len(body)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// fmtChunk fmt 块，extensible 时 tag 写入子格式 GUID
+func fmtChunk(tag uint16, channels, sampleRate, bits int, extensible bool) []byte {
+	var b []byte
+	header := tag
+	if extensible {
+		header = 0xFFFE
+	}
+	b = binary.LittleEndian.AppendUint16(b, header)
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate))
+	b = binary.LittleEndian.AppendUint32(b, uint32(sampleRate*channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+	if extensible {
+		b = binary.LittleEndian.AppendUint16(b, 22)
+		b = binary.LittleEndian.AppendUint16(b, uint16(bits))
+		b = binary.LittleEndian.AppendUint32(b, 0)
+		b = binary.LittleEndian.AppendUint16(b, tag)
+		b = append(b, make([]byte, 14)...)
+	}
+	return chunk("fmt ", b)
+}
+
+// riff 拼接 RIFF/WAVE 文件头与各块
+func riff(chunks ...[]byte) []byte {
+	body := slices.Concat(append([][]byte{[]byte("WAVE")}, chunks...)...)
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+// dataChunk 声明长度为 size 的 data 块（流式写出的文件长度可能为 0 或 0xFFFFFFFF）
+func dataChunk(size uint32, body []byte) []byte {
+	return append(append([]byte("data"), binary.LittleEndian.AppendUint32(nil, size)...), body...)
+}
+
+func TestParseWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	list := chunk("LIST", []byte("INFOISFT"))
+	mono16k := audio.Format{Encoding: audio.EncodingS16LE, SampleRate: 16000, Channels: 1}
+
+	cases := []struct {
+		name   string
+		data   []byte
+		format audio.Format
+		body   []byte
+	}{
+		{
+			name:   "pcm",
+			data:   riff(fmtChunk(1, 1, 16000, 16, false), chunk("data", pcm)),
+			format: mono16k,
+			body:   pcm,
+		},
+		{
+			// data 之后的 LIST 块不是音频
+			name:   "trailing chunk",
+			data:   riff(fmtChunk(1, 1, 16000, 16, false), chunk("data", pcm), list),
+			format: mono16k,
+			body:   pcm,
+		},
+		{
+			// fmt 之前奇数长度的块带填充字节
+			name:   "odd chunk before fmt",
+			data:   riff(chunk("junk", []byte{1, 2, 3}), fmtChunk(1, 1, 16000, 16, false), chunk("data", pcm)),
+			format: mono16k,
+			body:   pcm,
+		},
+		{
+			name:   "streamed zero length",
+			data:   riff(fmtChunk(1, 1, 16000, 16, false), dataChunk(0, pcm)),
+			format: mono16k,
+			body:   pcm,
+		},
+		{
+			name:   "streamed max length",
+			data:   riff(fmtChunk(1, 1, 16000, 16, false), dataChunk(0xFFFFFFFF, pcm)),
+			format: mono16k,
+			body:   pcm,
+		},
+		{
+			// 末尾不完整的采样帧被丢弃
+			name:   "partial frame",
+			data:   riff(fmtChunk(1, 2, 44100, 16, false), dataChunk(0, pcm[:7])),
+			format: audio.Format{Encoding: audio.EncodingS16LE, SampleRate: 44100, Channels: 2},
+			body:   pcm[:4],
+		},
+		{
+			name:   "extensible",
+			data:   riff(fmtChunk(1, 2, 48000, 24, true), chunk("data", pcm[:6])),
+			format: audio.Format{Encoding: audio.EncodingS24LE, SampleRate: 48000, Channels: 2},
+			body:   pcm[:6],
+		},
+		{
+			name:   "float",
+			data:   riff(fmtChunk(3, 1, 8000, 32, false), chunk("data", pcm)),
+			format: audio.Format{Encoding: audio.EncodingF32LE, SampleRate: 8000, Channels: 1},
+			body:   pcm,
+		},
+		{
+			name:   "8 bit",
+			data:   riff(fmtChunk(1, 1, 8000, 8, false), chunk("data", pcm[:3])),
+			format: audio.Format{Encoding: audio.EncodingU8, SampleRate: 8000, Channels: 1},
+			body:   pcm[:3],
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			format, body, err := audio.ParseWAV(tc.data)
+			if err != nil {
+				t.Fatalf("ParseWAV: %v", err)
+			}
+			if format != tc.format || !bytes.Equal(body, tc.body) {
+				t.Errorf("format = %v, body = %v; want %v, %v", format, body, tc.format, tc.body)
+			}
+		})
+	}
+}
+
+func TestParseWAVRejects(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	valid := riff(fmtChunk(1, 1, 16000, 16, false), chunk("data", pcm))
+	shortFmt := riff(chunk("fmt ", make([]byte, 14)), chunk("data", pcm))
+
+	cases := []struct {
+		name        string
+		data        []byte
+		unsupported bool   // 应为 ErrUnsupportedFormat
+		contains    string // 非 ErrUnsupportedFormat 时错误应包含的内容
+	}{
+		{name: "empty", contains: "invalid wav file"},
+		{name: "truncated header", data: valid[:30], contains: "invalid wav file"},
+		{name: "missing data chunk", data: riff(fmtChunk(1, 1, 16000, 16, false)), contains: "invalid wav file"},
+		{name: "not riff", data: append([]byte("RIFX"), valid[4:]...), contains: "missing RIFF/WAVE"},
+		{name: "not wave", data: append(append([]byte(nil), valid[:8]...), append([]byte("AVI "), valid[12:]...)...), contains: "missing RIFF/WAVE"},
+		{name: "data before fmt", data: riff(chunk("data", pcm), fmtChunk(1, 1, 16000, 16, false)), contains: "data chunk before fmt"},
+		{name: "short fmt", data: shortFmt, contains: "fmt chunk too short"},
+		{name: "a-law", data: riff(fmtChunk(6, 1, 8000, 8, false), chunk("data", pcm)), unsupported: true},
+		{name: "extensible adpcm", data: riff(fmtChunk(2, 1, 8000, 4, true), chunk("data", pcm)), unsupported: true},
+		{name: "64 bit float", data: riff(fmtChunk(3, 1, 16000, 64, false), chunk("data", pcm)), unsupported: true},
+		{name: "12 bit", data: riff(fmtChunk(1, 1, 16000, 12, false), chunk("data", pcm)), unsupported: true},
+		{name: "no channels", data: riff(fmtChunk(1, 0, 16000, 16, false), chunk("data", pcm)), unsupported: true},
+		{name: "sample rate", data: riff(fmtChunk(1, 1, 500, 16, false), chunk("data", pcm)), unsupported: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := audio.ParseWAV(tc.data)
+			switch {
+			case err == nil:
+				t.Fatal("ParseWAV succeeded")
+			case tc.unsupported && !errors.Is(err, audio.ErrUnsupportedFormat):
+				t.Errorf("err = %v, want ErrUnsupportedFormat", err)
+			case !tc.unsupported && !strings.Contains(err.Error(), tc.contains):
+				t.Errorf("err = %v, want it to contain %q", err, tc.contains)
+			}
+		})
+	}
+}
+
+func TestDetectContainer(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"wav", riff(fmtChunk(1, 1, 16000, 16, false)), audio.ContainerWAV},
+		{"ogg", []byte("OggS\x00\x02"), audio.ContainerOgg},
+		{"webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F}, audio.ContainerWebM},
+		{"flac", []byte("fLaC\x00"), audio.ContainerFLAC},
+		{"amr", []byte("#!AMR\n"), audio.ContainerAMR},
+		{"mp3 with id3", []byte("ID3\x04\x00"), audio.ContainerMP3},
+		{"mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64}, audio.ContainerMP3},
+		{"adts", []byte{0xFF, 0xF1, 0x50, 0x80}, audio.ContainerAAC},
+		{"mp4", []byte("\x00\x00\x00\x20ftypM4A "), audio.ContainerMP4},
+		// 裸 PCM 中常见的 0xFFFF 不应被误判为 MP3 帧头
+		{"pcm minus one", []byte{0xFF, 0xFF, 0xFF, 0xFF}, audio.ContainerRaw},
+		{"pcm silence", make([]byte, 64), audio.ContainerRaw},
+		{"short", []byte("RIF"), audio.ContainerRaw},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := audio.DetectContainer(tc.data); got != tc.want {
+				t.Errorf("DetectContainer = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatValidate(t *testing.T) {
+	cases := []struct {
+		format audio.Format
+		valid  bool
+	}{
+		{audio.PCM16kMono, true},
+		{audio.Format{Encoding: audio.EncodingF32LE, SampleRate: 384000, Channels: 8}, true},
+		{audio.Format{Encoding: "s12le", SampleRate: 16000, Channels: 1}, false},
+		{audio.Format{Encoding: audio.EncodingS16LE, SampleRate: 999, Channels: 1}, false},
+		{audio.Format{Encoding: audio.EncodingS16LE, SampleRate: 16000, Channels: 9}, false},
+		{audio.Format{Encoding: audio.EncodingS16LE, SampleRate: 16000}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.format.String(), func(t *testing.T) {
+			err := tc.format.Validate()
+			if tc.valid != (err == nil) {
+				t.Errorf("Validate = %v, want valid %v", err, tc.valid)
+			}
+			if err != nil && !errors.Is(err, audio.ErrUnsupportedFormat) {
+				t.Errorf("err = %v, want ErrUnsupportedFormat", err)
+			}
+		})
+	}
+}
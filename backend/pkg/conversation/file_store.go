@@ -0,0 +1,246 @@
+package conversation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 日志记录操作类型
+const (
+	fileOpSave   = "save"
+	fileOpAppend = "append"
+	fileOpDelete = "delete"
+)
+
+// fileRecord 单条追加日志记录（JSON Lines）
+type fileRecord struct {
+	Op           string              `json:"op"`
+	ID           string              `json:"id"`
+	Conversation *model.Conversation `json:"conversation,omitempty"`
+	Messages     []*model.Message    `json:"messages,omitempty"`
+	Time         time.Time           `json:"time"`
+}
+
+// FileStore 基于本地单文件的嵌入式会话存储
+//
+// 所有写操作以 JSON Lines 形式追加到日志文件，启动时回放日志重建内存索引，
+// 并将日志压缩为每个会话一条 save 记录。读操作直接命中内存索引。
+type FileStore struct {
+	mu    sync.Mutex
+	path  string
+	file  *os.File
+	index *MemoryStore
+}
+
+// NewFileStore 打开（或创建）path 指向的会话存储文件
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	s := &FileStore{
+		path:  path,
+		index: NewMemoryStore(),
+	}
+
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*model.Conversation, error) {
+	return s.index.Get(ctx, id)
+}
+
+func (s *FileStore) Save(ctx context.Context, conv *model.Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(&fileRecord{Op: fileOpSave, ID: conv.ID, Conversation: conv, Time: time.Now()}); err != nil {
+		return err
+	}
+
+	return s.index.Save(ctx, conv)
+}
+
+func (s *FileStore) Append(ctx context.Context, id string, msgs ...*model.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.index.Get(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.write(&fileRecord{Op: fileOpAppend, ID: id, Messages: msgs, Time: time.Now()}); err != nil {
+		return err
+	}
+
+	return s.index.Append(ctx, id, msgs...)
+}
+
+func (s *FileStore) Update(ctx context.Context, id string, fn func(conv *model.Conversation) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, err := s.index.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(conv); err != nil {
+		return err
+	}
+	conv.ID = id
+	conv.UpdatedAt = time.Now()
+
+	if err := s.write(&fileRecord{Op: fileOpSave, ID: id, Conversation: conv, Time: time.Now()}); err != nil {
+		return err
+	}
+
+	return s.index.Save(ctx, conv)
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(&fileRecord{Op: fileOpDelete, ID: id, Time: time.Now()}); err != nil {
+		return err
+	}
+
+	return s.index.Delete(ctx, id)
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// write 追加一条记录并落盘
+func (s *FileStore) write(rec *fileRecord) error {
+	if s.file == nil {
+		return fmt.Errorf("conversation store is closed")
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+
+	return s.file.Sync()
+}
+
+// replay 回放日志文件重建内存索引
+func (s *FileStore) replay() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open store file: %w", err)
+	}
+	defer f.Close()
+
+	ctx := context.Background()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec fileRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// 进程崩溃可能留下半行记录，跳过即可
+			logx.Errorf("Skipping corrupt conversation record at %s:%d: %v", s.path, line, err)
+			continue
+		}
+
+		switch rec.Op {
+		case fileOpSave:
+			if rec.Conversation != nil {
+				s.index.Save(ctx, rec.Conversation)
+			}
+		case fileOpAppend:
+			s.index.Append(ctx, rec.ID, rec.Messages...)
+		case fileOpDelete:
+			s.index.Delete(ctx, rec.ID)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read store file: %w", err)
+	}
+
+	return nil
+}
+
+// compact 将内存索引重写为紧凑日志，并以追加模式重新打开
+func (s *FileStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted store file: %w", err)
+	}
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+
+	s.index.mu.RLock()
+	for id, conv := range s.index.conversations {
+		if err := enc.Encode(&fileRecord{Op: fileOpSave, ID: id, Conversation: conv, Time: time.Now()}); err != nil {
+			s.index.mu.RUnlock()
+			tmp.Close()
+			return fmt.Errorf("failed to write compacted record: %w", err)
+		}
+	}
+	s.index.mu.RUnlock()
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to flush compacted store file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync compacted store file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close compacted store file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace store file: %w", err)
+	}
+
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open store file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package conversation_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+func openStore(t *testing.T, path string) *conversation.FileStore {
+	t.Helper()
+
+	s, err := conversation.NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func newConversation(id string) *model.Conversation {
+	now := time.Now()
+	return &model.Conversation{ID: id, RoleID: "harry", Owner: "a", CreatedAt: now, UpdatedAt: now}
+}
+
+func message(role, content string) *model.Message {
+	return &model.Message{Role: role, Content: content, Timestamp: time.Now()}
+}
+
+// contents 会话消息，格式为 role:content
+func contents(conv *model.Conversation) []string {
+	var out []string
+	for _, msg := range conv.Messages {
+		out = append(out, msg.Role+":"+msg.Content)
+	}
+	return out
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		write    func(t *testing.T, s *conversation.FileStore)
+		messages []string // 重新打开后 c1 的消息，nil 表示会话不存在
+		summary  string
+	}{
+		{
+			name: "append",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				s.Save(ctx, newConversation("c1"))
+				s.Append(ctx, "c1", message(model.RoleUser, "你好"), message(model.RoleAssistant, "你好！"))
+				s.Append(ctx, "c1", message(model.RoleUser, "再见"))
+			},
+			messages: []string{"user:你好", "assistant:你好！", "user:再见"},
+		},
+		{
+			name: "update",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				s.Save(ctx, newConversation("c1"))
+				s.Append(ctx, "c1", message(model.RoleUser, "你好"))
+				err := s.Update(ctx, "c1", func(conv *model.Conversation) error {
+					conv.Memory = &model.Memory{ConversationID: "c1", Summary: "打过招呼", Summarized: 1}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.Append(ctx, "c1", message(model.RoleAssistant, "你好！"))
+			},
+			messages: []string{"user:你好", "assistant:你好！"},
+			summary:  "打过招呼",
+		},
+		{
+			// 更新函数返回错误时不写入
+			name: "failed update",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				s.Save(ctx, newConversation("c1"))
+				err := s.Update(ctx, "c1", func(conv *model.Conversation) error {
+					conv.Messages = append(conv.Messages, message(model.RoleUser, "不应保存"))
+					return errors.New("abort")
+				})
+				if err == nil {
+					t.Fatal("Update succeeded")
+				}
+			},
+			messages: []string{},
+		},
+		{
+			name: "delete",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				s.Save(ctx, newConversation("c1"))
+				s.Append(ctx, "c1", message(model.RoleUser, "你好"))
+				s.Delete(ctx, "c1")
+			},
+		},
+		{
+			// 向不存在的会话追加消息既不报成功也不留下记录
+			name: "append to missing",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				if err := s.Append(ctx, "c1", message(model.RoleUser, "你好")); !errors.Is(err, conversation.ErrNotFound) {
+					t.Fatalf("err = %v, want ErrNotFound", err)
+				}
+			},
+		},
+		{
+			// 删除后以同一 ID 重新创建
+			name: "recreate",
+			write: func(t *testing.T, s *conversation.FileStore) {
+				s.Save(ctx, newConversation("c1"))
+				s.Append(ctx, "c1", message(model.RoleUser, "旧的"))
+				s.Delete(ctx, "c1")
+				s.Save(ctx, newConversation("c1"))
+				s.Append(ctx, "c1", message(model.RoleUser, "新的"))
+			},
+			messages: []string{"user:新的"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "store", "conversations.jsonl")
+			s := openStore(t, path)
+			tc.write(t, s)
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			conv, err := openStore(t, path).Get(ctx, "c1")
+			if tc.messages == nil {
+				if !errors.Is(err, conversation.ErrNotFound) {
+					t.Fatalf("conversation = %+v, err = %v; want ErrNotFound", conv, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if got := contents(conv); !slices.Equal(got, tc.messages) {
+				t.Errorf("messages = %q, want %q", got, tc.messages)
+			}
+			if conv.RoleID != "harry" || conv.Owner != "a" {
+				t.Errorf("conversation = %+v", conv)
+			}
+			var summary string
+			if conv.Memory != nil {
+				summary = conv.Memory.Summary
+			}
+			if summary != tc.summary {
+				t.Errorf("summary = %q, want %q", summary, tc.summary)
+			}
+		})
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+
+	s := openStore(t, path)
+	for _, id := range []string{"c1", "c2", "c3"} {
+		s.Save(ctx, newConversation(id))
+		for i := 0; i < 5; i++ {
+			s.Append(ctx, id, message(model.RoleUser, fmt.Sprintf("第%d句", i+1)))
+		}
+	}
+	s.Delete(ctx, "c2")
+	s.Close()
+
+	// 重新打开时日志压缩为每个会话一条记录，已删除的会话不再出现
+	s = openStore(t, path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines != 2 {
+		t.Errorf("compacted log has %d records, want 2", lines)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	// 压缩后继续追加，再次打开时内容完整
+	s.Append(ctx, "c1", message(model.RoleAssistant, "好的"))
+	s.Close()
+	s = openStore(t, path)
+	conv, err := s.Get(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(conv); len(got) != 6 || got[5] != "assistant:好的" {
+		t.Errorf("messages = %q", got)
+	}
+	if _, err := s.Get(ctx, "c2"); !errors.Is(err, conversation.ErrNotFound) {
+		t.Errorf("deleted conversation: err = %v", err)
+	}
+}
+
+func TestFileStoreSkipsTruncatedRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "conversations.jsonl")
+
+	s := openStore(t, path)
+	s.Save(ctx, newConversation("c1"))
+	s.Append(ctx, "c1", message(model.RoleUser, "你好"))
+	s.Close()
+
+	// 进程崩溃时最后一条记录只写了一半
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"append","id":"c1","messages":[{"role":"user","con`)
+	f.Close()
+
+	conv, err := openStore(t, path).Get(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(conv); !slices.Equal(got, []string{"user:你好"}) {
+		t.Errorf("messages = %q", got)
+	}
+}
+
+func TestFileStoreClosed(t *testing.T) {
+	ctx := context.Background()
+	s := openStore(t, filepath.Join(t.TempDir(), "conversations.jsonl"))
+	s.Close()
+
+	if err := s.Save(ctx, newConversation("c1")); err == nil {
+		t.Error("Save on a closed store succeeded")
+	}
+	if _, err := s.Get(ctx, "c1"); !errors.Is(err, conversation.ErrNotFound) {
+		t.Errorf("failed save reached the index: err = %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+const (
+	defaultWindowSize  = 12
+	defaultTokenBudget = 2000
+	maxKeyPoints       = 10
+)
+
+// 摘要生成提示词
+const summaryPrompt = `你是对话记忆整理助手。请把“已有记忆”和“新增对话”合并为一段简洁的中文摘要，并提炼用户的关键信息（姓名、偏好、提到的人和事、未完成的话题等）。
+只输出 JSON，格式为：{"summary": "不超过200字的摘要", "keyPoints": ["要点1", "要点2"]}，要点不超过10条。`
+
+// MemoryManager 滑动窗口 + 滚动摘要的会话记忆管理
+//
+// 最近 WindowSize 条消息原样保留；当未摘要的历史超过 token 预算时，
+// 调用 LLM 将更早的消息折叠进 Conversation.LastSummary 与 Memory.KeyPoints。
+type MemoryManager struct {
+	store       ConversationStore
+	windowSize  int
+	tokenBudget int
+	// 正在压缩的会话，避免同一会话并发摘要
+	compacting sync.Map
+}
+
+func NewMemoryManager(store ConversationStore, windowSize, tokenBudget int) *MemoryManager {
+	if windowSize <= 0 {
+		windowSize = defaultWindowSize
+	}
+	if tokenBudget <= 0 {
+		tokenBudget = defaultTokenBudget
+	}
+
+	return &MemoryManager{
+		store:       store,
+		windowSize:  windowSize,
+		tokenBudget: tokenBudget,
+	}
+}
+
+// Recent 返回尚未折叠进摘要、需要原样发送给 LLM 的消息
+func (m *MemoryManager) Recent(conv *model.Conversation) []*model.Message {
+	start := 0
+	if conv.Memory != nil {
+		start = conv.Memory.Summarized
+	}
+	if start > len(conv.Messages) {
+		start = len(conv.Messages)
+	}
+
+	return conv.Messages[start:]
+}
+
+// SystemPrompt 将会话记忆注入角色系统提示
+func (m *MemoryManager) SystemPrompt(rolePrompt string, conv *model.Conversation) string {
+	if conv == nil || (conv.LastSummary == "" && (conv.Memory == nil || len(conv.Memory.KeyPoints) == 0)) {
+		return rolePrompt
+	}
+
+	var sb strings.Builder
+	if rolePrompt != "" {
+		sb.WriteString(rolePrompt)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("【对话记忆】以下是你与用户之前对话的摘要，请在回答时自然地参考：\n")
+	if conv.LastSummary != "" {
+		sb.WriteString(conv.LastSummary)
+		sb.WriteString("\n")
+	}
+	if conv.Memory != nil && len(conv.Memory.KeyPoints) > 0 {
+		sb.WriteString("关键信息：\n")
+		for _, point := range conv.Memory.KeyPoints {
+			sb.WriteString("- ")
+			sb.WriteString(point)
+			sb.WriteString("\n")
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// Compact 在未摘要历史超过 token 预算时，将窗口之外的消息折叠进摘要
+func (m *MemoryManager) Compact(ctx context.Context, llm provider.LLMProvider, modelName, id string) error {
+	if _, busy := m.compacting.LoadOrStore(id, struct{}{}); busy {
+		return nil
+	}
+	defer m.compacting.Delete(id)
+
+	conv, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	recent := m.Recent(conv)
+	if len(recent) <= m.windowSize || estimateMessagesTokens(recent) <= m.tokenBudget {
+		return nil
+	}
+
+	folded := recent[:len(recent)-m.windowSize]
+	summarized := len(conv.Messages) - len(recent) + len(folded)
+
+	var keyPoints []string
+	if conv.Memory != nil {
+		keyPoints = conv.Memory.KeyPoints
+	}
+
+	summary, points, err := m.summarize(ctx, llm, modelName, conv.LastSummary, keyPoints, folded)
+	if err != nil {
+		return err
+	}
+
+	logx.Infof("Conversation %s memory compacted: %d messages folded, %d key points", id, len(folded), len(points))
+
+	return m.store.Update(ctx, id, func(c *model.Conversation) error {
+		c.LastSummary = summary
+		c.Memory = &model.Memory{
+			ConversationID: id,
+			Summary:        summary,
+			KeyPoints:      points,
+			WindowSize:     m.windowSize,
+			Summarized:     summarized,
+		}
+		return nil
+	})
+}
+
+// summarize 调用 LLM 合并已有摘要与新增对话
+func (m *MemoryManager) summarize(ctx context.Context, llm provider.LLMProvider, modelName, summary string,
+	keyPoints []string, msgs []*model.Message) (string, []string, error) {
+	var sb strings.Builder
+	sb.WriteString("已有记忆：\n")
+	if summary == "" && len(keyPoints) == 0 {
+		sb.WriteString("（无）\n")
+	} else {
+		sb.WriteString(summary)
+		sb.WriteString("\n")
+		for _, point := range keyPoints {
+			sb.WriteString("- ")
+			sb.WriteString(point)
+			sb.WriteString("\n")
+		}
+	}
+
+	sb.WriteString("\n新增对话：\n")
+	for _, msg := range msgs {
+		switch msg.Role {
+		case model.RoleUser:
+			sb.WriteString("用户：")
+		case model.RoleAssistant:
+			sb.WriteString("角色：")
+		default:
+			continue
+		}
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n")
+	}
+
+	temperature := 0.2 // 摘要需要稳定输出
+	resp, err := llm.Chat(ctx, &provider.ChatRequest{
+		Model: modelName,
+		Messages: []*provider.Message{
+			{Role: model.RoleSystem, Content: summaryPrompt},
+			{Role: model.RoleUser, Content: sb.String()},
+		},
+		Temperature: &temperature,
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	newSummary, newPoints, ok := parseSummary(resp.Text)
+	if newSummary == "" {
+		return "", nil, fmt.Errorf("empty summary returned by %s", llm.Name())
+	}
+	if !ok {
+		// 回复不是约定的 JSON 时无法得到新的关键信息，保留原有的而不是清空
+		logx.Errorf("Malformed summary returned by %s, keeping previous key points", llm.Name())
+		newPoints = keyPoints
+	}
+
+	return newSummary, newPoints, nil
+}
+
+// parseSummary 解析 LLM 返回的摘要 JSON，解析失败时将全文作为摘要并返回 ok = false
+func parseSummary(text string) (summary string, keyPoints []string, ok bool) {
+	text = strings.TrimSpace(text)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	text = strings.TrimSpace(text)
+
+	var result struct {
+		Summary   string   `json:"summary"`
+		KeyPoints []string `json:"keyPoints"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return text, nil, false
+	}
+
+	if len(result.KeyPoints) > maxKeyPoints {
+		result.KeyPoints = result.KeyPoints[:maxKeyPoints]
+	}
+
+	return strings.TrimSpace(result.Summary), result.KeyPoints, true
+}
+
+func estimateMessagesTokens(msgs []*model.Message) int {
+	total := 0
+	for _, msg := range msgs {
+		// 每条消息额外计入角色等格式开销
+		total += provider.EstimateTokens(msg.Content) + 4
+	}
+	return total
+}
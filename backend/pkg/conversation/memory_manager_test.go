@@ -0,0 +1,274 @@
+package conversation_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// summarizer 按固定回复生成摘要的 LLM
+type summarizer struct {
+	reply    string
+	err      error
+	requests []*provider.ChatRequest
+}
+
+func (s *summarizer) Name() string { return "summarizer" }
+
+func (s *summarizer) Chat(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	s.requests = append(s.requests, req)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &provider.ChatResponse{Text: s.reply}, nil
+}
+
+func (s *summarizer) ChatStream(ctx context.Context, req *provider.ChatRequest) (<-chan *provider.ChatDelta, error) {
+	return nil, errors.New("not supported")
+}
+
+// chat 保存含 n 条消息的会话，每条消息约 24 个 token
+func chat(t *testing.T, store conversation.ConversationStore, id string, n int) {
+	t.Helper()
+
+	ctx := context.Background()
+	if err := store.Save(ctx, newConversation(id)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		role := model.RoleUser
+		if i%2 == 1 {
+			role = model.RoleAssistant
+		}
+		if err := store.Append(ctx, id, message(role, fmt.Sprintf("%d%s", i+1, strings.Repeat("话", 19)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMemoryManagerRecent(t *testing.T) {
+	conv := newConversation("c1")
+	for i := 0; i < 5; i++ {
+		conv.Messages = append(conv.Messages, message(model.RoleUser, fmt.Sprint(i+1)))
+	}
+	m := conversation.NewMemoryManager(conversation.NewMemoryStore(), 2, 100)
+
+	cases := []struct {
+		name   string
+		memory *model.Memory
+		want   []string
+	}{
+		{"no memory", nil, []string{"user:1", "user:2", "user:3", "user:4", "user:5"}},
+		{"summarized", &model.Memory{Summarized: 3}, []string{"user:4", "user:5"}},
+		// 摘要记录的条数超过现有消息时不越界
+		{"beyond messages", &model.Memory{Summarized: 9}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conv.Memory = tc.memory
+			got := contents(&model.Conversation{Messages: m.Recent(conv)})
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("recent = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemoryManagerCompact(t *testing.T) {
+	cases := []struct {
+		name      string
+		messages  int
+		window    int
+		budget    int
+		reply     string
+		err       error
+		compacted bool // 是否调用 LLM 并写入记忆
+		failed    bool // Compact 是否返回错误
+		summary   string
+		keyPoints []string
+	}{
+		{name: "within window", messages: 4, window: 4, budget: 10},
+		{name: "within budget", messages: 20, window: 4, budget: 1000},
+		{
+			name:      "json",
+			messages:  20,
+			window:    4,
+			budget:    100,
+			reply:     `{"summary": " 用户叫小明 ", "keyPoints": ["名字是小明", "喜欢猫"]}`,
+			compacted: true,
+			summary:   "用户叫小明",
+			keyPoints: []string{"名字是小明", "喜欢猫"},
+		},
+		{
+			name:      "fenced json",
+			messages:  20,
+			window:    4,
+			budget:    100,
+			reply:     "```json\n{\"summary\": \"用户叫小明\", \"keyPoints\": [\"喜欢猫\"]}\n```",
+			compacted: true,
+			summary:   "用户叫小明",
+			keyPoints: []string{"喜欢猫"},
+		},
+		{
+			name:      "too many key points",
+			messages:  20,
+			window:    4,
+			budget:    100,
+			reply:     `{"summary": "很多要点", "keyPoints": ["1","2","3","4","5","6","7","8","9","10","11","12"]}`,
+			compacted: true,
+			summary:   "很多要点",
+			keyPoints: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+		},
+		{
+			// 不是 JSON 时全文作为摘要
+			name:      "malformed",
+			messages:  20,
+			window:    4,
+			budget:    100,
+			reply:     "用户叫小明，喜欢猫。",
+			compacted: true,
+			summary:   "用户叫小明，喜欢猫。",
+		},
+		{name: "empty summary", messages: 20, window: 4, budget: 100, reply: `{"summary": "", "keyPoints": ["喜欢猫"]}`, failed: true},
+		{name: "llm error", messages: 20, window: 4, budget: 100, err: errors.New("unavailable"), failed: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := conversation.NewMemoryStore()
+			chat(t, store, "c1", tc.messages)
+			llm := &summarizer{reply: tc.reply, err: tc.err}
+			m := conversation.NewMemoryManager(store, tc.window, tc.budget)
+
+			err := m.Compact(ctx, llm, "summary-model", "c1")
+			if tc.failed != (err != nil) {
+				t.Fatalf("err = %v, want failed %v", err, tc.failed)
+			}
+			conv, err := store.Get(ctx, "c1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.compacted {
+				if conv.Memory != nil || conv.LastSummary != "" {
+					t.Errorf("memory = %+v, summary = %q; want none", conv.Memory, conv.LastSummary)
+				}
+				if tc.reply == "" && tc.err == nil && len(llm.requests) != 0 {
+					t.Errorf("summarized %d times below the budget", len(llm.requests))
+				}
+				return
+			}
+
+			if conv.LastSummary != tc.summary || conv.Memory.Summary != tc.summary || !slices.Equal(conv.Memory.KeyPoints, tc.keyPoints) {
+				t.Errorf("summary = %q, memory = %+v; want %q, %q", conv.LastSummary, conv.Memory, tc.summary, tc.keyPoints)
+			}
+			// 窗口之内的消息原样保留，更早的消息折叠进摘要
+			if conv.Memory.Summarized != tc.messages-tc.window || len(m.Recent(conv)) != tc.window {
+				t.Errorf("summarized = %d, recent = %d", conv.Memory.Summarized, len(m.Recent(conv)))
+			}
+			if req := llm.requests[0]; req.Model != "summary-model" || !strings.Contains(req.Messages[1].Content, "用户：1话") ||
+				strings.Contains(req.Messages[1].Content, fmt.Sprintf("%d话", tc.messages-tc.window+1)) {
+				t.Errorf("request = %+v", req.Messages[1])
+			}
+		})
+	}
+}
+
+func TestMemoryManagerCompactRolls(t *testing.T) {
+	ctx := context.Background()
+	store := conversation.NewMemoryStore()
+	chat(t, store, "c1", 20)
+	llm := &summarizer{reply: `{"summary": "第一次摘要", "keyPoints": ["喜欢猫"]}`}
+	m := conversation.NewMemoryManager(store, 4, 100)
+	if err := m.Compact(ctx, llm, "", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再次超出预算时只折叠新增的消息，已有摘要与要点随请求一起发送
+	for i := 0; i < 10; i++ {
+		store.Append(ctx, "c1", message(model.RoleUser, "新"+strings.Repeat("话", 20)))
+	}
+	llm.reply = `{"summary": "第二次摘要", "keyPoints": ["喜欢猫", "养了一只狗"]}`
+	if err := m.Compact(ctx, llm, "", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := llm.requests[1].Messages[1].Content
+	if !strings.Contains(prompt, "第一次摘要\n- 喜欢猫") || strings.Contains(prompt, "用户：1话") {
+		t.Errorf("second summary request = %q", prompt)
+	}
+	conv, _ := store.Get(ctx, "c1")
+	if conv.LastSummary != "第二次摘要" || conv.Memory.Summarized != 26 || len(conv.Memory.KeyPoints) != 2 {
+		t.Errorf("memory = %+v", conv.Memory)
+	}
+}
+
+func TestMemoryManagerCompactKeepsKeyPoints(t *testing.T) {
+	ctx := context.Background()
+	store := conversation.NewMemoryStore()
+	chat(t, store, "c1", 20)
+	llm := &summarizer{reply: `{"summary": "第一次摘要", "keyPoints": ["喜欢猫"]}`}
+	m := conversation.NewMemoryManager(store, 4, 100)
+	if err := m.Compact(ctx, llm, "", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 回复格式错误时更新摘要，但不清空已有的关键信息
+	for i := 0; i < 10; i++ {
+		store.Append(ctx, "c1", message(model.RoleUser, "新"+strings.Repeat("话", 20)))
+	}
+	llm.reply = "```json\n{\"summary\": \"第二次摘要\", \"keyPoints\": [\n```"
+	if err := m.Compact(ctx, llm, "", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	conv, _ := store.Get(ctx, "c1")
+	if !strings.HasPrefix(conv.LastSummary, `{"summary": "第二次摘要"`) || !slices.Equal(conv.Memory.KeyPoints, []string{"喜欢猫"}) || conv.Memory.Summarized != 26 {
+		t.Errorf("summary = %q, memory = %+v", conv.LastSummary, conv.Memory)
+	}
+}
+
+func TestMemoryManagerSystemPrompt(t *testing.T) {
+	m := conversation.NewMemoryManager(conversation.NewMemoryStore(), 0, 0)
+
+	cases := []struct {
+		name       string
+		rolePrompt string
+		conv       *model.Conversation
+		want       string
+	}{
+		{"no conversation", "你是哈利", nil, "你是哈利"},
+		{"no memory", "你是哈利", &model.Conversation{}, "你是哈利"},
+		{
+			name:       "summary",
+			rolePrompt: "你是哈利",
+			conv:       &model.Conversation{LastSummary: "用户叫小明"},
+			want:       "你是哈利\n\n【对话记忆】以下是你与用户之前对话的摘要，请在回答时自然地参考：\n用户叫小明",
+		},
+		{
+			name:       "summary and key points",
+			rolePrompt: "你是哈利",
+			conv:       &model.Conversation{LastSummary: "用户叫小明", Memory: &model.Memory{KeyPoints: []string{"喜欢猫", "怕黑"}}},
+			want:       "你是哈利\n\n【对话记忆】以下是你与用户之前对话的摘要，请在回答时自然地参考：\n用户叫小明\n关键信息：\n- 喜欢猫\n- 怕黑",
+		},
+		{
+			name: "no role prompt",
+			conv: &model.Conversation{Memory: &model.Memory{KeyPoints: []string{"喜欢猫"}}},
+			want: "【对话记忆】以下是你与用户之前对话的摘要，请在回答时自然地参考：\n关键信息：\n- 喜欢猫",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := m.SystemPrompt(tc.rolePrompt, tc.conv); got != tc.want {
+				t.Errorf("SystemPrompt = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package conversation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+// MemoryStore 基于内存的会话存储，进程重启后数据丢失
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]*model.Conversation
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: make(map[string]*model.Conversation),
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*model.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.conversations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return clone(conv), nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, conv *model.Conversation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversations[conv.ID] = clone(conv)
+	return nil
+}
+
+func (s *MemoryStore) Append(ctx context.Context, id string, msgs ...*model.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, ok := s.conversations[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	for _, msg := range msgs {
+		m := *msg
+		conv.Messages = append(conv.Messages, &m)
+	}
+	conv.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (s *MemoryStore) Update(ctx context.Context, id string, fn func(conv *model.Conversation) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, ok := s.conversations[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	updated := clone(conv)
+	if err := fn(updated); err != nil {
+		return err
+	}
+	updated.ID = id
+	updated.UpdatedAt = time.Now()
+	s.conversations[id] = updated
+
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conversations, id)
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,90 @@
+package conversation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+// ErrNotFound 会话不存在
+var ErrNotFound = errors.New("conversation not found")
+
+// ConversationStore 会话存储接口，负责 model.Conversation 的持久化
+type ConversationStore interface {
+	// Get 读取会话，不存在时返回 ErrNotFound
+	Get(ctx context.Context, id string) (*model.Conversation, error)
+	// Save 创建或整体覆盖会话
+	Save(ctx context.Context, conv *model.Conversation) error
+	// Append 向会话追加消息，不存在时返回 ErrNotFound
+	Append(ctx context.Context, id string, msgs ...*model.Message) error
+	// Update 原子地读取-修改-写回会话，不存在时返回 ErrNotFound
+	Update(ctx context.Context, id string, fn func(conv *model.Conversation) error) error
+	// Delete 删除会话
+	Delete(ctx context.Context, id string) error
+	// Close 释放存储占用的资源
+	Close() error
+}
+
+// Resume 按 ID 恢复 owner 的会话，ID 为空时创建新会话。
+// 会话不存在或属于其他租户时均返回 ErrNotFound，不向调用方透露其他租户的会话是否存在
+func Resume(ctx context.Context, store ConversationStore, id, owner, roleID string) (conv *model.Conversation, resumed bool, err error) {
+	if id != "" {
+		conv, err = store.Get(ctx, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if conv.Owner != owner {
+			return nil, false, ErrNotFound
+		}
+		return conv, true, nil
+	}
+
+	now := time.Now()
+	conv = &model.Conversation{
+		ID:        NewID(),
+		RoleID:    roleID,
+		Owner:     owner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := store.Save(ctx, conv); err != nil {
+		return nil, false, err
+	}
+
+	return conv, false, nil
+}
+
+// NewID 生成随机会话 ID
+func NewID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
+
+// clone 深拷贝会话，避免调用方与存储共享切片
+func clone(conv *model.Conversation) *model.Conversation {
+	if conv == nil {
+		return nil
+	}
+
+	c := *conv
+	c.Messages = make([]*model.Message, len(conv.Messages))
+	for i, msg := range conv.Messages {
+		m := *msg
+		c.Messages[i] = &m
+	}
+
+	if conv.Memory != nil {
+		mem := *conv.Memory
+		mem.KeyPoints = append([]string(nil), conv.Memory.KeyPoints...)
+		c.Memory = &mem
+	}
+
+	return &c
+}
@@ -0,0 +1,46 @@
+package conversation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/conversation"
+)
+
+func TestResume(t *testing.T) {
+	ctx := context.Background()
+	store := conversation.NewMemoryStore()
+	owned, resumed, err := conversation.Resume(ctx, store, "", "a", "harry")
+	if err != nil || resumed || owned.Owner != "a" || owned.RoleID != "harry" {
+		t.Fatalf("new conversation = %+v, resumed = %v, err = %v", owned, resumed, err)
+	}
+
+	cases := []struct {
+		name  string
+		id    string
+		owner string
+		err   error
+	}{
+		{"same owner", owned.ID, "a", nil},
+		// 其他租户的会话与不存在的会话无法区分
+		{"other owner", owned.ID, "b", conversation.ErrNotFound},
+		{"unknown id", "missing", "a", conversation.ErrNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conv, resumed, err := conversation.Resume(ctx, store, tc.id, tc.owner, "curie")
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if tc.err == nil && (!resumed || conv.ID != owned.ID || conv.RoleID != "harry") {
+				t.Errorf("conversation = %+v, resumed = %v", conv, resumed)
+			}
+		})
+	}
+
+	// 未知 ID 不会被创建
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, conversation.ErrNotFound) {
+		t.Errorf("unknown id was created: err = %v", err)
+	}
+}
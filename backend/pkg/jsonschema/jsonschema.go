@@ -0,0 +1,193 @@
+// Package jsonschema 根据 Go 类型生成 JSON Schema（draft 2020-12）
+//
+// 规则与 encoding/json 保持一致：字段名取 json 标签，匿名嵌入的结构体字段展开，
+// 未标记 omitempty 的字段视为必填；具名结构体放入 $defs 并通过 $ref 引用。
+// 类型可实现 Schemer 接口自定义生成结果。
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema JSON Schema 的子集
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	ID                   string             `json:"$id,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // string 或 []string
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Schemer 由需要自定义 Schema 的类型实现
+type Schemer interface {
+	JSONSchema() *Schema
+}
+
+var (
+	schemerType = reflect.TypeOf((*Schemer)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+	numberType  = reflect.TypeOf(json.Number(""))
+	rawType     = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// Reflector 生成 Schema，同一个 Reflector 生成的结构体定义共享 Defs
+type Reflector struct {
+	Defs  map[string]*Schema
+	names map[reflect.Type]string
+}
+
+func NewReflector() *Reflector {
+	return &Reflector{
+		Defs:  make(map[string]*Schema),
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// Reflect 生成 v 的类型对应的 Schema，v 为 nil 时返回 nil
+func (r *Reflector) Reflect(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+	return r.reflect(reflect.TypeOf(v))
+}
+
+func (r *Reflector) reflect(t reflect.Type) *Schema {
+	if t.Implements(schemerType) {
+		return reflect.Zero(t).Interface().(Schemer).JSONSchema()
+	}
+	if reflect.PointerTo(t).Implements(schemerType) {
+		return reflect.New(t).Interface().(Schemer).JSONSchema()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case numberType:
+		return &Schema{Type: []string{"number", "string"}}
+	case rawType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return r.reflect(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: r.reflect(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.reflect(t.Elem())}
+	case reflect.Struct:
+		return r.reflectStruct(t)
+	default:
+		// interface{} 等任意值
+		return &Schema{}
+	}
+}
+
+// 具名结构体放入 $defs，匿名结构体内联
+func (r *Reflector) reflectStruct(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return r.object(t)
+	}
+
+	name, ok := r.names[t]
+	if !ok {
+		name = r.defName(t)
+		r.names[t] = name
+		r.Defs[name] = nil // 占位，允许递归引用
+		r.Defs[name] = r.object(t)
+	}
+	return &Schema{Ref: "#/$defs/" + name}
+}
+
+// 不同包的同名类型以包名区分
+func (r *Reflector) defName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := r.Defs[name]; !taken {
+		return name
+	}
+
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return pkg + "." + name
+}
+
+func (r *Reflector) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	r.fields(t, s)
+	return s
+}
+
+func (r *Reflector) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// 未命名的嵌入结构体，字段提升到外层
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.fields(ft, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = r.reflect(f.Type)
+		if !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package latency
+
+import (
+	"sort"
+	"sync"
+)
+
+// PipelineBuckets 对话流水线各阶段耗时（毫秒）的直方图桶
+var PipelineBuckets = []float64{50, 100, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000}
+
+// Histogram 进程内的分桶直方图，与 Prometheus 直方图使用相同的桶，
+// 未开启 Prometheus 时也能给出分位数（进程内保存，重启后清零）
+type Histogram struct {
+	buckets []float64 // 各桶上界，升序
+
+	mu     sync.Mutex
+	counts []int64 // 落入各桶的样本数，最后一个为 +Inf 桶
+	count  int64
+}
+
+func NewHistogram(buckets []float64) *Histogram {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]int64, len(buckets)+1),
+	}
+}
+
+// Bucket 样本落入的桶：第一个上界不小于 v 的桶，超过所有上界时为 +Inf 桶（序号 len(buckets)）
+func (h *Histogram) Bucket(v float64) int {
+	return sort.SearchFloat64s(h.buckets, v)
+}
+
+// Observe 记录一个样本
+func (h *Histogram) Observe(v float64) {
+	i := h.Bucket(v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[i]++
+	h.count++
+}
+
+// Count 样本总数
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+// Quantile 按 Prometheus histogram_quantile 的方式估算分位数：
+// 在目标样本所在桶的上下界之间线性插值，第一个桶的下界为 0，
+// 落在 +Inf 桶时返回最大的有限上界。没有样本时返回 0
+func (h *Histogram) Quantile(q float64) float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.count == 0 || len(h.buckets) == 0 {
+		return 0
+	}
+	if q < 0 {
+		q = 0
+	}
+	if q > 1 {
+		q = 1
+	}
+
+	rank := q * float64(h.count)
+	var cumulative int64
+	for i, n := range h.counts {
+		if float64(cumulative+n) < rank || n == 0 {
+			cumulative += n
+			continue
+		}
+		if i == len(h.buckets) {
+			break
+		}
+
+		lower := 0.0
+		if i > 0 {
+			lower = h.buckets[i-1]
+		}
+		return lower + (h.buckets[i]-lower)*(rank-float64(cumulative))/float64(n)
+	}
+	return h.buckets[len(h.buckets)-1]
+}
+
+// Summary 一组样本的分位数摘要
+type Summary struct {
+	Count int64
+	P50   float64
+	P95   float64
+}
+
+// Summary 返回当前的 P50/P95
+func (h *Histogram) Summary() Summary {
+	return Summary{Count: h.Count(), P50: h.Quantile(0.50), P95: h.Quantile(0.95)}
+}
+
+// HistogramVec 按标签区分的一组直方图
+type HistogramVec struct {
+	buckets []float64
+
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+func NewHistogramVec(buckets []float64) *HistogramVec {
+	return &HistogramVec{
+		buckets:    buckets,
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// Observe 记录标签 label 的一个样本
+func (v *HistogramVec) Observe(value float64, label string) {
+	v.mu.Lock()
+	h, ok := v.histograms[label]
+	if !ok {
+		h = NewHistogram(v.buckets)
+		v.histograms[label] = h
+	}
+	v.mu.Unlock()
+
+	h.Observe(value)
+}
+
+// Summaries 各标签的分位数摘要
+func (v *HistogramVec) Summaries() map[string]Summary {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	summaries := make(map[string]Summary, len(v.histograms))
+	for label, h := range v.histograms {
+		summaries[label] = h.Summary()
+	}
+	return summaries
+}
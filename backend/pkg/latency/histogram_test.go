@@ -0,0 +1,101 @@
+package latency_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/latency"
+)
+
+var buckets = []float64{100, 200, 500, 1000}
+
+func TestHistogramBucket(t *testing.T) {
+	h := latency.NewHistogram(buckets)
+
+	cases := []struct {
+		value  float64
+		bucket int
+	}{
+		{0, 0},
+		{99.9, 0},
+		{100, 0}, // 上界包含在桶内（le）
+		{100.1, 1},
+		{500, 2},
+		{999, 3},
+		{1000, 3},
+		{1000.1, 4}, // +Inf 桶
+		{60000, 4},
+	}
+	for _, tc := range cases {
+		if got := h.Bucket(tc.value); got != tc.bucket {
+			t.Errorf("Bucket(%v) = %d, want %d", tc.value, got, tc.bucket)
+		}
+	}
+
+	// 桶上界不要求按顺序给出
+	if got := latency.NewHistogram([]float64{1000, 100, 500, 200}).Bucket(150); got != 1 {
+		t.Errorf("unsorted Bucket(150) = %d, want 1", got)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	cases := []struct {
+		name    string
+		samples []float64
+		q       float64
+		want    float64
+	}{
+		{"empty", nil, 0.5, 0},
+		// 10 个样本都在 (100, 200]：第 5 个样本插值到桶的中点
+		{"within bucket", repeat(150, 10), 0.5, 150},
+		{"upper edge", repeat(150, 10), 1, 200},
+		// 第一个桶的下界为 0
+		{"first bucket", repeat(50, 4), 0.5, 50},
+		// 8 个在 (0, 100]，2 个在 (500, 1000]：P95 为第 9.5 个样本
+		{"tail", append(repeat(80, 8), 700, 900), 0.95, 875},
+		{"skips empty buckets", append(repeat(80, 8), 700, 900), 0.8, 100},
+		// 超过最大上界时返回最大的有限上界
+		{"overflow", repeat(5000, 3), 0.5, 1000},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := latency.NewHistogram(buckets)
+			for _, v := range tc.samples {
+				h.Observe(v)
+			}
+			if got := h.Quantile(tc.q); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Quantile(%v) = %v, want %v", tc.q, got, tc.want)
+			}
+			if h.Count() != int64(len(tc.samples)) {
+				t.Errorf("count = %d, want %d", h.Count(), len(tc.samples))
+			}
+		})
+	}
+}
+
+func TestHistogramVecSummaries(t *testing.T) {
+	v := latency.NewHistogramVec(buckets)
+	for _, ms := range repeat(150, 10) {
+		v.Observe(ms, "llm_first_token")
+	}
+	v.Observe(5000, "first_audio_sent")
+
+	summaries := v.Summaries()
+	if len(summaries) != 2 {
+		t.Fatalf("summaries = %+v", summaries)
+	}
+	if s := summaries["llm_first_token"]; s.Count != 10 || s.P50 != 150 || s.P95 != 195 {
+		t.Errorf("llm_first_token = %+v", s)
+	}
+	if s := summaries["first_audio_sent"]; s.Count != 1 || s.P50 != 1000 || s.P95 != 1000 {
+		t.Errorf("first_audio_sent = %+v", s)
+	}
+}
+
+func repeat(v float64, n int) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = v
+	}
+	return samples
+}
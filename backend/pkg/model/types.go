@@ -4,22 +4,38 @@ import "time"
 
 // Role represents a character with personality and skills
 type Role struct {
-	ID           string            `json:"id"`
-	Name         string            `json:"name"`
-	Avatar       string            `json:"avatar"`
-	Description  string            `json:"description"`
-	SystemPrompt string            `json:"systemPrompt"`
-	Guardrails   []string          `json:"guardrails"`
-	TTSDefault   map[string]string `json:"ttsDefault"` // voice, style settings
-	Skills       []string          `json:"skills"`    // knowledge_qa, storytelling, emotion_expression
+	ID           string            `json:"id" yaml:"id"`
+	Name         string            `json:"name" yaml:"name"`
+	Avatar       string            `json:"avatar" yaml:"avatar"`
+	Description  string            `json:"description" yaml:"description"`
+	SystemPrompt string            `json:"systemPrompt" yaml:"systemPrompt"`
+	Guardrails   []string          `json:"guardrails" yaml:"guardrails"`
+	Refusal      string            `json:"refusal" yaml:"refusal"`       // 拒答时以角色口吻说出的话
+	TTSDefault   map[string]string `json:"ttsDefault" yaml:"ttsDefault"` // provider, voice, style, speed settings
+	LLMDefault   *LLMSettings      `json:"llmDefault,omitempty" yaml:"llmDefault,omitempty"`
+	Skills       []string          `json:"skills" yaml:"skills"` // knowledge_qa, storytelling, emotion_expression
+	Version      int               `json:"version" yaml:"version"`
+}
+
+// LLMSettings LLM provider, model and sampling settings; unset fields fall back to the next level
+type LLMSettings struct {
+	Provider    string   `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Model       string   `json:"model,omitempty" yaml:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty" yaml:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty" yaml:"topP,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty" yaml:"maxTokens,omitempty"`
+	Stop        []string `json:"stop,omitempty" yaml:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty" yaml:"seed,omitempty"`
 }
 
 // Conversation represents a chat session
 type Conversation struct {
 	ID          string     `json:"id"`
 	RoleID      string     `json:"roleId"`
+	Owner       string     `json:"owner,omitempty"` // 创建会话的租户，只有同一租户可以恢复
 	Messages    []*Message `json:"messages"`
 	LastSummary string     `json:"lastSummary,omitempty"`
+	Memory      *Memory    `json:"memory,omitempty"`
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
 }
@@ -37,13 +53,16 @@ type Memory struct {
 	Summary        string   `json:"summary"`
 	KeyPoints      []string `json:"keyPoints"`
 	WindowSize     int      `json:"windowSize"` // recent messages to keep
+	Summarized     int      `json:"summarized"` // leading messages already folded into Summary
 }
 
-// WebSocket frame types for real-time communication
+// WSFrame v2 协议的帧：服务端帧的 seq 在连接内从 1 开始单调递增，turnId 标记所属的对话轮次
 type WSFrame struct {
-	Type    string      `json:"type"`
-	SeqNum  int         `json:"seq,omitempty"`
-	Content interface{} `json:"content,omitempty"`
+	Type      string      `json:"type"`
+	Seq       int64       `json:"seq"`
+	TurnID    int64       `json:"turnId,omitempty"`
+	Content   interface{} `json:"content,omitempty"`
+	Timestamp int64       `json:"timestamp"` // Unix 毫秒
 }
 
 type TextDeltaFrame struct {
@@ -51,13 +70,33 @@ type TextDeltaFrame struct {
 }
 
 type AudioChunkFrame struct {
-	Format string `json:"format"` // mp3|pcm
-	Data   string `json:"data"`   // base64 encoded
+	Format string `json:"format"`         // mp3|pcm
+	Data   []byte `json:"data"`           // base64 encoded
+	Text   string `json:"text,omitempty"` // 音频对应的句子
+}
+
+// EndFrame 一轮回复结束，text 为完整回复
+type EndFrame struct {
+	Text string `json:"text"`
 }
 
 type MetaFrame struct {
-	Usage    *Usage    `json:"usage,omitempty"`
-	Warnings []string  `json:"warnings,omitempty"`
+	TurnID            int64    `json:"turnId,omitempty"`
+	Usage             *Usage   `json:"usage,omitempty"`             // 本轮用量
+	ConversationUsage *Usage   `json:"conversationUsage,omitempty"` // 会话累计用量
+	Currency          string   `json:"currency,omitempty"`
+	Timing            *Timing  `json:"timing,omitempty"` // 本轮各阶段时间点
+	Warnings          []string `json:"warnings,omitempty"`
+}
+
+// Timing 一轮对话各阶段的时间点（Unix 毫秒），起点为用户输入结束（说完话或发送文本）
+type Timing struct {
+	InputEnd       int64 `json:"inputEnd"`
+	ASRFinal       int64 `json:"asrFinal,omitempty"`       // 语音识别最终结果
+	LLMFirstToken  int64 `json:"llmFirstToken,omitempty"`  // LLM 首个 token
+	FirstSentence  int64 `json:"firstSentence,omitempty"`  // 首个完整句子
+	TTSFirstByte   int64 `json:"ttsFirstByte,omitempty"`   // TTS 返回首个音频块
+	FirstAudioSent int64 `json:"firstAudioSent,omitempty"` // 首个音频帧下发给客户端
 }
 
 type ErrorFrame struct {
@@ -69,15 +108,19 @@ type Usage struct {
 	PromptTokens     int     `json:"promptTokens"`
 	CompletionTokens int     `json:"completionTokens"`
 	TotalTokens      int     `json:"totalTokens"`
+	TTSCharacters    int     `json:"ttsCharacters,omitempty"` // 送入 TTS 的字符数
+	ASRSeconds       float64 `json:"asrSeconds,omitempty"`    // 送入 ASR 的音频秒数
 	Cost             float64 `json:"cost,omitempty"`
+	Estimated        bool    `json:"estimated,omitempty"` // Provider 未返回用量，token 数为估算值
 }
 
 // Safety and moderation structures
 type SafetyResult struct {
-	Action  string   `json:"action"`  // block|rewrite|warn|pass
-	Score   float64  `json:"score"`
-	Labels  []string `json:"labels"`
-	Reason  string   `json:"reason"`
+	Action    string   `json:"action"` // block|rewrite|warn|pass
+	Score     float64  `json:"score"`
+	Labels    []string `json:"labels"`
+	Reason    string   `json:"reason"`
+	Rewritten string   `json:"rewritten,omitempty"` // rewrite 时替换后的文本
 }
 
 // Constants for frame types
@@ -0,0 +1,113 @@
+package provider
+
+import "unicode"
+
+// KeywordMatcher 基于 Aho-Corasick 自动机的多关键词匹配器（大小写不敏感）
+type KeywordMatcher struct {
+	nodes    []acNode
+	keywords []string
+	lengths  []int // 关键词 rune 长度
+}
+
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // 以该节点结尾的关键词下标
+}
+
+// KeywordMatch 一次关键词命中，Start/End 为 rune 下标（左闭右开）
+type KeywordMatch struct {
+	Keyword int
+	Start   int
+	End     int
+}
+
+// NewKeywordMatcher 构建关键词自动机，空关键词会被忽略
+func NewKeywordMatcher(keywords []string) *KeywordMatcher {
+	m := &KeywordMatcher{
+		nodes:    []acNode{{next: make(map[rune]int)}},
+		keywords: keywords,
+		lengths:  make([]int, len(keywords)),
+	}
+
+	for i, kw := range keywords {
+		if kw == "" {
+			continue
+		}
+
+		cur := 0
+		for _, r := range kw {
+			r = unicode.ToLower(r)
+			nxt, ok := m.nodes[cur].next[r]
+			if !ok {
+				nxt = len(m.nodes)
+				m.nodes = append(m.nodes, acNode{next: make(map[rune]int)})
+				m.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+			m.lengths[i]++
+		}
+		m.nodes[cur].output = append(m.nodes[cur].output, i)
+	}
+
+	m.buildFailLinks()
+	return m
+}
+
+// buildFailLinks 广度优先构建失配指针，并合并后缀节点的输出
+func (m *KeywordMatcher) buildFailLinks() {
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if nxt, ok := m.nodes[fail].next[r]; ok && nxt != child {
+				m.nodes[child].fail = nxt
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// Keyword 返回下标对应的关键词
+func (m *KeywordMatcher) Keyword(i int) string {
+	return m.keywords[i]
+}
+
+// FindAll 返回文本中的全部关键词命中
+func (m *KeywordMatcher) FindAll(text []rune) []KeywordMatch {
+	var matches []KeywordMatch
+
+	cur := 0
+	for i, r := range text {
+		r = unicode.ToLower(r)
+		for cur != 0 {
+			if _, ok := m.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		if nxt, ok := m.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+
+		for _, kw := range m.nodes[cur].output {
+			matches = append(matches, KeywordMatch{Keyword: kw, Start: i + 1 - m.lengths[kw], End: i + 1})
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,74 @@
+package provider_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+func TestKeywordMatcher(t *testing.T) {
+	cases := []struct {
+		name     string
+		keywords []string
+		text     string
+		want     []string // 关键词@起止（rune 下标），按命中结束位置排列
+	}{
+		{
+			// 同一位置结束的关键词都会输出，较长的在前，含作为后缀的关键词
+			name:     "overlapping",
+			keywords: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want:     []string{"she@1-4", "he@2-4", "hers@2-6"},
+		},
+		{
+			name:     "case insensitive",
+			keywords: []string{"VPN", "Proxy"},
+			text:     "free vpn and PROXY",
+			want:     []string{"VPN@5-8", "Proxy@13-18"},
+		},
+		{
+			name:     "chinese",
+			keywords: []string{"暴力", "力量", "暴力量"},
+			text:     "他用暴力量了一下",
+			want:     []string{"暴力@2-4", "暴力量@2-5", "力量@3-5"},
+		},
+		{
+			name:     "repeated",
+			keywords: []string{"aa"},
+			text:     "aaaa",
+			want:     []string{"aa@0-2", "aa@1-3", "aa@2-4"},
+		},
+		{
+			// 失配后回退到更短的前缀继续匹配
+			name:     "fail link",
+			keywords: []string{"abcd", "bce"},
+			text:     "abce",
+			want:     []string{"bce@1-4"},
+		},
+		{
+			name:     "empty keyword",
+			keywords: []string{"", "坏"},
+			text:     "不坏",
+			want:     []string{"坏@1-2"},
+		},
+		{
+			name:     "no match",
+			keywords: []string{"炸弹"},
+			text:     "炸鸡和蛋",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := provider.NewKeywordMatcher(tc.keywords)
+			var got []string
+			for _, match := range m.FindAll([]rune(tc.text)) {
+				got = append(got, fmt.Sprintf("%s@%d-%d", m.Keyword(match.Keyword), match.Start, match.End))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("matches = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
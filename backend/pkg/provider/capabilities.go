@@ -0,0 +1,112 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provider 类型
+const (
+	TypeLLM        = "llm"
+	TypeASR        = "asr"
+	TypeTTS        = "tts"
+	TypeModeration = "moderation"
+)
+
+// Capabilities Provider 的能力描述，零值字段表示未知或不适用
+type Capabilities struct {
+	Streaming      bool     `json:"streaming"`                // 流式：ASR 中间结果、LLM 增量输出、TTS 分块音频
+	Batch          bool     `json:"batch"`                    // 一次性：ASR Recognize、LLM Chat、审核 CheckText
+	AudioFormats   []string `json:"audioFormats,omitempty"`   // ASR 输入 / TTS 输出的音频格式
+	SampleRates    []int    `json:"sampleRates,omitempty"`    // 支持的采样率
+	Voices         []string `json:"voices,omitempty"`         // TTS 音色，动态音色列表见 VoiceLister
+	DefaultVoice   string   `json:"defaultVoice,omitempty"`   // TTS 默认音色
+	Models         []string `json:"models,omitempty"`         // LLM 模型，第一个为默认模型
+	MaxInputLength int      `json:"maxInputLength,omitempty"` // 单次输入上限：LLM 为 token，TTS 为字符，ASR 为音频秒数
+	Languages      []string `json:"languages,omitempty"`      // 支持的语言
+}
+
+// Describer 可选接口：Provider 实现后报告真实能力，未实现时按接口推断
+type Describer interface {
+	Describe() Capabilities
+}
+
+// Describe 返回 Provider 的能力描述
+func Describe(p interface{}) Capabilities {
+	if d, ok := p.(Describer); ok {
+		return d.Describe()
+	}
+
+	var caps Capabilities
+	switch p := p.(type) {
+	case LLMProvider:
+		caps.Streaming, caps.Batch = true, true
+	case ASRProvider:
+		// StreamRecognize 可能只是缓冲后批量识别，未声明时不当作流式
+		caps.Batch = true
+		for _, f := range p.InputFormats() {
+			caps.AudioFormats = append(caps.AudioFormats, f.String())
+			caps.SampleRates = appendUnique(caps.SampleRates, f.SampleRate)
+		}
+	case TTSProvider:
+		caps.Streaming = true
+	case ModerationProvider:
+		caps.Batch = true
+	}
+	return caps
+}
+
+// CheckModel 校验 LLM Provider 是否提供指定模型，Provider 未声明模型列表时不限制
+func CheckModel(p LLMProvider, model string) error {
+	models := Describe(p).Models
+	if model == "" || len(models) == 0 {
+		return nil
+	}
+
+	for _, m := range models {
+		if m == model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model '%s' is not available in LLM provider %s, available: %s", model, p.Name(), strings.Join(models, ", "))
+}
+
+// Features 能力对应的接口方法名，用于服务发现的 capabilities 字段
+func (c Capabilities) Features(providerType string, p interface{}) []string {
+	var features []string
+	switch providerType {
+	case TypeLLM:
+		if c.Batch {
+			features = append(features, "chat")
+		}
+		if c.Streaming {
+			features = append(features, "chat_stream")
+		}
+	case TypeASR:
+		if c.Batch {
+			features = append(features, "recognize")
+		}
+		if c.Streaming {
+			features = append(features, "stream_recognize")
+		}
+	case TypeTTS:
+		if c.Streaming {
+			features = append(features, "synthesize_stream")
+		}
+		if _, ok := p.(VoiceLister); ok {
+			features = append(features, "list_voices")
+		}
+	case TypeModeration:
+		features = append(features, "check_text")
+	}
+	return features
+}
+
+func appendUnique(values []int, v int) []int {
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
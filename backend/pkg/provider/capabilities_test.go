@@ -0,0 +1,216 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 以下 Provider 不实现 Describer，能力按接口推断
+
+type plainLLM struct{}
+
+func (plainLLM) Name() string { return "plain" }
+
+func (plainLLM) Chat(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (plainLLM) ChatStream(ctx context.Context, req *provider.ChatRequest) (<-chan *provider.ChatDelta, error) {
+	return nil, errors.New("not implemented")
+}
+
+type plainASR struct{}
+
+func (plainASR) Name() string { return "plain" }
+
+func (plainASR) Recognize(audioData []byte) (string, error) { return "", errors.New("not implemented") }
+
+func (plainASR) StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *provider.Transcript, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (plainASR) InputFormats() []audio.Format {
+	return []audio.Format{
+		audio.PCM16kMono,
+		{Encoding: audio.EncodingS16LE, SampleRate: 16000, Channels: 2},
+		{Encoding: audio.EncodingS16LE, SampleRate: 8000, Channels: 1},
+	}
+}
+
+type plainTTS struct{}
+
+func (plainTTS) Name() string { return "plain" }
+
+func (plainTTS) SynthesizeStream(ctx context.Context, textStream <-chan string, opts *provider.TTSOptions) (<-chan *provider.AudioChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestDescribe(t *testing.T) {
+	rules, err := provider.NewRuleModerationProvider("rules", []provider.ModerationRule{{Name: "r", Action: "block", Keywords: []string{"x"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name         string
+		providerType string
+		provider     interface{}
+		features     []string
+		caps         provider.Capabilities
+	}{
+		{
+			name:         "inferred llm",
+			providerType: provider.TypeLLM,
+			provider:     plainLLM{},
+			features:     []string{"chat", "chat_stream"},
+			caps:         provider.Capabilities{Streaming: true, Batch: true},
+		},
+		{
+			// 只声明 InputFormats 的 ASR 不当作流式，采样率去重
+			name:         "inferred asr",
+			providerType: provider.TypeASR,
+			provider:     plainASR{},
+			features:     []string{"recognize"},
+			caps: provider.Capabilities{
+				Batch:        true,
+				AudioFormats: []string{"s16le/16000Hz/1ch", "s16le/16000Hz/2ch", "s16le/8000Hz/1ch"},
+				SampleRates:  []int{16000, 8000},
+			},
+		},
+		{
+			name:         "inferred tts",
+			providerType: provider.TypeTTS,
+			provider:     plainTTS{},
+			features:     []string{"synthesize_stream"},
+			caps:         provider.Capabilities{Streaming: true},
+		},
+		{
+			name:         "inferred moderation",
+			providerType: provider.TypeModeration,
+			provider:     rules,
+			features:     []string{"check_text"},
+			caps:         provider.Capabilities{Batch: true},
+		},
+		{
+			// HTTP 接口只能整段识别，不报告为流式
+			name:         "qiniu asr",
+			providerType: provider.TypeASR,
+			provider:     provider.NewQiniuASRProvider("key"),
+			features:     []string{"recognize"},
+			caps: provider.Capabilities{
+				Batch:        true,
+				AudioFormats: []string{"s16le/16000Hz/1ch"},
+				SampleRates:  []int{16000},
+				Languages:    []string{"zh", "en"},
+			},
+		},
+		{
+			name:         "iflytek asr",
+			providerType: provider.TypeASR,
+			provider:     provider.NewIflytekASRProvider("app", "secret", "key"),
+			features:     []string{"recognize", "stream_recognize"},
+			caps: provider.Capabilities{
+				Streaming:      true,
+				Batch:          true,
+				AudioFormats:   []string{"s16le/16000Hz/1ch"},
+				SampleRates:    []int{16000},
+				MaxInputLength: 60,
+				Languages:      []string{"zh_cn"},
+			},
+		},
+		{
+			// 动态音色列表通过 list_voices 获取
+			name:         "qiniu tts",
+			providerType: provider.TypeTTS,
+			provider:     provider.NewQiniuTTSProvider("key"),
+			features:     []string{"synthesize_stream", "list_voices"},
+			caps: provider.Capabilities{
+				Streaming:    true,
+				AudioFormats: []string{"mp3"},
+				DefaultVoice: "qiniu_zh_female_wwxkjx",
+				Languages:    []string{"zh", "en"},
+			},
+		},
+		{
+			name:         "qiniu llm",
+			providerType: provider.TypeLLM,
+			provider:     provider.NewQiniuLLMProvider("key"),
+			features:     []string{"chat", "chat_stream"},
+			caps:         provider.Capabilities{Streaming: true, Batch: true, Models: []string{"deepseek-v3"}, Languages: []string{"zh", "en"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			caps := provider.Describe(tc.provider)
+			if !reflect.DeepEqual(caps, tc.caps) {
+				t.Errorf("Describe = %+v, want %+v", caps, tc.caps)
+			}
+			if features := caps.Features(tc.providerType, tc.provider); !reflect.DeepEqual(features, tc.features) {
+				t.Errorf("features = %v, want %v", features, tc.features)
+			}
+		})
+	}
+}
+
+func TestRegistryProviderInfo(t *testing.T) {
+	r := provider.NewRegistry()
+	r.RegisterTTS("qiniu", provider.NewQiniuTTSProvider("key"))
+	r.RegisterLLM("qiniu", provider.NewQiniuLLMProvider("key"))
+	r.RegisterLLM("plain", plainLLM{})
+	r.RegisterASR("iflytek", provider.NewIflytekASRProvider("app", "secret", "key"))
+
+	// 按类型顺序、同类型按名称排列
+	var got []string
+	for _, info := range r.GetAllProviders() {
+		got = append(got, info.Type+"/"+info.Name+":"+strings.Join(info.Capabilities, ","))
+	}
+	want := []string{
+		"llm/plain:chat,chat_stream",
+		"llm/qiniu:chat,chat_stream",
+		"asr/iflytek:recognize,stream_recognize",
+		"tts/qiniu:synthesize_stream,list_voices",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("providers = %q, want %q", got, want)
+	}
+
+	info, err := r.GetProviderInfo(provider.TypeLLM, "qiniu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(info.Details.Models, []string{"deepseek-v3"}) {
+		t.Errorf("details = %+v", info.Details)
+	}
+	if _, err := r.GetProviderInfo(provider.TypeASR, "qiniu"); err == nil {
+		t.Error("found an unregistered provider")
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	cases := []struct {
+		name  string
+		llm   provider.LLMProvider
+		model string
+		err   string
+	}{
+		{"default model", provider.NewQiniuLLMProvider("key"), "", ""},
+		{"listed model", provider.NewQiniuLLMProvider("key"), "deepseek-v3", ""},
+		{"unlisted model", provider.NewQiniuLLMProvider("key"), "gpt-4o", "available: deepseek-v3"},
+		// 未声明模型列表时不限制
+		{"no model list", plainLLM{}, "anything", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := provider.CheckModel(tc.llm, tc.model)
+			if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("err = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
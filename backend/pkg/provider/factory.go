@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Settings 配置文件中 Provider 的 settings，键名大小写不敏感
+type Settings map[string]interface{}
+
+// Decode 将 settings 解析到带 json 标签的结构体
+func (s Settings) Decode(v interface{}) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Factory 根据 settings 创建 Provider，返回值需实现 kind 对应的接口。
+// registry 中已包含配置列表里排在前面的 Provider
+type Factory func(name string, settings Settings, registry *Registry) (interface{}, error)
+
+// Spec 一个 Provider 的声明式配置
+type Spec struct {
+	Name     string   // 注册名，会话中以此选择 Provider
+	Type     string   // 实现类型，如 qiniu、iflytek、openai_compatible、failover
+	Kind     string   // llm|asr|tts|moderation
+	Settings Settings // 由对应 Factory 解析
+}
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory) // key: kind/type
+)
+
+// RegisterFactory 注册 Provider 实现，通常在实现文件的 init 中调用
+func RegisterFactory(kind, providerType string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	key := kind + "/" + providerType
+	if _, ok := factories[key]; ok {
+		panic("provider: factory registered twice: " + key)
+	}
+	factories[key] = factory
+}
+
+// FactoryTypes 返回 kind 下已注册的实现类型
+func FactoryTypes(kind string) []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	var types []string
+	for key := range factories {
+		if k, t, _ := strings.Cut(key, "/"); k == kind {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Load 按顺序创建并注册 Provider。单个 Provider 创建失败不影响其他 Provider，
+// 全部处理完后返回汇总的错误
+func (r *Registry) Load(specs []Spec) error {
+	var errs []error
+	for _, spec := range specs {
+		if err := r.load(spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s provider '%s' (%s): %w", spec.Kind, spec.Name, spec.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Registry) load(spec Spec) error {
+	if spec.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, ok := r.providers(spec.Kind)[spec.Name]; ok {
+		return errors.New("name already registered")
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[spec.Kind+"/"+spec.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown type, available: %s", strings.Join(FactoryTypes(spec.Kind), ", "))
+	}
+
+	p, err := factory(spec.Name, spec.Settings, r)
+	if err != nil {
+		return err
+	}
+
+	switch spec.Kind {
+	case TypeLLM:
+		llm, ok := p.(LLMProvider)
+		if !ok {
+			return errors.New("not an LLM provider")
+		}
+		r.RegisterLLM(spec.Name, llm)
+	case TypeASR:
+		asr, ok := p.(ASRProvider)
+		if !ok {
+			return errors.New("not an ASR provider")
+		}
+		r.RegisterASR(spec.Name, asr)
+	case TypeTTS:
+		tts, ok := p.(TTSProvider)
+		if !ok {
+			return errors.New("not a TTS provider")
+		}
+		r.RegisterTTS(spec.Name, tts)
+	case TypeModeration:
+		moderation, ok := p.(ModerationProvider)
+		if !ok {
+			return errors.New("not a moderation provider")
+		}
+		r.RegisterModeration(spec.Name, moderation)
+	default:
+		return fmt.Errorf("unknown kind '%s'", spec.Kind)
+	}
+	return nil
+}
+
+// Duration settings 中的时长，支持 "30s" 形式的字符串或秒数
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	default:
+		return fmt.Errorf("invalid duration %s", string(data))
+	}
+	return nil
+}
+
+// required 校验必填的 settings
+func required(fields map[string]string) error {
+	var missing []string
+	for key, value := range fields {
+		if value == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing settings: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
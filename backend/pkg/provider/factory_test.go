@@ -0,0 +1,186 @@
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+func init() {
+	// 返回值与 kind 不符的实现，用于校验 Load 的类型检查
+	provider.RegisterFactory(provider.TypeTTS, "mismatched", func(name string, settings provider.Settings, _ *provider.Registry) (interface{}, error) {
+		return plainLLM{}, nil
+	})
+}
+
+func TestFactoryTypes(t *testing.T) {
+	cases := []struct {
+		kind string
+		want []string
+	}{
+		{provider.TypeLLM, []string{"failover", "openai_compatible", "qiniu", "qwen"}},
+		{provider.TypeASR, []string{"failover", "iflytek", "qiniu"}},
+		{provider.TypeModeration, []string{"rule"}},
+		{"video", nil},
+	}
+	for _, tc := range cases {
+		if got := provider.FactoryTypes(tc.kind); !slices.Equal(got, tc.want) {
+			t.Errorf("FactoryTypes(%s) = %v, want %v", tc.kind, got, tc.want)
+		}
+	}
+}
+
+func TestRegisterFactoryTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a factory twice did not panic")
+		}
+	}()
+	provider.RegisterFactory(provider.TypeLLM, "qwen", func(string, provider.Settings, *provider.Registry) (interface{}, error) {
+		return nil, nil
+	})
+}
+
+func TestRegistryLoadErrors(t *testing.T) {
+	t.Setenv("COSTALK_TEST_EMPTY_KEY", "")
+
+	cases := []struct {
+		name string
+		spec provider.Spec
+		want string
+	}{
+		{"missing name", provider.Spec{Type: "qwen", Kind: provider.TypeLLM}, "llm provider '' (qwen): name is required"},
+		{"unknown type", provider.Spec{Name: "gpt", Type: "openai", Kind: provider.TypeLLM}, "unknown type, available: failover, openai_compatible, qiniu, qwen"},
+		{"unknown kind", provider.Spec{Name: "sora", Type: "qiniu", Kind: "video"}, "video provider 'sora' (qiniu): unknown type, available: "},
+		{"missing settings", provider.Spec{Name: "iflytek", Type: "iflytek", Kind: provider.TypeASR, Settings: provider.Settings{"appId": "app"}}, "missing settings: apiKey, apiSecret"},
+		{"required", provider.Spec{Name: "local", Type: "openai_compatible", Kind: provider.TypeLLM}, "missing settings: baseUrl, defaultModel"},
+		{
+			name: "api key env unset",
+			spec: provider.Spec{Name: "deepseek", Type: "openai_compatible", Kind: provider.TypeLLM, Settings: provider.Settings{
+				"baseUrl": "http://localhost", "defaultModel": "deepseek-chat", "apiKey": "sk-inline", "apiKeyEnv": "COSTALK_TEST_EMPTY_KEY",
+			}},
+			want: "environment variable COSTALK_TEST_EMPTY_KEY is not set",
+		},
+		{"wrong interface", provider.Spec{Name: "tts", Type: "mismatched", Kind: provider.TypeTTS}, "not a TTS provider"},
+		{"empty chain", provider.Spec{Name: "chain", Type: "failover", Kind: provider.TypeLLM, Settings: provider.Settings{"chain": []string{"missing"}}}, "no registered providers in chain [missing]"},
+		{"invalid settings", provider.Spec{Name: "qwen", Type: "qwen", Kind: provider.TypeLLM, Settings: provider.Settings{"apiKey": 42}}, "cannot unmarshal number"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := provider.NewRegistry().Load([]provider.Spec{tc.spec})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegistryLoad(t *testing.T) {
+	registry := provider.NewRegistry()
+	err := registry.Load([]provider.Spec{
+		{Name: "qwen", Type: "qwen", Kind: provider.TypeLLM, Settings: provider.Settings{"apiKey": "sk-qwen"}},
+		// 单个 Provider 失败不影响后续 Provider
+		{Name: "broken", Type: "qwen", Kind: provider.TypeLLM},
+		{Name: "qwen", Type: "qwen", Kind: provider.TypeLLM, Settings: provider.Settings{"apiKey": "sk-other"}},
+		{Name: "content", Type: "rule", Kind: provider.TypeModeration, Settings: provider.Settings{"rules": []map[string]interface{}{{"name": "violence", "action": "block", "keywords": []string{"暴力"}}}}},
+		// 故障转移链可以引用排在前面的 Provider，跳过未注册的成员
+		{Name: "chain", Type: "failover", Kind: provider.TypeLLM, Settings: provider.Settings{"chain": []string{"broken", "qwen"}}},
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "llm provider 'broken' (qwen): missing settings: apiKey") ||
+		!strings.Contains(err.Error(), "llm provider 'qwen' (qwen): name already registered") {
+		t.Errorf("err = %v", err)
+	}
+	for _, name := range []string{"qwen", "chain"} {
+		if _, err := registry.GetLLM(name); err != nil {
+			t.Errorf("GetLLM(%s): %v", name, err)
+		}
+	}
+	if _, err := registry.GetLLM("broken"); err == nil {
+		t.Error("broken provider registered")
+	}
+	// Provider 以配置的注册名作为自己的名称
+	if moderation, err := registry.GetModeration("content"); err != nil || moderation.Name() != "content" {
+		t.Errorf("GetModeration(content) = %v, %v", moderation, err)
+	}
+}
+
+func TestOpenAICompatibleSettings(t *testing.T) {
+	server := newOpenAIServer(t)
+	t.Setenv("COSTALK_TEST_KEY", "sk-env")
+
+	registry := provider.NewRegistry()
+	err := registry.Load([]provider.Spec{{
+		Name: "deepseek",
+		Type: "openai_compatible",
+		Kind: provider.TypeLLM,
+		Settings: provider.Settings{
+			"baseUrl":      server.URL + "/v1/",
+			"apiKey":       "sk-inline",
+			"apiKeyEnv":    "COSTALK_TEST_KEY", // 优先于 apiKey
+			"defaultModel": "deepseek-chat",
+			"models":       []string{"deepseek-reasoner"},
+			"headers":      map[string]string{"X-Tenant": "costalk"},
+			"timeout":      "5s",
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	llm, _ := registry.GetLLM("deepseek")
+
+	cases := []struct {
+		model string
+		want  string // 发送的模型，空表示拒绝
+	}{
+		{"", "deepseek-chat"},
+		{"deepseek-reasoner", "deepseek-reasoner"},
+		{"gpt-4o", ""},
+	}
+	for _, tc := range cases {
+		sent := server.requests()
+		_, err := llm.Chat(context.Background(), &provider.ChatRequest{Model: tc.model, Messages: []*provider.Message{{Role: "user", Content: "你好"}}})
+		if tc.want == "" {
+			if err == nil || !strings.Contains(err.Error(), "model 'gpt-4o' is not allowed, available: deepseek-chat, deepseek-reasoner") || server.requests() != sent {
+				t.Errorf("model %q: err = %v, requests = %d", tc.model, err, server.requests()-sent)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("model %q: %v", tc.model, err)
+		}
+		if got := server.lastBody()["model"]; got != tc.want {
+			t.Errorf("model %q: sent %v, want %s", tc.model, got, tc.want)
+		}
+		header := server.lastHeader()
+		if header.Get("Authorization") != "Bearer sk-env" || header.Get("X-Tenant") != "costalk" {
+			t.Errorf("headers = %v", header)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	cases := []struct {
+		data   string
+		want   time.Duration
+		failed bool
+	}{
+		{`"30s"`, 30 * time.Second, false},
+		{`"1m30s"`, 90 * time.Second, false},
+		{`2.5`, 2500 * time.Millisecond, false},
+		{`"soon"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tc := range cases {
+		var d provider.Duration
+		err := json.Unmarshal([]byte(tc.data), &d)
+		if tc.failed != (err != nil) || (!tc.failed && time.Duration(d) != tc.want) {
+			t.Errorf("Duration(%s) = %v, %v; want %v", tc.data, time.Duration(d), err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,861 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 续写提示：LLM 流式输出中途失败后，要求下一个 Provider 接着已下发的内容继续生成
+const continuationPrompt = "你上一条回复在中途被打断了。请紧接着上文最后一个字继续说完，不要重复已经说过的内容，也不要做任何解释。"
+
+var errCircuitOpen = errors.New("circuit open")
+
+func init() {
+	RegisterFactory(TypeLLM, "failover", func(name string, settings Settings, registry *Registry) (interface{}, error) {
+		members, policy, err := failoverSettings(name, settings, registry, TypeLLM)
+		if err != nil {
+			return nil, err
+		}
+		return NewFailoverLLMProvider(name, registry, members, policy)
+	})
+	RegisterFactory(TypeASR, "failover", func(name string, settings Settings, registry *Registry) (interface{}, error) {
+		members, policy, err := failoverSettings(name, settings, registry, TypeASR)
+		if err != nil {
+			return nil, err
+		}
+		return NewFailoverASRProvider(name, registry, members, policy)
+	})
+	RegisterFactory(TypeTTS, "failover", func(name string, settings Settings, registry *Registry) (interface{}, error) {
+		members, policy, err := failoverSettings(name, settings, registry, TypeTTS)
+		if err != nil {
+			return nil, err
+		}
+		return NewFailoverTTSProvider(name, registry, members, policy)
+	})
+}
+
+// failoverSettings 解析故障转移链配置，跳过未注册的成员（例如缺少密钥未能创建）
+func failoverSettings(name string, settings Settings, registry *Registry, kind string) ([]string, FailoverPolicy, error) {
+	var s struct {
+		Chain            []string `json:"chain"`
+		Retries          *int     `json:"retries"` // 默认 1
+		Budget           int      `json:"budget"`
+		Timeout          Duration `json:"timeout"`
+		FailureThreshold int      `json:"failureThreshold"`
+		Cooldown         Duration `json:"cooldown"`
+	}
+	if err := settings.Decode(&s); err != nil {
+		return nil, FailoverPolicy{}, err
+	}
+
+	policy := FailoverPolicy{
+		Retries:          1,
+		Budget:           s.Budget,
+		Timeout:          time.Duration(s.Timeout),
+		FailureThreshold: s.FailureThreshold,
+		Cooldown:         time.Duration(s.Cooldown),
+	}
+	if s.Retries != nil {
+		policy.Retries = *s.Retries
+	}
+
+	var members []string
+	for _, member := range s.Chain {
+		if _, ok := registry.providers(kind)[member]; !ok {
+			logx.Errorf("Failover %s/%s: skipping unregistered provider %s", kind, name, member)
+			continue
+		}
+		members = append(members, member)
+	}
+	if len(members) == 0 {
+		return nil, policy, fmt.Errorf("no registered providers in chain %v", s.Chain)
+	}
+	return members, policy, nil
+}
+
+// FailoverPolicy 故障转移策略，除 Retries 外零值字段使用默认值
+type FailoverPolicy struct {
+	Retries          int           // 每个 Provider 失败后的重试次数，0 为不重试；由配置创建时默认 1
+	Budget           int           // 单次调用在整条链上的最多尝试次数，默认为链长度 ×（重试次数 + 1）
+	Timeout          time.Duration // 单次尝试超时，流式调用为建立连接与等待首个结果的时间，默认 30s
+	FailureThreshold int           // 连续失败达到该次数后熔断，默认 3
+	Cooldown         time.Duration // 熔断持续时间，之后放行一次试探请求，默认 30s
+}
+
+func (p FailoverPolicy) withDefaults(members int) FailoverPolicy {
+	if p.Retries < 0 {
+		p.Retries = 0
+	}
+	if p.Budget <= 0 {
+		p.Budget = members * (p.Retries + 1)
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 30 * time.Second
+	}
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = 3
+	}
+	if p.Cooldown <= 0 {
+		p.Cooldown = 30 * time.Second
+	}
+	return p
+}
+
+// breaker 熔断器：连续失败达到阈值后打开，冷却结束后半开放行一个试探请求，成功即关闭
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release 调用方取消时既不算成功也不算失败，只释放试探名额
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// finalError 包装后不再尝试链上的其他 Provider，例如已向用户下发了部分音频
+type finalError struct {
+	err error
+}
+
+func (e *finalError) Error() string { return e.err.Error() }
+func (e *finalError) Unwrap() error { return e.err }
+
+type failoverMember[T any] struct {
+	name     string
+	provider T
+	primary  bool // 链上第一个 Provider，沿用调用方指定的模型与音色
+	breaker  *breaker
+}
+
+// failoverChain 按顺序尝试链上的 Provider，受重试预算与熔断限制
+type failoverChain[T any] struct {
+	name    string
+	policy  FailoverPolicy
+	members []*failoverMember[T]
+}
+
+func newFailoverChain[T any](name string, names []string, lookup func(string) (T, error), policy FailoverPolicy) (*failoverChain[T], error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("failover chain %s is empty", name)
+	}
+
+	policy = policy.withDefaults(len(names))
+	c := &failoverChain[T]{name: name, policy: policy}
+	for i, member := range names {
+		if member == name {
+			return nil, fmt.Errorf("failover chain %s cannot contain itself", name)
+		}
+		p, err := lookup(member)
+		if err != nil {
+			return nil, fmt.Errorf("failover chain %s: %w", name, err)
+		}
+		c.members = append(c.members, &failoverMember[T]{
+			name:     member,
+			provider: p,
+			primary:  i == 0,
+			breaker:  &breaker{threshold: policy.FailureThreshold, cooldown: policy.Cooldown},
+		})
+	}
+	return c, nil
+}
+
+// run 依次尝试链上的 Provider 直到 attempt 成功；调用方取消不计入 Provider 的失败
+func (c *failoverChain[T]) run(ctx context.Context, attempt func(ctx context.Context, m *failoverMember[T]) error) error {
+	budget := c.policy.Budget
+	var errs []error
+
+	for _, m := range c.members {
+		for try := 0; try <= c.policy.Retries; try++ {
+			if budget == 0 {
+				errs = append(errs, fmt.Errorf("retry budget of %d attempts exhausted", c.policy.Budget))
+				return fmt.Errorf("all providers in %s failed: %w", c.name, errors.Join(errs...))
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !m.breaker.allow() {
+				errs = append(errs, fmt.Errorf("%s: %w", m.name, errCircuitOpen))
+				break
+			}
+
+			budget--
+			err := attempt(ctx, m)
+			if err == nil {
+				m.breaker.success()
+				return nil
+			}
+			if ctx.Err() != nil {
+				m.breaker.release()
+				return ctx.Err()
+			}
+
+			m.breaker.failure()
+			logx.Errorf("Failover %s: provider %s failed (attempt %d): %v", c.name, m.name, try+1, err)
+
+			var final *finalError
+			if errors.As(err, &final) {
+				return fmt.Errorf("%s: %w", m.name, final.err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+
+	return fmt.Errorf("all providers in %s failed: %w", c.name, errors.Join(errs...))
+}
+
+// attemptContext 单次尝试的上下文：timeout 内未调用 stop 则取消，timedOut 报告是否因超时取消
+func attemptContext(ctx context.Context, timeout time.Duration) (attemptCtx context.Context, stop func(), timedOut func() bool, cancel context.CancelFunc) {
+	attemptCtx, cancel = context.WithCancel(ctx)
+	var fired atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		fired.Store(true)
+		cancel()
+	})
+	return attemptCtx, func() { timer.Stop() }, fired.Load, cancel
+}
+
+// FailoverLLMProvider 组合 LLM Provider：按顺序故障转移，流式输出中途失败时由下一个 Provider 续写
+type FailoverLLMProvider struct {
+	chain *failoverChain[LLMProvider]
+}
+
+func NewFailoverLLMProvider(name string, registry *Registry, members []string, policy FailoverPolicy) (*FailoverLLMProvider, error) {
+	chain, err := newFailoverChain(name, members, registry.GetLLM, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &FailoverLLMProvider{chain: chain}, nil
+}
+
+func (p *FailoverLLMProvider) Name() string {
+	return p.chain.name
+}
+
+// Describe 实现 Describer 接口：以主 Provider 为准，模型取全部成员的并集
+func (p *FailoverLLMProvider) Describe() Capabilities {
+	caps := Describe(p.chain.members[0].provider)
+	caps.Models = nil
+	for _, m := range p.chain.members {
+		for _, model := range Describe(m.provider).Models {
+			caps.Models = appendUniqueString(caps.Models, model)
+		}
+	}
+	return caps
+}
+
+func (p *FailoverLLMProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var resp *ChatResponse
+	err := p.chain.run(ctx, func(ctx context.Context, m *failoverMember[LLMProvider]) error {
+		ctx, cancel := context.WithTimeout(ctx, p.chain.policy.Timeout)
+		defer cancel()
+
+		var err error
+		resp, err = m.provider.Chat(ctx, memberChatRequest(m.provider, req))
+		return err
+	})
+	return resp, err
+}
+
+// ChatStream 流式对话。建立连接和首个输出受超时限制；已有输出后失败时，
+// 下一个 Provider 以已下发文本为上文续写，并去掉重复输出的前缀，用户不会收到重复内容。
+// 失败的尝试消耗的用量并入之后上报的用量，未上报时按文本估算。全部失败时以 Err 结束输出流。
+func (p *FailoverLLMProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatDelta, error) {
+	out := make(chan *ChatDelta, 100)
+
+	go func() {
+		defer close(out)
+
+		var (
+			sent  strings.Builder
+			spent *Usage // 此前失败的尝试消耗的用量
+		)
+		err := p.chain.run(ctx, func(ctx context.Context, m *failoverMember[LLMProvider]) (err error) {
+			r := memberChatRequest(m.provider, req)
+			var dedup *prefixFilter
+			if sent.Len() > 0 {
+				r = continuationRequest(r, sent.String())
+				dedup = &prefixFilter{prefix: sent.String()}
+				logx.Infof("Failover %s: %s continues after %d bytes", p.chain.name, m.name, sent.Len())
+			}
+
+			attemptCtx, stop, timedOut, cancel := attemptContext(ctx, p.chain.policy.Timeout)
+			defer cancel()
+
+			stream, err := m.provider.ChatStream(attemptCtx, r)
+			if err != nil {
+				stop()
+				if timedOut() {
+					return fmt.Errorf("no response within %v", p.chain.policy.Timeout)
+				}
+				return err
+			}
+			defer func() {
+				// 放弃的流需排空，避免 Provider 协程阻塞
+				go func() {
+					for range stream {
+					}
+				}()
+			}()
+
+			var (
+				reported  *Usage          // 本次尝试上报的用量
+				generated strings.Builder // 本次尝试生成的文本，含去掉的重复前缀
+			)
+			defer func() {
+				// 已有输出的失败尝试同样计费
+				if err != nil && (reported != nil || generated.Len() > 0) {
+					spent = attemptUsage(spent, r, reported, generated.String())
+				}
+			}()
+
+			for delta := range stream {
+				stop()
+				if delta == nil {
+					continue
+				}
+				if delta.Err != nil {
+					return delta.Err
+				}
+				generated.WriteString(delta.Text)
+
+				text := delta.Text
+				if dedup != nil {
+					text = dedup.filter(text)
+				}
+				if text == "" && delta.FinishReason == "" && delta.Usage == nil {
+					continue
+				}
+				sent.WriteString(text)
+
+				usage := delta.Usage
+				if usage != nil {
+					reported = usage
+					if spent != nil {
+						total := addUsage(*spent, *usage)
+						usage = &total
+					}
+				}
+
+				select {
+				case out <- &ChatDelta{Text: text, FinishReason: delta.FinishReason, Usage: usage}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			if timedOut() {
+				return fmt.Errorf("no response within %v", p.chain.policy.Timeout)
+			}
+			if reported == nil && spent != nil {
+				// 续写的 Provider 未上报用量，补发合计，避免调用方只按最终文本估算
+				select {
+				case out <- &ChatDelta{Usage: attemptUsage(spent, r, nil, generated.String())}:
+				case <-ctx.Done():
+				}
+			}
+			return ctx.Err()
+		})
+
+		if err != nil && ctx.Err() == nil {
+			out <- &ChatDelta{Err: err}
+		}
+	}()
+
+	return out, nil
+}
+
+// attemptUsage 将一次尝试的用量并入此前的合计，未上报时按文本估算
+func attemptUsage(spent *Usage, req *ChatRequest, reported *Usage, completion string) *Usage {
+	u := EstimateChatUsage(req, completion)
+	if reported != nil {
+		u = *reported
+	}
+	if spent != nil {
+		u = addUsage(*spent, u)
+	}
+	return &u
+}
+
+// addUsage 合计两次尝试的用量，模型以后一次为准
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+		Cost:             a.Cost + b.Cost,
+		Model:            b.Model,
+		Estimated:        a.Estimated || b.Estimated,
+	}
+}
+
+// memberChatRequest 成员不支持请求的模型时改用其默认模型
+func memberChatRequest(p LLMProvider, req *ChatRequest) *ChatRequest {
+	if CheckModel(p, req.Model) == nil {
+		return req
+	}
+
+	r := *req
+	r.Model = ""
+	return &r
+}
+
+func continuationRequest(req *ChatRequest, partial string) *ChatRequest {
+	r := *req
+	r.Messages = append(append([]*Message(nil), req.Messages...),
+		&Message{Role: "assistant", Content: partial},
+		&Message{Role: "user", Content: continuationPrompt},
+	)
+	return &r
+}
+
+// prefixFilter 去掉续写输出中与已下发文本重复的开头（模型有时会从头复述）
+type prefixFilter struct {
+	prefix  string
+	pending string
+	done    bool
+}
+
+func (f *prefixFilter) filter(text string) string {
+	if f.done {
+		return text
+	}
+
+	f.pending += text
+	switch {
+	case strings.HasPrefix(f.prefix, f.pending):
+		// 仍与已下发文本一致，继续缓冲；完全一致时丢弃
+		if len(f.pending) == len(f.prefix) {
+			f.done = true
+			f.pending = ""
+		}
+		return ""
+	case strings.HasPrefix(f.pending, f.prefix):
+		text = f.pending[len(f.prefix):]
+	default:
+		text = f.pending
+	}
+
+	f.done = true
+	f.pending = ""
+	return text
+}
+
+// FailoverASRProvider 组合 ASR Provider：按顺序故障转移，流式识别失败时向下一个 Provider 重放已收到的音频
+type FailoverASRProvider struct {
+	chain   *failoverChain[ASRProvider]
+	formats []audio.Format
+}
+
+func NewFailoverASRProvider(name string, registry *Registry, members []string, policy FailoverPolicy) (*FailoverASRProvider, error) {
+	chain, err := newFailoverChain(name, members, registry.GetASR, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 音频在进入组合 Provider 前完成转码，只能选择全部成员都接受的格式
+	formats := chain.members[0].provider.InputFormats()
+	for _, m := range chain.members[1:] {
+		formats = commonFormats(formats, m.provider.InputFormats())
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("failover chain %s: members share no common input format", name)
+	}
+
+	return &FailoverASRProvider{chain: chain, formats: formats}, nil
+}
+
+func (p *FailoverASRProvider) Name() string {
+	return p.chain.name
+}
+
+func (p *FailoverASRProvider) InputFormats() []audio.Format {
+	return p.formats
+}
+
+// Describe 实现 Describer 接口：以主 Provider 为准，时长上限取全部成员中最严格的值
+func (p *FailoverASRProvider) Describe() Capabilities {
+	caps := Describe(p.chain.members[0].provider)
+	for _, m := range p.chain.members[1:] {
+		limit := Describe(m.provider).MaxInputLength
+		if limit > 0 && (caps.MaxInputLength == 0 || limit < caps.MaxInputLength) {
+			caps.MaxInputLength = limit
+		}
+	}
+	return caps
+}
+
+// Recognize 批量识别，接口不带 ctx，超时的尝试在后台自行结束
+func (p *FailoverASRProvider) Recognize(audioData []byte) (string, error) {
+	var text string
+	err := p.chain.run(context.Background(), func(ctx context.Context, m *failoverMember[ASRProvider]) error {
+		type result struct {
+			text string
+			err  error
+		}
+		done := make(chan result, 1)
+		go func() {
+			text, err := m.provider.Recognize(audioData)
+			done <- result{text, err}
+		}()
+
+		timer := time.NewTimer(p.chain.policy.Timeout)
+		defer timer.Stop()
+
+		select {
+		case r := <-done:
+			text = r.text
+			return r.err
+		case <-timer.C:
+			return fmt.Errorf("no result within %v", p.chain.policy.Timeout)
+		}
+	})
+	return text, err
+}
+
+// StreamRecognize 流式识别。音频只从 audioStream 读取一次并保留副本，
+// 当前 Provider 失败时，下一个 Provider 从头重放全部音频，并跳过已下发过最终结果的句子。
+// 全部失败时以 Err 结束结果流。
+func (p *FailoverASRProvider) StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *Transcript, error) {
+	replay := newAudioReplay()
+	go replay.pump(ctx, audioStream)
+
+	out := make(chan *Transcript, 16)
+	go func() {
+		defer close(out)
+
+		finals := 0 // 已下发的最终结果数
+		err := p.chain.run(ctx, func(ctx context.Context, m *failoverMember[ASRProvider]) error {
+			attemptCtx, stop, timedOut, cancel := attemptContext(ctx, p.chain.policy.Timeout)
+			defer cancel()
+
+			in := make(chan []byte, 100)
+			transcripts, err := m.provider.StreamRecognize(attemptCtx, in)
+			stop() // 语音前可能是长时间静音，超时只限制建立连接
+			if err != nil {
+				close(in)
+				if timedOut() {
+					return fmt.Errorf("no connection within %v", p.chain.policy.Timeout)
+				}
+				return err
+			}
+			go replay.feed(attemptCtx, in)
+
+			skip := finals
+			for t := range transcripts {
+				if t == nil {
+					continue
+				}
+				if t.Err != nil {
+					return t.Err
+				}
+				if skip > 0 {
+					if t.IsFinal {
+						skip--
+					}
+					continue
+				}
+				select {
+				case out <- t:
+					if t.IsFinal {
+						finals++
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return ctx.Err()
+		})
+
+		if err != nil && ctx.Err() == nil {
+			out <- &Transcript{Err: err}
+		}
+	}()
+
+	return out, nil
+}
+
+// audioReplay 缓存流式音频，供每次尝试从头读取
+type audioReplay struct {
+	mu     sync.Mutex
+	chunks [][]byte
+	closed bool
+	notify chan struct{} // 有新数据或输入结束时关闭并替换
+}
+
+func newAudioReplay() *audioReplay {
+	return &audioReplay{notify: make(chan struct{})}
+}
+
+func (r *audioReplay) pump(ctx context.Context, src <-chan []byte) {
+	defer r.update(nil, true)
+
+	for {
+		select {
+		case data, ok := <-src:
+			if !ok {
+				return
+			}
+			r.update(data, false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *audioReplay) update(data []byte, closed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if data != nil {
+		r.chunks = append(r.chunks, data)
+	}
+	r.closed = r.closed || closed
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// feed 从头发送全部音频到 dst，输入结束后关闭 dst
+func (r *audioReplay) feed(ctx context.Context, dst chan<- []byte) {
+	defer close(dst)
+
+	next := 0
+	for {
+		r.mu.Lock()
+		chunks := r.chunks[next:]
+		closed := r.closed
+		notify := r.notify
+		r.mu.Unlock()
+
+		for _, chunk := range chunks {
+			select {
+			case dst <- chunk:
+				next++
+			case <-ctx.Done():
+				return
+			}
+		}
+		if len(chunks) > 0 {
+			continue
+		}
+		if closed {
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FailoverTTSProvider 组合 TTS Provider：逐段文本故障转移。
+// 某段文本已下发部分音频后失败不再切换，避免用户听到重复的语音。
+type FailoverTTSProvider struct {
+	chain *failoverChain[TTSProvider]
+}
+
+func NewFailoverTTSProvider(name string, registry *Registry, members []string, policy FailoverPolicy) (*FailoverTTSProvider, error) {
+	chain, err := newFailoverChain(name, members, registry.GetTTS, policy)
+	if err != nil {
+		return nil, err
+	}
+	return &FailoverTTSProvider{chain: chain}, nil
+}
+
+func (p *FailoverTTSProvider) Name() string {
+	return p.chain.name
+}
+
+// Describe 实现 Describer 接口：以主 Provider 为准
+func (p *FailoverTTSProvider) Describe() Capabilities {
+	return Describe(p.chain.members[0].provider)
+}
+
+func (p *FailoverTTSProvider) SynthesizeStream(ctx context.Context, textStream <-chan string, opts *TTSOptions) (<-chan *AudioChunk, error) {
+	out := make(chan *AudioChunk, 10)
+
+	go func() {
+		defer close(out)
+
+		seqNum := 0
+		for {
+			var text string
+			select {
+			case <-ctx.Done():
+				return
+			case t, ok := <-textStream:
+				if !ok {
+					return
+				}
+				text = t
+			}
+
+			err := p.chain.run(ctx, func(ctx context.Context, m *failoverMember[TTSProvider]) error {
+				return p.synthesize(ctx, m, text, opts, out, &seqNum)
+			})
+			if err != nil && ctx.Err() == nil {
+				select {
+				case out <- &AudioChunk{Err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (p *FailoverTTSProvider) synthesize(ctx context.Context, m *failoverMember[TTSProvider], text string, opts *TTSOptions, out chan<- *AudioChunk, seqNum *int) error {
+	attemptCtx, stop, timedOut, cancel := attemptContext(ctx, p.chain.policy.Timeout)
+	defer cancel()
+
+	in := make(chan string, 1)
+	in <- text
+	close(in)
+
+	chunks, err := m.provider.SynthesizeStream(attemptCtx, in, memberTTSOptions(m, opts))
+	if err != nil {
+		stop()
+		if timedOut() {
+			return fmt.Errorf("no response within %v", p.chain.policy.Timeout)
+		}
+		return err
+	}
+	defer func() {
+		go func() {
+			for range chunks {
+			}
+		}()
+	}()
+
+	forwarded := 0
+	for chunk := range chunks {
+		if chunk == nil {
+			continue
+		}
+		if chunk.Err != nil {
+			if forwarded > 0 {
+				return &finalError{err: chunk.Err}
+			}
+			return chunk.Err
+		}
+		stop()
+
+		c := *chunk
+		c.SeqNum = *seqNum
+		*seqNum++
+		select {
+		case out <- &c:
+			forwarded++
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if timedOut() {
+		return fmt.Errorf("no audio within %v", p.chain.policy.Timeout)
+	}
+	if forwarded == 0 {
+		return errors.New("no audio returned")
+	}
+	return nil
+}
+
+// memberTTSOptions 备用 Provider 不认识调用方的音色时改用其默认音色
+func memberTTSOptions(m *failoverMember[TTSProvider], opts *TTSOptions) *TTSOptions {
+	if m.primary || opts == nil || opts.Voice == "" {
+		return opts
+	}
+
+	caps := Describe(m.provider)
+	if containsString(caps.Voices, opts.Voice) {
+		return opts
+	}
+
+	o := *opts
+	o.Voice = caps.DefaultVoice
+	return &o
+}
+
+func commonFormats(a, b []audio.Format) []audio.Format {
+	var common []audio.Format
+	for _, f := range a {
+		for _, g := range b {
+			if f == g {
+				common = append(common, f)
+				break
+			}
+		}
+	}
+	return common
+}
+
+func containsString(values []string, v string) bool {
+	for _, existing := range values {
+		if existing == v {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUniqueString(values []string, v string) []string {
+	if containsString(values, v) {
+		return values
+	}
+	return append(values, v)
+}
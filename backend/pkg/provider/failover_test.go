@@ -0,0 +1,213 @@
+package provider_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestFailoverLLMSwitchesProvider(t *testing.T) {
+	primary := providertest.NewLLM("primary", providertest.LLMReply{Err: errors.New("503")})
+	backup := providertest.NewLLM("backup", providertest.Reply("备用", "回复。"))
+
+	r := provider.NewRegistry()
+	r.RegisterLLM("primary", primary)
+	r.RegisterLLM("backup", backup)
+	chain, err := provider.NewFailoverLLMProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, _ := chain.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")})
+	text, _, err := collectChat(stream)
+	if err != nil || text != "备用回复。" {
+		t.Errorf("text = %q, err = %v", text, err)
+	}
+	if len(primary.Requests()) != 1 || len(backup.Requests()) != 1 {
+		t.Errorf("primary got %d requests, backup got %d", len(primary.Requests()), len(backup.Requests()))
+	}
+}
+
+func TestFailoverLLMContinuesInterruptedStream(t *testing.T) {
+	primary := providertest.NewLLM("primary", providertest.LLMReply{
+		Chunks:    []string{"你好，", "我是"},
+		StreamErr: errors.New("connection reset"),
+	})
+	// 续写时模型从头复述了已下发的内容
+	backup := providertest.NewLLM("backup", providertest.Reply("你好，我是", "哈利。"))
+
+	r := provider.NewRegistry()
+	r.RegisterLLM("primary", primary)
+	r.RegisterLLM("backup", backup)
+	chain, _ := provider.NewFailoverLLMProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+
+	stream, _ := chain.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("你是谁？")})
+	text, _, err := collectChat(stream)
+	if err != nil || text != "你好，我是哈利。" {
+		t.Errorf("text = %q, err = %v; want no duplicated prefix", text, err)
+	}
+
+	messages := backup.Requests()[0].Messages
+	if len(messages) != 3 || messages[1].Role != "assistant" || messages[1].Content != "你好，我是" {
+		t.Errorf("continuation request did not carry the partial reply: %+v", messages)
+	}
+}
+
+func TestFailoverLLMSumsUsageAcrossAttempts(t *testing.T) {
+	partial := []string{"你好，", "我是"}
+	// 主 Provider 中断前未上报用量时按其请求与已生成文本估算
+	estimated := provider.EstimateChatUsage(&provider.ChatRequest{Messages: userMessage("你是谁？")}, "你好，我是")
+
+	cases := []struct {
+		name    string
+		primary *provider.Usage
+		backup  *provider.Usage
+		want    provider.Usage
+	}{
+		{
+			name:    "both reported",
+			primary: &provider.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12, Model: "a"},
+			backup:  &provider.Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23, Model: "b"},
+			want:    provider.Usage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35, Model: "b"},
+		},
+		{
+			name:   "primary estimated",
+			backup: &provider.Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23, Model: "b"},
+			want: provider.Usage{
+				PromptTokens:     20 + estimated.PromptTokens,
+				CompletionTokens: 3 + estimated.CompletionTokens,
+				TotalTokens:      23 + estimated.TotalTokens,
+				Model:            "b",
+				Estimated:        true,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			primary := providertest.NewLLM("primary", providertest.LLMReply{
+				Chunks:    partial,
+				Usage:     tc.primary,
+				StreamErr: errors.New("connection reset"),
+			})
+			backup := providertest.NewLLM("backup", providertest.LLMReply{Chunks: []string{"哈利。"}, Usage: tc.backup})
+
+			r := provider.NewRegistry()
+			r.RegisterLLM("primary", primary)
+			r.RegisterLLM("backup", backup)
+			chain, _ := provider.NewFailoverLLMProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+
+			stream, _ := chain.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("你是谁？")})
+			_, usage, err := collectChat(stream)
+			if err != nil || usage == nil || *usage != tc.want {
+				t.Errorf("usage = %+v, err = %v; want %+v", usage, err, tc.want)
+			}
+		})
+	}
+
+	t.Run("none reported", func(t *testing.T) {
+		primary := providertest.NewLLM("primary", providertest.LLMReply{Chunks: partial, StreamErr: errors.New("connection reset")})
+		backup := providertest.NewLLM("backup", providertest.Reply("哈利。"))
+
+		r := provider.NewRegistry()
+		r.RegisterLLM("primary", primary)
+		r.RegisterLLM("backup", backup)
+		chain, _ := provider.NewFailoverLLMProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+
+		stream, _ := chain.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("你是谁？")})
+		_, usage, err := collectChat(stream)
+		if err != nil || usage == nil || !usage.Estimated || usage.PromptTokens <= estimated.PromptTokens {
+			t.Errorf("usage = %+v, err = %v; want both attempts estimated", usage, err)
+		}
+	})
+}
+
+func TestFailoverLLMCircuitBreaker(t *testing.T) {
+	primary := providertest.NewLLM("primary",
+		providertest.LLMReply{Err: errors.New("503")},
+		providertest.Reply("不应被调用"),
+	)
+	backup := providertest.NewLLM("backup", providertest.Reply("一"), providertest.Reply("二"))
+
+	r := provider.NewRegistry()
+	r.RegisterLLM("primary", primary)
+	r.RegisterLLM("backup", backup)
+	chain, _ := provider.NewFailoverLLMProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{
+		FailureThreshold: 1,
+		Cooldown:         time.Minute,
+	})
+
+	for _, want := range []string{"一", "二"} {
+		resp, err := chain.Chat(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")})
+		if err != nil || resp.Text != want {
+			t.Fatalf("resp = %+v, err = %v", resp, err)
+		}
+	}
+	if n := len(primary.Requests()); n != 1 {
+		t.Errorf("primary got %d requests while its circuit was open", n)
+	}
+}
+
+func TestFailoverASRReplaysAudio(t *testing.T) {
+	primary := providertest.NewASR("primary", providertest.ASRResult{StreamErr: errors.New("engine busy")})
+	backup := providertest.NewASR("backup", providertest.Transcript("讲个故事"))
+
+	r := provider.NewRegistry()
+	r.RegisterASR("primary", primary)
+	r.RegisterASR("backup", backup)
+	chain, err := provider.NewFailoverASRProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	audio := make(chan []byte, 3)
+	for _, chunk := range [][]byte{{1, 2}, {3, 4}, {5, 6}} {
+		audio <- chunk
+	}
+	close(audio)
+
+	results, _ := chain.StreamRecognize(testContext(t), audio)
+	var final string
+	for transcript := range results {
+		if transcript.Err != nil {
+			t.Fatalf("stream failed: %v", transcript.Err)
+		}
+		if transcript.IsFinal {
+			final = transcript.Text
+		}
+	}
+	if final != "讲个故事" {
+		t.Errorf("final = %q", final)
+	}
+	if got := backup.Audio(); len(got) != 1 || !bytes.Equal(got[0], []byte{1, 2, 3, 4, 5, 6}) {
+		t.Errorf("backup received %v, want the full replayed audio", got)
+	}
+}
+
+func TestFailoverTTS(t *testing.T) {
+	primary := providertest.NewTTS("primary").FailOn(func(text string) error { return errors.New("voice unavailable") })
+	backup := providertest.NewTTS("backup")
+
+	r := provider.NewRegistry()
+	r.RegisterTTS("primary", primary)
+	r.RegisterTTS("backup", backup)
+	chain, err := provider.NewFailoverTTSProvider("chain", r, []string{"primary", "backup"}, provider.FailoverPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, _ := chain.SynthesizeStream(testContext(t), texts("你好。"), &provider.TTSOptions{Voice: "custom"})
+	audio, err := collectAudio(stream)
+	if err != nil || !bytes.Equal(audio, providertest.AudioFor("你好。")) {
+		t.Errorf("audio = %q, err = %v", audio, err)
+	}
+
+	// 备用 Provider 不认识调用方的音色，改用其默认音色
+	calls := backup.Calls()
+	if len(calls) != 1 || calls[0].Options.Voice != "fake" {
+		t.Errorf("backup calls = %+v", calls)
+	}
+}
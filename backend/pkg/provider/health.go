@@ -0,0 +1,254 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Provider 健康状态
+const (
+	StatusUnknown  = "unknown"  // 尚未检查，或 Provider 未实现 HealthChecker
+	StatusOnline   = "online"   // 检查通过
+	StatusDegraded = "degraded" // 偶发失败或响应过慢
+	StatusOffline  = "offline"  // 连续失败
+)
+
+// HealthChecker 可选接口：执行一次低成本的可用性检查，例如鉴权后列出模型或建立连接后立即关闭，
+// 不应产生计费调用
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// HealthConfig 健康检查参数，零值字段使用默认值
+type HealthConfig struct {
+	Interval      time.Duration // 检查间隔，默认 30s
+	Timeout       time.Duration // 单次检查超时，默认 5s
+	OfflineAfter  int           // 连续失败达到该次数判定离线，默认 3
+	SlowThreshold time.Duration // P95 延迟超过该值判定降级，默认 2s
+	Window        int           // 延迟统计的样本数，默认 20
+}
+
+func (c HealthConfig) withDefaults() HealthConfig {
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	if c.OfflineAfter <= 0 {
+		c.OfflineAfter = 3
+	}
+	if c.SlowThreshold <= 0 {
+		c.SlowThreshold = 2 * time.Second
+	}
+	if c.Window <= 0 {
+		c.Window = 20
+	}
+	return c
+}
+
+// Health Provider 的健康状态
+type Health struct {
+	Status              string        `json:"status"`
+	LastError           string        `json:"lastError,omitempty"`
+	LastErrorAt         time.Time     `json:"lastErrorAt"`
+	LastCheck           time.Time     `json:"lastCheck"`
+	ConsecutiveFailures int           `json:"consecutiveFailures"`
+	LatencyP50          time.Duration `json:"latencyP50"`
+	LatencyP95          time.Duration `json:"latencyP95"`
+}
+
+// HealthMonitor 记录各 Provider 的检查结果，并可在后台周期性检查
+type HealthMonitor struct {
+	cfg HealthConfig
+
+	mu     sync.RWMutex
+	states map[string]*healthState // key: type/name
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+type healthState struct {
+	health    Health
+	latencies []time.Duration // 最近的成功检查延迟，环形缓冲
+	next      int
+}
+
+func NewHealthMonitor(cfg HealthConfig) *HealthMonitor {
+	return &HealthMonitor{
+		cfg:    cfg.withDefaults(),
+		states: make(map[string]*healthState),
+		stop:   make(chan struct{}),
+	}
+}
+
+func healthKey(providerType, name string) string {
+	return providerType + "/" + name
+}
+
+// Health 返回 Provider 的健康状态，未检查过时为 unknown
+func (m *HealthMonitor) Health(providerType, name string) Health {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if state, ok := m.states[healthKey(providerType, name)]; ok {
+		return state.health
+	}
+	return Health{Status: StatusUnknown}
+}
+
+// Record 记录一次检查结果并更新状态：
+// 成功后恢复 online（P95 延迟过高时为 degraded），失败后为 degraded，连续失败达到阈值后为 offline
+func (m *HealthMonitor) Record(providerType, name string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := healthKey(providerType, name)
+	state, ok := m.states[key]
+	if !ok {
+		state = &healthState{}
+		m.states[key] = state
+	}
+
+	now := time.Now()
+	h := &state.health
+	h.LastCheck = now
+
+	if err != nil {
+		h.ConsecutiveFailures++
+		h.LastError = err.Error()
+		h.LastErrorAt = now
+		if h.ConsecutiveFailures >= m.cfg.OfflineAfter {
+			h.Status = StatusOffline
+		} else {
+			h.Status = StatusDegraded
+		}
+		return
+	}
+
+	h.ConsecutiveFailures = 0
+	if len(state.latencies) < m.cfg.Window {
+		state.latencies = append(state.latencies, latency)
+	} else {
+		state.latencies[state.next] = latency
+		state.next = (state.next + 1) % m.cfg.Window
+	}
+	h.LatencyP50 = percentile(state.latencies, 0.50)
+	h.LatencyP95 = percentile(state.latencies, 0.95)
+
+	if h.LatencyP95 > m.cfg.SlowThreshold {
+		h.Status = StatusDegraded
+	} else {
+		h.Status = StatusOnline
+	}
+}
+
+// Start 立即检查一次，之后按间隔在后台检查 registry 中的全部 Provider
+func (m *HealthMonitor) Start(registry *Registry) {
+	go func() {
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			m.CheckAll(context.Background(), registry)
+
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (m *HealthMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// CheckAll 并发检查所有实现了 HealthChecker 的 Provider
+func (m *HealthMonitor) CheckAll(ctx context.Context, registry *Registry) {
+	var wg sync.WaitGroup
+	for _, providerType := range providerTypes {
+		for name, p := range registry.providers(providerType) {
+			checker, ok := p.(HealthChecker)
+			if !ok {
+				continue
+			}
+
+			wg.Add(1)
+			go func(providerType, name string, checker HealthChecker) {
+				defer wg.Done()
+				m.check(ctx, providerType, name, checker)
+			}(providerType, name, checker)
+		}
+	}
+	wg.Wait()
+}
+
+func (m *HealthMonitor) check(ctx context.Context, providerType, name string, checker HealthChecker) {
+	ctx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.HealthCheck(ctx)
+	latency := time.Since(start)
+
+	before := m.Health(providerType, name).Status
+	m.Record(providerType, name, latency, err)
+	after := m.Health(providerType, name).Status
+
+	if before != after {
+		if err != nil {
+			logx.Errorf("Provider %s/%s is now %s: %v", providerType, name, after, err)
+		} else {
+			logx.Infof("Provider %s/%s is now %s (latency %v)", providerType, name, after, latency)
+		}
+	}
+}
+
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	// 最近秩法
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return sorted[index]
+}
+
+// checkHTTP 以 GET 请求检查 HTTP 接口的连通性与鉴权
+func checkHTTP(ctx context.Context, client *http.Client, url, apiKey string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("health check returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
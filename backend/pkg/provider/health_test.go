@@ -0,0 +1,128 @@
+package provider_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+func TestHealthMonitorTransitions(t *testing.T) {
+	const ms = time.Millisecond
+	m := provider.NewHealthMonitor(provider.HealthConfig{OfflineAfter: 3, SlowThreshold: 100 * ms, Window: 4})
+	if h := m.Health(provider.TypeLLM, "a"); h.Status != provider.StatusUnknown {
+		t.Fatalf("status before the first check = %s", h.Status)
+	}
+
+	failure := errors.New("connection refused")
+	// 各步骤依次记录到同一个 Provider
+	steps := []struct {
+		name     string
+		latency  time.Duration
+		err      error
+		status   string
+		failures int
+		p95      time.Duration
+	}{
+		{name: "first success", latency: 10 * ms, status: provider.StatusOnline, p95: 10 * ms},
+		{name: "first failure", err: failure, status: provider.StatusDegraded, failures: 1, p95: 10 * ms},
+		{name: "second failure", err: failure, status: provider.StatusDegraded, failures: 2, p95: 10 * ms},
+		{name: "offline", err: failure, status: provider.StatusOffline, failures: 3, p95: 10 * ms},
+		{name: "still offline", err: failure, status: provider.StatusOffline, failures: 4, p95: 10 * ms},
+		{name: "recovered", latency: 20 * ms, status: provider.StatusOnline, p95: 20 * ms},
+		// 单次慢响应即抬高 P95
+		{name: "slow", latency: 500 * ms, status: provider.StatusDegraded, p95: 500 * ms},
+		{name: "slow in window", latency: 10 * ms, status: provider.StatusDegraded, p95: 500 * ms},
+		{name: "still in window", latency: 10 * ms, status: provider.StatusDegraded, p95: 500 * ms},
+		// 窗口只保留最近 4 次成功的延迟，慢样本移出后恢复
+		{name: "evicts first sample", latency: 10 * ms, status: provider.StatusDegraded, p95: 500 * ms},
+		{name: "slow sample evicted", latency: 10 * ms, status: provider.StatusOnline, p95: 10 * ms},
+	}
+	for _, step := range steps {
+		m.Record(provider.TypeLLM, "a", step.latency, step.err)
+		h := m.Health(provider.TypeLLM, "a")
+		if h.Status != step.status || h.ConsecutiveFailures != step.failures || h.LatencyP95 != step.p95 {
+			t.Fatalf("%s: health = %+v, want %s with %d failures, p95 %v", step.name, h, step.status, step.failures, step.p95)
+		}
+		if h.LastError != failure.Error() && step.name != "first success" {
+			t.Errorf("%s: last error = %q", step.name, h.LastError)
+		}
+	}
+
+	if h := m.Health(provider.TypeASR, "a"); h.Status != provider.StatusUnknown {
+		t.Errorf("other provider type shares the state: %+v", h)
+	}
+}
+
+// checkedLLM 实现 HealthChecker 的 LLM
+type checkedLLM struct {
+	plainLLM
+	delay time.Duration
+	err   error
+}
+
+func (p checkedLLM) HealthCheck(ctx context.Context) error {
+	select {
+	case <-time.After(p.delay):
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestHealthMonitorCheckAll(t *testing.T) {
+	registry := provider.NewRegistry()
+	registry.RegisterLLM("healthy", checkedLLM{})
+	registry.RegisterLLM("failing", checkedLLM{err: errors.New("401 unauthorized")})
+	registry.RegisterLLM("hanging", checkedLLM{delay: time.Second})
+	registry.RegisterLLM("plain", plainLLM{})
+
+	m := provider.NewHealthMonitor(provider.HealthConfig{Timeout: 50 * time.Millisecond, OfflineAfter: 2})
+	m.CheckAll(context.Background(), registry)
+
+	cases := []struct {
+		name   string
+		status string
+		err    string
+	}{
+		{"healthy", provider.StatusOnline, ""},
+		{"failing", provider.StatusDegraded, "401 unauthorized"},
+		// 超时按失败计
+		{"hanging", provider.StatusDegraded, context.DeadlineExceeded.Error()},
+		// 未实现 HealthChecker 的 Provider 不检查
+		{"plain", provider.StatusUnknown, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := m.Health(provider.TypeLLM, tc.name)
+			if h.Status != tc.status || h.LastError != tc.err {
+				t.Errorf("health = %+v, want %s (%q)", h, tc.status, tc.err)
+			}
+		})
+	}
+
+	m.CheckAll(context.Background(), registry)
+	if h := m.Health(provider.TypeLLM, "failing"); h.Status != provider.StatusOffline {
+		t.Errorf("after two failed checks: %+v", h)
+	}
+}
+
+func TestRegistryReportsHealth(t *testing.T) {
+	registry := provider.NewRegistry()
+	registry.RegisterLLM("failing", checkedLLM{err: errors.New("401 unauthorized")})
+	registry.RegisterLLM("plain", plainLLM{})
+
+	registry.Health().CheckAll(context.Background(), registry)
+
+	var got []string
+	for _, info := range registry.GetProvidersByType(provider.TypeLLM) {
+		got = append(got, info.Name+":"+info.Status+":"+info.Health.LastError)
+	}
+	want := []string{"failing:degraded:401 unauthorized", "plain:unknown:"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("providers = %q, want %q", got, want)
+	}
+}
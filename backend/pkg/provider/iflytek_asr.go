@@ -8,19 +8,71 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+
 	"github.com/gorilla/websocket"
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
-// IflytekASRProvider 科大讯飞语音识别提供商 (WebSocket批量转写)
+// 音频帧状态
+const (
+	iflytekStatusFirst    = 0
+	iflytekStatusContinue = 1
+	iflytekStatusLast     = 2
+)
+
+const (
+	iflytekFrameSize      = 1280                  // 每帧音频字节数（16k 16bit 单声道 40ms）
+	iflytekFrameInterval  = 40 * time.Millisecond // 服务端要求按实时速率发送，每帧间隔 40ms
+	iflytekSessionTimeout = 60 * time.Second
+)
+
+func init() {
+	RegisterFactory(TypeASR, "iflytek", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		s := struct {
+			iflytekSettings
+			DynamicCorrection *bool `json:"dynamicCorrection"` // 默认开启
+		}{}
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+
+		p := NewIflytekASRProvider(s.AppID, s.APISecret, s.APIKey).
+			WithDynamicCorrection(s.DynamicCorrection == nil || *s.DynamicCorrection)
+		if s.BaseURL != "" {
+			p.WithBaseURL(s.BaseURL)
+		}
+		return p, nil
+	})
+}
+
+// iflytekSettings 科大讯飞 ASR/TTS 共用的鉴权配置
+type iflytekSettings struct {
+	AppID     string `json:"appId"`
+	APISecret string `json:"apiSecret"`
+	APIKey    string `json:"apiKey"`
+	BaseURL   string `json:"baseUrl"`
+}
+
+func (s iflytekSettings) validate() error {
+	return required(map[string]string{"appId": s.AppID, "apiSecret": s.APISecret, "apiKey": s.APIKey})
+}
+
+// IflytekASRProvider 科大讯飞语音识别提供商 (WebSocket流式听写)
 type IflytekASRProvider struct {
 	appID     string
 	apiSecret string
 	apiKey    string
 	baseURL   string
+	// 是否开启 dwa=wpgs 动态修正
+	dynamicCorrection bool
 }
 
 // NewIflytekASRProvider 创建科大讯飞ASR提供商
@@ -35,6 +87,18 @@ func NewIflytekASRProvider(appID, apiSecret, apiKey string) *IflytekASRProvider
 	}
 }
 
+// WithDynamicCorrection 设置是否开启动态修正（dwa=wpgs），开启后中间结果可能改写之前的文字
+func (p *IflytekASRProvider) WithDynamicCorrection(enabled bool) *IflytekASRProvider {
+	p.dynamicCorrection = enabled
+	return p
+}
+
+// WithBaseURL 设置语音听写 WebSocket 地址，鉴权签名按该地址的 host 与 path 计算
+func (p *IflytekASRProvider) WithBaseURL(baseURL string) *IflytekASRProvider {
+	p.baseURL = baseURL
+	return p
+}
+
 // iFlytek WebSocket 请求/响应结构
 type IflytekMessage struct {
 	Common   *IflytekCommon   `json:"common,omitempty"`
@@ -74,9 +138,11 @@ type IflytekResponseData struct {
 }
 
 type IflytekResultData struct {
-	Sn int                    `json:"sn"`
-	Ls bool                   `json:"ls"`
-	Ws []IflytekWordData     `json:"ws"`
+	Sn  int               `json:"sn"`
+	Ls  bool              `json:"ls"`
+	Ws  []IflytekWordData `json:"ws"`
+	Pgs string            `json:"pgs,omitempty"` // 动态修正：apd 追加，rpl 替换
+	Rg  []int             `json:"rg,omitempty"`  // rpl 时被替换的结果序号范围
 }
 
 type IflytekWordData struct {
@@ -88,121 +154,273 @@ type IflytekCharData struct {
 	W string `json:"w"`
 }
 
-// Recognize 实现ASRProvider接口的批量识别方法
+// Recognize 实现ASRProvider接口的批量识别方法，音频按帧切分后走流式会话
 func (p *IflytekASRProvider) Recognize(audioData []byte) (string, error) {
 	logx.Infof("iFlytek WebSocket ASR starting recognition, audio size: %d bytes", len(audioData))
-	
-	// 生成签名认证URL
-	authURL, err := p.generateAuthURL()
+
+	ctx, cancel := context.WithTimeout(context.Background(), iflytekSessionTimeout)
+	defer cancel()
+
+	conn, err := p.dial(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate auth URL: %v", err)
-	}
-	
-	logx.Infof("Connecting to iFlytek WebSocket: %s", authURL)
-	
-	// 建立WebSocket连接
-	dialer := websocket.Dialer{
-		HandshakeTimeout: 30 * time.Second,
+		return "", err
 	}
-	
-	conn, _, err := dialer.Dial(authURL, nil)
+
+	audioStream := make(chan []byte, 1)
+	go func() {
+		defer close(audioStream)
+		select {
+		case audioStream <- audioData:
+		case <-ctx.Done():
+		}
+	}()
+
+	var result string
+	err = p.session(ctx, conn, audioStream, func(t *Transcript) {
+		result = t.Text
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to connect to iFlytek WebSocket: %v", err)
-	}
-	defer conn.Close()
-	
-	logx.Infof("Successfully connected to iFlytek WebSocket")
-	
-	// 发送第一帧（带配置参数）
-	firstFrame := IflytekMessage{
-		Common: &IflytekCommon{
-			AppID: p.appID,
-		},
-		Business: &IflytekBusiness{
-			Language: "zh_cn",
-			Domain:   "iat",
-			Accent:   "mandarin",
-			VadEos:   10000, // 10秒后端点检测
-		},
-		Data: &IflytekData{
-			Status:   0, // 第一帧
-			Format:   "audio/L16;rate=16000",
-			Encoding: "raw",
-			Audio:    base64.StdEncoding.EncodeToString(audioData),
-		},
+		return "", err
 	}
-	
-	firstFrameData, err := json.Marshal(firstFrame)
+
+	logx.Infof("iFlytek ASR final result: %s", result)
+	return result, nil
+}
+
+// StreamRecognize 流式识别：音频块到达即发送，返回中间结果与最终结果
+//
+// 中间结果为当前整句的完整文本（开启动态修正时可能改写之前的文字），
+// 收到 ls=true 的结果时 IsFinal 为 true。audioStream 关闭后发送结束帧。
+func (p *IflytekASRProvider) StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *Transcript, error) {
+	conn, err := p.dial(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal first frame: %v", err)
+		return nil, err
 	}
-	
-	if err := conn.WriteMessage(websocket.TextMessage, firstFrameData); err != nil {
-		return "", fmt.Errorf("failed to send first frame: %v", err)
+
+	results := make(chan *Transcript, 16)
+	go func() {
+		defer close(results)
+
+		err := p.session(ctx, conn, audioStream, func(t *Transcript) {
+			select {
+			case results <- t:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			logx.Errorf("iFlytek stream recognition failed: %v", err)
+			select {
+			case results <- &Transcript{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// dial 建立带签名认证的 WebSocket 连接
+func (p *IflytekASRProvider) dial(ctx context.Context) (*websocket.Conn, error) {
+	authURL, err := p.generateAuthURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate auth URL: %v", err)
 	}
-	
-	logx.Infof("Sent first frame with audio data")
-	
-	// 发送结束帧
-	endFrame := IflytekMessage{
-		Data: &IflytekData{
-			Status: 2, // 最后一帧
-		},
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: 30 * time.Second,
 	}
-	
-	endFrameData, err := json.Marshal(endFrame)
+
+	conn, _, err := dialer.DialContext(ctx, authURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal end frame: %v", err)
-	}
-	
-	if err := conn.WriteMessage(websocket.TextMessage, endFrameData); err != nil {
-		return "", fmt.Errorf("failed to send end frame: %v", err)
+		return nil, fmt.Errorf("failed to connect to iFlytek WebSocket: %v", err)
 	}
-	
-	logx.Infof("Sent end frame")
-	
-	// 接收识别结果
-	var finalText strings.Builder
-	
+
+	logx.Infof("Successfully connected to iFlytek WebSocket")
+	return conn, nil
+}
+
+// session 在已建立的连接上完成一次识别会话，直到服务端返回最后一个结果
+func (p *IflytekASRProvider) session(ctx context.Context, conn *websocket.Conn, audioStream <-chan []byte, emit func(*Transcript)) error {
+	defer conn.Close()
+
+	// ctx 取消时关闭连接，结束阻塞的读取
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	go p.sendAudio(ctx, conn, audioStream)
+
+	sentence := newIflytekSentence()
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
-			logx.Errorf("Error reading message: %v", err)
-			break
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("failed to read iFlytek response: %v", err)
 		}
-		
-		logx.Infof("Raw iFlytek response: %s", string(message))
-		
+
 		var response IflytekResponse
 		if err := json.Unmarshal(message, &response); err != nil {
 			logx.Errorf("Failed to unmarshal response: %v", err)
 			continue
 		}
-		
+
 		if response.Code != 0 {
-			return "", fmt.Errorf("iFlytek ASR error: code=%d, message=%s", response.Code, response.Message)
+			return fmt.Errorf("iFlytek ASR error: code=%d, message=%s", response.Code, response.Message)
+		}
+
+		if response.Data == nil {
+			continue
+		}
+
+		if result := response.Data.Result; result != nil {
+			sentence.apply(result)
+			emit(&Transcript{
+				Text:    sentence.text(),
+				IsFinal: result.Ls,
+			})
+		}
+
+		// 最后一个结果
+		if response.Data.Status == 2 {
+			return nil
+		}
+	}
+}
+
+// sendAudio 按帧发送音频：首帧 status=0 携带业务参数，中间帧 status=1，音频流结束后发送 status=2。
+// 帧的发送不早于其音频在实时播放中的时间点：实时音频流本身按实时速率到达，
+// 批量识别与故障转移重放的整段音频则在此按 40ms 一帧限速，避免服务端因发送过快报错。
+func (p *IflytekASRProvider) sendAudio(ctx context.Context, conn *websocket.Conn, audioStream <-chan []byte) {
+	status := iflytekStatusFirst
+
+	start, frames := time.Now(), 0
+	pace := func() bool {
+		wait := time.Until(start.Add(time.Duration(frames) * iflytekFrameInterval))
+		frames++
+		if wait <= 0 {
+			return true
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	send := func(frameStatus int, audio []byte) error {
+		frame := IflytekMessage{
+			Data: &IflytekData{
+				Status:   frameStatus,
+				Format:   "audio/L16;rate=16000",
+				Encoding: "raw",
+				Audio:    base64.StdEncoding.EncodeToString(audio),
+			},
+		}
+		if status == iflytekStatusFirst {
+			frame.Common = &IflytekCommon{AppID: p.appID}
+			frame.Business = p.business()
+			status = iflytekStatusContinue
 		}
-		
-		if response.Data != nil && response.Data.Result != nil {
-			// 提取文字
-			for _, word := range response.Data.Result.Ws {
-				for _, char := range word.Cw {
-					finalText.WriteString(char.W)
+
+		data, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-audioStream:
+			if !ok {
+				if err := send(iflytekStatusLast, nil); err != nil {
+					logx.Errorf("Failed to send iFlytek end frame: %v", err)
 				}
+				return
 			}
-			
-			// 检查是否是最后一个结果
-			if response.Data.Status == 2 {
-				logx.Infof("Received final result, closing connection")
-				break
+
+			for len(chunk) > 0 {
+				if !pace() {
+					return
+				}
+				n := min(len(chunk), iflytekFrameSize)
+				if err := send(status, chunk[:n]); err != nil {
+					logx.Errorf("Failed to send iFlytek audio frame: %v", err)
+					return
+				}
+				chunk = chunk[n:]
 			}
 		}
 	}
-	
-	result := finalText.String()
-	logx.Infof("iFlytek ASR final result: %s", result)
-	
-	return result, nil
+}
+
+// business 首帧业务参数
+func (p *IflytekASRProvider) business() *IflytekBusiness {
+	business := &IflytekBusiness{
+		Language: "zh_cn",
+		Domain:   "iat",
+		Accent:   "mandarin",
+		VadEos:   10000, // 10秒后端点检测
+	}
+	if p.dynamicCorrection {
+		business.Dwa = "wpgs" // 开启动态修正
+	}
+	return business
+}
+
+// iflytekSentence 按结果序号 sn 拼接识别文本，支持 wpgs 动态修正
+type iflytekSentence struct {
+	segments map[int]string
+	order    []int
+}
+
+func newIflytekSentence() *iflytekSentence {
+	return &iflytekSentence{segments: make(map[int]string)}
+}
+
+// apply 合并一次识别结果：pgs=rpl 时先删除 rg 范围内被替换的结果
+func (s *iflytekSentence) apply(result *IflytekResultData) {
+	if result.Pgs == "rpl" && len(result.Rg) == 2 {
+		for sn := result.Rg[0]; sn <= result.Rg[1]; sn++ {
+			delete(s.segments, sn)
+		}
+	}
+
+	var sb strings.Builder
+	for _, word := range result.Ws {
+		for _, char := range word.Cw {
+			sb.WriteString(char.W)
+		}
+	}
+
+	if _, exists := s.segments[result.Sn]; !exists {
+		s.order = append(s.order, result.Sn)
+	}
+	s.segments[result.Sn] = sb.String()
+}
+
+// text 当前整句文本
+func (s *iflytekSentence) text() string {
+	sort.Ints(s.order)
+
+	var sb strings.Builder
+	kept := s.order[:0]
+	for _, sn := range s.order {
+		if segment, ok := s.segments[sn]; ok {
+			sb.WriteString(segment)
+			kept = append(kept, sn)
+		}
+	}
+	s.order = kept
+
+	return sb.String()
 }
 
 // generateAuthURL 生成带认证的WebSocket URL
@@ -252,7 +470,33 @@ func (p *IflytekASRProvider) Name() string {
 	return "iFlytek"
 }
 
-// StreamRecognize 实现流式识别接口（暂时不支持）
-func (p *IflytekASRProvider) StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *Transcript, error) {
-	return nil, fmt.Errorf("stream recognize not implemented for iFlytek batch ASR")
-}
\ No newline at end of file
+// InputFormats 讯飞听写只接受 audio/L16;rate=16000，即 16kHz 单声道 16bit PCM
+func (p *IflytekASRProvider) InputFormats() []audio.Format {
+	return []audio.Format{audio.PCM16kMono}
+}
+
+// Describe 实现 Describer 接口：WebSocket 流式听写，单次会话最长 60 秒音频
+func (p *IflytekASRProvider) Describe() Capabilities {
+	return Capabilities{
+		Streaming:      true,
+		Batch:          true,
+		AudioFormats:   []string{audio.PCM16kMono.String()},
+		SampleRates:    []int{16000},
+		MaxInputLength: int(iflytekSessionTimeout.Seconds()),
+		Languages:      []string{"zh_cn"},
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：完成鉴权握手后立即关闭连接，不发送音频
+func (p *IflytekASRProvider) HealthCheck(ctx context.Context) error {
+	authURL, err := p.generateAuthURL()
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, authURL, nil)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,124 @@
+package provider_test
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestIflytekASRStreamRecognize(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+	server.EnqueueASR(providertest.Transcript("今天天气怎么样", "今天", "今天天气"))
+
+	r := load(t, provider.Spec{
+		Name: "iflytek", Kind: provider.TypeASR, Type: "iflytek",
+		Settings: provider.Settings{"appId": "app", "apiSecret": "secret", "apiKey": "key", "baseUrl": server.ASRURL()},
+	})
+	asr, _ := r.GetASR("iflytek")
+
+	// 每块恰好一帧（1280 字节），每帧对应一个中间结果
+	pcm := bytes.Repeat([]byte{7}, 3*1280)
+	audio := make(chan []byte)
+	results, err := asr.StreamRecognize(testContext(t), audio)
+	if err != nil {
+		t.Fatalf("StreamRecognize: %v", err)
+	}
+	go func() {
+		defer close(audio)
+		for i := 0; i < len(pcm); i += 1280 {
+			audio <- pcm[i : i+1280]
+		}
+	}()
+
+	var (
+		partials []string
+		final    string
+	)
+	for transcript := range results {
+		if transcript.Err != nil {
+			t.Fatalf("stream failed: %v", transcript.Err)
+		}
+		if transcript.IsFinal {
+			final = transcript.Text
+		} else {
+			partials = append(partials, transcript.Text)
+		}
+	}
+
+	if !slices.Equal(partials, []string{"今天", "今天天气"}) || final != "今天天气怎么样" {
+		t.Errorf("partials = %q, final = %q", partials, final)
+	}
+	if received := server.ASRAudio(); len(received) != 1 || !bytes.Equal(received[0], pcm) {
+		t.Errorf("server did not receive the full audio")
+	}
+}
+
+func TestIflytekASRRecognize(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+	server.EnqueueASR(providertest.Transcript("讲个故事"))
+
+	asr := provider.NewIflytekASRProvider("app", "secret", "key").
+		WithBaseURL(server.ASRURL()).
+		WithDynamicCorrection(false)
+	text, err := asr.Recognize(bytes.Repeat([]byte{1}, 4000))
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if text != "讲个故事" {
+		t.Errorf("text = %q", text)
+	}
+}
+
+func TestIflytekASRPacesBufferedAudio(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+	server.EnqueueASR(providertest.Transcript("讲个故事"))
+
+	// 整段音频 10 帧，按 40ms 一帧发送至少需要 360ms
+	asr := provider.NewIflytekASRProvider("app", "secret", "key").WithBaseURL(server.ASRURL())
+	start := time.Now()
+	if _, err := asr.Recognize(bytes.Repeat([]byte{1}, 10*1280)); err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 360*time.Millisecond {
+		t.Errorf("10 frames sent in %v, want paced at 40ms per frame", elapsed)
+	}
+}
+
+func TestIflytekASRErrors(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+
+	t.Run("bad signature", func(t *testing.T) {
+		asr := provider.NewIflytekASRProvider("app", "wrong", "key").WithBaseURL(server.ASRURL())
+		if _, err := asr.Recognize([]byte{0, 0}); err == nil {
+			t.Error("Recognize succeeded with a wrong secret")
+		}
+		if err := asr.HealthCheck(testContext(t)); err == nil {
+			t.Error("HealthCheck succeeded with a wrong secret")
+		}
+	})
+
+	t.Run("wrong app id", func(t *testing.T) {
+		server.EnqueueASR(providertest.Transcript("不会返回"))
+		asr := provider.NewIflytekASRProvider("other", "secret", "key").WithBaseURL(server.ASRURL())
+		if _, err := asr.Recognize([]byte{0, 0}); err == nil {
+			t.Error("Recognize succeeded with a wrong app id")
+		}
+	})
+
+	t.Run("recognition error", func(t *testing.T) {
+		server.EnqueueASR(providertest.ASRResult{StreamErr: errors.New("engine busy")})
+		asr := provider.NewIflytekASRProvider("app", "secret", "key").WithBaseURL(server.ASRURL())
+		if _, err := asr.Recognize([]byte{0, 0}); err == nil {
+			t.Error("Recognize succeeded on a server error")
+		}
+	})
+}
@@ -13,6 +13,24 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+func init() {
+	RegisterFactory(TypeTTS, "iflytek", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		var s iflytekSettings
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+
+		p := NewIflytekTTSProvider(s.AppID, s.APISecret, s.APIKey)
+		if s.BaseURL != "" {
+			p.WithBaseURL(s.BaseURL)
+		}
+		return p, nil
+	})
+}
+
 // 科大讯飞 TTS Provider 实现
 type IflytekTTSProvider struct {
 	appID     string
@@ -30,10 +48,43 @@ func NewIflytekTTSProvider(appID, apiSecret, apiKey string) *IflytekTTSProvider
 	}
 }
 
+// WithBaseURL 设置语音合成 WebSocket 地址，鉴权签名按该地址的 host 与 path 计算
+func (p *IflytekTTSProvider) WithBaseURL(baseURL string) *IflytekTTSProvider {
+	p.baseURL = baseURL
+	return p
+}
+
 func (p *IflytekTTSProvider) Name() string {
 	return "iflytek-tts"
 }
 
+// Describe 实现 Describer 接口：返回 16k 裸 PCM，单次文本不超过 8000 字节（约 2000 字）
+func (p *IflytekTTSProvider) Describe() Capabilities {
+	return Capabilities{
+		Streaming:      true,
+		AudioFormats:   []string{"pcm"},
+		SampleRates:    []int{16000},
+		Voices:         []string{"xiaoyan", "aisjiuxu", "aisxping", "aisjinger", "aisbabyxu"},
+		DefaultVoice:   "xiaoyan",
+		MaxInputLength: 2000,
+		Languages:      []string{"zh", "en"},
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：完成鉴权握手后立即关闭连接，不发送文本
+func (p *IflytekTTSProvider) HealthCheck(ctx context.Context) error {
+	authURL, err := p.generateTTSAuthURL()
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, authURL, nil)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
 // 科大讯飞 TTS 请求参数
 type iflytekTTSParams struct {
 	Common   iflytekCommonTTS   `json:"common"`
@@ -0,0 +1,52 @@
+package provider_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestIflytekTTSSynthesizeStream(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+
+	r := load(t, provider.Spec{
+		Name: "iflytek", Kind: provider.TypeTTS, Type: "iflytek",
+		Settings: provider.Settings{"appId": "app", "apiSecret": "secret", "apiKey": "key", "baseUrl": server.TTSURL()},
+	})
+	tts, _ := r.GetTTS("iflytek")
+
+	stream, err := tts.SynthesizeStream(testContext(t), texts("你好。", "很高兴认识你。"), &provider.TTSOptions{Voice: "aisjinger"})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+
+	audio, err := collectAudio(stream)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if want := providertest.AudioFor("你好。很高兴认识你。"); !bytes.Equal(audio, want) {
+		t.Errorf("audio = %q, want %q", audio, want)
+	}
+
+	calls := server.TTSCalls()
+	if len(calls) != 1 || !slices.Equal(calls[0].Texts, []string{"你好。", "很高兴认识你。"}) || calls[0].Options.Voice != "aisjinger" {
+		t.Errorf("calls = %+v", calls)
+	}
+}
+
+func TestIflytekTTSBadSignature(t *testing.T) {
+	server := providertest.NewIflytekServer("app", "secret", "key")
+	defer server.Close()
+
+	tts := provider.NewIflytekTTSProvider("app", "wrong", "key").WithBaseURL(server.TTSURL())
+	if _, err := tts.SynthesizeStream(testContext(t), texts("你好。"), nil); err == nil {
+		t.Error("SynthesizeStream connected with a wrong secret")
+	}
+	if err := tts.HealthCheck(testContext(t)); err == nil {
+		t.Error("HealthCheck succeeded with a wrong secret")
+	}
+}
@@ -0,0 +1,366 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+func init() {
+	RegisterFactory(TypeLLM, "openai_compatible", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		cfg, err := decodeOpenAICompatible(name, settings, OpenAICompatibleConfig{})
+		if err != nil {
+			return nil, err
+		}
+		if err := required(map[string]string{"baseUrl": cfg.BaseURL, "defaultModel": cfg.DefaultModel}); err != nil {
+			return nil, err
+		}
+		return NewOpenAICompatibleProvider(cfg), nil
+	})
+}
+
+// OpenAICompatibleConfig OpenAI 兼容 LLM 服务的配置，DeepSeek、vLLM、Ollama、OpenAI 等均可通过配置接入
+type OpenAICompatibleConfig struct {
+	Name         string            // Provider 名称
+	BaseURL      string            // 接口地址，如 https://api.openai.com/v1
+	APIKey       string            // 为空时不发送 Authorization，适用于本地服务
+	DefaultModel string            // 请求未指定模型时使用
+	Models       []string          // 允许请求的模型，为空时不限制
+	Headers      map[string]string // 附加请求头
+	Timeout      time.Duration     // 请求超时，默认 60s
+}
+
+// openAICompatibleSettings 配置文件中的 settings
+type openAICompatibleSettings struct {
+	BaseURL      string            `json:"baseUrl"`
+	APIKey       string            `json:"apiKey"`
+	APIKeyEnv    string            `json:"apiKeyEnv"` // 从该环境变量读取 API Key，优先于 apiKey
+	DefaultModel string            `json:"defaultModel"`
+	Models       []string          `json:"models"`
+	Headers      map[string]string `json:"headers"`
+	Timeout      Duration          `json:"timeout"`
+}
+
+// decodeOpenAICompatible 解析 settings，未设置的字段使用 defaults
+func decodeOpenAICompatible(name string, settings Settings, defaults OpenAICompatibleConfig) (OpenAICompatibleConfig, error) {
+	var s openAICompatibleSettings
+	if err := settings.Decode(&s); err != nil {
+		return defaults, err
+	}
+
+	cfg := defaults
+	cfg.Name = name
+	if s.BaseURL != "" {
+		cfg.BaseURL = s.BaseURL
+	}
+	if s.APIKey != "" {
+		cfg.APIKey = s.APIKey
+	}
+	if s.APIKeyEnv != "" {
+		if cfg.APIKey = os.Getenv(s.APIKeyEnv); cfg.APIKey == "" {
+			return cfg, fmt.Errorf("environment variable %s is not set", s.APIKeyEnv)
+		}
+	}
+	if s.DefaultModel != "" {
+		cfg.DefaultModel = s.DefaultModel
+	}
+	if len(s.Models) > 0 {
+		cfg.Models = s.Models
+	}
+	if len(s.Headers) > 0 {
+		cfg.Headers = s.Headers
+	}
+	if s.Timeout > 0 {
+		cfg.Timeout = time.Duration(s.Timeout)
+	}
+	return cfg, nil
+}
+
+// OpenAICompatibleProvider 通用的 OpenAI Chat Completions 客户端
+type OpenAICompatibleProvider struct {
+	cfg    OpenAICompatibleConfig
+	client *http.Client
+}
+
+func NewOpenAICompatibleProvider(cfg OpenAICompatibleConfig) *OpenAICompatibleProvider {
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 60 * time.Second
+	}
+
+	return &OpenAICompatibleProvider{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+	}
+}
+
+func (p *OpenAICompatibleProvider) Name() string {
+	return p.cfg.Name
+}
+
+// OpenAI 兼容请求结构
+type openAIChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	TopP        *float64  `json:"top_p,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stop        []string  `json:"stop,omitempty"`
+	Seed        *int      `json:"seed,omitempty"`
+	Stream      bool      `json:"stream"`
+	// 流式响应默认不返回用量，需显式请求
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// OpenAI 兼容响应结构
+type openAIChatResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+type openAIChoice struct {
+	Index        int      `json:"index"`
+	Message      *Message `json:"message,omitempty"`
+	Delta        *Message `json:"delta,omitempty"`
+	FinishReason string   `json:"finish_reason,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *openAIUsage) toUsage(model string) *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		Model:            model,
+	}
+}
+
+// Describe 实现 Describer 接口：默认模型排在第一位
+func (p *OpenAICompatibleProvider) Describe() Capabilities {
+	var models []string
+	if p.cfg.DefaultModel != "" {
+		models = append(models, p.cfg.DefaultModel)
+	}
+	for _, model := range p.cfg.Models {
+		models = appendUniqueString(models, model)
+	}
+
+	return Capabilities{
+		Streaming: true,
+		Batch:     true,
+		Models:    models,
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：列出模型，不产生计费调用
+func (p *OpenAICompatibleProvider) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.cfg.BaseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	p.setHeaders(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("health check returned status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (p *OpenAICompatibleProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	resp, err := p.send(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(chatResp.Choices) == 0 || chatResp.Choices[0].Message == nil {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	choice := chatResp.Choices[0]
+	return &ChatResponse{
+		Text:         choice.Message.Content,
+		FinishReason: choice.FinishReason,
+		Usage:        chatResp.Usage.toUsage(chatResp.Model),
+	}, nil
+}
+
+func (p *OpenAICompatibleProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatDelta, error) {
+	resp, err := p.send(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	deltaStream := make(chan *ChatDelta, 100)
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(deltaStream)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			// 跳过空行和注释行
+			if line == "" || strings.HasPrefix(line, ":") {
+				continue
+			}
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				return
+			}
+
+			var streamResp openAIChatResponse
+			if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+				logx.Errorf("%s: failed to parse stream data: %v", p.cfg.Name, err)
+				continue
+			}
+
+			// 部分服务在最后单独发送不带 choices 的 usage
+			delta := &ChatDelta{Usage: streamResp.Usage.toUsage(streamResp.Model)}
+			if len(streamResp.Choices) > 0 {
+				choice := streamResp.Choices[0]
+				if choice.Delta != nil {
+					delta.Text = choice.Delta.Content
+				}
+				delta.FinishReason = choice.FinishReason
+			} else if delta.Usage == nil {
+				continue
+			}
+
+			select {
+			case deltaStream <- delta:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			logx.Errorf("%s: stream reading error: %v", p.cfg.Name, err)
+			deltaStream <- &ChatDelta{Err: fmt.Errorf("stream interrupted: %w", err)}
+		}
+	}()
+
+	return deltaStream, nil
+}
+
+// model 校验请求的模型，未指定时使用默认模型
+func (p *OpenAICompatibleProvider) model(requested string) (string, error) {
+	if requested == "" {
+		if p.cfg.DefaultModel == "" {
+			return "", fmt.Errorf("%s: no model specified and no default model configured", p.cfg.Name)
+		}
+		return p.cfg.DefaultModel, nil
+	}
+
+	if len(p.cfg.Models) > 0 && requested != p.cfg.DefaultModel && !containsString(p.cfg.Models, requested) {
+		return "", fmt.Errorf("%s: model '%s' is not allowed, available: %s", p.cfg.Name, requested, strings.Join(p.Describe().Models, ", "))
+	}
+	return requested, nil
+}
+
+// send 发送 Chat Completions 请求，非 200 响应返回错误
+func (p *OpenAICompatibleProvider) send(ctx context.Context, req *ChatRequest, stream bool) (*http.Response, error) {
+	model, err := p.model(req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		messages = append(messages, *msg)
+	}
+
+	chatReq := openAIChatRequest{
+		Model:       model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Seed:        req.Seed,
+		Stream:      stream,
+	}
+	if stream {
+		chatReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+
+	reqBody, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.cfg.BaseURL+"/chat/completions", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	p.setHeaders(httpReq)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+func (p *OpenAICompatibleProvider) setHeaders(req *http.Request) {
+	if p.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+	for key, value := range p.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+}
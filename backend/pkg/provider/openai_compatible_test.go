@@ -0,0 +1,286 @@
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// openAIServer 记录请求的 Chat Completions 服务，非流式请求回复 reply，流式请求逐块回复
+type openAIServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	bodies   []map[string]interface{}
+	headers  []http.Header
+	reply    string
+	chunks   []string
+	usageSSE string
+}
+
+func newOpenAIServer(t *testing.T) *openAIServer {
+	s := &openAIServer{reply: "你好"}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *openAIServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v1/models" {
+		s.mu.Lock()
+		s.headers = append(s.headers, r.Header.Clone())
+		s.mu.Unlock()
+		w.Write([]byte(`{"data": []}`))
+		return
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	var body map[string]interface{}
+	json.Unmarshal(data, &body)
+	s.mu.Lock()
+	s.bodies = append(s.bodies, body)
+	s.headers = append(s.headers, r.Header.Clone())
+	s.mu.Unlock()
+
+	if body["stream"] != true {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"message": map[string]string{"role": "assistant", "content": s.reply}, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 3, "completion_tokens": 2, "total_tokens": 5},
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, chunk := range s.chunks {
+		delta, _ := json.Marshal(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{"delta": map[string]string{"content": chunk}}},
+		})
+		w.Write([]byte(": keep-alive\n\ndata: " + string(delta) + "\n\n"))
+	}
+	if s.usageSSE != "" {
+		w.Write([]byte("data: " + s.usageSSE + "\n\n"))
+	}
+	w.Write([]byte("data: [DONE]\n\n"))
+}
+
+func (s *openAIServer) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func (s *openAIServer) lastBody() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies[len(s.bodies)-1]
+}
+
+func (s *openAIServer) lastHeader() http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers[len(s.headers)-1]
+}
+
+func TestOpenAICompatibleDescribe(t *testing.T) {
+	cases := []struct {
+		name         string
+		defaultModel string
+		models       []string
+		want         []string
+	}{
+		{"default only", "deepseek-chat", nil, []string{"deepseek-chat"}},
+		// 默认模型排在第一位，重复的模型只出现一次
+		{"default listed", "deepseek-chat", []string{"deepseek-reasoner", "deepseek-chat", "deepseek-reasoner"}, []string{"deepseek-chat", "deepseek-reasoner"}},
+		{"no default", "", []string{"llama3", "qwen2"}, []string{"llama3", "qwen2"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := provider.NewOpenAICompatibleProvider(provider.OpenAICompatibleConfig{Name: "deepseek", DefaultModel: tc.defaultModel, Models: tc.models})
+			caps := provider.Describe(p)
+			if !reflect.DeepEqual(caps.Models, tc.want) || !caps.Streaming || !caps.Batch {
+				t.Errorf("Describe = %+v, want models %v", caps, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleModels(t *testing.T) {
+	server := newOpenAIServer(t)
+
+	cases := []struct {
+		name         string
+		defaultModel string
+		models       []string
+		requested    string
+		want         string // 发送的模型，空表示拒绝
+		err          string
+	}{
+		{name: "default", defaultModel: "deepseek-chat", want: "deepseek-chat"},
+		{name: "no allowlist", defaultModel: "deepseek-chat", requested: "anything", want: "anything"},
+		{name: "allowed", defaultModel: "deepseek-chat", models: []string{"deepseek-reasoner"}, requested: "deepseek-reasoner", want: "deepseek-reasoner"},
+		// 默认模型不在列表中也允许
+		{name: "default not listed", defaultModel: "deepseek-chat", models: []string{"deepseek-reasoner"}, requested: "deepseek-chat", want: "deepseek-chat"},
+		{name: "not allowed", defaultModel: "deepseek-chat", models: []string{"deepseek-reasoner"}, requested: "gpt-4o", err: "available: deepseek-chat, deepseek-reasoner"},
+		{name: "no default", requested: "", err: "no default model configured"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := provider.NewOpenAICompatibleProvider(provider.OpenAICompatibleConfig{
+				Name:         "deepseek",
+				BaseURL:      server.URL + "/v1/",
+				DefaultModel: tc.defaultModel,
+				Models:       tc.models,
+			})
+			sent := server.requests()
+			_, err := p.Chat(context.Background(), &provider.ChatRequest{Model: tc.requested, Messages: []*provider.Message{{Role: "user", Content: "你好"}}})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("err = %v, want %q", err, tc.err)
+				}
+				if server.requests() != sent {
+					t.Error("rejected request was sent")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := server.lastBody()["model"]; got != tc.want {
+				t.Errorf("model = %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleHeaders(t *testing.T) {
+	server := newOpenAIServer(t)
+
+	cases := []struct {
+		name          string
+		apiKey        string
+		headers       map[string]string
+		authorization string
+	}{
+		{name: "api key", apiKey: "sk-test", authorization: "Bearer sk-test"},
+		// 本地服务不需要鉴权
+		{name: "no api key"},
+		{name: "extra headers", apiKey: "sk-test", headers: map[string]string{"X-Org": "costalk", "HTTP-Referer": "https://costalk.example"}, authorization: "Bearer sk-test"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := provider.NewOpenAICompatibleProvider(provider.OpenAICompatibleConfig{
+				Name:         "local",
+				BaseURL:      server.URL + "/v1",
+				APIKey:       tc.apiKey,
+				DefaultModel: "llama3",
+				Headers:      tc.headers,
+			})
+
+			// 对话与健康检查使用相同的请求头
+			for _, call := range []func() error{
+				func() error {
+					_, err := p.Chat(context.Background(), &provider.ChatRequest{Messages: []*provider.Message{{Role: "user", Content: "你好"}}})
+					return err
+				},
+				func() error { return p.HealthCheck(context.Background()) },
+			} {
+				if err := call(); err != nil {
+					t.Fatal(err)
+				}
+				header := server.lastHeader()
+				if got := header.Get("Authorization"); got != tc.authorization {
+					t.Errorf("Authorization = %q, want %q", got, tc.authorization)
+				}
+				for key, value := range tc.headers {
+					if got := header.Get(key); got != value {
+						t.Errorf("%s = %q, want %q", key, got, value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAICompatibleChat(t *testing.T) {
+	server := newOpenAIServer(t)
+	server.chunks = []string{"你", "好", "！"}
+	server.usageSSE = `{"choices": [], "usage": {"prompt_tokens": 3, "completion_tokens": 3, "total_tokens": 6}}`
+	p := provider.NewOpenAICompatibleProvider(provider.OpenAICompatibleConfig{Name: "local", BaseURL: server.URL + "/v1", DefaultModel: "llama3"})
+	req := &provider.ChatRequest{Messages: []*provider.Message{{Role: "system", Content: "你是哈利"}, {Role: "user", Content: "你好"}}}
+
+	resp, err := p.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "你好" || resp.FinishReason != "stop" || resp.Usage == nil || resp.Usage.TotalTokens != 5 {
+		t.Errorf("response = %+v", resp)
+	}
+	messages, _ := json.Marshal(server.lastBody()["messages"])
+	if string(messages) != `[{"content":"你是哈利","role":"system"},{"content":"你好","role":"user"}]` {
+		t.Errorf("messages = %s", messages)
+	}
+
+	// 不带 choices 的最后一块只携带用量
+	stream, err := p.ChatStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		text  string
+		usage *provider.Usage
+	)
+	for delta := range stream {
+		text += delta.Text
+		if delta.Usage != nil {
+			usage = delta.Usage
+		}
+	}
+	if text != "你好！" || usage == nil || usage.TotalTokens != 6 {
+		t.Errorf("stream = %q, usage = %+v", text, usage)
+	}
+	if server.lastBody()["stream"] != true {
+		t.Errorf("stream request = %v", server.lastBody())
+	}
+}
+
+func TestOpenAICompatibleSampling(t *testing.T) {
+	server := newOpenAIServer(t)
+	p := provider.NewOpenAICompatibleProvider(provider.OpenAICompatibleConfig{Name: "local", BaseURL: server.URL + "/v1", DefaultModel: "llama3"})
+	temperature, topP, seed := 0.0, 0.9, 42
+
+	cases := []struct {
+		name string
+		req  *provider.ChatRequest
+		want map[string]interface{} // 请求体中的采样参数，nil 表示不发送
+	}{
+		{"unset", &provider.ChatRequest{}, map[string]interface{}{"temperature": nil, "top_p": nil, "seed": nil, "stop": nil, "max_tokens": nil}},
+		{
+			// 温度为 0 也要发送
+			name: "set",
+			req:  &provider.ChatRequest{Temperature: &temperature, TopP: &topP, Seed: &seed, Stop: []string{"。"}, MaxTokens: 100},
+			want: map[string]interface{}{"temperature": 0.0, "top_p": 0.9, "seed": 42.0, "stop": []interface{}{"。"}, "max_tokens": 100.0},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.Messages = []*provider.Message{{Role: "user", Content: "你好"}}
+			if _, err := p.Chat(context.Background(), tc.req); err != nil {
+				t.Fatal(err)
+			}
+			body := server.lastBody()
+			for key, want := range tc.want {
+				if got, ok := body[key]; (want == nil && ok) || (want != nil && !reflect.DeepEqual(got, want)) {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
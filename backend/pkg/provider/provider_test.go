@@ -0,0 +1,72 @@
+package provider_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// load 按声明式配置创建 Provider，任一失败即终止测试
+func load(t *testing.T, specs ...provider.Spec) *provider.Registry {
+	t.Helper()
+
+	r := provider.NewRegistry()
+	if err := r.Load(specs); err != nil {
+		t.Fatalf("load providers: %v", err)
+	}
+	return r
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// collectChat 读完输出流，返回拼接的文本、最后一次用量与流中途的错误
+func collectChat(stream <-chan *provider.ChatDelta) (string, *provider.Usage, error) {
+	var (
+		text  string
+		usage *provider.Usage
+	)
+	for delta := range stream {
+		if delta.Err != nil {
+			return text, usage, delta.Err
+		}
+		text += delta.Text
+		if delta.Usage != nil {
+			usage = delta.Usage
+		}
+	}
+	return text, usage, nil
+}
+
+// collectAudio 读完音频流，返回拼接的音频与第一个合成错误
+func collectAudio(stream <-chan *provider.AudioChunk) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	for chunk := range stream {
+		if chunk.Err != nil && err == nil {
+			err = chunk.Err
+		}
+		data = append(data, chunk.Data...)
+	}
+	return data, err
+}
+
+func texts(values ...string) <-chan string {
+	ch := make(chan string, len(values))
+	for _, v := range values {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
+
+func userMessage(text string) []*provider.Message {
+	return []*provider.Message{{Role: "user", Content: text}}
+}
@@ -0,0 +1,175 @@
+package providertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// ASRResult 一次识别的预设结果
+type ASRResult struct {
+	Partials  []string // 流式识别时每收到一块音频下发一个中间结果（整句文本）
+	Final     string   // 音频结束后下发的最终结果
+	Err       error    // 调用直接失败
+	StreamErr error    // 音频结束后以失败代替最终结果
+
+	Hold <-chan struct{} // 流式识别在该通道关闭后才开始读取音频，模拟处理缓慢的 ASR
+}
+
+// Transcript 只有最终结果的识别
+func Transcript(final string, partials ...string) ASRResult {
+	return ASRResult{Partials: partials, Final: final}
+}
+
+// ASR 按顺序返回预设结果的 ASRProvider，并记录每次识别收到的音频
+type ASR struct {
+	name    string
+	formats []audio.Format
+
+	mu      sync.Mutex
+	results []ASRResult
+	audio   [][]byte
+}
+
+func NewASR(name string, results ...ASRResult) *ASR {
+	return &ASR{name: name, formats: []audio.Format{audio.PCM16kMono}, results: results}
+}
+
+// WithInputFormats 设置接受的输入格式，默认 16kHz 单声道 16bit PCM
+func (f *ASR) WithInputFormats(formats ...audio.Format) *ASR {
+	f.formats = formats
+	return f
+}
+
+// Enqueue 追加预设结果
+func (f *ASR) Enqueue(results ...ASRResult) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.results = append(f.results, results...)
+}
+
+// Audio 每次识别收到的全部音频
+func (f *ASR) Audio() [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([][]byte(nil), f.audio...)
+}
+
+func (f *ASR) Name() string {
+	return f.name
+}
+
+func (f *ASR) InputFormats() []audio.Format {
+	return f.formats
+}
+
+// Describe 实现 provider.Describer 接口
+func (f *ASR) Describe() provider.Capabilities {
+	caps := provider.Capabilities{Streaming: true, Batch: true}
+	for _, format := range f.formats {
+		caps.AudioFormats = append(caps.AudioFormats, format.String())
+		caps.SampleRates = append(caps.SampleRates, format.SampleRate)
+	}
+	return caps
+}
+
+func (f *ASR) Recognize(audioData []byte) (string, error) {
+	result, index, err := f.next()
+	if err != nil {
+		return "", err
+	}
+	f.record(index, audioData)
+
+	if result.Err != nil {
+		return "", result.Err
+	}
+	if result.StreamErr != nil {
+		return "", result.StreamErr
+	}
+	return result.Final, nil
+}
+
+func (f *ASR) StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *provider.Transcript, error) {
+	result, index, err := f.next()
+	if err != nil {
+		return nil, err
+	}
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	transcripts := make(chan *provider.Transcript, len(result.Partials)+1)
+	go func() {
+		defer close(transcripts)
+
+		send := func(t *provider.Transcript) bool {
+			select {
+			case transcripts <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if result.Hold != nil {
+			select {
+			case <-result.Hold:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		partials := result.Partials
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case chunk, ok := <-audioStream:
+				if !ok {
+					if result.StreamErr != nil {
+						send(&provider.Transcript{Err: result.StreamErr})
+					} else if result.Final != "" {
+						send(&provider.Transcript{Text: result.Final, IsFinal: true, Confidence: 1})
+					}
+					return
+				}
+
+				f.record(index, chunk)
+				if len(partials) > 0 {
+					if !send(&provider.Transcript{Text: partials[0]}) {
+						return
+					}
+					partials = partials[1:]
+				}
+			}
+		}
+	}()
+
+	return transcripts, nil
+}
+
+// next 取出下一个预设结果，返回该次识别的序号
+func (f *ASR) next() (ASRResult, int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.results) == 0 {
+		return ASRResult{}, 0, ErrNoReply
+	}
+
+	result := f.results[0]
+	f.results = f.results[1:]
+	f.audio = append(f.audio, nil)
+	return result, len(f.audio) - 1, nil
+}
+
+func (f *ASR) record(index int, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.audio[index] = append(f.audio[index], data...)
+}
@@ -0,0 +1,160 @@
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+const dashScopeGenerationPath = "/api/v1/services/aigc/text-generation/generation"
+
+// DashScopeServer 模拟阿里云 DashScope 文本生成接口（通义千问原生协议）的本地服务：
+//   - 文本生成：JSON 响应，或 X-DashScope-SSE: enable 时以 SSE 逐块下发
+//   - 兼容模式的模型列表 /compatible-mode/v1/models（健康检查）
+//
+// 回复按 Enqueue 预设的顺序返回，所有接口校验 Authorization: Bearer <apiKey>。
+type DashScopeServer struct {
+	*httptest.Server
+	apiKey string
+
+	replies  script[LLMReply]
+	requests recorder[[]byte]
+}
+
+func NewDashScopeServer(apiKey string) *DashScopeServer {
+	s := &DashScopeServer{apiKey: apiKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+dashScopeGenerationPath, s.handleGeneration)
+	mux.HandleFunc("GET /compatible-mode/v1/models", s.handleModels)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// BaseURL 文本生成接口地址，对应 qwen settings 中的 baseUrl
+func (s *DashScopeServer) BaseURL() string {
+	return s.URL + dashScopeGenerationPath
+}
+
+// Enqueue 追加预设回复，Err 以 500 响应返回，StreamErr 在输出文本块后断开连接
+func (s *DashScopeServer) Enqueue(replies ...LLMReply) {
+	s.replies.enqueue(replies...)
+}
+
+// Requests 收到的文本生成请求体
+func (s *DashScopeServer) Requests() [][]byte {
+	return s.requests.all()
+}
+
+func (s *DashScopeServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"object": "list",
+		"data":   []map[string]string{{"id": "qwen-turbo", "object": "model"}},
+	})
+}
+
+func (s *DashScopeServer) handleGeneration(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.requests.add(body)
+
+	var req struct {
+		Model      string `json:"model"`
+		Parameters struct {
+			IncrementalOutput bool `json:"incremental_output"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reply, ok := s.replies.next()
+	if !ok {
+		reply.Err = ErrNoReply
+	}
+
+	requestID := fmt.Sprintf("req-%d", time.Now().UnixNano())
+	if reply.Err != nil || (reply.StreamErr != nil && r.Header.Get("X-DashScope-SSE") != "enable") {
+		err := reply.Err
+		if err == nil {
+			err = reply.StreamErr
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"code":       "InternalError",
+			"message":    err.Error(),
+			"request_id": requestID,
+		})
+		return
+	}
+
+	if r.Header.Get("X-DashScope-SSE") != "enable" {
+		writeJSON(w, dashScopeResponse(requestID, reply.Text(), reply.finishReason(), reply))
+		return
+	}
+
+	// 增量输出时每个事件只包含新增文本，否则为截至当前的全部文本；用量随最后一个事件下发
+	events := newSSE(w)
+	seq := 0
+	send := func(resp map[string]interface{}) {
+		seq++
+		events.event(fmt.Sprintf("id:%d", seq), "event:result", ":HTTP_STATUS/200", "data:"+payload(resp))
+	}
+
+	text := ""
+	for _, chunk := range reply.Chunks {
+		select {
+		case <-time.After(reply.Delay):
+		case <-r.Context().Done():
+			return
+		}
+
+		text += chunk
+		output := text
+		if req.Parameters.IncrementalOutput {
+			output = chunk
+		}
+		send(dashScopeResponse(requestID, output, "null", LLMReply{}))
+	}
+
+	if reply.StreamErr != nil {
+		events.abort()
+	}
+
+	final := text
+	if req.Parameters.IncrementalOutput {
+		final = ""
+	}
+	send(dashScopeResponse(requestID, final, reply.finishReason(), reply))
+}
+
+func dashScopeResponse(requestID, text, finishReason string, reply LLMReply) map[string]interface{} {
+	resp := map[string]interface{}{
+		"output":     map[string]string{"text": text, "finish_reason": finishReason},
+		"request_id": requestID,
+	}
+	if reply.Usage != nil {
+		resp["usage"] = map[string]int{
+			"input_tokens":  reply.Usage.PromptTokens,
+			"output_tokens": reply.Usage.CompletionTokens,
+			"total_tokens":  reply.Usage.TotalTokens,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,283 @@
+package providertest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 讯飞帧状态
+const (
+	iflytekStatusFirst = 0
+	iflytekStatusLast  = 2
+)
+
+// iflytekTTSChunkSize 合成每个音频包的字节数
+const iflytekTTSChunkSize = 8
+
+var iflytekSignature = regexp.MustCompile(`api_key="([^"]*)".*signature="([^"]*)"`)
+
+// IflytekServer 模拟科大讯飞 WebSocket 接口的本地服务：
+//   - 语音听写 /v2/iat：按帧接收音频，开启动态修正（dwa=wpgs）时每帧音频下发一个中间结果，
+//     收到结束帧后下发最终结果
+//   - 语音合成 /v2/tts：每个文本帧合成 AudioFor(text) 并分包返回，收到结束帧后下发 status=2
+//
+// 握手时按 HMAC-SHA256 校验 URL 中的签名，首帧校验 app_id。
+type IflytekServer struct {
+	*httptest.Server
+	appID     string
+	apiKey    string
+	apiSecret string
+
+	asrResults script[ASRResult]
+	asrAudio   recorder[[]byte]
+	ttsCalls   recorder[TTSCall]
+}
+
+// iflytekFrame 客户端上行帧（听写与合成共用）
+type iflytekFrame struct {
+	Common *struct {
+		AppID string `json:"app_id"`
+	} `json:"common"`
+	Business *struct {
+		Dwa string `json:"dwa"`
+		Vcn string `json:"vcn"`
+	} `json:"business"`
+	Data *struct {
+		Status int    `json:"status"`
+		Audio  string `json:"audio"`
+		Text   string `json:"text"`
+	} `json:"data"`
+}
+
+func NewIflytekServer(appID, apiSecret, apiKey string) *IflytekServer {
+	s := &IflytekServer{appID: appID, apiKey: apiKey, apiSecret: apiSecret}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v2/iat", s.handleIAT)
+	mux.HandleFunc("GET /v2/tts", s.handleTTS)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// ASRURL 语音听写地址，对应 ASR settings 中的 baseUrl
+func (s *IflytekServer) ASRURL() string {
+	return wsURL(s.URL, "/v2/iat")
+}
+
+// TTSURL 语音合成地址，对应 TTS settings 中的 baseUrl
+func (s *IflytekServer) TTSURL() string {
+	return wsURL(s.URL, "/v2/tts")
+}
+
+// EnqueueASR 追加听写的预设结果，Err 在首帧后、StreamErr 在结束帧后以错误码返回
+func (s *IflytekServer) EnqueueASR(results ...ASRResult) {
+	s.asrResults.enqueue(results...)
+}
+
+// ASRAudio 每次听写收到的音频（已解码）
+func (s *IflytekServer) ASRAudio() [][]byte {
+	return s.asrAudio.all()
+}
+
+// TTSCalls 每次合成收到的文本，Options.Voice 为请求的发音人
+func (s *IflytekServer) TTSCalls() []TTSCall {
+	return s.ttsCalls.all()
+}
+
+// authorize 校验鉴权参数：签名原文为 host、date 与请求行
+func (s *IflytekServer) authorize(w http.ResponseWriter, r *http.Request) bool {
+	query := r.URL.Query()
+	authorization, err := base64.StdEncoding.DecodeString(query.Get("authorization"))
+	if err != nil {
+		http.Error(w, "invalid authorization", http.StatusUnauthorized)
+		return false
+	}
+
+	match := iflytekSignature.FindStringSubmatch(string(authorization))
+	if match == nil || match[1] != s.apiKey {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return false
+	}
+
+	origin := fmt.Sprintf("host: %s\ndate: %s\nGET %s HTTP/1.1", query.Get("host"), query.Get("date"), r.URL.Path)
+	h := hmac.New(sha256.New, []byte(s.apiSecret))
+	h.Write([]byte(origin))
+	if match[2] != base64.StdEncoding.EncodeToString(h.Sum(nil)) {
+		http.Error(w, "signature mismatch", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (s *IflytekServer) handleIAT(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sid := fmt.Sprintf("iat%d", time.Now().UnixNano())
+	result, ok := s.asrResults.next()
+	if !ok {
+		result.Err = ErrNoReply
+	}
+
+	var (
+		audio    []byte
+		dwa      string
+		partials = result.Partials
+		sn       = 0
+	)
+	send := func(status int, text string, last bool) error {
+		sn++
+		res := map[string]interface{}{
+			"sn": sn,
+			"ls": last,
+			"ws": []map[string]interface{}{{"bg": 0, "cw": []map[string]string{{"w": text}}}},
+		}
+		if dwa == "wpgs" {
+			// 整句文本替换之前的全部结果
+			if sn > 1 {
+				res["pgs"] = "rpl"
+				res["rg"] = []int{1, sn - 1}
+			} else {
+				res["pgs"] = "apd"
+			}
+		}
+		return conn.WriteJSON(map[string]interface{}{
+			"code":    0,
+			"message": "success",
+			"sid":     sid,
+			"data":    map[string]interface{}{"status": status, "result": res},
+		})
+	}
+	fail := func(code int, err error) {
+		conn.WriteJSON(map[string]interface{}{"code": code, "message": err.Error(), "sid": sid})
+	}
+
+	for {
+		var frame iflytekFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		if frame.Data == nil {
+			fail(10163, fmt.Errorf("missing data"))
+			return
+		}
+
+		if frame.Data.Status == iflytekStatusFirst || frame.Common != nil {
+			if frame.Common == nil || frame.Common.AppID != s.appID {
+				fail(10313, fmt.Errorf("invalid appid"))
+				return
+			}
+			if frame.Business != nil {
+				dwa = frame.Business.Dwa
+			}
+			if result.Err != nil {
+				fail(10800, result.Err)
+				return
+			}
+		}
+
+		data, err := base64.StdEncoding.DecodeString(frame.Data.Audio)
+		if err != nil {
+			fail(10163, err)
+			return
+		}
+		audio = append(audio, data...)
+
+		if frame.Data.Status == iflytekStatusLast {
+			s.asrAudio.add(audio)
+			if result.StreamErr != nil {
+				fail(10800, result.StreamErr)
+				return
+			}
+			send(2, result.Final, true)
+			return
+		}
+
+		if len(data) > 0 && len(partials) > 0 && dwa == "wpgs" {
+			if err := send(1, partials[0], false); err != nil {
+				return
+			}
+			partials = partials[1:]
+		}
+	}
+}
+
+func (s *IflytekServer) handleTTS(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	sid := fmt.Sprintf("tts%d", time.Now().UnixNano())
+	send := func(status int, audio []byte) error {
+		return conn.WriteJSON(map[string]interface{}{
+			"code":    0,
+			"message": "success",
+			"sid":     sid,
+			"data": map[string]interface{}{
+				"audio":  base64.StdEncoding.EncodeToString(audio),
+				"status": status,
+				"ced":    fmt.Sprint(len(audio)),
+			},
+		})
+	}
+
+	call := TTSCall{Options: &provider.TTSOptions{}}
+	for {
+		var frame iflytekFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		if frame.Common == nil || frame.Common.AppID != s.appID || frame.Data == nil {
+			conn.WriteJSON(map[string]interface{}{"code": 10313, "message": "invalid appid", "sid": sid})
+			return
+		}
+		if frame.Business != nil {
+			call.Options.Voice = frame.Business.Vcn
+		}
+
+		if frame.Data.Text != "" {
+			text, err := base64.StdEncoding.DecodeString(frame.Data.Text)
+			if err != nil {
+				conn.WriteJSON(map[string]interface{}{"code": 10163, "message": err.Error(), "sid": sid})
+				return
+			}
+			call.Texts = append(call.Texts, string(text))
+
+			for data := AudioFor(string(text)); len(data) > 0; {
+				n := min(len(data), iflytekTTSChunkSize)
+				if err := send(1, data[:n]); err != nil {
+					return
+				}
+				data = data[n:]
+			}
+		}
+
+		if frame.Data.Status == iflytekStatusLast {
+			s.ttsCalls.add(call)
+			send(2, nil)
+			return
+		}
+	}
+}
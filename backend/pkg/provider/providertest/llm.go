@@ -0,0 +1,192 @@
+// Package providertest 提供离线测试用的 Provider 替身：
+// 可编排输出的 LLM/ASR/TTS 假实现，以及模拟七牛云、DashScope、科大讯飞协议的本地服务，
+// 真实的 Provider 实现可通过 WithBaseURL 或 settings 中的 baseUrl 指向这些服务，无需访问网络。
+package providertest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// ErrNoReply 预设的回复已用完
+var ErrNoReply = errors.New("providertest: no scripted reply")
+
+// LLMReply 一次 LLM 调用的预设回复
+type LLMReply struct {
+	Chunks       []string        // 流式输出的文本块，非流式调用时拼接为完整回复
+	FinishReason string          // 默认 stop
+	Usage        *provider.Usage // 随最后一个文本块下发
+	Delay        time.Duration   // 每个文本块之前的等待时间
+	Err          error           // 调用直接失败
+	StreamErr    error           // 输出全部文本块后流中途失败
+}
+
+// Text 完整回复文本
+func (r LLMReply) Text() string {
+	return strings.Join(r.Chunks, "")
+}
+
+func (r LLMReply) finishReason() string {
+	if r.FinishReason == "" {
+		return "stop"
+	}
+	return r.FinishReason
+}
+
+// Reply 按句子或词切好的文本块组成的回复
+func Reply(chunks ...string) LLMReply {
+	return LLMReply{Chunks: chunks}
+}
+
+// LLM 按顺序返回预设回复的 LLMProvider，并记录收到的请求
+type LLM struct {
+	name   string
+	models []string
+
+	mu       sync.Mutex
+	replies  []LLMReply
+	requests []*provider.ChatRequest
+}
+
+func NewLLM(name string, replies ...LLMReply) *LLM {
+	return &LLM{name: name, replies: replies}
+}
+
+// WithModels 设置 Describe 声明的模型列表，第一个为默认模型
+func (f *LLM) WithModels(models ...string) *LLM {
+	f.models = models
+	return f
+}
+
+// Enqueue 追加预设回复
+func (f *LLM) Enqueue(replies ...LLMReply) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.replies = append(f.replies, replies...)
+}
+
+// Requests 已收到的请求
+func (f *LLM) Requests() []*provider.ChatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]*provider.ChatRequest(nil), f.requests...)
+}
+
+func (f *LLM) Name() string {
+	return f.name
+}
+
+// Describe 实现 provider.Describer 接口
+func (f *LLM) Describe() provider.Capabilities {
+	return provider.Capabilities{
+		Streaming: true,
+		Batch:     true,
+		Models:    f.models,
+	}
+}
+
+func (f *LLM) Chat(ctx context.Context, req *provider.ChatRequest) (*provider.ChatResponse, error) {
+	reply, err := f.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+	if reply.StreamErr != nil {
+		return nil, reply.StreamErr
+	}
+
+	return &provider.ChatResponse{
+		Text:         reply.Text(),
+		FinishReason: reply.finishReason(),
+		Usage:        reply.Usage,
+	}, nil
+}
+
+func (f *LLM) ChatStream(ctx context.Context, req *provider.ChatRequest) (<-chan *provider.ChatDelta, error) {
+	reply, err := f.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Err != nil {
+		return nil, reply.Err
+	}
+
+	deltas := make(chan *provider.ChatDelta)
+	go func() {
+		defer close(deltas)
+
+		send := func(delta *provider.ChatDelta) bool {
+			select {
+			case deltas <- delta:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for i, chunk := range reply.Chunks {
+			if !sleep(ctx, reply.Delay) {
+				return
+			}
+
+			delta := &provider.ChatDelta{Text: chunk}
+			if i == len(reply.Chunks)-1 {
+				delta.Usage = reply.Usage
+				if reply.StreamErr == nil {
+					delta.FinishReason = reply.finishReason()
+				}
+			}
+			if !send(delta) {
+				return
+			}
+		}
+
+		if reply.StreamErr != nil {
+			send(&provider.ChatDelta{Err: reply.StreamErr})
+		} else if len(reply.Chunks) == 0 {
+			send(&provider.ChatDelta{FinishReason: reply.finishReason(), Usage: reply.Usage})
+		}
+	}()
+
+	return deltas, nil
+}
+
+func (f *LLM) next(req *provider.ChatRequest) (LLMReply, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, req)
+	if len(f.replies) == 0 {
+		return LLMReply{}, ErrNoReply
+	}
+
+	reply := f.replies[0]
+	f.replies = f.replies[1:]
+	return reply, nil
+}
+
+// sleep 等待 d，ctx 取消时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
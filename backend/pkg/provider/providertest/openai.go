@@ -0,0 +1,102 @@
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// serveOpenAIChat 按 OpenAI Chat Completions 协议返回预设回复：
+// stream=true 时以 SSE 逐块下发，stream_options.include_usage 时在 [DONE] 前单独下发用量
+func serveOpenAIChat(w http.ResponseWriter, r *http.Request, body []byte, reply LLMReply) {
+	var req struct {
+		Model         string `json:"model"`
+		Stream        bool   `json:"stream"`
+		StreamOptions *struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if reply.Err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":{"message":%q}}`, reply.Err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	id := fmt.Sprintf("chatcmpl-%d", time.Now().UnixNano())
+	usage := openAIUsage(reply)
+
+	if !req.Stream {
+		if reply.StreamErr != nil {
+			http.Error(w, fmt.Sprintf(`{"error":{"message":%q}}`, reply.StreamErr.Error()), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"id":     id,
+			"object": "chat.completion",
+			"model":  req.Model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": reply.Text()},
+				"finish_reason": reply.finishReason(),
+			}},
+			"usage": usage,
+		})
+		return
+	}
+
+	chunk := func(delta map[string]string, finishReason interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"id":     id,
+			"object": "chat.completion.chunk",
+			"model":  req.Model,
+			"choices": []map[string]interface{}{{
+				"index":         0,
+				"delta":         delta,
+				"finish_reason": finishReason,
+			}},
+		}
+	}
+
+	events := newSSE(w)
+	events.data(chunk(map[string]string{"role": "assistant", "content": ""}, nil))
+	for _, text := range reply.Chunks {
+		select {
+		case <-time.After(reply.Delay):
+		case <-r.Context().Done():
+			return
+		}
+		events.data(chunk(map[string]string{"content": text}, nil))
+	}
+
+	if reply.StreamErr != nil {
+		events.abort()
+	}
+
+	events.data(chunk(map[string]string{}, reply.finishReason()))
+	if usage != nil && req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		events.data(map[string]interface{}{
+			"id":      id,
+			"object":  "chat.completion.chunk",
+			"model":   req.Model,
+			"choices": []interface{}{},
+			"usage":   usage,
+		})
+	}
+	events.data("[DONE]")
+}
+
+func openAIUsage(reply LLMReply) map[string]int {
+	if reply.Usage == nil {
+		return nil
+	}
+	return map[string]int{
+		"prompt_tokens":     reply.Usage.PromptTokens,
+		"completion_tokens": reply.Usage.CompletionTokens,
+		"total_tokens":      reply.Usage.TotalTokens,
+	}
+}
@@ -0,0 +1,256 @@
+package providertest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// QiniuServer 模拟七牛云 AI 接口的本地服务：
+//   - OpenAI 兼容的 /v1/chat/completions（JSON 与 SSE 流式）与 /v1/models
+//   - 语音识别 /v1/voice/asr
+//   - 语音合成：音色列表 /v1/voice/list、HTTP /v1/voice/tts 与 WebSocket /v1/voice/tts
+//
+// LLM 回复与识别结果按 EnqueueChat / EnqueueASR 预设的顺序返回，合成音频为 AudioFor(text)。
+// 所有接口校验 Authorization: Bearer <apiKey>。
+type QiniuServer struct {
+	*httptest.Server
+	apiKey string
+
+	chatReplies  script[LLMReply]
+	asrResults   script[ASRResult]
+	chatRequests recorder[[]byte]
+	asrAudio     recorder[[]byte]
+	ttsRequests  recorder[QiniuTTSRequest]
+}
+
+// QiniuTTSRequest 语音合成请求（HTTP 与 WebSocket 相同）
+type QiniuTTSRequest struct {
+	Audio struct {
+		VoiceType  string  `json:"voice_type"`
+		Encoding   string  `json:"encoding"`
+		SpeedRatio float64 `json:"speed_ratio"`
+	} `json:"audio"`
+	Request struct {
+		Text string `json:"text"`
+	} `json:"request"`
+}
+
+// QiniuVoices 音色列表接口返回的音色
+var QiniuVoices = []string{"qiniu_zh_female_wwxkjx", "qiniu_zh_male_ljfdxz"}
+
+// qiniuTTSChunkSize WebSocket 合成每个音频包的字节数
+const qiniuTTSChunkSize = 8
+
+func NewQiniuServer(apiKey string) *QiniuServer {
+	s := &QiniuServer{apiKey: apiKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChat)
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	mux.HandleFunc("POST /v1/voice/asr", s.handleASR)
+	mux.HandleFunc("GET /v1/voice/list", s.handleVoices)
+	mux.HandleFunc("POST /v1/voice/tts", s.handleTTS)
+	mux.HandleFunc("GET /v1/voice/tts", s.handleTTSStream)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// BaseURL LLM、ASR 与 TTS HTTP 接口地址，对应 settings 中的 baseUrl
+func (s *QiniuServer) BaseURL() string {
+	return s.URL + "/v1"
+}
+
+// WSURL 流式语音合成地址，对应 TTS settings 中的 wsUrl
+func (s *QiniuServer) WSURL() string {
+	return wsURL(s.URL, "/v1/voice/tts")
+}
+
+// EnqueueChat 追加 /chat/completions 的预设回复，Err 以 500 响应返回，StreamErr 在输出文本块后断开连接
+func (s *QiniuServer) EnqueueChat(replies ...LLMReply) {
+	s.chatReplies.enqueue(replies...)
+}
+
+// EnqueueASR 追加 /voice/asr 的预设结果，只使用 Final 与 Err
+func (s *QiniuServer) EnqueueASR(results ...ASRResult) {
+	s.asrResults.enqueue(results...)
+}
+
+// ChatRequests 收到的 /chat/completions 请求体
+func (s *QiniuServer) ChatRequests() [][]byte {
+	return s.chatRequests.all()
+}
+
+// ASRAudio 每次识别收到的音频（已解码）
+func (s *QiniuServer) ASRAudio() [][]byte {
+	return s.asrAudio.all()
+}
+
+// TTSRequests 收到的语音合成请求（HTTP 与 WebSocket）
+func (s *QiniuServer) TTSRequests() []QiniuTTSRequest {
+	return s.ttsRequests.all()
+}
+
+func (s *QiniuServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"object": "list",
+		"data":   []map[string]string{{"id": "deepseek-v3", "object": "model"}},
+	})
+}
+
+func (s *QiniuServer) handleChat(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.chatRequests.add(body)
+
+	reply, ok := s.chatReplies.next()
+	if !ok {
+		reply.Err = ErrNoReply
+	}
+	serveOpenAIChat(w, r, body, reply)
+}
+
+func (s *QiniuServer) handleASR(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+		Audio struct {
+			Format string `json:"format"`
+			Data   string `json:"data"`
+		} `json:"audio"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	audioData, err := base64.StdEncoding.DecodeString(req.Audio.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.asrAudio.add(audioData)
+
+	result, ok := s.asrResults.next()
+	if !ok {
+		result.Err = ErrNoReply
+	}
+	if result.Err != nil {
+		http.Error(w, result.Err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"reqid":     fmt.Sprintf("asr-%d", time.Now().UnixNano()),
+		"operation": "query",
+		"data": map[string]interface{}{
+			"audio_info": map[string]int{"duration": len(audioData) / 32}, // 16k 16bit 单声道每毫秒 32 字节
+			"result":     map[string]interface{}{"text": result.Final},
+		},
+	})
+}
+
+func (s *QiniuServer) handleVoices(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	voices := make([]map[string]interface{}, 0, len(QiniuVoices))
+	for _, voice := range QiniuVoices {
+		voices = append(voices, map[string]interface{}{
+			"voice_name": voice,
+			"voice_type": voice,
+			"category":   "test",
+		})
+	}
+	writeJSON(w, voices)
+}
+
+func (s *QiniuServer) handleTTS(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	var req QiniuTTSRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.ttsRequests.add(req)
+
+	writeJSON(w, qiniuTTSResponse(1, AudioFor(req.Request.Text)))
+}
+
+// handleTTSStream WebSocket 合成：客户端以二进制消息发送一次请求，服务端分包返回音频，
+// 最后一包的 sequence 为负数
+func (s *QiniuServer) handleTTSStream(w http.ResponseWriter, r *http.Request) {
+	if !authorized(w, r, s.apiKey) {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+
+	var req QiniuTTSRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseUnsupportedData, err.Error()))
+		return
+	}
+	s.ttsRequests.add(req)
+
+	data := AudioFor(req.Request.Text)
+	for seq := 1; ; seq++ {
+		n := min(len(data), qiniuTTSChunkSize)
+		last := n == len(data)
+
+		sequence := seq
+		if last {
+			sequence = -seq
+		}
+		if err := conn.WriteJSON(qiniuTTSResponse(sequence, data[:n])); err != nil {
+			return
+		}
+
+		data = data[n:]
+		if last {
+			return
+		}
+	}
+}
+
+func qiniuTTSResponse(sequence int, audio []byte) map[string]interface{} {
+	return map[string]interface{}{
+		"reqid":     fmt.Sprintf("tts-%d", time.Now().UnixNano()),
+		"operation": "query",
+		"sequence":  sequence,
+		"data":      base64.StdEncoding.EncodeToString(audio),
+		"addition":  map[string]string{"duration": fmt.Sprint(len(audio))},
+	}
+}
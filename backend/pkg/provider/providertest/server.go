@@ -0,0 +1,124 @@
+package providertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// script 按顺序取出的预设回复
+type script[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+func (s *script[T]) enqueue(items ...T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, items...)
+}
+
+func (s *script[T]) next() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var item T
+	if len(s.items) == 0 {
+		return item, false
+	}
+	item = s.items[0]
+	s.items = s.items[1:]
+	return item, true
+}
+
+// recorder 并发安全的请求记录
+type recorder[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+func (l *recorder[T]) add(item T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.items = append(l.items, item)
+}
+
+func (l *recorder[T]) all() []T {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]T(nil), l.items...)
+}
+
+// wsURL 将 httptest 服务地址转换为 WebSocket 地址
+func wsURL(serverURL, path string) string {
+	return "ws" + strings.TrimPrefix(serverURL, "http") + path
+}
+
+// authorized 校验 Bearer Token，失败时返回 401
+func authorized(w http.ResponseWriter, r *http.Request, apiKey string) bool {
+	if r.Header.Get("Authorization") != "Bearer "+apiKey {
+		http.Error(w, `{"error":{"message":"invalid api key"}}`, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// sse 逐条写出 Server-Sent Events
+type sse struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSE(w http.ResponseWriter) *sse {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	return &sse{w: w, flusher: flusher}
+}
+
+// data 写出一条只有 data 字段的事件，v 为字符串时原样写出
+func (s *sse) data(v interface{}) {
+	s.event("data: " + payload(v))
+}
+
+// event 写出由若干行组成的一条事件
+func (s *sse) event(lines ...string) {
+	for _, line := range lines {
+		fmt.Fprintf(s.w, "%s\n", line)
+	}
+	fmt.Fprint(s.w, "\n")
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func payload(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// abort 中断连接，客户端读取到不完整的流
+func (s *sse) abort() {
+	panic(http.ErrAbortHandler)
+}
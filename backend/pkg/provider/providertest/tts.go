@@ -0,0 +1,157 @@
+package providertest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// AudioFor 替身 TTS 为 text 合成的“音频”：即文本的 UTF-8 字节，便于断言音频与文本的对应关系
+func AudioFor(text string) []byte {
+	return []byte(text)
+}
+
+// TTSCall 一次合成请求
+type TTSCall struct {
+	Texts   []string
+	Options *provider.TTSOptions
+}
+
+// TTS 将文本按 AudioFor 合成为分块音频的 TTSProvider，并记录收到的文本
+type TTS struct {
+	name      string
+	format    string
+	chunkSize int
+	delay     time.Duration
+	err       error
+	fail      func(text string) error
+
+	mu    sync.Mutex
+	calls []*TTSCall
+}
+
+func NewTTS(name string) *TTS {
+	return &TTS{name: name, format: "pcm", chunkSize: 4}
+}
+
+// WithFormat 设置音频块的格式，默认 pcm
+func (f *TTS) WithFormat(format string) *TTS {
+	f.format = format
+	return f
+}
+
+// WithChunkSize 设置每个音频块的字节数，默认 4
+func (f *TTS) WithChunkSize(size int) *TTS {
+	f.chunkSize = size
+	return f
+}
+
+// WithDelay 设置每个音频块之前的等待时间
+func (f *TTS) WithDelay(delay time.Duration) *TTS {
+	f.delay = delay
+	return f
+}
+
+// WithError 合成调用直接失败
+func (f *TTS) WithError(err error) *TTS {
+	f.err = err
+	return f
+}
+
+// FailOn fail 返回错误的文本以失败块代替音频
+func (f *TTS) FailOn(fail func(text string) error) *TTS {
+	f.fail = fail
+	return f
+}
+
+// Calls 已收到的合成请求
+func (f *TTS) Calls() []TTSCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	calls := make([]TTSCall, len(f.calls))
+	for i, call := range f.calls {
+		calls[i] = TTSCall{Texts: append([]string(nil), call.Texts...), Options: call.Options}
+	}
+	return calls
+}
+
+func (f *TTS) Name() string {
+	return f.name
+}
+
+// Describe 实现 provider.Describer 接口
+func (f *TTS) Describe() provider.Capabilities {
+	return provider.Capabilities{
+		Streaming:    true,
+		AudioFormats: []string{f.format},
+		Voices:       []string{"fake"},
+		DefaultVoice: "fake",
+	}
+}
+
+func (f *TTS) SynthesizeStream(ctx context.Context, textStream <-chan string, opts *provider.TTSOptions) (<-chan *provider.AudioChunk, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	call := &TTSCall{Options: opts}
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	chunks := make(chan *provider.AudioChunk)
+	go func() {
+		defer close(chunks)
+
+		send := func(chunk *provider.AudioChunk) bool {
+			select {
+			case chunks <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		seq := 0
+		for {
+			var text string
+			select {
+			case <-ctx.Done():
+				return
+			case t, ok := <-textStream:
+				if !ok {
+					return
+				}
+				text = t
+			}
+
+			f.mu.Lock()
+			call.Texts = append(call.Texts, text)
+			f.mu.Unlock()
+
+			if f.fail != nil {
+				if err := f.fail(text); err != nil {
+					if !send(&provider.AudioChunk{Err: err}) {
+						return
+					}
+					continue
+				}
+			}
+
+			data := AudioFor(text)
+			for len(data) > 0 {
+				n := min(len(data), max(f.chunkSize, 1))
+				if !sleep(ctx, f.delay) || !send(&provider.AudioChunk{Data: data[:n], Format: f.format, SeqNum: seq}) {
+					return
+				}
+				data = data[n:]
+				seq++
+			}
+		}
+	}()
+
+	return chunks, nil
+}
@@ -10,9 +10,32 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
+
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+func init() {
+	RegisterFactory(TypeASR, "qiniu", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		var s struct {
+			APIKey  string `json:"apiKey"`
+			BaseURL string `json:"baseUrl"`
+		}
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if err := required(map[string]string{"apiKey": s.APIKey}); err != nil {
+			return nil, err
+		}
+
+		p := NewQiniuASRProvider(s.APIKey)
+		if s.BaseURL != "" {
+			p.WithBaseURL(s.BaseURL)
+		}
+		return p, nil
+	})
+}
+
 type QiniuASRProvider struct {
 	apiKey     string
 	baseURL    string
@@ -61,6 +84,12 @@ func NewQiniuASRProvider(apiKey string) *QiniuASRProvider {
 	}
 }
 
+// WithBaseURL 设置接口地址（不含 /voice/asr），可指向本地替身服务
+func (p *QiniuASRProvider) WithBaseURL(baseURL string) *QiniuASRProvider {
+	p.baseURL = baseURL
+	return p
+}
+
 func (p *QiniuASRProvider) Name() string {
 	return "qiniu"
 }
@@ -87,6 +116,7 @@ func (p *QiniuASRProvider) StreamRecognize(ctx context.Context, audioStream <-ch
 		text, err := p.recognizeHTTP(audioData)
 		if err != nil {
 			logx.Errorf("HTTP ASR recognition failed: %v", err)
+			resultChan <- &Transcript{Err: err}
 			return
 		}
 		
@@ -162,6 +192,27 @@ func (p *QiniuASRProvider) recognizeHTTP(audioData []byte) (string, error) {
 	return asrResponse.Data.Result.Text, nil
 }
 
+// InputFormats 请求中声明 format=pcm，即 16kHz 单声道 16bit PCM
+func (p *QiniuASRProvider) InputFormats() []audio.Format {
+	return []audio.Format{audio.PCM16kMono}
+}
+
+// Describe 实现 Describer 接口：HTTP 接口只能整段识别，StreamRecognize 缓冲全部音频后才返回结果
+func (p *QiniuASRProvider) Describe() Capabilities {
+	return Capabilities{
+		Streaming:    false,
+		Batch:        true,
+		AudioFormats: []string{audio.PCM16kMono.String()},
+		SampleRates:  []int{16000},
+		Languages:    []string{"zh", "en"},
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：与 LLM 共用鉴权，列出模型即可验证连通性
+func (p *QiniuASRProvider) HealthCheck(ctx context.Context) error {
+	return checkHTTP(ctx, p.httpClient, p.baseURL+"/models", p.apiKey)
+}
+
 // Recognize 实现ASRProvider接口的批量识别方法
 func (p *QiniuASRProvider) Recognize(audioData []byte) (string, error) {
 	return p.recognizeHTTP(audioData)
@@ -0,0 +1,78 @@
+package provider_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestQiniuASR(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+	server.EnqueueASR(providertest.Transcript("今天天气怎么样"), providertest.Transcript("讲个故事"))
+
+	r := load(t, provider.Spec{
+		Name: "qiniu", Kind: provider.TypeASR, Type: "qiniu",
+		Settings: provider.Settings{"apiKey": "sk-test", "baseUrl": server.BaseURL()},
+	})
+	asr, _ := r.GetASR("qiniu")
+
+	pcm := bytes.Repeat([]byte{1, 2}, 1600)
+	text, err := asr.Recognize(pcm)
+	if err != nil {
+		t.Fatalf("Recognize: %v", err)
+	}
+	if text != "今天天气怎么样" {
+		t.Errorf("text = %q", text)
+	}
+
+	// 流式接口缓冲全部音频后整段识别
+	audio := make(chan []byte, 2)
+	audio <- pcm[:1600]
+	audio <- pcm[1600:]
+	close(audio)
+
+	results, err := asr.StreamRecognize(testContext(t), audio)
+	if err != nil {
+		t.Fatalf("StreamRecognize: %v", err)
+	}
+	var finals []string
+	for transcript := range results {
+		if transcript.Err != nil {
+			t.Fatalf("stream failed: %v", transcript.Err)
+		}
+		if transcript.IsFinal {
+			finals = append(finals, transcript.Text)
+		}
+	}
+	if len(finals) != 1 || finals[0] != "讲个故事" {
+		t.Errorf("finals = %q", finals)
+	}
+
+	received := server.ASRAudio()
+	if len(received) != 2 || !bytes.Equal(received[0], pcm) || !bytes.Equal(received[1], pcm) {
+		t.Errorf("server received %d recordings, want the full audio twice", len(received))
+	}
+}
+
+func TestQiniuASRErrors(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+	server.EnqueueASR(providertest.ASRResult{Err: errors.New("audio too short")})
+
+	asr := provider.NewQiniuASRProvider("sk-test").WithBaseURL(server.BaseURL())
+	if _, err := asr.Recognize([]byte{0, 0}); err == nil {
+		t.Error("Recognize succeeded on a server error")
+	}
+
+	unauthorized := provider.NewQiniuASRProvider("sk-wrong").WithBaseURL(server.BaseURL())
+	if _, err := unauthorized.Recognize([]byte{0, 0}); err == nil {
+		t.Error("Recognize succeeded with a wrong key")
+	}
+	if err := unauthorized.HealthCheck(testContext(t)); err == nil {
+		t.Error("HealthCheck succeeded with a wrong key")
+	}
+}
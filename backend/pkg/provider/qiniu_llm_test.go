@@ -0,0 +1,153 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestQiniuLLMChatStream(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+	server.EnqueueChat(providertest.LLMReply{
+		Chunks: []string{"你好，", "我是哈利。"},
+		Usage:  &provider.Usage{PromptTokens: 12, CompletionTokens: 6, TotalTokens: 18},
+	})
+
+	r := load(t, provider.Spec{
+		Name: "qiniu", Kind: provider.TypeLLM, Type: "qiniu",
+		Settings: provider.Settings{"apiKey": "sk-test", "baseUrl": server.BaseURL()},
+	})
+	llm, _ := r.GetLLM("qiniu")
+
+	temperature := 0.3
+	stream, err := llm.ChatStream(testContext(t), &provider.ChatRequest{
+		Messages:    userMessage("你是谁？"),
+		Temperature: &temperature,
+		Stop:        []string{"用户："},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	text, usage, err := collectChat(stream)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if text != "你好，我是哈利。" {
+		t.Errorf("text = %q", text)
+	}
+	if usage == nil || usage.TotalTokens != 18 || usage.Model != "deepseek-v3" {
+		t.Errorf("usage = %+v, want 18 tokens of deepseek-v3", usage)
+	}
+
+	requests := server.ChatRequests()
+	if len(requests) != 1 {
+		t.Fatalf("got %d requests", len(requests))
+	}
+	var req struct {
+		Model         string   `json:"model"`
+		Stream        bool     `json:"stream"`
+		Temperature   *float64 `json:"temperature"`
+		TopP          *float64 `json:"top_p"`
+		Stop          []string `json:"stop"`
+		StreamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	if err := json.Unmarshal(requests[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "deepseek-v3" || !req.Stream || !req.StreamOptions.IncludeUsage {
+		t.Errorf("request = %s", requests[0])
+	}
+	if req.Temperature == nil || *req.Temperature != 0.3 || req.TopP != nil {
+		t.Errorf("sampling parameters not passed through: %s", requests[0])
+	}
+	if len(req.Stop) != 1 || req.Stop[0] != "用户：" {
+		t.Errorf("stop = %v", req.Stop)
+	}
+}
+
+func TestQiniuLLMChat(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+	server.EnqueueChat(providertest.LLMReply{
+		Chunks: []string{"魔法", "无处不在。"},
+		Usage:  &provider.Usage{PromptTokens: 5, CompletionTokens: 4, TotalTokens: 9},
+	})
+
+	llm := provider.NewQiniuLLMProvider("sk-test").WithBaseURL(server.BaseURL() + "/")
+	resp, err := llm.Chat(testContext(t), &provider.ChatRequest{Messages: userMessage("说点什么")})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Text != "魔法无处不在。" || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestQiniuLLMErrors(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+
+	t.Run("unauthorized", func(t *testing.T) {
+		llm := provider.NewQiniuLLMProvider("sk-wrong").WithBaseURL(server.BaseURL())
+		if _, err := llm.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")}); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("err = %v, want 401", err)
+		}
+	})
+
+	t.Run("interrupted stream", func(t *testing.T) {
+		server.EnqueueChat(providertest.LLMReply{
+			Chunks:    []string{"说到一半"},
+			StreamErr: errors.New("connection reset"),
+		})
+
+		llm := provider.NewQiniuLLMProvider("sk-test").WithBaseURL(server.BaseURL())
+		stream, err := llm.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")})
+		if err != nil {
+			t.Fatalf("ChatStream: %v", err)
+		}
+		text, _, err := collectChat(stream)
+		if text != "说到一半" || err == nil {
+			t.Errorf("text = %q, err = %v; want partial text and an error", text, err)
+		}
+	})
+
+	t.Run("model not allowed", func(t *testing.T) {
+		r := load(t, provider.Spec{
+			Name: "qiniu", Kind: provider.TypeLLM, Type: "qiniu",
+			Settings: provider.Settings{"apiKey": "sk-test", "baseUrl": server.BaseURL(), "models": []string{"deepseek-r1"}},
+		})
+		llm, _ := r.GetLLM("qiniu")
+		if err := provider.CheckModel(llm, "gpt-4o"); err == nil {
+			t.Error("CheckModel accepted an undeclared model")
+		}
+		if _, err := llm.Chat(testContext(t), &provider.ChatRequest{Model: "gpt-4o", Messages: userMessage("hi")}); err == nil {
+			t.Error("Chat accepted an undeclared model")
+		}
+	})
+}
+
+func TestQiniuLLMHealthCheck(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+
+	ok := provider.NewQiniuLLMProvider("sk-test").WithBaseURL(server.BaseURL())
+	if err := ok.HealthCheck(testContext(t)); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+
+	bad := provider.NewQiniuLLMProvider("sk-wrong").WithBaseURL(server.BaseURL())
+	if err := bad.HealthCheck(testContext(t)); err == nil {
+		t.Error("HealthCheck succeeded with a wrong key")
+	}
+}
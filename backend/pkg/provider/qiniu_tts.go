@@ -14,6 +14,31 @@ import (
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+func init() {
+	RegisterFactory(TypeTTS, "qiniu", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		var s struct {
+			APIKey  string `json:"apiKey"`
+			BaseURL string `json:"baseUrl"`
+			WSURL   string `json:"wsUrl"`
+		}
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if err := required(map[string]string{"apiKey": s.APIKey}); err != nil {
+			return nil, err
+		}
+
+		p := NewQiniuTTSProvider(s.APIKey)
+		if s.BaseURL != "" {
+			p.WithBaseURL(s.BaseURL)
+		}
+		if s.WSURL != "" {
+			p.WithWSURL(s.WSURL)
+		}
+		return p, nil
+	})
+}
+
 type QiniuTTSProvider struct {
 	apiKey     string
 	baseURL    string
@@ -68,10 +93,37 @@ func NewQiniuTTSProvider(apiKey string) *QiniuTTSProvider {
 	}
 }
 
+// WithBaseURL 设置 HTTP 接口地址（音色列表与非流式合成）
+func (p *QiniuTTSProvider) WithBaseURL(baseURL string) *QiniuTTSProvider {
+	p.baseURL = baseURL
+	return p
+}
+
+// WithWSURL 设置流式合成的 WebSocket 地址
+func (p *QiniuTTSProvider) WithWSURL(wsURL string) *QiniuTTSProvider {
+	p.wsURL = wsURL
+	return p
+}
+
 func (p *QiniuTTSProvider) Name() string {
 	return "qiniu-tts"
 }
 
+// Describe 实现 Describer 接口，完整音色列表通过 ListVoices 获取
+func (p *QiniuTTSProvider) Describe() Capabilities {
+	return Capabilities{
+		Streaming:    true,
+		AudioFormats: []string{"mp3"},
+		DefaultVoice: "qiniu_zh_female_wwxkjx",
+		Languages:    []string{"zh", "en"},
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：获取音色列表
+func (p *QiniuTTSProvider) HealthCheck(ctx context.Context) error {
+	return checkHTTP(ctx, p.httpClient, p.baseURL+"/voice/list", p.apiKey)
+}
+
 // 实现 TTSProvider 接口中的 SynthesizeStream 方法
 func (p *QiniuTTSProvider) SynthesizeStream(ctx context.Context, textStream <-chan string, opts *TTSOptions) (<-chan *AudioChunk, error) {
 	resultChan := make(chan *AudioChunk, 10)
@@ -92,6 +144,11 @@ func (p *QiniuTTSProvider) SynthesizeStream(ctx context.Context, textStream <-ch
 				err := p.synthesizeStreamWS(ctx, text, opts, resultChan)
 				if err != nil {
 					logx.Errorf("TTS WebSocket synthesis failed: %v", err)
+					select {
+					case resultChan <- &AudioChunk{Err: err}:
+					case <-ctx.Done():
+						return
+					}
 					continue
 				}
 			}
@@ -304,4 +361,19 @@ func (p *QiniuTTSProvider) GetVoices(ctx context.Context) ([]QiniuVoice, error)
 	}
 
 	return voices, nil
+}
+
+// ListVoices 实现 VoiceLister 接口，返回可用的音色类型
+func (p *QiniuTTSProvider) ListVoices(ctx context.Context) ([]string, error) {
+	voices, err := p.GetVoices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	voiceTypes := make([]string, 0, len(voices))
+	for _, v := range voices {
+		voiceTypes = append(voiceTypes, v.VoiceType)
+	}
+
+	return voiceTypes, nil
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package provider_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestQiniuTTSSynthesizeStream(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+
+	r := load(t, provider.Spec{
+		Name: "qiniu", Kind: provider.TypeTTS, Type: "qiniu",
+		Settings: provider.Settings{"apiKey": "sk-test", "baseUrl": server.BaseURL(), "wsUrl": server.WSURL()},
+	})
+	tts, _ := r.GetTTS("qiniu")
+
+	stream, err := tts.SynthesizeStream(testContext(t), texts("你好。", "我是居里夫人。"), &provider.TTSOptions{
+		Voice: "qiniu_zh_male_ljfdxz",
+		Speed: 1.2,
+	})
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+
+	audio, err := collectAudio(stream)
+	if err != nil {
+		t.Fatalf("synthesis failed: %v", err)
+	}
+	if want := providertest.AudioFor("你好。我是居里夫人。"); !bytes.Equal(audio, want) {
+		t.Errorf("audio = %q, want %q", audio, want)
+	}
+
+	requests := server.TTSRequests()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want one per sentence", len(requests))
+	}
+	if requests[1].Request.Text != "我是居里夫人。" || requests[1].Audio.VoiceType != "qiniu_zh_male_ljfdxz" || requests[1].Audio.SpeedRatio != 1.2 {
+		t.Errorf("request = %+v", requests[1])
+	}
+}
+
+func TestQiniuTTSVoices(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+
+	tts := provider.NewQiniuTTSProvider("sk-test").WithBaseURL(server.BaseURL()).WithWSURL(server.WSURL())
+	voices, err := tts.ListVoices(testContext(t))
+	if err != nil {
+		t.Fatalf("ListVoices: %v", err)
+	}
+	if !slices.Equal(voices, providertest.QiniuVoices) {
+		t.Errorf("voices = %v", voices)
+	}
+	if err := tts.HealthCheck(testContext(t)); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestQiniuTTSUnauthorized(t *testing.T) {
+	server := providertest.NewQiniuServer("sk-test")
+	defer server.Close()
+
+	tts := provider.NewQiniuTTSProvider("sk-wrong").WithBaseURL(server.BaseURL()).WithWSURL(server.WSURL())
+	stream, err := tts.SynthesizeStream(testContext(t), texts("你好。"), nil)
+	if err != nil {
+		t.Fatalf("SynthesizeStream: %v", err)
+	}
+	if audio, err := collectAudio(stream); err == nil || len(audio) > 0 {
+		t.Errorf("audio = %q, err = %v; want a failed chunk", audio, err)
+	}
+}
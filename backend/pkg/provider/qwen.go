@@ -8,16 +8,49 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
 )
 
+func init() {
+	RegisterFactory(TypeLLM, "qwen", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		var s struct {
+			APIKey  string   `json:"apiKey"`
+			BaseURL string   `json:"baseUrl"`
+			Model   string   `json:"model"`
+			Models  []string `json:"models"`
+		}
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if err := required(map[string]string{"apiKey": s.APIKey}); err != nil {
+			return nil, err
+		}
+
+		p := NewQwenLLMProvider(s.APIKey)
+		if s.BaseURL != "" {
+			p.WithBaseURL(s.BaseURL)
+		}
+		if s.Model != "" {
+			p.WithModel(s.Model)
+		}
+		if len(s.Models) > 0 {
+			p.models = s.Models
+		}
+		return p, nil
+	})
+}
+
 // 通义千问 LLM Provider 实现
 type QwenLLMProvider struct {
-	apiKey string
+	apiKey  string
 	baseURL string
-	model  string
-	client *http.Client
+	model   string
+	models  []string // 允许请求的其他模型
+	client  *http.Client
 }
 
 func NewQwenLLMProvider(apiKey string) *QwenLLMProvider {
@@ -25,6 +58,7 @@ func NewQwenLLMProvider(apiKey string) *QwenLLMProvider {
 		apiKey:  apiKey,
 		baseURL: "https://dashscope.aliyuncs.com/api/v1/services/aigc/text-generation/generation",
 		model:   "qwen-turbo",
+		models:  []string{"qwen-turbo", "qwen-plus", "qwen-max"},
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
@@ -35,11 +69,23 @@ func (p *QwenLLMProvider) Name() string {
 	return "qwen"
 }
 
+// WithBaseURL 设置 DashScope 文本生成接口地址（非兼容模式）
+func (p *QwenLLMProvider) WithBaseURL(baseURL string) *QwenLLMProvider {
+	p.baseURL = baseURL
+	return p
+}
+
+// WithModel 设置请求未指定模型时使用的模型
+func (p *QwenLLMProvider) WithModel(model string) *QwenLLMProvider {
+	p.model = model
+	return p
+}
+
 // 通义千问请求结构
 type qwenRequest struct {
-	Model      string        `json:"model"`
-	Input      qwenInput     `json:"input"`
-	Parameters qwenParams    `json:"parameters"`
+	Model      string     `json:"model"`
+	Input      qwenInput  `json:"input"`
+	Parameters qwenParams `json:"parameters"`
 }
 
 type qwenInput struct {
@@ -52,23 +98,23 @@ type qwenMessage struct {
 }
 
 type qwenParams struct {
-	ResultFormat      string  `json:"result_format"`
-	Seed              int     `json:"seed,omitempty"`
-	MaxTokens         int     `json:"max_tokens,omitempty"`
-	TopP              float64 `json:"top_p,omitempty"`
-	TopK              int     `json:"top_k,omitempty"`
-	RepetitionPenalty float64 `json:"repetition_penalty,omitempty"`
-	Temperature       float64 `json:"temperature,omitempty"`
+	ResultFormat      string   `json:"result_format"`
+	Seed              *int     `json:"seed,omitempty"`
+	MaxTokens         int      `json:"max_tokens,omitempty"`
+	TopP              *float64 `json:"top_p,omitempty"`
+	TopK              int      `json:"top_k,omitempty"`
+	RepetitionPenalty float64  `json:"repetition_penalty,omitempty"`
+	Temperature       *float64 `json:"temperature,omitempty"`
 	Stop              []string `json:"stop,omitempty"`
-	EnableSearch      bool    `json:"enable_search,omitempty"`
-	IncrementalOutput bool    `json:"incremental_output,omitempty"`
+	EnableSearch      bool     `json:"enable_search,omitempty"`
+	IncrementalOutput bool     `json:"incremental_output,omitempty"`
 }
 
 // 通义千问响应结构
 type qwenResponse struct {
-	Output qwenOutput `json:"output"`
-	Usage  qwenUsage  `json:"usage"`
-	RequestID string  `json:"request_id"`
+	Output    qwenOutput `json:"output"`
+	Usage     qwenUsage  `json:"usage"`
+	RequestID string     `json:"request_id"`
 }
 
 type qwenOutput struct {
@@ -82,29 +128,34 @@ type qwenUsage struct {
 	TotalTokens  int `json:"total_tokens"`
 }
 
-func (p *QwenLLMProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// 转换消息格式
-	qwenMessages := make([]qwenMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		qwenMessages[i] = qwenMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+// Describe 实现 Describer 接口：默认模型排在第一位
+func (p *QwenLLMProvider) Describe() Capabilities {
+	models := []string{p.model}
+	for _, model := range p.models {
+		models = appendUniqueString(models, model)
 	}
 
-	// 构建请求
-	qwenReq := qwenRequest{
-		Model: p.model,
-		Input: qwenInput{
-			Messages: qwenMessages,
-		},
-		Parameters: qwenParams{
-			ResultFormat:      "text",
-			MaxTokens:         req.MaxTokens,
-			Temperature:       req.Temperature,
-			TopP:              req.TopP,
-			IncrementalOutput: false,
-		},
+	return Capabilities{
+		Streaming: true,
+		Batch:     true,
+		Models:    models,
+		Languages: []string{"zh", "en"},
+	}
+}
+
+// HealthCheck 实现 HealthChecker 接口：通过兼容模式接口列出模型，不产生计费调用
+func (p *QwenLLMProvider) HealthCheck(ctx context.Context) error {
+	u, err := url.Parse(p.baseURL)
+	if err != nil {
+		return err
+	}
+	return checkHTTP(ctx, p.client, u.Scheme+"://"+u.Host+"/compatible-mode/v1/models", p.apiKey)
+}
+
+func (p *QwenLLMProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	qwenReq, err := p.buildRequest(req, false)
+	if err != nil {
+		return nil, err
 	}
 
 	// 发送请求
@@ -125,11 +176,29 @@ func (p *QwenLLMProvider) Chat(ctx context.Context, req *ChatRequest) (*ChatResp
 			PromptTokens:     qwenResp.Usage.InputTokens,
 			CompletionTokens: qwenResp.Usage.OutputTokens,
 			TotalTokens:      qwenResp.Usage.TotalTokens,
+			Model:            qwenReq.Model,
 		},
 	}, nil
 }
 
 func (p *QwenLLMProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-chan *ChatDelta, error) {
+	qwenReq, err := p.buildRequest(req, true) // 启用增量输出
+	if err != nil {
+		return nil, err
+	}
+
+	return p.sendStreamRequest(ctx, qwenReq)
+}
+
+// buildRequest 转换为通义千问请求，校验请求的模型并传递采样参数
+func (p *QwenLLMProvider) buildRequest(req *ChatRequest, incremental bool) (qwenRequest, error) {
+	model := req.Model
+	if model == "" {
+		model = p.model
+	} else if !containsString(p.Describe().Models, model) {
+		return qwenRequest{}, fmt.Errorf("qwen: model '%s' is not allowed, available: %s", model, strings.Join(p.Describe().Models, ", "))
+	}
+
 	// 转换消息格式
 	qwenMessages := make([]qwenMessage, len(req.Messages))
 	for i, msg := range req.Messages {
@@ -139,22 +208,21 @@ func (p *QwenLLMProvider) ChatStream(ctx context.Context, req *ChatRequest) (<-c
 		}
 	}
 
-	// 构建流式请求
-	qwenReq := qwenRequest{
-		Model: p.model,
+	return qwenRequest{
+		Model: model,
 		Input: qwenInput{
 			Messages: qwenMessages,
 		},
 		Parameters: qwenParams{
 			ResultFormat:      "text",
+			Seed:              req.Seed,
 			MaxTokens:         req.MaxTokens,
-			Temperature:       req.Temperature,
 			TopP:              req.TopP,
-			IncrementalOutput: true, // 启用流式输出
+			Temperature:       req.Temperature,
+			Stop:              req.Stop,
+			IncrementalOutput: incremental,
 		},
-	}
-
-	return p.sendStreamRequest(ctx, qwenReq)
+	}, nil
 }
 
 func (p *QwenLLMProvider) sendRequest(ctx context.Context, req qwenRequest) ([]byte, error) {
@@ -224,12 +292,12 @@ func (p *QwenLLMProvider) sendStreamRequest(ctx context.Context, req qwenRequest
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := scanner.Text()
-			
+
 			// 解析 SSE 事件
 			if strings.HasPrefix(line, "data:") {
 				data := strings.TrimPrefix(line, "data:")
 				data = strings.TrimSpace(data)
-				
+
 				if data == "[DONE]" {
 					deltaChan <- &ChatDelta{
 						Text:         "",
@@ -250,10 +318,12 @@ func (p *QwenLLMProvider) sendStreamRequest(ctx context.Context, req qwenRequest
 				}
 
 				if qwenResp.Usage.TotalTokens > 0 {
+					// 增量输出时 usage 为累计值
 					delta.Usage = &Usage{
 						PromptTokens:     qwenResp.Usage.InputTokens,
 						CompletionTokens: qwenResp.Usage.OutputTokens,
 						TotalTokens:      qwenResp.Usage.TotalTokens,
+						Model:            req.Model,
 					}
 				}
 
@@ -265,11 +335,11 @@ func (p *QwenLLMProvider) sendStreamRequest(ctx context.Context, req qwenRequest
 			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			// 可以考虑通过 error channel 返回错误
-			fmt.Printf("Error reading stream: %v\n", err)
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			logx.Errorf("Qwen: stream reading error: %v", err)
+			deltaChan <- &ChatDelta{Err: fmt.Errorf("stream interrupted: %w", err)}
 		}
 	}()
 
 	return deltaChan, nil
-}
\ No newline at end of file
+}
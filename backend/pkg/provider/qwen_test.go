@@ -0,0 +1,190 @@
+package provider_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider/providertest"
+)
+
+func TestQwenChatStream(t *testing.T) {
+	server := providertest.NewDashScopeServer("sk-qwen")
+	defer server.Close()
+	server.Enqueue(providertest.LLMReply{
+		Chunks: []string{"镭", "是我发现的。"},
+		Usage:  &provider.Usage{PromptTokens: 20, CompletionTokens: 7, TotalTokens: 27},
+	})
+
+	r := load(t, provider.Spec{
+		Name: "qwen", Kind: provider.TypeLLM, Type: "qwen",
+		Settings: provider.Settings{"apiKey": "sk-qwen", "baseUrl": server.BaseURL()},
+	})
+	llm, _ := r.GetLLM("qwen")
+
+	seed := 7
+	stream, err := llm.ChatStream(testContext(t), &provider.ChatRequest{
+		Model:    "qwen-plus",
+		Messages: userMessage("谁发现了镭？"),
+		Seed:     &seed,
+	})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+
+	text, usage, err := collectChat(stream)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if text != "镭是我发现的。" {
+		t.Errorf("text = %q", text)
+	}
+	if usage == nil || usage.TotalTokens != 27 || usage.Model != "qwen-plus" {
+		t.Errorf("usage = %+v, want 27 tokens of qwen-plus", usage)
+	}
+
+	var req struct {
+		Model      string `json:"model"`
+		Parameters struct {
+			Seed              *int `json:"seed"`
+			IncrementalOutput bool `json:"incremental_output"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(server.Requests()[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Model != "qwen-plus" || !req.Parameters.IncrementalOutput || req.Parameters.Seed == nil || *req.Parameters.Seed != 7 {
+		t.Errorf("request = %s", server.Requests()[0])
+	}
+}
+
+func TestQwenChat(t *testing.T) {
+	server := providertest.NewDashScopeServer("sk-qwen")
+	defer server.Close()
+	server.Enqueue(providertest.Reply("放射性", "元素。"))
+
+	llm := provider.NewQwenLLMProvider("sk-qwen").WithBaseURL(server.BaseURL())
+	resp, err := llm.Chat(testContext(t), &provider.ChatRequest{Messages: userMessage("镭是什么？")})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if resp.Text != "放射性元素。" || resp.Usage.Model != "qwen-turbo" {
+		t.Errorf("response = %+v, usage = %+v", resp, resp.Usage)
+	}
+}
+
+func TestQwenErrors(t *testing.T) {
+	server := providertest.NewDashScopeServer("sk-qwen")
+	defer server.Close()
+	llm := provider.NewQwenLLMProvider("sk-qwen").WithBaseURL(server.BaseURL())
+
+	if _, err := llm.ChatStream(testContext(t), &provider.ChatRequest{Model: "gpt-4o", Messages: userMessage("hi")}); err == nil {
+		t.Error("ChatStream accepted an undeclared model")
+	}
+
+	server.Enqueue(providertest.LLMReply{Err: errors.New("quota exceeded")})
+	if _, err := llm.Chat(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")}); err == nil {
+		t.Error("Chat succeeded on a server error")
+	}
+
+	server.Enqueue(providertest.LLMReply{Chunks: []string{"一半"}, StreamErr: errors.New("reset")})
+	stream, err := llm.ChatStream(testContext(t), &provider.ChatRequest{Messages: userMessage("hi")})
+	if err != nil {
+		t.Fatalf("ChatStream: %v", err)
+	}
+	if text, _, err := collectChat(stream); text != "一半" || err == nil {
+		t.Errorf("text = %q, err = %v; want partial text and an error", text, err)
+	}
+
+	if err := llm.HealthCheck(testContext(t)); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestQwenModels(t *testing.T) {
+	server := providertest.NewDashScopeServer("sk-qwen")
+	defer server.Close()
+
+	r := load(t, provider.Spec{
+		Name: "qwen", Kind: provider.TypeLLM, Type: "qwen",
+		Settings: provider.Settings{"apiKey": "sk-qwen", "baseUrl": server.BaseURL(), "model": "qwen-plus", "models": []string{"qwen-max", "qwen-plus"}},
+	})
+	llm, _ := r.GetLLM("qwen")
+
+	if got := provider.Describe(llm).Models; !reflect.DeepEqual(got, []string{"qwen-plus", "qwen-max"}) {
+		t.Errorf("models = %v", got)
+	}
+
+	cases := []struct {
+		model string
+		want  string // 发送的模型，空表示拒绝
+	}{
+		{"", "qwen-plus"},
+		{"qwen-max", "qwen-max"},
+		{"qwen-turbo", ""},
+	}
+	for _, tc := range cases {
+		server.Enqueue(providertest.Reply("你好"))
+		sent := len(server.Requests())
+		_, err := llm.Chat(testContext(t), &provider.ChatRequest{Model: tc.model, Messages: userMessage("你好")})
+		if tc.want == "" {
+			if err == nil || !strings.Contains(err.Error(), "model 'qwen-turbo' is not allowed, available: qwen-plus, qwen-max") || len(server.Requests()) != sent {
+				t.Errorf("model %q: err = %v", tc.model, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("model %q: %v", tc.model, err)
+		}
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.Unmarshal(server.Requests()[sent], &req)
+		if req.Model != tc.want {
+			t.Errorf("model %q: sent %s, want %s", tc.model, req.Model, tc.want)
+		}
+	}
+}
+
+func TestQwenSampling(t *testing.T) {
+	server := providertest.NewDashScopeServer("sk-qwen")
+	defer server.Close()
+	llm := provider.NewQwenLLMProvider("sk-qwen").WithBaseURL(server.BaseURL())
+	temperature, topP, seed := 0.0, 0.9, 42
+
+	cases := []struct {
+		name string
+		req  *provider.ChatRequest
+		want map[string]interface{} // parameters 中的采样参数，nil 表示不发送
+	}{
+		{"unset", &provider.ChatRequest{}, map[string]interface{}{"temperature": nil, "top_p": nil, "seed": nil, "stop": nil}},
+		{
+			// 温度为 0 也要发送
+			name: "set",
+			req:  &provider.ChatRequest{Temperature: &temperature, TopP: &topP, Seed: &seed, Stop: []string{"。"}},
+			want: map[string]interface{}{"temperature": 0.0, "top_p": 0.9, "seed": 42.0, "stop": []interface{}{"。"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server.Enqueue(providertest.Reply("你好"))
+			tc.req.Messages = userMessage("你好")
+			if _, err := llm.Chat(testContext(t), tc.req); err != nil {
+				t.Fatal(err)
+			}
+			requests := server.Requests()
+			var body struct {
+				Parameters map[string]interface{} `json:"parameters"`
+			}
+			json.Unmarshal(requests[len(requests)-1], &body)
+			for key, want := range tc.want {
+				if got, ok := body.Parameters[key]; (want == nil && ok) || (want != nil && !reflect.DeepEqual(got, want)) {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
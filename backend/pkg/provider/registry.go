@@ -3,6 +3,9 @@ package provider
 import (
 	"context"
 	"fmt"
+	"sort"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/audio"
 )
 
 // Registry manages all providers with unified interfaces
@@ -11,6 +14,7 @@ type Registry struct {
 	asrProviders        map[string]ASRProvider
 	ttsProviders        map[string]TTSProvider
 	moderationProviders map[string]ModerationProvider
+	health              *HealthMonitor
 }
 
 func NewRegistry() *Registry {
@@ -19,6 +23,7 @@ func NewRegistry() *Registry {
 		asrProviders:        make(map[string]ASRProvider),
 		ttsProviders:        make(map[string]TTSProvider),
 		moderationProviders: make(map[string]ModerationProvider),
+		health:              NewHealthMonitor(HealthConfig{}),
 	}
 }
 
@@ -32,8 +37,9 @@ type LLMProvider interface {
 // ASR Provider Interface
 type ASRProvider interface {
 	Name() string
-	Recognize(audioData []byte) (string, error) // 批量识别
+	Recognize(audioData []byte) (string, error)                                                 // 批量识别
 	StreamRecognize(ctx context.Context, audioStream <-chan []byte) (<-chan *Transcript, error) // 流式识别（可选）
+	InputFormats() []audio.Format                                                               // 接受的音频输入格式，按优先级排列
 }
 
 // TTS Provider Interface
@@ -42,6 +48,11 @@ type TTSProvider interface {
 	SynthesizeStream(ctx context.Context, textStream <-chan string, opts *TTSOptions) (<-chan *AudioChunk, error)
 }
 
+// VoiceLister 可选接口：TTS Provider 实现后可用于校验音色是否存在
+type VoiceLister interface {
+	ListVoices(ctx context.Context) ([]string, error)
+}
+
 // Moderation Provider Interface
 type ModerationProvider interface {
 	Name() string
@@ -49,17 +60,21 @@ type ModerationProvider interface {
 }
 
 // Data structures
+
+// ChatRequest 对话请求，采样参数为空时使用 Provider 的默认值
 type ChatRequest struct {
-	Model       string     `json:"model"`
+	Model       string     `json:"model"` // 为空时使用 Provider 的默认模型
 	Messages    []*Message `json:"messages"`
-	Temperature float64    `json:"temperature,omitempty"`
-	TopP        float64    `json:"top_p,omitempty"`
+	Temperature *float64   `json:"temperature,omitempty"`
+	TopP        *float64   `json:"top_p,omitempty"`
 	MaxTokens   int        `json:"max_tokens,omitempty"`
+	Stop        []string   `json:"stop,omitempty"` // 停止序列
+	Seed        *int       `json:"seed,omitempty"` // 随机种子，用于复现输出
 	Stream      bool       `json:"stream"`
 }
 
 type Message struct {
-	Role    string `json:"role"`    // system|user|assistant
+	Role    string `json:"role"` // system|user|assistant
 	Content string `json:"content"`
 }
 
@@ -72,7 +87,8 @@ type ChatResponse struct {
 type ChatDelta struct {
 	Text         string `json:"text"`
 	FinishReason string `json:"finish_reason,omitempty"`
-	Usage        *Usage `json:"usage,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"` // 截至当前的累计用量，以最后一次为准
+	Err          error  `json:"-"`               // 流中途失败时作为最后一个元素发送
 }
 
 type Usage struct {
@@ -80,31 +96,36 @@ type Usage struct {
 	CompletionTokens int     `json:"completion_tokens"`
 	TotalTokens      int     `json:"total_tokens"`
 	Cost             float64 `json:"cost,omitempty"`
+	Model            string  `json:"model,omitempty"` // 实际生成回复的模型，用于计价
+	Estimated        bool    `json:"-"`               // 部分用量由文本估算，例如故障转移前未上报用量的尝试
 }
 
 type Transcript struct {
-	Text      string  `json:"text"`
-	IsFinal   bool    `json:"is_final"`
+	Text       string  `json:"text"`
+	IsFinal    bool    `json:"is_final"`
 	Confidence float64 `json:"confidence"`
+	Err        error   `json:"-"` // 识别中途失败时作为最后一个元素发送
 }
 
 type AudioChunk struct {
 	Data   []byte `json:"data"`
 	Format string `json:"format"` // mp3|pcm
 	SeqNum int    `json:"seq_num"`
+	Err    error  `json:"-"` // 某段文本合成失败，Data 为空
 }
 
 type TTSOptions struct {
-	Voice string `json:"voice"`
-	Style string `json:"style,omitempty"`
+	Voice string  `json:"voice"`
+	Style string  `json:"style,omitempty"`
 	Speed float64 `json:"speed,omitempty"`
 }
 
 type ModerationResult struct {
-	Level   string   `json:"level"`   // block|rewrite|warn|pass
-	Score   float64  `json:"score"`   // 0.0-1.0
-	Labels  []string `json:"labels"`  // detected categories
-	Reason  string   `json:"reason"`  // explanation
+	Level     string   `json:"level"`               // block|rewrite|warn|pass
+	Score     float64  `json:"score"`               // 0.0-1.0
+	Labels    []string `json:"labels"`              // detected categories
+	Reason    string   `json:"reason"`              // explanation
+	Rewritten string   `json:"rewritten,omitempty"` // rewrite 时替换后的文本
 }
 
 // Registry methods
@@ -152,6 +173,33 @@ func (r *Registry) GetModeration(name string) (ModerationProvider, error) {
 	return nil, fmt.Errorf("Moderation provider '%s' not found", name)
 }
 
+// ListModeration 按名称顺序返回全部审核 Provider
+func (r *Registry) ListModeration() []ModerationProvider {
+	names := make([]string, 0, len(r.moderationProviders))
+	for name := range r.moderationProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	providers := make([]ModerationProvider, 0, len(names))
+	for _, name := range names {
+		providers = append(providers, r.moderationProviders[name])
+	}
+	return providers
+}
+
+// StartHealthChecks 按配置在后台周期性检查全部 Provider，应在注册完成后调用
+func (r *Registry) StartHealthChecks(cfg HealthConfig) {
+	r.health.Stop()
+	r.health = NewHealthMonitor(cfg)
+	r.health.Start(r)
+}
+
+// Health 返回 Provider 健康状态的记录
+func (r *Registry) Health() *HealthMonitor {
+	return r.health
+}
+
 // 服务发现相关方法
 
 // ProviderInfo 表示 Provider 信息
@@ -160,142 +208,83 @@ type ProviderInfo struct {
 	Type         string            `json:"type"`
 	Status       string            `json:"status"`
 	Capabilities []string          `json:"capabilities,omitempty"`
+	Details      Capabilities      `json:"details"`
+	Health       Health            `json:"health"`
 	Config       map[string]string `json:"config,omitempty"`
 }
 
+// 服务发现的 Provider 类型顺序
+var providerTypes = []string{TypeLLM, TypeASR, TypeTTS, TypeModeration}
+
+// providers 返回指定类型的全部 Provider
+func (r *Registry) providers(providerType string) map[string]interface{} {
+	providers := make(map[string]interface{})
+	switch providerType {
+	case TypeLLM:
+		for name, p := range r.llmProviders {
+			providers[name] = p
+		}
+	case TypeASR:
+		for name, p := range r.asrProviders {
+			providers[name] = p
+		}
+	case TypeTTS:
+		for name, p := range r.ttsProviders {
+			providers[name] = p
+		}
+	case TypeModeration:
+		for name, p := range r.moderationProviders {
+			providers[name] = p
+		}
+	}
+	return providers
+}
+
+func (r *Registry) providerInfo(providerType, name string, p interface{}) ProviderInfo {
+	caps := Describe(p)
+	health := r.health.Health(providerType, name)
+	return ProviderInfo{
+		Name:         name,
+		Type:         providerType,
+		Status:       health.Status,
+		Capabilities: caps.Features(providerType, p),
+		Details:      caps,
+		Health:       health,
+	}
+}
+
 // GetAllProviders 获取所有 Provider 信息
 func (r *Registry) GetAllProviders() []ProviderInfo {
 	var providers []ProviderInfo
-	
-	// LLM Providers
-	for name, _ := range r.llmProviders {
-		providers = append(providers, ProviderInfo{
-			Name:         name,
-			Type:         "llm",
-			Status:       "online",
-			Capabilities: []string{"chat", "stream"},
-		})
-	}
-	
-	// ASR Providers
-	for name, _ := range r.asrProviders {
-		providers = append(providers, ProviderInfo{
-			Name:         name,
-			Type:         "asr",
-			Status:       "online",
-			Capabilities: []string{"stream_recognize"},
-		})
-	}
-	
-	// TTS Providers
-	for name, _ := range r.ttsProviders {
-		providers = append(providers, ProviderInfo{
-			Name:         name,
-			Type:         "tts",
-			Status:       "online",
-			Capabilities: []string{"synthesize_stream"},
-		})
+	for _, providerType := range providerTypes {
+		providers = append(providers, r.GetProvidersByType(providerType)...)
 	}
-	
-	// Moderation Providers
-	for name, _ := range r.moderationProviders {
-		providers = append(providers, ProviderInfo{
-			Name:         name,
-			Type:         "moderation",
-			Status:       "online",
-			Capabilities: []string{"check_text"},
-		})
-	}
-	
 	return providers
 }
 
-// GetProvidersByType 根据类型获取 Provider 信息
+// GetProvidersByType 根据类型获取 Provider 信息，按名称排序
 func (r *Registry) GetProvidersByType(providerType string) []ProviderInfo {
-	var providers []ProviderInfo
-	
-	switch providerType {
-	case "llm":
-		for name, _ := range r.llmProviders {
-			providers = append(providers, ProviderInfo{
-				Name:         name,
-				Type:         "llm",
-				Status:       "online",
-				Capabilities: []string{"chat", "stream"},
-			})
-		}
-	case "asr":
-		for name, _ := range r.asrProviders {
-			providers = append(providers, ProviderInfo{
-				Name:         name,
-				Type:         "asr",
-				Status:       "online",
-				Capabilities: []string{"stream_recognize"},
-			})
-		}
-	case "tts":
-		for name, _ := range r.ttsProviders {
-			providers = append(providers, ProviderInfo{
-				Name:         name,
-				Type:         "tts",
-				Status:       "online",
-				Capabilities: []string{"synthesize_stream"},
-			})
-		}
-	case "moderation":
-		for name, _ := range r.moderationProviders {
-			providers = append(providers, ProviderInfo{
-				Name:         name,
-				Type:         "moderation",
-				Status:       "online",
-				Capabilities: []string{"check_text"},
-			})
-		}
+	all := r.providers(providerType)
+
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	providers := make([]ProviderInfo, 0, len(names))
+	for _, name := range names {
+		providers = append(providers, r.providerInfo(providerType, name, all[name]))
 	}
-	
 	return providers
 }
 
 // GetProviderInfo 获取特定 Provider 的信息
 func (r *Registry) GetProviderInfo(providerType, name string) (*ProviderInfo, error) {
-	switch providerType {
-	case "llm":
-		if _, ok := r.llmProviders[name]; ok {
-			return &ProviderInfo{
-				Name:         name,
-				Type:         "llm",
-				Status:       "online",
-				Capabilities: []string{"chat", "stream"},
-			}, nil
-		}
-	case "asr":
-		if _, ok := r.asrProviders[name]; ok {
-			return &ProviderInfo{
-				Name:         name,
-				Type:         "asr",
-				Status:       "online",
-				Capabilities: []string{"stream_recognize"},
-			}, nil
-		}
-	case "tts":
-		if _, ok := r.ttsProviders[name]; ok {
-			return &ProviderInfo{
-				Name:         name,
-				Type:         "tts",
-				Status:       "online",
-				Capabilities: []string{"synthesize_stream"},
-			}, nil
-		}
-	case "moderation":
-		if _, ok := r.moderationProviders[name]; ok {
-			return &ProviderInfo{
-				Name:         name,
-				Type:         "moderation",
-				Status:       "online",
-				Capabilities: []string{"check_text"},
-			}, nil
-		}
+	if p, ok := r.providers(providerType)[name]; ok {
+		info := r.providerInfo(providerType, name, p)
+		return &info, nil
 	}
-	
+
 	return nil, fmt.Errorf("provider '%s' of type '%s' not found", name, providerType)
-}
\ No newline at end of file
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+func init() {
+	RegisterFactory(TypeModeration, "rule", func(name string, settings Settings, _ *Registry) (interface{}, error) {
+		var s struct {
+			Rules []ModerationRule `json:"rules"`
+		}
+		if err := settings.Decode(&s); err != nil {
+			return nil, err
+		}
+		if len(s.Rules) == 0 {
+			return nil, fmt.Errorf("missing settings: rules")
+		}
+		return NewRuleModerationProvider(name, s.Rules)
+	})
+}
+
+var moderationSeverity = map[string]int{
+	model.SafetyActionPass:    0,
+	model.SafetyActionWarn:    1,
+	model.SafetyActionRewrite: 2,
+	model.SafetyActionBlock:   3,
+}
+
+var moderationScore = map[string]float64{
+	model.SafetyActionPass:    0,
+	model.SafetyActionWarn:    0.3,
+	model.SafetyActionRewrite: 0.6,
+	model.SafetyActionBlock:   1.0,
+}
+
+// ModerationSeverity 返回审核动作的严重程度，用于比较多个审核结果
+func ModerationSeverity(level string) int {
+	return moderationSeverity[level]
+}
+
+// ModerationRule 一条审核规则：关键词或正则任一命中即触发 Action
+type ModerationRule struct {
+	Name        string   `json:"name"`
+	Action      string   `json:"action"` // block|rewrite|warn
+	Keywords    []string `json:"keywords,omitempty"`
+	Patterns    []string `json:"patterns,omitempty"`
+	Replacement string   `json:"replacement,omitempty"` // rewrite 时替换命中内容，默认 ***
+}
+
+type compiledRule struct {
+	ModerationRule
+	patterns []*regexp.Regexp
+}
+
+// RuleModerationProvider 本地规则审核：Aho-Corasick 关键词匹配 + 正则
+type RuleModerationProvider struct {
+	name    string
+	rules   []compiledRule
+	matcher *KeywordMatcher
+	// 关键词下标 -> 规则下标
+	keywordRule []int
+}
+
+func NewRuleModerationProvider(name string, rules []ModerationRule) (*RuleModerationProvider, error) {
+	p := &RuleModerationProvider{name: name}
+
+	var keywords []string
+	for i, rule := range rules {
+		if _, ok := moderationSeverity[rule.Action]; !ok || rule.Action == model.SafetyActionPass {
+			return nil, fmt.Errorf("moderation rule '%s': invalid action '%s'", rule.Name, rule.Action)
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = "***"
+		}
+
+		compiled := compiledRule{ModerationRule: rule}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("moderation rule '%s': invalid pattern %q: %w", rule.Name, pattern, err)
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		p.rules = append(p.rules, compiled)
+
+		for _, kw := range rule.Keywords {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				keywords = append(keywords, kw)
+				p.keywordRule = append(p.keywordRule, i)
+			}
+		}
+	}
+
+	p.matcher = NewKeywordMatcher(keywords)
+	return p, nil
+}
+
+// HealthCheck 本地规则始终可用
+func (p *RuleModerationProvider) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+func (p *RuleModerationProvider) Name() string {
+	return p.name
+}
+
+// CheckText 返回命中规则中最严重的动作；rewrite 时 Rewritten 为替换后的文本
+func (p *RuleModerationProvider) CheckText(ctx context.Context, text string) (*ModerationResult, error) {
+	level := model.SafetyActionPass
+	labels := make(map[string]bool)
+	var reasons []string
+
+	hit := func(rule *compiledRule, what string) {
+		if moderationSeverity[rule.Action] > moderationSeverity[level] {
+			level = rule.Action
+		}
+		if !labels[rule.Name] {
+			labels[rule.Name] = true
+			reasons = append(reasons, fmt.Sprintf("%s: %s", rule.Name, what))
+		}
+	}
+
+	// 关键词匹配
+	runes := []rune(text)
+	var rewriteSpans []KeywordMatch
+	for _, m := range p.matcher.FindAll(runes) {
+		rule := &p.rules[p.keywordRule[m.Keyword]]
+		hit(rule, p.matcher.Keyword(m.Keyword))
+		if rule.Action == model.SafetyActionRewrite {
+			rewriteSpans = append(rewriteSpans, m)
+		}
+	}
+
+	// 正则匹配
+	for i := range p.rules {
+		rule := &p.rules[i]
+		for _, re := range rule.patterns {
+			if match := re.FindString(text); match != "" {
+				hit(rule, match)
+			}
+		}
+	}
+
+	result := &ModerationResult{
+		Level:  level,
+		Score:  moderationScore[level],
+		Labels: sortedKeys(labels),
+		Reason: strings.Join(reasons, "; "),
+	}
+
+	if level == model.SafetyActionRewrite {
+		result.Rewritten = p.rewrite(runes, rewriteSpans)
+	}
+
+	return result, nil
+}
+
+// rewrite 用规则的替换文本遮盖 rewrite 规则命中的关键词与正则片段
+func (p *RuleModerationProvider) rewrite(runes []rune, spans []KeywordMatch) string {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Start != spans[j].Start {
+			return spans[i].Start < spans[j].Start
+		}
+		return spans[i].End > spans[j].End
+	})
+
+	var sb strings.Builder
+	pos := 0
+	for _, span := range spans {
+		if span.Start < pos {
+			continue // 与前一个命中重叠
+		}
+		sb.WriteString(string(runes[pos:span.Start]))
+		sb.WriteString(p.rules[p.keywordRule[span.Keyword]].Replacement)
+		pos = span.End
+	}
+	sb.WriteString(string(runes[pos:]))
+
+	text := sb.String()
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if rule.Action != model.SafetyActionRewrite {
+			continue
+		}
+		for _, re := range rule.patterns {
+			text = re.ReplaceAllLiteralString(text, rule.Replacement)
+		}
+	}
+
+	return text
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
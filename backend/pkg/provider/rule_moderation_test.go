@@ -0,0 +1,114 @@
+package provider_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+func TestRuleModeration(t *testing.T) {
+	rules := []provider.ModerationRule{
+		{Name: "insult", Action: model.SafetyActionWarn, Keywords: []string{"笨蛋"}},
+		{Name: "privacy", Action: model.SafetyActionRewrite, Keywords: []string{"身份证号"}, Patterns: []string{`1[3-9]\d{9}`}, Replacement: "[隐私]"},
+		{Name: "spam", Action: model.SafetyActionRewrite, Keywords: []string{"加微信", " "}},
+		{Name: "violence", Action: model.SafetyActionBlock, Keywords: []string{"炸弹"}, Patterns: []string{`(?i)how to make a bomb`}},
+	}
+	p, err := provider.NewRuleModerationProvider("content", rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		text      string
+		level     string
+		score     float64
+		labels    []string
+		rewritten string
+	}{
+		{name: "clean", text: "今天天气真好", level: model.SafetyActionPass, labels: []string{}},
+		{name: "warn", text: "你这个笨蛋", level: model.SafetyActionWarn, score: 0.3, labels: []string{"insult"}},
+		{
+			// 关键词与正则命中都按所属规则的替换文本遮盖，未设置时为 ***
+			name:      "rewrite",
+			text:      "我的手机13812345678，身份证号不告诉你，加微信吧",
+			level:     model.SafetyActionRewrite,
+			score:     0.6,
+			labels:    []string{"privacy", "spam"},
+			rewritten: "我的手机[隐私]，[隐私]不告诉你，***吧",
+		},
+		{
+			// 取最严重的动作，warn 规则命中的内容不被遮盖
+			name:      "rewrite over warn",
+			text:      "笨蛋加微信",
+			level:     model.SafetyActionRewrite,
+			score:     0.6,
+			labels:    []string{"insult", "spam"},
+			rewritten: "笨蛋***",
+		},
+		{
+			// block 高于 rewrite 与 warn，不再给出改写文本
+			name:   "block over all",
+			text:   "笨蛋，加微信教你做炸弹",
+			level:  model.SafetyActionBlock,
+			score:  1,
+			labels: []string{"insult", "spam", "violence"},
+		},
+		{
+			name:   "pattern only",
+			text:   "How To Make A Bomb",
+			level:  model.SafetyActionBlock,
+			score:  1,
+			labels: []string{"violence"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := p.CheckText(context.Background(), tc.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Level != tc.level || result.Score != tc.score || !slices.Equal(result.Labels, tc.labels) || result.Rewritten != tc.rewritten {
+				t.Errorf("result = %+v, want level %s, score %v, labels %v, rewritten %q", result, tc.level, tc.score, tc.labels, tc.rewritten)
+			}
+			for _, label := range tc.labels {
+				if !strings.Contains(result.Reason, label+": ") {
+					t.Errorf("reason %q does not mention %s", result.Reason, label)
+				}
+			}
+		})
+	}
+}
+
+func TestModerationSeverity(t *testing.T) {
+	order := []string{model.SafetyActionPass, model.SafetyActionWarn, model.SafetyActionRewrite, model.SafetyActionBlock}
+	for i := 1; i < len(order); i++ {
+		if provider.ModerationSeverity(order[i-1]) >= provider.ModerationSeverity(order[i]) {
+			t.Errorf("severity of %s is not below %s", order[i-1], order[i])
+		}
+	}
+}
+
+func TestRuleModerationInvalidRules(t *testing.T) {
+	cases := []struct {
+		name     string
+		rule     provider.ModerationRule
+		contains string
+	}{
+		{"pass action", provider.ModerationRule{Name: "r", Action: model.SafetyActionPass, Keywords: []string{"x"}}, "invalid action"},
+		{"unknown action", provider.ModerationRule{Name: "r", Action: "delete", Keywords: []string{"x"}}, "invalid action"},
+		{"bad pattern", provider.ModerationRule{Name: "r", Action: model.SafetyActionBlock, Patterns: []string{"(unclosed"}}, "invalid pattern"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := provider.NewRuleModerationProvider("content", []provider.ModerationRule{tc.rule})
+			if err == nil || !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.contains)
+			}
+		})
+	}
+}
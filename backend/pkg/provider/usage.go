@@ -0,0 +1,29 @@
+package provider
+
+import "unicode"
+
+// EstimateTokens 粗略估算文本 token 数：中日韩字符按 1 个 token，其余按 4 个字符 1 个 token
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+
+	return cjk + (other+3)/4
+}
+
+// EstimateChatUsage 按请求消息与生成文本估算一次对话的用量
+func EstimateChatUsage(req *ChatRequest, completion string) Usage {
+	prompt := 0
+	for _, msg := range req.Messages {
+		prompt += EstimateTokens(msg.Content) + 4 // 每条消息的格式开销
+	}
+	u := Usage{PromptTokens: prompt, CompletionTokens: EstimateTokens(completion), Model: req.Model, Estimated: true}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return u
+}
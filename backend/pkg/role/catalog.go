@@ -0,0 +1,343 @@
+package role
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/zeromicro/go-zero/core/logx"
+	"gopkg.in/yaml.v2"
+)
+
+// 历史版本归档目录（位于角色目录下）
+const historyDir = ".history"
+
+var (
+	ErrNotFound        = errors.New("role not found")
+	ErrAlreadyExists   = errors.New("role already exists")
+	ErrVersionConflict = errors.New("role version conflict")
+)
+
+// Catalog 角色目录，从指定目录加载 YAML/JSON 角色定义
+type Catalog struct {
+	mu    sync.RWMutex
+	dir   string
+	roles map[string]*model.Role
+	paths map[string]string    // 角色 ID -> 定义文件路径
+	mods  map[string]time.Time // 角色 ID -> 内存中的定义对应的文件修改时间
+}
+
+// NewCatalog 创建角色目录并加载 dir 下的所有角色文件
+func NewCatalog(dir string) (*Catalog, error) {
+	c := &Catalog{
+		dir:   dir,
+		roles: make(map[string]*model.Role),
+		paths: make(map[string]string),
+		mods:  make(map[string]time.Time),
+	}
+
+	if err := c.Load(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Load 重新加载角色目录，任一文件解析失败时保持原有数据不变。
+//
+// 读取文件期间可能有 Create/Update/Delete 写入，合并时以文件修改时间与版本号较新的一方为准，
+// 避免用读到的旧内容覆盖刚由接口写入的角色。
+func (c *Catalog) Load() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read role directory %s: %w", c.dir, err)
+	}
+
+	roles := make(map[string]*model.Role)
+	paths := make(map[string]string)
+	mods := make(map[string]time.Time)
+	for _, entry := range entries {
+		if entry.IsDir() || !isRoleFile(entry.Name()) {
+			continue
+		}
+
+		path := filepath.Join(c.dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // 读取目录后被删除
+		}
+		r, err := LoadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if _, exists := roles[r.ID]; exists {
+			return fmt.Errorf("duplicate role id '%s' in %s", r.ID, path)
+		}
+		roles[r.ID] = r
+		paths[r.ID] = path
+		mods[r.ID] = info.ModTime()
+	}
+
+	c.mu.Lock()
+	c.merge(roles, paths, mods)
+	c.roles = roles
+	c.paths = paths
+	c.mods = mods
+	n := len(roles)
+	c.mu.Unlock()
+
+	logx.Infof("Loaded %d roles from %s", n, c.dir)
+	return nil
+}
+
+// merge 保留读取期间由接口写入的角色，调用方持有写锁。接口写入同样持有写锁，
+// 因此此时文件状态与内存一致，可据此判断读到的内容是否已过期
+func (c *Catalog) merge(roles map[string]*model.Role, paths map[string]string, mods map[string]time.Time) {
+	for id, r := range roles {
+		// 读取后被删除
+		if _, err := os.Stat(paths[id]); err != nil {
+			delete(roles, id)
+			delete(paths, id)
+			delete(mods, id)
+			continue
+		}
+
+		current, ok := c.roles[id]
+		if !ok || c.paths[id] != paths[id] {
+			continue
+		}
+		// 内存中的定义更新：文件修改时间更晚，或修改时间精度不足以区分时版本号更高
+		newer := c.mods[id].After(mods[id])
+		if c.mods[id].Equal(mods[id]) && current.Version > r.Version {
+			newer = true
+		}
+		if newer {
+			roles[id] = current
+			mods[id] = c.mods[id]
+		}
+	}
+
+	// 读取目录之后新建的角色：定义文件没有被本次读取过，且仍然存在
+	loaded := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		loaded[path] = true
+	}
+	for id, current := range c.roles {
+		if _, ok := roles[id]; ok || loaded[c.paths[id]] {
+			continue
+		}
+		if _, err := os.Stat(c.paths[id]); err == nil {
+			roles[id] = current
+			paths[id] = c.paths[id]
+			mods[id] = c.mods[id]
+		}
+	}
+}
+
+// Get 按 ID 获取角色
+func (c *Catalog) Get(id string) (*model.Role, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	r, ok := c.roles[id]
+	return r, ok
+}
+
+// List 返回按 ID 排序的全部角色
+func (c *Catalog) List() []*model.Role {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	roles := make([]*model.Role, 0, len(c.roles))
+	for _, r := range c.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].ID < roles[j].ID
+	})
+
+	return roles
+}
+
+// Create 新建角色并写入 <id>.yaml，版本号从 1 开始
+func (c *Catalog) Create(r *model.Role) (*model.Role, error) {
+	if err := Validate(r); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.roles[r.ID]; exists {
+		return nil, ErrAlreadyExists
+	}
+
+	created := *r
+	created.Version = 1
+
+	path := filepath.Join(c.dir, created.ID+".yaml")
+	if err := writeFile(path, &created); err != nil {
+		return nil, err
+	}
+
+	c.roles[created.ID] = &created
+	c.paths[created.ID] = path
+	c.mods[created.ID] = modTime(path)
+
+	return &created, nil
+}
+
+// Update 更新角色，旧版本归档到 .history 目录
+//
+// r.Version 非 0 时作为乐观锁，必须与当前版本一致。
+func (c *Catalog) Update(r *model.Role) (*model.Role, error) {
+	if err := Validate(r); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.roles[r.ID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if r.Version != 0 && r.Version != current.Version {
+		return nil, fmt.Errorf("%w: expected version %d, current version %d", ErrVersionConflict, r.Version, current.Version)
+	}
+
+	if err := c.archive(current); err != nil {
+		return nil, err
+	}
+
+	updated := *r
+	updated.Version = current.Version + 1
+
+	path := c.paths[r.ID]
+	if err := writeFile(path, &updated); err != nil {
+		return nil, err
+	}
+
+	c.roles[updated.ID] = &updated
+	c.mods[updated.ID] = modTime(path)
+
+	return &updated, nil
+}
+
+// Delete 删除角色，定义文件归档到 .history 目录
+func (c *Catalog) Delete(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current, ok := c.roles[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if err := c.archive(current); err != nil {
+		return err
+	}
+
+	if err := os.Remove(c.paths[id]); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove role file: %w", err)
+	}
+
+	delete(c.roles, id)
+	delete(c.paths, id)
+	delete(c.mods, id)
+
+	return nil
+}
+
+// archive 将角色当前版本写入 .history/<id>.v<version>.yaml
+func (c *Catalog) archive(r *model.Role) error {
+	dir := filepath.Join(c.dir, historyDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create role history directory: %w", err)
+	}
+
+	return writeFile(filepath.Join(dir, fmt.Sprintf("%s.v%d.yaml", r.ID, r.Version)), r)
+}
+
+// LoadFile 解析单个角色文件，按扩展名选择 YAML 或 JSON
+func LoadFile(path string) (*model.Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read role file %s: %w", path, err)
+	}
+
+	var r model.Role
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &r)
+	default:
+		err = yaml.UnmarshalStrict(data, &r)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse role file %s: %w", path, err)
+	}
+
+	r.SystemPrompt = strings.TrimSpace(r.SystemPrompt)
+	if r.Version == 0 {
+		r.Version = 1
+	}
+
+	if err := Validate(&r); err != nil {
+		return nil, fmt.Errorf("role file %s: %w", path, err)
+	}
+
+	return &r, nil
+}
+
+// writeFile 按扩展名写入角色定义，先写临时文件再重命名
+func writeFile(path string, r *model.Role) error {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		data, err = json.MarshalIndent(r, "", "  ")
+	} else {
+		data, err = yaml.Marshal(r)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to marshal role %s: %w", r.ID, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write role file: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace role file: %w", err)
+	}
+
+	return nil
+}
+
+// 文件修改时间，无法获取时为零值
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func isRoleFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,184 @@
+package role_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+)
+
+// writeRole 写入角色文件并设置修改时间
+func writeRole(t *testing.T, dir, id, prompt string, version int, mod time.Time) string {
+	t.Helper()
+
+	path := filepath.Join(dir, id+".yaml")
+	data := fmt.Sprintf("id: %s\nname: %s\nsystemPrompt: %s\nversion: %d\n", id, id, prompt, version)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newCatalog(t *testing.T, dir string) *role.Catalog {
+	t.Helper()
+
+	c, err := role.NewCatalog(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCatalogLoadKeepsNewerUpdate(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	path := writeRole(t, dir, "harry", "v1", 1, start)
+	c := newCatalog(t, dir)
+
+	if _, err := c.Update(&model.Role{ID: "harry", Name: "harry", SystemPrompt: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := info.ModTime()
+
+	cases := []struct {
+		name    string
+		prompt  string
+		version int
+		mod     time.Time
+		want    string
+	}{
+		// 轮询在接口写入前读到的旧内容不能覆盖接口写入的版本
+		{"stale read", "v1", 1, start, "v2"},
+		// 修改时间相同（精度不足）时按版本号判断
+		{"same mtime, older version", "v1", 1, updated, "v2"},
+		// 之后在磁盘上的修改照常生效
+		{"later edit", "edited", 2, updated.Add(time.Second), "edited"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			writeRole(t, dir, "harry", tc.prompt, tc.version, tc.mod)
+			if err := c.Load(); err != nil {
+				t.Fatal(err)
+			}
+			if r, _ := c.Get("harry"); r.SystemPrompt != tc.want {
+				t.Errorf("systemPrompt = %q, want %q", r.SystemPrompt, tc.want)
+			}
+		})
+	}
+}
+
+func TestCatalogLoadDropsDeletedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeRole(t, dir, "harry", "v1", 1, time.Now())
+	path := writeRole(t, dir, "curie", "v1", 1, time.Now())
+	c := newCatalog(t, dir)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get("curie"); ok {
+		t.Errorf("deleted role is still loaded")
+	}
+	if _, ok := c.Get("harry"); !ok {
+		t.Errorf("harry is missing")
+	}
+}
+
+// history .history 目录下的归档，格式为 文件名:systemPrompt
+func history(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(filepath.Join(dir, ".history"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, entry := range entries {
+		r, err := role.LoadFile(filepath.Join(dir, ".history", entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, entry.Name()+":"+r.SystemPrompt)
+	}
+	return out
+}
+
+func TestCatalogVersions(t *testing.T) {
+	dir := t.TempDir()
+	c := newCatalog(t, dir)
+
+	// 各步骤依次作用于同一个目录
+	cases := []struct {
+		name    string
+		op      string // create、update 或 delete
+		prompt  string
+		version int   // 请求中的版本号
+		err     error // 期望的错误
+		want    int   // 之后的当前版本，0 表示角色不存在
+		history []string
+	}{
+		// 新建时忽略请求中的版本号，从 1 开始
+		{name: "create", op: "create", prompt: "v1", version: 5, want: 1},
+		{name: "duplicate create", op: "create", prompt: "again", err: role.ErrAlreadyExists, want: 1},
+		// 版本号为 0 时不做乐观锁检查
+		{name: "update", op: "update", prompt: "v2", want: 2, history: []string{"curie.v1.yaml:v1"}},
+		{name: "update with version", op: "update", prompt: "v3", version: 2, want: 3, history: []string{"curie.v1.yaml:v1", "curie.v2.yaml:v2"}},
+		// 版本冲突时既不写入也不归档
+		{name: "stale update", op: "update", prompt: "stale", version: 2, err: role.ErrVersionConflict, want: 3, history: []string{"curie.v1.yaml:v1", "curie.v2.yaml:v2"}},
+		{name: "delete", op: "delete", history: []string{"curie.v1.yaml:v1", "curie.v2.yaml:v2", "curie.v3.yaml:v3"}},
+		{name: "update deleted", op: "update", prompt: "v4", err: role.ErrNotFound, history: []string{"curie.v1.yaml:v1", "curie.v2.yaml:v2", "curie.v3.yaml:v3"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &model.Role{ID: "curie", Name: "curie", SystemPrompt: tc.prompt, Version: tc.version}
+			var err error
+			switch tc.op {
+			case "create":
+				_, err = c.Create(r)
+			case "update":
+				_, err = c.Update(r)
+			case "delete":
+				err = c.Delete(r.ID)
+			}
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+
+			// 内存中的角色与重新从磁盘加载的一致
+			for _, catalog := range []*role.Catalog{c, newCatalog(t, dir)} {
+				got, ok := catalog.Get("curie")
+				if tc.want == 0 {
+					if ok {
+						t.Errorf("role = %+v, want it deleted", got)
+					}
+					continue
+				}
+				if !ok || got.Version != tc.want || got.SystemPrompt != fmt.Sprintf("v%d", tc.want) {
+					t.Errorf("role = %+v, want version %d", got, tc.want)
+				}
+			}
+			if got := history(t, dir); !slices.Equal(got, tc.history) {
+				t.Errorf("history = %q, want %q", got, tc.history)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package role
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 结构化守则类型，写法为 "<类型>: 参数1, 参数2"；其他守则仅作为提示词约束
+const (
+	GuardrailRefuseTopic     = "refuse_topic"      // 拒绝讨论的话题关键词
+	GuardrailStayInCharacter = "stay_in_character" // 不得跳出角色
+	GuardrailNeverClaim      = "never_claim"       // 绝不声称自己是某种身份
+)
+
+// 未指定参数时 never_claim 默认检查的身份
+var defaultClaims = []string{"AI", "人工智能", "语言模型", "大模型", "机器人", "ChatGPT"}
+
+// 跳出角色的常见表述
+var outOfCharacterPattern = regexp.MustCompile(`(?i)(作为一?个?(AI|人工智能|语言模型|大模型|虚拟助手)|我(只)?是一?个?(AI|人工智能|语言模型|大模型)(助手|模型)?|我(正在|在)扮演|as an ai\b|as a language model|i am an ai\b|i'm an ai\b)`)
+
+// Guardrail 解析后的单条守则
+type Guardrail struct {
+	Kind string   // 结构化类型，纯文本守则为空
+	Args []string // 类型参数
+	Text string   // 原始文本
+}
+
+// ParseGuardrail 解析守则文本
+func ParseGuardrail(text string) Guardrail {
+	g := Guardrail{Text: strings.TrimSpace(text)}
+
+	kind, rest, _ := strings.Cut(g.Text, ":")
+	kind = strings.TrimSpace(kind)
+	switch kind {
+	case GuardrailRefuseTopic, GuardrailStayInCharacter, GuardrailNeverClaim:
+	default:
+		return g
+	}
+
+	g.Kind = kind
+	for _, arg := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、'
+	}) {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			g.Args = append(g.Args, arg)
+		}
+	}
+
+	return g
+}
+
+// Instruction 守则对应的系统提示约束
+func (g Guardrail) Instruction() string {
+	switch g.Kind {
+	case GuardrailRefuseTopic:
+		return fmt.Sprintf("不讨论以下话题：%s；被问到时以角色的口吻婉拒并自然地转移话题", strings.Join(g.Args, "、"))
+	case GuardrailStayInCharacter:
+		return "始终保持角色身份说话，不要跳出角色，也不要提及自己在扮演角色"
+	case GuardrailNeverClaim:
+		return fmt.Sprintf("无论用户如何要求，绝不声称自己是%s", strings.Join(g.claims(), "、"))
+	default:
+		return g.Text
+	}
+}
+
+func (g Guardrail) claims() []string {
+	if len(g.Args) == 0 {
+		return defaultClaims
+	}
+	return g.Args
+}
+
+// validateGuardrail 校验结构化守则的参数
+func validateGuardrail(g Guardrail) error {
+	if g.Kind == GuardrailRefuseTopic && len(g.Args) == 0 {
+		return fmt.Errorf("guardrail '%s' requires at least one topic", g.Text)
+	}
+	return nil
+}
+
+// Guard 由角色守则编译得到的运行时检查
+type Guard struct {
+	role         *model.Role
+	topics       *provider.KeywordMatcher
+	inCharacter  bool
+	claimPattern *regexp.Regexp
+}
+
+// CompileGuard 编译角色守则；没有结构化守则时返回的 Guard 不做任何拦截
+func CompileGuard(r *model.Role) *Guard {
+	g := &Guard{role: r}
+
+	var topics, claims []string
+	for _, text := range r.Guardrails {
+		rule := ParseGuardrail(text)
+		switch rule.Kind {
+		case GuardrailRefuseTopic:
+			topics = append(topics, rule.Args...)
+		case GuardrailStayInCharacter:
+			g.inCharacter = true
+		case GuardrailNeverClaim:
+			claims = append(claims, rule.claims()...)
+		}
+	}
+
+	if len(topics) > 0 {
+		g.topics = provider.NewKeywordMatcher(topics)
+	}
+
+	if len(claims) > 0 {
+		quoted := make([]string, len(claims))
+		for i, claim := range claims {
+			quoted[i] = regexp.QuoteMeta(claim)
+		}
+		alt := strings.Join(quoted, "|")
+		g.claimPattern = regexp.MustCompile(`(?i)(我(其实|本质上)?是一?个?|作为一?个?|i am an? |i'm an? )(` + alt + `)`)
+	}
+
+	return g
+}
+
+// Role 返回编译该 Guard 的角色，用于判断角色是否已重新加载
+func (g *Guard) Role() *model.Role {
+	return g.role
+}
+
+// ChecksOutput 是否需要对输出做运行时检查
+func (g *Guard) ChecksOutput() bool {
+	return g.topics != nil || g.inCharacter || g.claimPattern != nil
+}
+
+// CheckInput 检查用户输入是否触及拒绝讨论的话题
+func (g *Guard) CheckInput(text string) *model.SafetyResult {
+	if topic := g.matchTopic(text); topic != "" {
+		return violation(GuardrailRefuseTopic, "topic: "+topic)
+	}
+	return &model.SafetyResult{Action: model.SafetyActionPass}
+}
+
+// CheckOutput 检查角色输出是否违反守则
+func (g *Guard) CheckOutput(text string) *model.SafetyResult {
+	if topic := g.matchTopic(text); topic != "" {
+		return violation(GuardrailRefuseTopic, "topic: "+topic)
+	}
+	if g.claimPattern != nil {
+		if match := g.claimPattern.FindString(text); match != "" {
+			return violation(GuardrailNeverClaim, "claim: "+match)
+		}
+	}
+	if g.inCharacter {
+		if match := outOfCharacterPattern.FindString(text); match != "" {
+			return violation(GuardrailStayInCharacter, "out of character: "+match)
+		}
+	}
+	return &model.SafetyResult{Action: model.SafetyActionPass}
+}
+
+func (g *Guard) matchTopic(text string) string {
+	if g.topics == nil {
+		return ""
+	}
+	matches := g.topics.FindAll([]rune(text))
+	if len(matches) == 0 {
+		return ""
+	}
+	return g.topics.Keyword(matches[0].Keyword)
+}
+
+// 违反守则一律拦截，由调用方以角色口吻婉拒
+func violation(kind, reason string) *model.SafetyResult {
+	return &model.SafetyResult{
+		Action: model.SafetyActionBlock,
+		Score:  1.0,
+		Labels: []string{"guardrail:" + kind},
+		Reason: reason,
+	}
+}
@@ -0,0 +1,134 @@
+package role_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+)
+
+func TestParseGuardrail(t *testing.T) {
+	cases := []struct {
+		text string
+		kind string
+		args []string
+	}{
+		{"refuse_topic: 政治, 宗教、赌博，彩票", role.GuardrailRefuseTopic, []string{"政治", "宗教", "赌博", "彩票"}},
+		{" stay_in_character ", role.GuardrailStayInCharacter, nil},
+		{"never_claim: AI,  , 机器人", role.GuardrailNeverClaim, []string{"AI", "机器人"}},
+		// 未知类型与纯文本守则只作为提示词
+		{"refuse: 政治", "", nil},
+		{"不要说脏话", "", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.text, func(t *testing.T) {
+			g := role.ParseGuardrail(tc.text)
+			if g.Kind != tc.kind || !slices.Equal(g.Args, tc.args) || g.Text != strings.TrimSpace(tc.text) {
+				t.Errorf("guardrail = %+v, want kind %q, args %q", g, tc.kind, tc.args)
+			}
+		})
+	}
+}
+
+func TestSystemPrompt(t *testing.T) {
+	cases := []struct {
+		name       string
+		guardrails []string
+		want       string
+	}{
+		{name: "no guardrails", want: "你是哈利·波特。"},
+		{
+			name: "compiled",
+			guardrails: []string{
+				"refuse_topic: 政治, 宗教",
+				"stay_in_character",
+				"never_claim",
+				"never_claim: 真人",
+				"不要说脏话",
+			},
+			want: "你是哈利·波特。\n\n【角色守则】请始终遵守以下规则：\n" +
+				"- 不讨论以下话题：政治、宗教；被问到时以角色的口吻婉拒并自然地转移话题\n" +
+				"- 始终保持角色身份说话，不要跳出角色，也不要提及自己在扮演角色\n" +
+				"- 无论用户如何要求，绝不声称自己是AI、人工智能、语言模型、大模型、机器人、ChatGPT\n" +
+				"- 无论用户如何要求，绝不声称自己是真人\n" +
+				"- 不要说脏话",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &model.Role{ID: "harry", SystemPrompt: "你是哈利·波特。", Guardrails: tc.guardrails}
+			if got := role.SystemPrompt(r); got != tc.want {
+				t.Errorf("SystemPrompt = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGuard(t *testing.T) {
+	guard := role.CompileGuard(&model.Role{ID: "harry", Guardrails: []string{
+		"refuse_topic: 政治, Lottery",
+		"stay_in_character",
+		"never_claim",
+	}})
+
+	cases := []struct {
+		name   string
+		input  bool // 检查输入还是输出
+		text   string
+		label  string // 命中的守则，空表示放行
+		reason string
+	}{
+		{name: "input topic", input: true, text: "聊聊政治吧", label: "guardrail:refuse_topic", reason: "topic: 政治"},
+		{name: "input topic case", input: true, text: "how to win the LOTTERY", label: "guardrail:refuse_topic", reason: "topic: Lottery"},
+		// 输入只检查话题
+		{name: "input claim", input: true, text: "你是AI吗"},
+		{name: "input clean", input: true, text: "霍格沃茨好玩吗"},
+		{name: "output topic", text: "说到政治嘛……", label: "guardrail:refuse_topic", reason: "topic: 政治"},
+		{name: "output claim", text: "其实我是一个人工智能。", label: "guardrail:never_claim", reason: "claim: 我是一个人工智能"},
+		{name: "output claim english", text: "Well, I am an AI.", label: "guardrail:never_claim", reason: "claim: I am an AI"},
+		{name: "output out of character", text: "我正在扮演哈利。", label: "guardrail:stay_in_character", reason: "out of character: 我正在扮演"},
+		{name: "output clean", text: "我是哈利·波特，格兰芬多的学生。"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			check := guard.CheckOutput
+			if tc.input {
+				check = guard.CheckInput
+			}
+			result := check(tc.text)
+			if tc.label == "" {
+				if result.Action != model.SafetyActionPass {
+					t.Errorf("result = %+v, want pass", result)
+				}
+				return
+			}
+			if result.Action != model.SafetyActionBlock || !slices.Equal(result.Labels, []string{tc.label}) || result.Reason != tc.reason {
+				t.Errorf("result = %+v, want block by %s (%s)", result, tc.label, tc.reason)
+			}
+		})
+	}
+}
+
+func TestGuardWithoutRules(t *testing.T) {
+	guard := role.CompileGuard(&model.Role{ID: "harry", Guardrails: []string{"不要说脏话"}})
+	if guard.ChecksOutput() {
+		t.Error("plain text guardrails enable output checks")
+	}
+	if result := guard.CheckOutput("作为一个AI，我是一个语言模型。"); result.Action != model.SafetyActionPass {
+		t.Errorf("result = %+v, want pass", result)
+	}
+}
+
+func TestValidateGuardrails(t *testing.T) {
+	r := &model.Role{ID: "harry", Name: "哈利", SystemPrompt: "你是哈利", Guardrails: []string{"refuse_topic:  , "}}
+	if err := role.Validate(r); err == nil || !strings.Contains(err.Error(), "requires at least one topic") {
+		t.Errorf("err = %v, want refuse_topic without topics rejected", err)
+	}
+
+	r.Guardrails = []string{"refuse_topic: 政治", "stay_in_character", "never_claim", "不要说脏话"}
+	if err := role.Validate(r); err != nil {
+		t.Errorf("valid guardrails rejected: %v", err)
+	}
+}
@@ -0,0 +1,25 @@
+package role
+
+import (
+	"strings"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+// SystemPrompt 将角色设定与守则组装为系统提示
+func SystemPrompt(r *model.Role) string {
+	if len(r.Guardrails) == 0 {
+		return r.SystemPrompt
+	}
+
+	var sb strings.Builder
+	sb.WriteString(r.SystemPrompt)
+	sb.WriteString("\n\n【角色守则】请始终遵守以下规则：\n")
+	for _, text := range r.Guardrails {
+		sb.WriteString("- ")
+		sb.WriteString(ParseGuardrail(text).Instruction())
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,95 @@
+package role
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+)
+
+// 角色技能
+const (
+	SkillKnowledgeQA       = "knowledge_qa"
+	SkillStorytelling      = "storytelling"
+	SkillEmotionExpression = "emotion_expression"
+	SkillMemory            = "memory"
+)
+
+// KnownSkills 平台支持的角色技能
+var KnownSkills = map[string]bool{
+	SkillKnowledgeQA:       true,
+	SkillStorytelling:      true,
+	SkillEmotionExpression: true,
+	SkillMemory:            true,
+}
+
+// 角色 ID 同时用作文件名，限制为小写字母、数字、下划线和连字符
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Validate 校验角色定义的必填字段、技能与守则
+func Validate(r *model.Role) error {
+	if !idPattern.MatchString(r.ID) {
+		return fmt.Errorf("invalid role id '%s': must match %s", r.ID, idPattern.String())
+	}
+	if r.Name == "" {
+		return fmt.Errorf("role %s: missing name", r.ID)
+	}
+	if r.SystemPrompt == "" {
+		return fmt.Errorf("role %s: missing systemPrompt", r.ID)
+	}
+
+	for _, skill := range r.Skills {
+		if !KnownSkills[skill] {
+			return fmt.Errorf("role %s: unknown skill '%s'", r.ID, skill)
+		}
+	}
+
+	for _, text := range r.Guardrails {
+		if err := validateGuardrail(ParseGuardrail(text)); err != nil {
+			return fmt.Errorf("role %s: %w", r.ID, err)
+		}
+	}
+
+	if speed, ok := r.TTSDefault["speed"]; ok {
+		if v, err := strconv.ParseFloat(speed, 64); err != nil || v <= 0 {
+			return fmt.Errorf("role %s: invalid ttsDefault speed '%s'", r.ID, speed)
+		}
+	}
+
+	if r.LLMDefault != nil {
+		if err := ValidateLLMSettings(r.LLMDefault); err != nil {
+			return fmt.Errorf("role %s: llmDefault: %w", r.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// 停止序列数量上限（与 OpenAI 接口一致）
+const maxStopSequences = 4
+
+// ValidateLLMSettings 校验采样参数的取值范围，模型是否可用由调用方对照 Provider 校验
+func ValidateLLMSettings(s *model.LLMSettings) error {
+	if s.Temperature != nil && (*s.Temperature < 0 || *s.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2, got %v", *s.Temperature)
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		return fmt.Errorf("topP must be in (0, 1], got %v", *s.TopP)
+	}
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("maxTokens must not be negative, got %d", s.MaxTokens)
+	}
+	if len(s.Stop) > maxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", maxStopSequences, len(s.Stop))
+	}
+	for _, stop := range s.Stop {
+		if stop == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	if s.Seed != nil && *s.Seed < 0 {
+		return fmt.Errorf("seed must not be negative, got %d", *s.Seed)
+	}
+	return nil
+}
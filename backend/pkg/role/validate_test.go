@@ -0,0 +1,49 @@
+package role_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/role"
+)
+
+func TestValidateLLMSettings(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	integer := func(v int) *int { return &v }
+
+	cases := []struct {
+		name     string
+		settings model.LLMSettings
+		err      string
+	}{
+		{name: "empty"},
+		{name: "bounds", settings: model.LLMSettings{Temperature: float(0), TopP: float(1), MaxTokens: 0, Seed: integer(0)}},
+		{name: "max temperature", settings: model.LLMSettings{Temperature: float(2)}},
+		{name: "negative temperature", settings: model.LLMSettings{Temperature: float(-0.1)}, err: "temperature must be between 0 and 2, got -0.1"},
+		{name: "high temperature", settings: model.LLMSettings{Temperature: float(2.5)}, err: "temperature must be between 0 and 2"},
+		{name: "zero top_p", settings: model.LLMSettings{TopP: float(0)}, err: "topP must be in (0, 1], got 0"},
+		{name: "high top_p", settings: model.LLMSettings{TopP: float(1.1)}, err: "topP must be in (0, 1]"},
+		{name: "negative max tokens", settings: model.LLMSettings{MaxTokens: -1}, err: "maxTokens must not be negative"},
+		{name: "stop sequences", settings: model.LLMSettings{Stop: []string{"。", "！", "？", "\n"}}},
+		{name: "too many stop sequences", settings: model.LLMSettings{Stop: []string{"a", "b", "c", "d", "e"}}, err: "at most 4 stop sequences are allowed, got 5"},
+		{name: "empty stop sequence", settings: model.LLMSettings{Stop: []string{"。", ""}}, err: "stop sequences must not be empty"},
+		{name: "negative seed", settings: model.LLMSettings{Seed: integer(-1)}, err: "seed must not be negative"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := role.ValidateLLMSettings(&tc.settings)
+			if tc.err == "" && err != nil || tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+				t.Errorf("err = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestValidateLLMDefault(t *testing.T) {
+	temperature := 3.0
+	r := &model.Role{ID: "harry", Name: "哈利", SystemPrompt: "你是哈利", LLMDefault: &model.LLMSettings{Temperature: &temperature}}
+	if err := role.Validate(r); err == nil || !strings.Contains(err.Error(), "role harry: llmDefault: temperature must be between 0 and 2") {
+		t.Errorf("err = %v", err)
+	}
+}
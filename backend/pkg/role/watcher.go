@@ -0,0 +1,64 @@
+package role
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Watch 定期检查角色目录，文件变化时自动重新加载；返回的函数用于停止监听
+func (c *Catalog) Watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	last := c.snapshot()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := c.snapshot()
+				if current == last {
+					continue
+				}
+
+				if err := c.Load(); err != nil {
+					// 保留旧数据，等待下一次修改
+					logx.Errorf("Failed to reload roles from %s: %v", c.dir, err)
+				}
+				last = current
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// snapshot 生成目录内角色文件的名称、大小与修改时间签名
+func (c *Catalog) snapshot() string {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || !isRoleFile(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+
+	return sb.String()
+}
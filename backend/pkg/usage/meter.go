@@ -0,0 +1,239 @@
+package usage
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/provider"
+)
+
+// 未匹配到 API Key 的连接计入该租户
+const AnonymousTenant = "anonymous"
+
+// 费用保留的小数位数对应的精度，避免浮点误差随累计放大
+const costPrecision = 1e6
+
+// Price 一条计价规则。Provider 为空时按模型匹配任意 Provider（适用于故障转移链），
+// Model 为空时匹配该 Provider 的所有模型，两者都为空时作为该类型的默认价格
+type Price struct {
+	Kind            string  // llm|asr|tts
+	Provider        string  // 注册名
+	Model           string  // 仅 LLM
+	PromptPer1K     float64 // LLM 每千输入 token
+	CompletionPer1K float64 // LLM 每千输出 token
+	PerChar         float64 // TTS 每字符
+	PerSecond       float64 // ASR 每秒音频
+}
+
+// Totals 累计用量
+type Totals struct {
+	model.Usage
+	Turns     int       `json:"turns"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Meter 按价格表计算费用，并按会话与租户累计用量（进程内保存，重启后清零）
+type Meter struct {
+	currency string
+	prices   []Price
+	tenants  map[string]string // API Key -> 租户
+
+	mu            sync.Mutex
+	conversations map[string]*Totals
+	owners        map[string]string // 会话 ID -> 首次记录用量的租户
+	tenantTotals  map[string]*Totals
+}
+
+func NewMeter(currency string, prices []Price, tenants map[string]string) *Meter {
+	return &Meter{
+		currency:      currency,
+		prices:        prices,
+		tenants:       tenants,
+		conversations: make(map[string]*Totals),
+		owners:        make(map[string]string),
+		tenantTotals:  make(map[string]*Totals),
+	}
+}
+
+// Currency 费用的货币单位
+func (m *Meter) Currency() string {
+	return m.currency
+}
+
+// TenantOf 返回 API Key 对应的租户
+func (m *Meter) TenantOf(apiKey string) string {
+	if tenant, ok := m.tenants[apiKey]; ok && apiKey != "" {
+		return tenant
+	}
+	return AnonymousTenant
+}
+
+// LLM 计算一次 LLM 调用的用量与费用
+func (m *Meter) LLM(providerName, modelName string, promptTokens, completionTokens int) model.Usage {
+	u := model.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+	if p := m.price(provider.TypeLLM, providerName, modelName); p != nil {
+		u.Cost = roundCost(float64(promptTokens)/1000*p.PromptPer1K + float64(completionTokens)/1000*p.CompletionPer1K)
+	}
+	return u
+}
+
+// TTS 计算一次语音合成的用量与费用
+func (m *Meter) TTS(providerName string, chars int) model.Usage {
+	u := model.Usage{TTSCharacters: chars}
+	if p := m.price(provider.TypeTTS, providerName, ""); p != nil {
+		u.Cost = roundCost(float64(chars) * p.PerChar)
+	}
+	return u
+}
+
+// ASR 计算一段语音识别的用量与费用
+func (m *Meter) ASR(providerName string, seconds float64) model.Usage {
+	u := model.Usage{ASRSeconds: seconds}
+	if p := m.price(provider.TypeASR, providerName, ""); p != nil {
+		u.Cost = roundCost(seconds * p.PerSecond)
+	}
+	return u
+}
+
+// price 匹配顺序：Provider+模型 > Provider > 模型 > 默认价格
+func (m *Meter) price(kind, providerName, modelName string) *Price {
+	var byProvider, byModel, fallback *Price
+	for i := range m.prices {
+		p := &m.prices[i]
+		if p.Kind != kind {
+			continue
+		}
+		switch {
+		case p.Provider == providerName && p.Model != "" && p.Model == modelName:
+			return p
+		case p.Provider == providerName && p.Model == "":
+			if byProvider == nil {
+				byProvider = p
+			}
+		case p.Provider == "" && p.Model != "" && p.Model == modelName:
+			if byModel == nil {
+				byModel = p
+			}
+		case p.Provider == "" && p.Model == "":
+			if fallback == nil {
+				fallback = p
+			}
+		}
+	}
+	switch {
+	case byProvider != nil:
+		return byProvider
+	case byModel != nil:
+		return byModel
+	}
+	return fallback
+}
+
+// Record 记录一轮对话的用量
+func (m *Meter) Record(tenant, conversationID string, u model.Usage) {
+	m.record(tenant, conversationID, u, 1)
+}
+
+// RecordOutsideTurn 记录不属于任何轮次的用量（如被拒绝的语音输入），不计入轮次数
+func (m *Meter) RecordOutsideTurn(tenant, conversationID string, u model.Usage) {
+	m.record(tenant, conversationID, u, 0)
+}
+
+func (m *Meter) record(tenant, conversationID string, u model.Usage, turns int) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if conversationID != "" {
+		add(m.totals(m.conversations, conversationID), u, turns, now)
+		if _, ok := m.owners[conversationID]; !ok {
+			m.owners[conversationID] = tenant
+		}
+	}
+	add(m.totals(m.tenantTotals, tenant), u, turns, now)
+}
+
+func (m *Meter) totals(index map[string]*Totals, key string) *Totals {
+	t, ok := index[key]
+	if !ok {
+		t = &Totals{}
+		index[key] = t
+	}
+	return t
+}
+
+// Conversation 会话的累计用量
+func (m *Meter) Conversation(id string) (Totals, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.conversations[id]
+	if !ok {
+		return Totals{}, false
+	}
+	return *t, true
+}
+
+// Owner 会话所属的租户
+func (m *Meter) Owner(conversationID string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tenant, ok := m.owners[conversationID]
+	return tenant, ok
+}
+
+// Tenant 租户的累计用量
+func (m *Meter) Tenant(name string) (Totals, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tenantTotals[name]
+	if !ok {
+		return Totals{}, false
+	}
+	return *t, true
+}
+
+// Tenants 有用量记录的租户，按名称排序
+func (m *Meter) Tenants() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.tenantTotals))
+	for name := range m.tenantTotals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Add 将 u 计入 total
+func Add(total *model.Usage, u model.Usage) {
+	total.PromptTokens += u.PromptTokens
+	total.CompletionTokens += u.CompletionTokens
+	total.TotalTokens += u.TotalTokens
+	total.TTSCharacters += u.TTSCharacters
+	total.ASRSeconds += u.ASRSeconds
+	total.Cost = roundCost(total.Cost + u.Cost)
+	total.Estimated = total.Estimated || u.Estimated
+}
+
+// roundCost 将费用舍入到百万分之一
+func roundCost(cost float64) float64 {
+	return math.Round(cost*costPrecision) / costPrecision
+}
+
+func add(t *Totals, u model.Usage, turns int, now time.Time) {
+	Add(&t.Usage, u)
+	t.Turns += turns
+	t.UpdatedAt = now
+}
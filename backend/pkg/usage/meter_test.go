@@ -0,0 +1,136 @@
+package usage_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/model"
+	"github.com/unclewu3242592726/CosTalk/backend/pkg/usage"
+)
+
+var prices = []usage.Price{
+	{Kind: "llm", PromptPer1K: 0.001, CompletionPer1K: 0.002}, // 默认价格
+	{Kind: "llm", Provider: "deepseek", PromptPer1K: 0.002, CompletionPer1K: 0.008},
+	{Kind: "llm", Provider: "deepseek", Model: "deepseek-reasoner", PromptPer1K: 0.004, CompletionPer1K: 0.016},
+	{Kind: "llm", Model: "qwen-max", PromptPer1K: 0.02, CompletionPer1K: 0.06},
+	{Kind: "tts", Provider: "qiniu", PerChar: 0.0002},
+	{Kind: "asr", PerSecond: 0.0001},
+}
+
+// almostEqual 比较浮点费用
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMeterLLMPricing(t *testing.T) {
+	m := usage.NewMeter("CNY", prices, nil)
+
+	cases := []struct {
+		name     string
+		provider string
+		model    string
+		cost     float64 // 1000 输入 token + 500 输出 token 的费用
+	}{
+		{"provider and model", "deepseek", "deepseek-reasoner", 0.004 + 0.008},
+		{"provider", "deepseek", "deepseek-chat", 0.002 + 0.004},
+		// 未配置 Provider 价格时按模型匹配，适用于故障转移链
+		{"model", "chain", "qwen-max", 0.02 + 0.03},
+		{"fallback", "qwen", "qwen-turbo", 0.001 + 0.001},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := m.LLM(tc.provider, tc.model, 1000, 500)
+			if u.PromptTokens != 1000 || u.CompletionTokens != 500 || u.TotalTokens != 1500 || !almostEqual(u.Cost, tc.cost) {
+				t.Errorf("usage = %+v, want cost %v", u, tc.cost)
+			}
+		})
+	}
+
+	// 没有默认价格时未匹配的模型不计费
+	m = usage.NewMeter("CNY", prices[1:], nil)
+	if u := m.LLM("qwen", "qwen-turbo", 1000, 500); u.Cost != 0 || u.TotalTokens != 1500 {
+		t.Errorf("unpriced usage = %+v", u)
+	}
+}
+
+func TestMeterSpeechPricing(t *testing.T) {
+	m := usage.NewMeter("CNY", prices, nil)
+
+	if u := m.TTS("qiniu", 100); u.TTSCharacters != 100 || !almostEqual(u.Cost, 0.02) {
+		t.Errorf("qiniu tts = %+v", u)
+	}
+	// TTS 没有默认价格
+	if u := m.TTS("iflytek", 100); u.TTSCharacters != 100 || u.Cost != 0 {
+		t.Errorf("iflytek tts = %+v", u)
+	}
+	if u := m.ASR("iflytek", 12.5); u.ASRSeconds != 12.5 || !almostEqual(u.Cost, 0.00125) {
+		t.Errorf("asr = %+v", u)
+	}
+}
+
+func TestMeterRecord(t *testing.T) {
+	m := usage.NewMeter("CNY", prices, map[string]string{"key-a": "tenant-a"})
+
+	if m.TenantOf("key-a") != "tenant-a" || m.TenantOf("key-b") != usage.AnonymousTenant || m.TenantOf("") != usage.AnonymousTenant {
+		t.Errorf("TenantOf mismatch")
+	}
+
+	m.Record("tenant-a", "c1", model.Usage{PromptTokens: 10, TotalTokens: 10, Cost: 0.1})
+	m.Record("tenant-a", "c1", model.Usage{TTSCharacters: 5, Cost: 0.2, Estimated: true})
+	m.Record("tenant-a", "c2", model.Usage{ASRSeconds: 1.5})
+	m.Record(usage.AnonymousTenant, "", model.Usage{PromptTokens: 1, TotalTokens: 1})
+
+	c1, ok := m.Conversation("c1")
+	if !ok || c1.Turns != 2 || c1.PromptTokens != 10 || c1.TTSCharacters != 5 || !c1.Estimated || !almostEqual(c1.Cost, 0.3) || c1.UpdatedAt.IsZero() {
+		t.Errorf("c1 = %+v", c1)
+	}
+	tenant, ok := m.Tenant("tenant-a")
+	if !ok || tenant.Turns != 3 || tenant.ASRSeconds != 1.5 || !almostEqual(tenant.Cost, 0.3) {
+		t.Errorf("tenant-a = %+v", tenant)
+	}
+	if _, ok := m.Conversation(""); ok {
+		t.Error("usage without conversation recorded as a conversation")
+	}
+	if got := m.Tenants(); !slices.Equal(got, []string{usage.AnonymousTenant, "tenant-a"}) {
+		t.Errorf("tenants = %v", got)
+	}
+}
+
+func TestMeterCostRounding(t *testing.T) {
+	m := usage.NewMeter("CNY", prices, nil)
+
+	// 单次费用舍入到百万分之一，可以直接比较
+	cases := []struct {
+		name string
+		u    model.Usage
+		cost float64
+	}{
+		{"llm", m.LLM("qwen", "qwen-turbo", 1, 0), 0.000001},
+		{"below precision", m.ASR("qiniu", 0.004), 0},
+		{"tts", m.TTS("qiniu", 3), 0.0006},
+		{"asr", m.ASR("qiniu", 1.0/3), 0.000033},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.u.Cost != tc.cost {
+				t.Errorf("cost = %v, want %v", tc.u.Cost, tc.cost)
+			}
+		})
+	}
+
+	// 累计时不积累浮点误差
+	var total model.Usage
+	usage.Add(&total, model.Usage{Cost: 0.1})
+	usage.Add(&total, model.Usage{Cost: 0.2})
+	if total.Cost != 0.3 {
+		t.Errorf("0.1 + 0.2 = %v, want 0.3", total.Cost)
+	}
+
+	for i := 0; i < 10; i++ {
+		m.Record("tenant-a", "c1", m.ASR("qiniu", 1.0/3))
+	}
+	if c1, _ := m.Conversation("c1"); c1.Cost != 0.00033 {
+		t.Errorf("conversation cost = %v, want 0.00033", c1.Cost)
+	}
+}